@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS payment_refunds (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    refund_id VARCHAR(64) NOT NULL,
+    payment_transaction_id BIGINT UNSIGNED NOT NULL,
+    amount DECIMAL(15, 2) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+    reason_code VARCHAR(32) NOT NULL,
+    reason_note VARCHAR(255) NULL,
+    refund_method VARCHAR(32) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    driver_reversal_amount DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    provider_reference_id VARCHAR(128) NULL,
+    requested_by VARCHAR(64) NOT NULL,
+    raw_payload TEXT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_payment_refunds_refund_id (refund_id),
+    KEY idx_payment_refunds_payment (payment_transaction_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS payment_webhook_inbox (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    dedupe_key VARCHAR(191) NOT NULL,
+    order_id VARCHAR(64) NOT NULL,
+    transaction_id VARCHAR(128) NULL,
+    transaction_status VARCHAR(32) NULL,
+    payload MEDIUMTEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'RECEIVED',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    next_attempt_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    processed_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_payment_webhook_inbox_dedupe (dedupe_key),
+    KEY idx_payment_webhook_inbox_due (status, next_attempt_at),
+    KEY idx_payment_webhook_inbox_order (order_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS payment_reviews (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    payment_transaction_id BIGINT UNSIGNED NOT NULL,
+    order_id VARCHAR(64) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'OPEN',
+    reviewed_by VARCHAR(64) NULL,
+    review_note VARCHAR(255) NULL,
+    provider_response TEXT NULL,
+    reviewed_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_payment_reviews_payment (payment_transaction_id),
+    KEY idx_payment_reviews_status (status, created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
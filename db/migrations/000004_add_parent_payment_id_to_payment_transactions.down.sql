@@ -0,0 +1,3 @@
+ALTER TABLE payment_transactions
+    DROP KEY idx_payment_transactions_parent,
+    DROP COLUMN parent_payment_id;
@@ -0,0 +1,3 @@
+ALTER TABLE payment_transactions
+    ADD COLUMN parent_payment_id BIGINT UNSIGNED NULL AFTER ride_order_id,
+    ADD KEY idx_payment_transactions_parent (parent_payment_id);
@@ -0,0 +1,17 @@
+ALTER TABLE payment_refunds
+    MODIFY amount DECIMAL(15, 2) NOT NULL,
+    MODIFY driver_reversal_amount DECIMAL(15, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE payment_settlements
+    MODIFY settlement_amount DECIMAL(15, 2) NOT NULL,
+    MODIFY platform_fee DECIMAL(15, 2) NOT NULL DEFAULT 0,
+    MODIFY tax_amount DECIMAL(15, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE payment_transactions
+    MODIFY amount DECIMAL(15, 2) NOT NULL;
+
+ALTER TABLE wallet_transactions
+    MODIFY amount DECIMAL(15, 2) NOT NULL;
+
+ALTER TABLE wallets
+    MODIFY balance DECIMAL(15, 2) NOT NULL DEFAULT 0;
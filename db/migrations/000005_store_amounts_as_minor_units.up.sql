@@ -0,0 +1,36 @@
+-- Amounts become integer minor units. Every existing row is in rupiah, which
+-- has no minor digits, so converting is rounding to whole rupiah. The service
+-- already writes whole numbers and reads both DECIMAL and BIGINT, so this can
+-- run after the deploy.
+UPDATE wallets SET balance = ROUND(balance) WHERE balance <> ROUND(balance);
+ALTER TABLE wallets
+    MODIFY balance BIGINT NOT NULL DEFAULT 0;
+
+UPDATE wallet_transactions SET amount = ROUND(amount) WHERE amount <> ROUND(amount);
+ALTER TABLE wallet_transactions
+    MODIFY amount BIGINT NOT NULL;
+
+UPDATE payment_transactions SET amount = ROUND(amount) WHERE amount <> ROUND(amount);
+ALTER TABLE payment_transactions
+    MODIFY amount BIGINT NOT NULL;
+
+UPDATE payment_settlements
+SET settlement_amount = ROUND(settlement_amount),
+    platform_fee = ROUND(platform_fee),
+    tax_amount = ROUND(tax_amount)
+WHERE settlement_amount <> ROUND(settlement_amount)
+   OR platform_fee <> ROUND(platform_fee)
+   OR tax_amount <> ROUND(tax_amount);
+ALTER TABLE payment_settlements
+    MODIFY settlement_amount BIGINT NOT NULL,
+    MODIFY platform_fee BIGINT NOT NULL DEFAULT 0,
+    MODIFY tax_amount BIGINT NOT NULL DEFAULT 0;
+
+UPDATE payment_refunds
+SET amount = ROUND(amount),
+    driver_reversal_amount = ROUND(driver_reversal_amount)
+WHERE amount <> ROUND(amount)
+   OR driver_reversal_amount <> ROUND(driver_reversal_amount);
+ALTER TABLE payment_refunds
+    MODIFY amount BIGINT NOT NULL,
+    MODIFY driver_reversal_amount BIGINT NOT NULL DEFAULT 0;
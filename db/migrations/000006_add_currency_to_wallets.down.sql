@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS payment_fx_conversions;
+
+ALTER TABLE wallets
+    DROP COLUMN currency;
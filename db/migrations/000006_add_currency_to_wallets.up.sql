@@ -0,0 +1,19 @@
+ALTER TABLE wallets
+    ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT 'IDR' AFTER balance;
+
+CREATE TABLE IF NOT EXISTS payment_fx_conversions (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    payment_transaction_id BIGINT UNSIGNED NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    wallet_id VARCHAR(64) NOT NULL,
+    wallet_currency VARCHAR(3) NOT NULL,
+    wallet_amount BIGINT NOT NULL,
+    payment_currency VARCHAR(3) NOT NULL,
+    payment_amount BIGINT NOT NULL,
+    rate_pair VARCHAR(7) NOT NULL,
+    rate VARCHAR(40) NOT NULL,
+    rate_source VARCHAR(32) NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_payment_fx_conversions_payment_purpose (payment_transaction_id, purpose)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,6 @@
+UPDATE wallets w
+JOIN driver_debts d ON d.driver_id = w.user_id
+SET w.balance = w.balance - d.outstanding;
+
+DROP TABLE IF EXISTS driver_debt_entries;
+DROP TABLE IF EXISTS driver_debts;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS driver_debts (
+    driver_id VARCHAR(64) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+    outstanding BIGINT NOT NULL DEFAULT 0,
+    blocked_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (driver_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS driver_debt_entries (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    driver_id VARCHAR(64) NOT NULL,
+    payment_transaction_id BIGINT UNSIGNED NULL,
+    entry_type VARCHAR(16) NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    amount BIGINT NOT NULL,
+    outstanding_after BIGINT NOT NULL,
+    description VARCHAR(255) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    KEY idx_driver_debt_entries_driver (driver_id, created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- wallets driven below zero by cash commissions become debt
+INSERT INTO driver_debts (driver_id, currency, outstanding)
+SELECT user_id, currency, -balance
+FROM wallets
+WHERE balance < 0;
+
+INSERT INTO driver_debt_entries (driver_id, entry_type, source, amount, outstanding_after, description)
+SELECT user_id, 'DEBT', 'NEGATIVE_BALANCE', -balance, -balance, 'Negative wallet balance carried over'
+FROM wallets
+WHERE balance < 0;
+
+UPDATE wallets
+SET balance = 0
+WHERE balance < 0;
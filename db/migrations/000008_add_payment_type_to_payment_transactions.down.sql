@@ -0,0 +1,5 @@
+ALTER TABLE payment_transactions
+    DROP KEY idx_payment_transactions_order_type,
+    DROP KEY uq_payment_transactions_charge_order_id,
+    DROP COLUMN charge_order_id,
+    DROP COLUMN payment_type;
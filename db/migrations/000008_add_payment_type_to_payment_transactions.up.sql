@@ -0,0 +1,5 @@
+ALTER TABLE payment_transactions
+    ADD COLUMN payment_type VARCHAR(16) NOT NULL DEFAULT 'TRIP' AFTER parent_payment_id,
+    ADD COLUMN charge_order_id VARCHAR(64) NULL AFTER payment_type,
+    ADD UNIQUE KEY uq_payment_transactions_charge_order_id (charge_order_id),
+    ADD KEY idx_payment_transactions_order_type (ride_order_id, payment_type);
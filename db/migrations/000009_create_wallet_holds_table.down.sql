@@ -0,0 +1,25 @@
+-- open holds go back to being debits: holds taken since the migration get a
+-- new debit, migrated ones get back the debit they superseded
+INSERT INTO wallet_transactions (wallet_id, transaction_id, amount, type, description, timestamp, created_at)
+SELECT h.wallet_id, CONCAT('NBJ_GEN_', h.hold_id), h.amount, 'debit', CONCAT('Hold for order ', o.order_id), h.created_at, h.created_at
+FROM wallet_holds h
+JOIN payment_transactions pt ON pt.id = h.payment_transaction_id
+JOIN orders o ON o.id = pt.ride_order_id
+WHERE h.status = 'HELD'
+  AND NOT EXISTS (
+      SELECT 1 FROM wallet_transactions wt WHERE wt.superseded_by_hold_id = h.hold_id
+  );
+
+UPDATE wallet_transactions wt
+JOIN wallet_holds h ON h.hold_id = wt.superseded_by_hold_id
+SET wt.superseded_by_hold_id = NULL
+WHERE h.status = 'HELD';
+
+ALTER TABLE wallet_transactions
+    DROP KEY idx_wallet_transactions_superseded,
+    DROP COLUMN superseded_by_hold_id;
+
+DROP TABLE IF EXISTS wallet_holds;
+
+ALTER TABLE wallets
+    DROP COLUMN held_balance;
@@ -0,0 +1,57 @@
+ALTER TABLE wallets
+    ADD COLUMN held_balance BIGINT NOT NULL DEFAULT 0 AFTER balance;
+
+CREATE TABLE IF NOT EXISTS wallet_holds (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    hold_id VARCHAR(64) NOT NULL,
+    wallet_id VARCHAR(64) NOT NULL,
+    payment_transaction_id BIGINT UNSIGNED NOT NULL,
+    amount BIGINT NOT NULL,
+    captured_amount BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL,
+    expires_at DATETIME(6) NULL,
+    captured_at DATETIME(6) NULL,
+    released_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_wallet_holds_hold_id (hold_id),
+    UNIQUE KEY uq_wallet_holds_payment (payment_transaction_id),
+    KEY idx_wallet_holds_wallet_status (wallet_id, status),
+    KEY idx_wallet_holds_status_expires (status, expires_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- open wallet holds were taken as plain debits; carry them over as holds
+INSERT INTO wallet_holds (hold_id, wallet_id, payment_transaction_id, amount, status, expires_at)
+SELECT CONCAT('NBJ_HLD_MIGRATED_', pt.id), w.id, pt.id, COALESCE(fx.wallet_amount, pt.amount), 'HELD', pt.expired_at
+FROM payment_transactions pt
+JOIN wallets w ON w.user_id = pt.passenger_id
+LEFT JOIN payment_fx_conversions fx ON fx.payment_transaction_id = pt.id AND fx.purpose = 'HOLD'
+WHERE pt.payment_status = 'PENDING'
+  AND pt.payment_type = 'TRIP'
+  AND pt.payment_method IN ('WALLET', 'EWALLET');
+
+UPDATE wallets w
+JOIN (
+    SELECT wallet_id, SUM(amount) AS held
+    FROM wallet_holds
+    WHERE status = 'HELD'
+    GROUP BY wallet_id
+) h ON h.wallet_id = w.id
+SET w.held_balance = h.held;
+
+-- the hold debits stay in the ledger but leave the history; the capture
+-- writes the one charge
+ALTER TABLE wallet_transactions
+    ADD COLUMN superseded_by_hold_id VARCHAR(64) NULL AFTER description,
+    ADD KEY idx_wallet_transactions_superseded (superseded_by_hold_id);
+
+UPDATE wallet_transactions wt
+JOIN wallet_holds h ON h.wallet_id = wt.wallet_id AND h.amount = wt.amount
+JOIN payment_transactions pt ON pt.id = h.payment_transaction_id
+JOIN orders o ON o.id = pt.ride_order_id
+SET wt.superseded_by_hold_id = h.hold_id
+WHERE h.status = 'HELD'
+  AND wt.type = 'debit'
+  AND wt.superseded_by_hold_id IS NULL
+  AND wt.description IN (CONCAT('Hold for order ', o.order_id), CONCAT('Split hold for order ', o.order_id));
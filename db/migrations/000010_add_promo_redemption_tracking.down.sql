@@ -0,0 +1,6 @@
+ALTER TABLE payment_transactions
+    DROP COLUMN discount_amount;
+
+DROP TABLE IF EXISTS payment_promo_redemptions;
+
+DROP TABLE IF EXISTS payment_promo_campaigns;
@@ -0,0 +1,74 @@
+-- the promo tables are shared with the order service; create them where they
+-- are missing with the columns both services already rely on
+CREATE TABLE IF NOT EXISTS promo_campaigns (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    promo_code VARCHAR(32) NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    discount_type VARCHAR(16) NOT NULL,
+    discount_value DECIMAL(15, 2) NOT NULL,
+    max_discount DECIMAL(15, 2) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_promo_campaigns_code (promo_code)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    promo_campaign_id BIGINT UNSIGNED NOT NULL,
+    ride_order_id VARCHAR(64) NOT NULL,
+    discount_applied DECIMAL(15, 2) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    KEY idx_promo_redemptions_order (ride_order_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- limits and usage of a campaign as this service enforces them; a campaign
+-- without a row has no limits and no usage yet
+CREATE TABLE IF NOT EXISTS payment_promo_campaigns (
+    promo_campaign_id BIGINT UNSIGNED NOT NULL,
+    per_user_limit INT NULL,
+    usage_limit INT NULL,
+    usage_count INT NOT NULL DEFAULT 0,
+    budget BIGINT NULL,
+    budget_used BIGINT NOT NULL DEFAULT 0,
+    starts_at DATETIME(6) NULL,
+    ends_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (promo_campaign_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- what became of a redemption the order service attached to an order
+CREATE TABLE IF NOT EXISTS payment_promo_redemptions (
+    promo_redemption_id BIGINT UNSIGNED NOT NULL,
+    promo_campaign_id BIGINT UNSIGNED NOT NULL,
+    ride_order_id VARCHAR(64) NOT NULL,
+    passenger_id VARCHAR(64) NULL,
+    payment_transaction_id BIGINT UNSIGNED NULL,
+    discount_amount BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING',
+    reversed_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (promo_redemption_id),
+    KEY idx_payment_promo_redemptions_payment (payment_transaction_id),
+    KEY idx_payment_promo_redemptions_campaign_passenger (promo_campaign_id, passenger_id, status)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+ALTER TABLE payment_transactions
+    ADD COLUMN discount_amount BIGINT NOT NULL DEFAULT 0 AFTER amount;
+
+-- redemptions on orders already paid count against their campaign
+INSERT INTO payment_promo_redemptions (promo_redemption_id, promo_campaign_id, ride_order_id, passenger_id, discount_amount, status)
+SELECT pr.id, pr.promo_campaign_id, pr.ride_order_id, o.passenger_id,
+       IF(o.payment_status = 'PAID', ROUND(COALESCE(pr.discount_applied, 0)), 0),
+       IF(o.payment_status = 'PAID', 'APPLIED', 'PENDING')
+FROM promo_redemptions pr
+JOIN orders o ON o.order_id = pr.ride_order_id;
+
+INSERT INTO payment_promo_campaigns (promo_campaign_id, usage_count, budget_used)
+SELECT promo_campaign_id, COUNT(*), SUM(discount_amount)
+FROM payment_promo_redemptions
+WHERE status = 'APPLIED'
+GROUP BY promo_campaign_id;
@@ -0,0 +1,9 @@
+ALTER TABLE payment_settlements
+    DROP COLUMN commission_rate,
+    DROP COLUMN commission_rule_version,
+    DROP COLUMN commission_rule_code,
+    DROP COLUMN commission_rule_id;
+
+DROP TABLE IF EXISTS payment_order_service_tiers;
+
+DROP TABLE IF EXISTS commission_rules;
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS commission_rules (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    rule_code VARCHAR(64) NOT NULL,
+    version INT NOT NULL,
+    city VARCHAR(64) NULL,
+    vehicle_type VARCHAR(32) NULL,
+    service_tier VARCHAR(32) NULL,
+    window_start TIME NULL,
+    window_end TIME NULL,
+    fee_rate DECIMAL(6, 4) NOT NULL,
+    priority INT NOT NULL DEFAULT 0,
+    effective_from DATETIME(6) NOT NULL,
+    effective_to DATETIME(6) NULL,
+    description VARCHAR(255) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_commission_rules_code_version (rule_code, version),
+    KEY idx_commission_rules_effective (effective_from, effective_to)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- the service tier an order event carried; trips without one match rules for
+-- any tier
+CREATE TABLE IF NOT EXISTS payment_order_service_tiers (
+    order_id VARCHAR(64) NOT NULL,
+    service_tier VARCHAR(32) NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (order_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+ALTER TABLE payment_settlements
+    ADD COLUMN commission_rule_id BIGINT UNSIGNED NULL,
+    ADD COLUMN commission_rule_code VARCHAR(64) NULL,
+    ADD COLUMN commission_rule_version INT NULL,
+    ADD COLUMN commission_rate DECIMAL(6, 4) NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE payment_settlements
+    DROP KEY idx_payment_settlements_driver_created,
+    DROP COLUMN tax_breakdown;
+
+DROP TABLE IF EXISTS tax_rates;
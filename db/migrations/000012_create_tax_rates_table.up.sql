@@ -0,0 +1,22 @@
+-- base_ratio is the share of the amount taxed (DPP); rate applies to it
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    tax_type VARCHAR(16) NOT NULL,
+    rate DECIMAL(6, 4) NOT NULL,
+    base_ratio DECIMAL(8, 6) NOT NULL DEFAULT 1,
+    effective_from DATETIME(6) NOT NULL,
+    effective_to DATETIME(6) NULL,
+    description VARCHAR(255) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    KEY idx_tax_rates_type_effective (tax_type, effective_from, effective_to)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+INSERT INTO tax_rates (tax_type, rate, base_ratio, effective_from, effective_to, description) VALUES
+    ('PPN', 0.1100, 1.000000, '2022-04-01 00:00:00', '2025-01-01 00:00:00', 'PPN 11% on the platform service fee (UU HPP)'),
+    ('PPN', 0.1200, 0.916667, '2025-01-01 00:00:00', NULL, 'PPN 12% on a DPP of 11/12 of the platform service fee (PMK 131/2024)'),
+    ('PPH', 0.0500, 0.500000, '2024-01-01 00:00:00', NULL, 'PPh 21 withheld on driver income as a non-employee, 5% on 50% DPP');
+
+ALTER TABLE payment_settlements
+    ADD COLUMN tax_breakdown JSON NULL,
+    ADD KEY idx_payment_settlements_driver_created (driver_id, created_at);
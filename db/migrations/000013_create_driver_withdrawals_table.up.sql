@@ -0,0 +1,31 @@
+-- amount is taken from the wallet; the bank receives amount - fee. While a
+-- withdrawal is open its amount sits in wallets.held_balance.
+CREATE TABLE IF NOT EXISTS driver_withdrawals (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    withdrawal_id VARCHAR(64) NOT NULL,
+    driver_id VARCHAR(64) NOT NULL,
+    wallet_id VARCHAR(64) NOT NULL,
+    amount BIGINT NOT NULL,
+    fee BIGINT NOT NULL DEFAULT 0,
+    net_amount BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    bank_code VARCHAR(32) NOT NULL,
+    account_number VARCHAR(64) NOT NULL,
+    account_name VARCHAR(128) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    provider VARCHAR(32) NULL,
+    provider_reference VARCHAR(128) NULL,
+    failure_reason VARCHAR(255) NULL,
+    reviewed_by VARCHAR(64) NULL,
+    review_note VARCHAR(255) NULL,
+    reviewed_at DATETIME(6) NULL,
+    completed_at DATETIME(6) NULL,
+    failed_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_driver_withdrawals_withdrawal_id (withdrawal_id),
+    UNIQUE KEY uq_driver_withdrawals_provider_reference (provider, provider_reference),
+    KEY idx_driver_withdrawals_driver_created (driver_id, created_at),
+    KEY idx_driver_withdrawals_status (status, created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
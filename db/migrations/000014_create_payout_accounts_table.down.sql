@@ -0,0 +1,4 @@
+ALTER TABLE driver_withdrawals
+    DROP COLUMN payout_account_id;
+
+DROP TABLE IF EXISTS payout_accounts;
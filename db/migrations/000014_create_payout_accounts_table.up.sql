@@ -0,0 +1,32 @@
+-- account numbers and names are AES-GCM encrypted by the service;
+-- account_number_hash is a keyed digest to find duplicates by
+CREATE TABLE IF NOT EXISTS payout_accounts (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    account_id VARCHAR(64) NOT NULL,
+    driver_id VARCHAR(64) NOT NULL,
+    bank_code VARCHAR(32) NOT NULL,
+    account_number_encrypted VARCHAR(255) NOT NULL,
+    account_number_hash CHAR(64) NOT NULL,
+    account_number_last4 VARCHAR(4) NOT NULL,
+    holder_name_encrypted VARCHAR(512) NOT NULL,
+    inquiry_name_encrypted VARCHAR(512) NULL,
+    name_match_score DECIMAL(5, 4) NULL,
+    status VARCHAR(16) NOT NULL,
+    rejection_reason VARCHAR(255) NULL,
+    available_after DATETIME(6) NULL,
+    verified_at DATETIME(6) NULL,
+    removed_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_payout_accounts_account_id (account_id),
+    KEY idx_payout_accounts_driver_status (driver_id, status),
+    KEY idx_payout_accounts_number_hash (bank_code, account_number_hash)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+ALTER TABLE driver_withdrawals
+    ADD COLUMN payout_account_id BIGINT UNSIGNED NULL AFTER wallet_id;
+
+-- withdrawals from a payout account keep only the last digits; the full
+-- number stays encrypted on the account. Rows made before the registry are
+-- left as they are and masked by the service when read.
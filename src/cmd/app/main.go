@@ -24,6 +24,79 @@ func main() {
 	viperConfig.SetDefault("log.level", "DEBUG")
 	viperConfig.SetDefault("app.name", "NOTIFICATION_SERVICE")
 	viperConfig.SetDefault("web.port", 8080)
+	viperConfig.SetDefault("payment.provider.default", "MIDTRANS_SNAP")
+	viperConfig.SetDefault("payment.provider.qris_core", "MIDTRANS_CORE")
+	viperConfig.SetDefault("midtrans.qris.expiry_minutes", 15)
+	viperConfig.SetDefault("payment.idempotency.ttl_hours", 24)
+	viperConfig.SetDefault("midtrans.snap.expiry_minutes", 60)
+	viperConfig.SetDefault("payment.status.requery_after_seconds", 60)
+	viperConfig.SetDefault("payment.charge.pending_seconds", 300)
+	viperConfig.SetDefault("wallet.hold.expiry_hours", 24)
+	viperConfig.SetDefault("payment.split.enabled", true)
+	viperConfig.SetDefault("payment.currency", "IDR")
+	viperConfig.SetDefault("payment.qris.currencies", []string{"IDR"})
+	viperConfig.SetDefault("payment.va.currencies", []string{"IDR"})
+	viperConfig.SetDefault("midtrans.va.expiry_minutes", 1440)
+	viperConfig.SetDefault("payment.methods", map[string]interface{}{
+		"QRIS": map[string]interface{}{
+			"display_name": "QRIS",
+			"enabled":      true,
+		},
+		"EWALLET": map[string]interface{}{
+			"display_name": "Wallet",
+			"provider":     "WALLET",
+			"enabled":      true,
+			"aliases":      []string{"WALLET"},
+		},
+		"CASH": map[string]interface{}{
+			"display_name": "Cash",
+			"provider":     "CASH",
+			"enabled":      true,
+		},
+		"VA_BCA":     vaMethodDefault("BCA Virtual Account"),
+		"VA_BNI":     vaMethodDefault("BNI Virtual Account"),
+		"VA_BRI":     vaMethodDefault("BRI Virtual Account"),
+		"VA_MANDIRI": vaMethodDefault("Mandiri Bill Payment"),
+		"VA_PERMATA": vaMethodDefault("Permata Virtual Account"),
+	})
+	viperConfig.SetDefault("driver.debt.credit_limit", 50000)
+	viperConfig.SetDefault("tip.window_minutes", 1440)
+	viperConfig.SetDefault("tip.min_amount", 1000)
+	viperConfig.SetDefault("tip.max_amount", 500000)
+	viperConfig.SetDefault("cancellation.fee", 5000)
+	viperConfig.SetDefault("cancellation.free_minutes", 5)
+	viperConfig.SetDefault("cancellation.driver_share", 0.5)
+	viperConfig.SetDefault("platform.fee_rounding", "HALF_UP")
+	viperConfig.SetDefault("commission.timezone", "Asia/Jakarta")
+	viperConfig.SetDefault("tax.timezone", "Asia/Jakarta")
+	viperConfig.SetDefault("withdrawal.min_amount", 50000)
+	viperConfig.SetDefault("withdrawal.fee", 2500)
+	viperConfig.SetDefault("withdrawal.daily_limit", 10000000)
+	viperConfig.SetDefault("withdrawal.daily_count", 3)
+	viperConfig.SetDefault("withdrawal.auto_approve_max", 0)
+	viperConfig.SetDefault("withdrawal.timezone", "Asia/Jakarta")
+	viperConfig.SetDefault("withdrawal.reconcile_after_seconds", 600)
+	viperConfig.SetDefault("payment.refund.retry_after_seconds", 300)
+	viperConfig.SetDefault("payout.provider", "MIDTRANS_IRIS")
+	viperConfig.SetDefault("payout_account.name_match_threshold", 0.8)
+	viperConfig.SetDefault("payout_account.cooling_off_hours", 24)
+	viperConfig.SetDefault("platform.tax_rounding", "HALF_UP")
+	viperConfig.SetDefault("scheduler.payment_expiry.enabled", true)
+	viperConfig.SetDefault("scheduler.payment_expiry.interval_seconds", 60)
+	viperConfig.SetDefault("scheduler.payment_expiry.batch_size", 100)
+	viperConfig.SetDefault("scheduler.webhook_inbox.enabled", true)
+	viperConfig.SetDefault("scheduler.webhook_inbox.interval_seconds", 5)
+	viperConfig.SetDefault("scheduler.payout_reconcile.enabled", true)
+	viperConfig.SetDefault("scheduler.payout_reconcile.interval_seconds", 300)
+	viperConfig.SetDefault("scheduler.payout_reconcile.batch_size", 100)
+	viperConfig.SetDefault("scheduler.refund_retry.enabled", true)
+	viperConfig.SetDefault("scheduler.refund_retry.interval_seconds", 300)
+	viperConfig.SetDefault("scheduler.refund_retry.batch_size", 100)
+	viperConfig.SetDefault("webhook.inbox.batch_size", 100)
+	viperConfig.SetDefault("webhook.inbox.max_attempts", 8)
+	viperConfig.SetDefault("webhook.inbox.retry_base_seconds", 30)
+	viperConfig.SetDefault("kafka.topic.payment_alert", "payment-alert")
+	viperConfig.SetDefault("kafka.topic.order_cancelled", "order-cancelled")
 
 	log.InitLogger(viperConfig)
 	logger := log.GetLogger()
@@ -61,6 +134,15 @@ func main() {
 		Producer: producer,
 	})
 
+	config.BootstrapScheduler(&config.SchedulerBootstrapConfig{
+		Ctx:      ctx,
+		DB:       db,
+		Log:      logger,
+		Config:   viperConfig,
+		Redis:    redisClient,
+		Producer: producer,
+	})
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
@@ -86,3 +168,13 @@ func main() {
 
 	logger.Info("main", fmt.Sprintf("Server %s stopped", viperConfig.GetString("app.name")), "gracefull", "")
 }
+
+// vaMethodDefault is the catalog default for a virtual account, charged through
+// the Midtrans Core API.
+func vaMethodDefault(displayName string) map[string]interface{} {
+	return map[string]interface{}{
+		"display_name": displayName,
+		"provider":     "MIDTRANS_CORE",
+		"enabled":      true,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"payment-service/src/internal/delivery/http"
 	"payment-service/src/internal/delivery/http/middleware"
 	"payment-service/src/internal/delivery/http/route"
+	"payment-service/src/internal/gateway/messaging"
 
 	"payment-service/src/internal/repository"
 	"payment-service/src/internal/usecase"
@@ -34,6 +35,26 @@ func Bootstrap(config *BootstrapConfig) {
 	orderRepository := repository.NewOrderRepository(config.DB)
 	walletRepository := repository.NewWalletRepository(config.DB)
 	paymentRepository := repository.NewPaymentRepository(config.DB)
+	idempotencyRepository := repository.NewIdempotencyRepository(config.Redis)
+	paymentReviewRepository := repository.NewPaymentReviewRepository(config.DB)
+	refundRepository := repository.NewRefundRepository(config.DB)
+	webhookInboxRepository := repository.NewWebhookInboxRepository(config.DB)
+	driverRepository := repository.NewDriverRepository(config.DB)
+	driverDebtRepository := repository.NewDriverDebtRepository(config.DB)
+	commissionRuleRepository := repository.NewCommissionRuleRepository(config.DB)
+	taxRepository := repository.NewTaxRepository(config.DB)
+	withdrawalRepository := repository.NewWithdrawalRepository(config.DB)
+	payoutAccountRepository := repository.NewPayoutAccountRepository(config.DB)
+
+	// setup gateways
+	paymentProviders := NewPaymentProviders(config.Config)
+	paymentMethods := NewPaymentMethods(config.Config)
+	payoutProvider := NewPayoutProvider(config.Config)
+	payoutAccountCipher, err := NewPayoutAccountCipher(config.Config)
+	if err != nil {
+		config.Log.Error("bootstrap", "payout accounts and withdrawals are disabled", "Bootstrap", err.Error())
+	}
+	paymentAlertProducer := messaging.NewPaymentAlertProducer(config.Producer, config.Config.GetString("kafka.topic.payment_alert"), config.Log)
 
 	// setup use cases
 	walletUseCase := usecase.NewWalletUseCase(
@@ -43,8 +64,28 @@ func Bootstrap(config *BootstrapConfig) {
 		orderRepository,
 		walletRepository,
 		paymentRepository,
+		driverRepository,
+		driverDebtRepository,
+		commissionRuleRepository,
+		taxRepository,
 		config.DB,
 		config.Redis,
+		NewFxRateSource(config.Config),
+		paymentMethods,
+		paymentProviders,
+	)
+
+	refundUseCase := usecase.NewRefundUseCase(
+		config.Log,
+		config.Config,
+		orderRepository,
+		walletRepository,
+		paymentRepository,
+		refundRepository,
+		driverRepository,
+		driverDebtRepository,
+		config.DB,
+		paymentProviders,
 	)
 
 	paymentUseCase := usecase.NewPaymentUseCase(
@@ -53,22 +94,92 @@ func Bootstrap(config *BootstrapConfig) {
 		userRepository,
 		paymentRepository,
 		orderRepository,
+		idempotencyRepository,
+		paymentReviewRepository,
+		walletRepository,
+		driverRepository,
+		driverDebtRepository,
 		config.DB,
 		config.Redis,
+		paymentProviders,
+		paymentMethods,
+		NewFxRateSource(config.Config),
+		paymentAlertProducer,
+		refundUseCase,
+	)
+
+	webhookInboxUseCase := usecase.NewWebhookInboxUseCase(
+		config.Log,
+		config.Config,
+		webhookInboxRepository,
+		paymentUseCase,
+		config.DB,
+	)
+
+	paymentReviewUseCase := usecase.NewPaymentReviewUseCase(
+		config.Log,
+		config.Config,
+		paymentReviewRepository,
+		paymentUseCase,
+		config.DB,
+	)
+
+	taxUseCase := usecase.NewTaxUseCase(
+		config.Log,
+		config.Config,
+		taxRepository,
+	)
+
+	withdrawalUseCase := usecase.NewWithdrawalUseCase(
+		config.Log,
+		config.Config,
+		walletRepository,
+		withdrawalRepository,
+		driverRepository,
+		payoutAccountRepository,
+		config.DB,
+		payoutProvider,
+		payoutAccountCipher,
+	)
+
+	payoutAccountUseCase := usecase.NewPayoutAccountUseCase(
+		config.Log,
+		config.Config,
+		payoutAccountRepository,
+		userRepository,
+		config.DB,
+		payoutProvider,
+		payoutAccountCipher,
 	)
 
 	// setup controller
 	walletController := http.NewWalletController(walletUseCase, config.Log)
 	paymentController := http.NewPaymentController(paymentUseCase, config.Log)
+	refundController := http.NewRefundController(refundUseCase, config.Log)
+	webhookController := http.NewWebhookController(webhookInboxUseCase, config.Log)
+	reviewController := http.NewPaymentReviewController(paymentReviewUseCase, config.Log)
+	taxController := http.NewTaxController(taxUseCase, config.Log)
+	withdrawalController := http.NewWithdrawalController(withdrawalUseCase, config.Log)
+	payoutAccountController := http.NewPayoutAccountController(payoutAccountUseCase, config.Log)
 
 	// setup middleware
 	authMiddleware := middleware.VerifyBearer(config.Config)
+	adminMiddleware := middleware.VerifyAdminKey(config.Config)
+	payoutMiddleware := middleware.RequirePayouts(payoutAccountCipher != nil)
 
 	routeConfig := route.RouteConfig{
-		App:               config.App,
-		WalletController:  walletController,
-		PaymentController: paymentController,
-		AuthMiddleware:    authMiddleware,
+		App:                     config.App,
+		WalletController:        walletController,
+		PaymentController:       paymentController,
+		RefundController:        refundController,
+		WebhookController:       webhookController,
+		ReviewController:        reviewController,
+		TaxController:           taxController,
+		WithdrawalController:    withdrawalController,
+		PayoutAccountController: payoutAccountController,
+		AuthMiddleware:          authMiddleware,
+		AdminMiddleware:         adminMiddleware,
+		PayoutMiddleware:        payoutMiddleware,
 	}
 	routeConfig.Setup()
 }
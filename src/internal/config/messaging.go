@@ -28,6 +28,10 @@ func BootstrapMessaging(cfg *MessagingBootstrapConfig) {
 	orderRepository := repository.NewOrderRepository(cfg.DB)
 	walletRepository := repository.NewWalletRepository(cfg.DB)
 	paymentRepository := repository.NewPaymentRepository(cfg.DB)
+	driverRepository := repository.NewDriverRepository(cfg.DB)
+	driverDebtRepository := repository.NewDriverDebtRepository(cfg.DB)
+	commissionRuleRepository := repository.NewCommissionRuleRepository(cfg.DB)
+	taxRepository := repository.NewTaxRepository(cfg.DB)
 
 	walletUseCase := usecase.NewWalletUseCase(
 		cfg.Log,
@@ -36,8 +40,15 @@ func BootstrapMessaging(cfg *MessagingBootstrapConfig) {
 		orderRepository,
 		walletRepository,
 		paymentRepository,
+		driverRepository,
+		driverDebtRepository,
+		commissionRuleRepository,
+		taxRepository,
 		cfg.DB,
 		cfg.Redis,
+		NewFxRateSource(cfg.Config),
+		NewPaymentMethods(cfg.Config),
+		NewPaymentProviders(cfg.Config),
 	)
 
 	orderHandler := messaging.NewOrderConsumerHandler(
@@ -45,7 +56,12 @@ func BootstrapMessaging(cfg *MessagingBootstrapConfig) {
 		walletUseCase,
 	)
 
-	walletHandler := messaging.NewOrderConsumerHandler(
+	cancellationHandler := messaging.NewCancellationConsumerHandler(
+		cfg.Log,
+		walletUseCase,
+	)
+
+	walletHandler := messaging.NewWalletConsumerHandler(
 		cfg.Log,
 		walletUseCase,
 	)
@@ -55,8 +71,9 @@ func BootstrapMessaging(cfg *MessagingBootstrapConfig) {
 		Consumer: cfg.Consumer,
 		Logger:   cfg.Log,
 		Handlers: map[string]kafkaPkgConfluent.ConsumerHandler{
-			cfg.Config.GetString("kafka.topic.payment"): walletHandler,
-			cfg.Config.GetString("kafka.topic.order"):   orderHandler,
+			cfg.Config.GetString("kafka.topic.payment"):         walletHandler,
+			cfg.Config.GetString("kafka.topic.order"):           orderHandler,
+			cfg.Config.GetString("kafka.topic.order_cancelled"): cancellationHandler,
 		},
 	}
 
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"payment-service/src/internal/gateway/fx"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/gateway/payout"
+	"payment-service/src/pkg/utils"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+func NewPaymentProviders(viper *viper.Viper) *paymentGateway.Registry {
+	registry := paymentGateway.NewRegistry(
+		viper,
+		paymentGateway.NewMidtransSnapProvider(viper),
+		paymentGateway.NewMidtransCoreProvider(viper),
+	)
+	if viper.GetBool("payment.fake.enabled") {
+		registry.Register(paymentGateway.NewFakeProvider(viper.GetString("payment.fake.secret")))
+	}
+	return registry
+}
+
+// NewPayoutProvider picks the provider withdrawals are sent through,
+// payout.provider, Midtrans Iris unless the fake is asked for.
+func NewPayoutProvider(viper *viper.Viper) payout.PayoutProvider {
+	if strings.EqualFold(viper.GetString("payout.provider"), payout.ProviderFake) {
+		return payout.NewFakeProvider(viper.GetString("payout.fake.secret"))
+	}
+	return payout.NewIrisProvider(viper)
+}
+
+// NewPayoutAccountCipher builds the cipher payout account numbers and names
+// are stored with. Without a valid payout_account.encryption_key they could
+// not be read back, so the caller turns payouts off rather than stopping the
+// service.
+func NewPayoutAccountCipher(viper *viper.Viper) (*utils.FieldCipher, error) {
+	cipher, err := utils.NewFieldCipher(viper.GetString("payout_account.encryption_key"))
+	if err != nil {
+		return nil, fmt.Errorf("payout_account.encryption_key: %w", err)
+	}
+	return cipher, nil
+}
+
+// NewPaymentMethods loads the payment method catalog. Like the rate table, a
+// malformed catalog stops startup.
+func NewPaymentMethods(viper *viper.Viper) *paymentGateway.MethodCatalog {
+	methods, err := paymentGateway.NewMethodCatalog(viper)
+	if err != nil {
+		panic(err)
+	}
+	return methods
+}
+
+// NewFxRateSource builds the rates cross-currency wallet payments are priced
+// with. A malformed rate table stops startup rather than blocking payments
+// later.
+func NewFxRateSource(viper *viper.Viper) fx.RateSource {
+	rates, err := fx.NewStaticRates(viper)
+	if err != nil {
+		panic(err)
+	}
+	return rates
+}
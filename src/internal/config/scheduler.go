@@ -0,0 +1,138 @@
+package config
+
+import (
+	"context"
+	"payment-service/src/internal/delivery/scheduler"
+	"payment-service/src/internal/gateway/messaging"
+	"payment-service/src/internal/repository"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/databases/mysql"
+	kafkaPkgConfluent "payment-service/src/pkg/kafka/confluent"
+	"payment-service/src/pkg/log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+type SchedulerBootstrapConfig struct {
+	Ctx      context.Context
+	DB       mysql.DBInterface
+	Log      log.Log
+	Config   *viper.Viper
+	Redis    redis.UniversalClient
+	Producer kafkaPkgConfluent.Producer
+}
+
+func BootstrapScheduler(cfg *SchedulerBootstrapConfig) {
+	orderRepository := repository.NewOrderRepository(cfg.DB)
+	walletRepository := repository.NewWalletRepository(cfg.DB)
+	paymentRepository := repository.NewPaymentRepository(cfg.DB)
+	userRepository := repository.NewUserRepository(cfg.DB)
+	idempotencyRepository := repository.NewIdempotencyRepository(cfg.Redis)
+	paymentReviewRepository := repository.NewPaymentReviewRepository(cfg.DB)
+	webhookInboxRepository := repository.NewWebhookInboxRepository(cfg.DB)
+	driverRepository := repository.NewDriverRepository(cfg.DB)
+	withdrawalRepository := repository.NewWithdrawalRepository(cfg.DB)
+	payoutAccountRepository := repository.NewPayoutAccountRepository(cfg.DB)
+	refundRepository := repository.NewRefundRepository(cfg.DB)
+	driverDebtRepository := repository.NewDriverDebtRepository(cfg.DB)
+
+	paymentProviders := NewPaymentProviders(cfg.Config)
+	paymentAlertProducer := messaging.NewPaymentAlertProducer(cfg.Producer, cfg.Config.GetString("kafka.topic.payment_alert"), cfg.Log)
+
+	paymentExpiryUseCase := usecase.NewPaymentExpiryUseCase(
+		cfg.Log,
+		cfg.Config,
+		orderRepository,
+		walletRepository,
+		paymentRepository,
+		driverRepository,
+		driverDebtRepository,
+		cfg.DB,
+		paymentProviders,
+		NewFxRateSource(cfg.Config),
+	)
+
+	refundUseCase := usecase.NewRefundUseCase(
+		cfg.Log,
+		cfg.Config,
+		orderRepository,
+		walletRepository,
+		paymentRepository,
+		refundRepository,
+		driverRepository,
+		driverDebtRepository,
+		cfg.DB,
+		paymentProviders,
+	)
+
+	paymentUseCase := usecase.NewPaymentUseCase(
+		cfg.Log,
+		cfg.Config,
+		userRepository,
+		paymentRepository,
+		orderRepository,
+		idempotencyRepository,
+		paymentReviewRepository,
+		walletRepository,
+		driverRepository,
+		driverDebtRepository,
+		cfg.DB,
+		cfg.Redis,
+		paymentProviders,
+		NewPaymentMethods(cfg.Config),
+		NewFxRateSource(cfg.Config),
+		paymentAlertProducer,
+		refundUseCase,
+	)
+
+	webhookInboxUseCase := usecase.NewWebhookInboxUseCase(
+		cfg.Log,
+		cfg.Config,
+		webhookInboxRepository,
+		paymentUseCase,
+		cfg.DB,
+	)
+
+	// reconciling payouts never reads account numbers, so it also runs
+	// without payout_account.encryption_key
+	payoutAccountCipher, _ := NewPayoutAccountCipher(cfg.Config)
+
+	withdrawalUseCase := usecase.NewWithdrawalUseCase(
+		cfg.Log,
+		cfg.Config,
+		walletRepository,
+		withdrawalRepository,
+		driverRepository,
+		payoutAccountRepository,
+		cfg.DB,
+		NewPayoutProvider(cfg.Config),
+		payoutAccountCipher,
+	)
+
+	jobs := scheduler.Scheduler{
+		Ctx:    cfg.Ctx,
+		Logger: cfg.Log,
+	}
+
+	if cfg.Config.GetBool("scheduler.payment_expiry.enabled") {
+		interval := time.Duration(cfg.Config.GetInt("scheduler.payment_expiry.interval_seconds")) * time.Second
+		jobs.Every(interval, scheduler.NewExpirySweeper(cfg.Log, paymentExpiryUseCase, interval))
+	}
+
+	if cfg.Config.GetBool("scheduler.webhook_inbox.enabled") {
+		interval := time.Duration(cfg.Config.GetInt("scheduler.webhook_inbox.interval_seconds")) * time.Second
+		jobs.Every(interval, scheduler.NewWebhookInboxWorker(cfg.Log, webhookInboxUseCase, interval))
+	}
+
+	if cfg.Config.GetBool("scheduler.payout_reconcile.enabled") {
+		interval := time.Duration(cfg.Config.GetInt("scheduler.payout_reconcile.interval_seconds")) * time.Second
+		jobs.Every(interval, scheduler.NewPayoutReconciler(cfg.Log, withdrawalUseCase, interval))
+	}
+
+	if cfg.Config.GetBool("scheduler.refund_retry.enabled") {
+		interval := time.Duration(cfg.Config.GetInt("scheduler.refund_retry.interval_seconds")) * time.Second
+		jobs.Every(interval, scheduler.NewRefundRetrier(cfg.Log, refundUseCase, interval))
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"payment-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+// VerifyAdminKey guards the operator endpoints with the shared key in
+// admin.api_key, sent as X-Admin-Key. Without a configured key every request
+// is refused.
+func VerifyAdminKey(viper *viper.Viper) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		expected := viper.GetString("admin.api_key")
+		key := c.Get("X-Admin-Key", "")
+		if expected == "" || subtle.ConstantTimeCompare([]byte(key), []byte(expected)) != 1 {
+			return utils.Response(nil, "Invalid admin key!", http.StatusUnauthorized, c)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"payment-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePayouts guards the payout account and withdrawal endpoints. When
+// payouts are not configured, e.g. payout_account.encryption_key is missing,
+// those endpoints are refused while the rest of the service keeps running.
+func RequirePayouts(available bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !available {
+			return utils.Response(nil, "Payouts are not available!", http.StatusServiceUnavailable, c)
+		}
+		return c.Next()
+	}
+}
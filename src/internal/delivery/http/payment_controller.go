@@ -25,11 +25,12 @@ func NewPaymentController(useCase *usecase.PaymentUseCase, logger log.Log) *Paym
 func (c *PaymentController) GeneratePayment(ctx *fiber.Ctx) error {
 	auth := middleware.GetUser(ctx)
 	request := new(model.CreateQrisPaymentRequest)
-	request.UserID = auth.UserID
 	if err := ctx.BodyParser(request); err != nil {
 		c.Log.Error("WalletController.TopUpWallet", "Failed to parse request body", "error", err.Error())
 		return utils.ResponseError(err, ctx)
 	}
+	request.UserID = auth.UserID
+	request.IdempotencyKey = ctx.Get("Idempotency-Key")
 	result := c.UseCase.GenerateQrisPayment(ctx.Context(), request)
 	if result.Error != nil {
 		return utils.ResponseError(result.Error, ctx)
@@ -38,16 +39,64 @@ func (c *PaymentController) GeneratePayment(ctx *fiber.Ctx) error {
 	return utils.Response(result.Data, "Top Up Wallet", fiber.StatusOK, ctx)
 }
 
-func (c *PaymentController) CallbackPayment(ctx *fiber.Ctx) error {
-	request := new(model.MidtransNotification)
+func (c *PaymentController) GenerateVaPayment(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.CreateVaPaymentRequest)
 	if err := ctx.BodyParser(request); err != nil {
-		c.Log.Error("WalletController.TopUpWallet", "Failed to parse request body", "error", err.Error())
+		c.Log.Error("PaymentController.GenerateVaPayment", "Failed to parse request body", "error", err.Error())
 		return utils.ResponseError(err, ctx)
 	}
-	result := c.UseCase.CallbackPayment(ctx.Context(), request)
+	request.UserID = auth.UserID
+	request.IdempotencyKey = ctx.Get("Idempotency-Key")
+	result := c.UseCase.GenerateVaPayment(ctx.Context(), request)
 	if result.Error != nil {
 		return utils.ResponseError(result.Error, ctx)
 	}
 
-	return utils.Response(result.Data, "Top Up Wallet", fiber.StatusOK, ctx)
+	return utils.Response(result.Data, "Virtual Account Payment", fiber.StatusOK, ctx)
+}
+
+func (c *PaymentController) TipDriver(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.TipRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("PaymentController.TipDriver", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.UserID = auth.UserID
+	request.IdempotencyKey = ctx.Get("Idempotency-Key")
+	result := c.UseCase.TipDriver(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Tip Driver", fiber.StatusOK, ctx)
+}
+
+func (c *PaymentController) GetPaymentStatus(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := &model.GetPaymentStatusRequest{
+		OrderID: ctx.Params("orderId"),
+		UserID:  auth.UserID,
+	}
+	result := c.UseCase.GetPaymentStatus(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Payment Status", fiber.StatusOK, ctx)
+}
+
+func (c *PaymentController) ListPaymentMethods(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := &model.ListPaymentMethodsRequest{
+		OrderID: ctx.Query("orderId"),
+		UserID:  auth.UserID,
+	}
+	result := c.UseCase.ListPaymentMethods(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Payment Methods", fiber.StatusOK, ctx)
 }
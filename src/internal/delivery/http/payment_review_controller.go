@@ -0,0 +1,63 @@
+package http
+
+import (
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PaymentReviewController struct {
+	Log     log.Log
+	UseCase *usecase.PaymentReviewUseCase
+}
+
+func NewPaymentReviewController(useCase *usecase.PaymentReviewUseCase, logger log.Log) *PaymentReviewController {
+	return &PaymentReviewController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *PaymentReviewController) ListReviews(ctx *fiber.Ctx) error {
+	request := new(model.ListPaymentReviewRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("PaymentReviewController.ListReviews", "Failed to parse query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.ListReviews(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Payment Reviews", fiber.StatusOK, ctx)
+}
+
+func (c *PaymentReviewController) ApproveReview(ctx *fiber.Ctx) error {
+	return c.decide(ctx, true, "Approve Payment")
+}
+
+func (c *PaymentReviewController) DenyReview(ctx *fiber.Ctx) error {
+	return c.decide(ctx, false, "Deny Payment")
+}
+
+func (c *PaymentReviewController) decide(ctx *fiber.Ctx, approve bool, message string) error {
+	request := new(model.DecidePaymentReviewRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("PaymentReviewController.decide", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("PaymentReviewController.decide", "Failed to parse params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.Approve = approve
+	result := c.UseCase.DecideReview(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, message, fiber.StatusOK, ctx)
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"payment-service/src/internal/delivery/http/middleware"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PayoutAccountController struct {
+	Log     log.Log
+	UseCase *usecase.PayoutAccountUseCase
+}
+
+func NewPayoutAccountController(useCase *usecase.PayoutAccountUseCase, logger log.Log) *PayoutAccountController {
+	return &PayoutAccountController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *PayoutAccountController) AddAccount(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.PayoutAccountRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("PayoutAccountController.AddAccount", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	request.AccountID = ""
+	result := c.UseCase.AddAccount(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Add Payout Account", fiber.StatusOK, ctx)
+}
+
+func (c *PayoutAccountController) UpdateAccount(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.PayoutAccountRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("PayoutAccountController.UpdateAccount", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("PayoutAccountController.UpdateAccount", "Failed to parse params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	result := c.UseCase.UpdateAccount(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Update Payout Account", fiber.StatusOK, ctx)
+}
+
+func (c *PayoutAccountController) VerifyAccount(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.PayoutAccountRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("PayoutAccountController.VerifyAccount", "Failed to parse params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	result := c.UseCase.VerifyAccount(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Verify Payout Account", fiber.StatusOK, ctx)
+}
+
+func (c *PayoutAccountController) ListAccounts(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := &model.PayoutAccountRequest{DriverID: auth.UserID}
+	result := c.UseCase.ListAccounts(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Payout Accounts", fiber.StatusOK, ctx)
+}
+
+func (c *PayoutAccountController) RemoveAccount(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.PayoutAccountRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("PayoutAccountController.RemoveAccount", "Failed to parse params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	result := c.UseCase.RemoveAccount(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Remove Payout Account", fiber.StatusOK, ctx)
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"payment-service/src/internal/delivery/http/middleware"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RefundController struct {
+	Log     log.Log
+	UseCase *usecase.RefundUseCase
+}
+
+func NewRefundController(useCase *usecase.RefundUseCase, logger log.Log) *RefundController {
+	return &RefundController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *RefundController) RefundPayment(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.RefundPaymentRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("RefundController.RefundPayment", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.UserID = auth.UserID
+	result := c.UseCase.RefundPayment(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Refund Payment", fiber.StatusOK, ctx)
+}
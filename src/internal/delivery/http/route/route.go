@@ -8,10 +8,18 @@ import (
 )
 
 type RouteConfig struct {
-	App               *fiber.App
-	WalletController  *http.WalletController
-	PaymentController *http.PaymentController
-	AuthMiddleware    fiber.Handler
+	App                     *fiber.App
+	WalletController        *http.WalletController
+	PaymentController       *http.PaymentController
+	RefundController        *http.RefundController
+	WebhookController       *http.WebhookController
+	ReviewController        *http.PaymentReviewController
+	TaxController           *http.TaxController
+	WithdrawalController    *http.WithdrawalController
+	PayoutAccountController *http.PayoutAccountController
+	AuthMiddleware          fiber.Handler
+	AdminMiddleware         fiber.Handler
+	PayoutMiddleware        fiber.Handler
 }
 
 func (c *RouteConfig) Setup() {
@@ -20,16 +28,44 @@ func (c *RouteConfig) Setup() {
 		return ctx.SendString("OK")
 	})
 	c.SetupGuestRoute()
+	c.SetupAdminRoute()
 	c.SetupAuthRoute()
 }
 func (c *RouteConfig) SetupGuestRoute() {
-	c.App.Post("/payment/v1/webhook", c.PaymentController.CallbackPayment)
+	c.App.Post("/payment/v1/webhook", c.WebhookController.ReceiveNotification)
+	c.App.Post("/payment/v1/payout/webhook", c.WithdrawalController.ReceivePayoutNotification)
+}
+
+func (c *RouteConfig) SetupAdminRoute() {
+	admin := c.App.Group("/payment/v1/admin", c.AdminMiddleware)
+	admin.Get("/webhooks", c.WebhookController.ListFailedNotifications)
+	admin.Post("/webhooks/:id/replay", c.WebhookController.ReplayNotification)
+	admin.Get("/reviews", c.ReviewController.ListReviews)
+	admin.Post("/reviews/:id/approve", c.ReviewController.ApproveReview)
+	admin.Post("/reviews/:id/deny", c.ReviewController.DenyReview)
+	admin.Get("/tax/withholding", c.TaxController.ExportWithholding)
+	admin.Get("/withdrawals", c.PayoutMiddleware, c.WithdrawalController.ListWithdrawals)
+	admin.Post("/withdrawals/:id/approve", c.PayoutMiddleware, c.WithdrawalController.ApproveWithdrawal)
+	admin.Post("/withdrawals/:id/reject", c.PayoutMiddleware, c.WithdrawalController.RejectWithdrawal)
+	admin.Post("/withdrawals/:id/reconcile", c.PayoutMiddleware, c.WithdrawalController.ReconcileWithdrawal)
 }
 
 func (c *RouteConfig) SetupAuthRoute() {
 	c.App.Use(c.AuthMiddleware)
 	c.App.Post("/wallet/v1/top-up", c.WalletController.TopUpWallet)
 	c.App.Get("/wallet/v1/info", c.WalletController.GetWallet)
+	c.App.Post("/wallet/v1/withdrawals", c.PayoutMiddleware, c.WithdrawalController.RequestWithdrawal)
+	c.App.Get("/wallet/v1/withdrawals", c.PayoutMiddleware, c.WithdrawalController.ListMyWithdrawals)
+	c.App.Post("/wallet/v1/payout-accounts", c.PayoutMiddleware, c.PayoutAccountController.AddAccount)
+	c.App.Get("/wallet/v1/payout-accounts", c.PayoutMiddleware, c.PayoutAccountController.ListAccounts)
+	c.App.Put("/wallet/v1/payout-accounts/:id", c.PayoutMiddleware, c.PayoutAccountController.UpdateAccount)
+	c.App.Delete("/wallet/v1/payout-accounts/:id", c.PayoutMiddleware, c.PayoutAccountController.RemoveAccount)
+	c.App.Post("/wallet/v1/payout-accounts/:id/verify", c.PayoutMiddleware, c.PayoutAccountController.VerifyAccount)
 
 	c.App.Post("/order/v1/payment", c.PaymentController.GeneratePayment)
+	c.App.Post("/order/v1/payment/va", c.PaymentController.GenerateVaPayment)
+	c.App.Post("/order/v1/tip", c.PaymentController.TipDriver)
+	c.App.Get("/order/v1/payment/:orderId", c.PaymentController.GetPaymentStatus)
+	c.App.Get("/payment/v1/methods", c.PaymentController.ListPaymentMethods)
+	c.App.Post("/payment/v1/refund", c.RefundController.RefundPayment)
 }
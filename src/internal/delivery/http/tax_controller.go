@@ -0,0 +1,69 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TaxController struct {
+	Log     log.Log
+	UseCase *usecase.TaxUseCase
+}
+
+func NewTaxController(useCase *usecase.TaxUseCase, logger log.Log) *TaxController {
+	return &TaxController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+// ExportWithholding returns the month's withholding slips, as JSON or, with
+// format=csv, as a CSV file.
+func (c *TaxController) ExportWithholding(ctx *fiber.Ctx) error {
+	request := new(model.WithholdingExportRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("TaxController.ExportWithholding", "Failed to parse query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.WithholdingSlips(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+	if !strings.EqualFold(request.Format, "csv") {
+		return utils.Response(result.Data, "Withholding Slips", fiber.StatusOK, ctx)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"period", "driver_id", "driver_name", "trips", "gross_income", "tax_base", "pph_withheld", "ppn_charged"})
+	for _, slip := range result.Data.([]*model.WithholdingSlipResponse) {
+		_ = w.Write([]string{
+			slip.Period,
+			slip.DriverID,
+			slip.DriverName,
+			strconv.Itoa(slip.Trips),
+			strconv.FormatInt(slip.GrossIncome.Int64(), 10),
+			strconv.FormatInt(slip.TaxBase.Int64(), 10),
+			strconv.FormatInt(slip.PPhWithheld.Int64(), 10),
+			strconv.FormatInt(slip.PPNCharged.Int64(), 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		c.Log.Error("TaxController.ExportWithholding", "Failed to write csv", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/csv")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"bukti-potong-%s.csv\"", request.Period))
+	return ctx.Send(buf.Bytes())
+}
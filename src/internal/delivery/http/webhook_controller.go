@@ -0,0 +1,60 @@
+package http
+
+import (
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebhookController struct {
+	Log     log.Log
+	UseCase *usecase.WebhookInboxUseCase
+}
+
+func NewWebhookController(useCase *usecase.WebhookInboxUseCase, logger log.Log) *WebhookController {
+	return &WebhookController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *WebhookController) ReceiveNotification(ctx *fiber.Ctx) error {
+	payload := append([]byte(nil), ctx.Body()...)
+	result := c.UseCase.ReceiveNotification(ctx.Context(), payload)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Payment Notification", fiber.StatusOK, ctx)
+}
+
+func (c *WebhookController) ListFailedNotifications(ctx *fiber.Ctx) error {
+	request := new(model.ListWebhookInboxRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("WebhookController.ListFailedNotifications", "Failed to parse query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.ListFailedNotifications(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Webhook Inbox", fiber.StatusOK, ctx)
+}
+
+func (c *WebhookController) ReplayNotification(ctx *fiber.Ctx) error {
+	request := new(model.ReplayWebhookRequest)
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("WebhookController.ReplayNotification", "Failed to parse params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.ReplayNotification(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Replay Webhook", fiber.StatusOK, ctx)
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"payment-service/src/internal/delivery/http/middleware"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WithdrawalController struct {
+	Log     log.Log
+	UseCase *usecase.WithdrawalUseCase
+}
+
+func NewWithdrawalController(useCase *usecase.WithdrawalUseCase, logger log.Log) *WithdrawalController {
+	return &WithdrawalController{
+		Log:     logger,
+		UseCase: useCase,
+	}
+}
+
+func (c *WithdrawalController) RequestWithdrawal(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.WithdrawalRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("WithdrawalController.RequestWithdrawal", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	result := c.UseCase.RequestWithdrawal(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Request Withdrawal", fiber.StatusOK, ctx)
+}
+
+func (c *WithdrawalController) ListMyWithdrawals(ctx *fiber.Ctx) error {
+	auth := middleware.GetUser(ctx)
+	request := new(model.ListWithdrawalRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("WithdrawalController.ListMyWithdrawals", "Failed to parse query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = auth.UserID
+	result := c.UseCase.ListWithdrawals(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Withdrawals", fiber.StatusOK, ctx)
+}
+
+func (c *WithdrawalController) ListWithdrawals(ctx *fiber.Ctx) error {
+	request := new(model.ListWithdrawalRequest)
+	if err := ctx.QueryParser(request); err != nil {
+		c.Log.Error("WithdrawalController.ListWithdrawals", "Failed to parse query", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.DriverID = ""
+	result := c.UseCase.ListWithdrawals(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Withdrawals", fiber.StatusOK, ctx)
+}
+
+func (c *WithdrawalController) ApproveWithdrawal(ctx *fiber.Ctx) error {
+	return c.decide(ctx, true, "Approve Withdrawal")
+}
+
+func (c *WithdrawalController) RejectWithdrawal(ctx *fiber.Ctx) error {
+	return c.decide(ctx, false, "Reject Withdrawal")
+}
+
+func (c *WithdrawalController) decide(ctx *fiber.Ctx, approve bool, message string) error {
+	request := new(model.DecideWithdrawalRequest)
+	if err := ctx.BodyParser(request); err != nil {
+		c.Log.Error("WithdrawalController.decide", "Failed to parse request body", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("WithdrawalController.decide", "Failed to parse params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	request.Approve = approve
+	result := c.UseCase.DecideWithdrawal(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, message, fiber.StatusOK, ctx)
+}
+
+func (c *WithdrawalController) ReconcileWithdrawal(ctx *fiber.Ctx) error {
+	request := new(model.ReconcileWithdrawalRequest)
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(request); err != nil {
+			c.Log.Error("WithdrawalController.ReconcileWithdrawal", "Failed to parse request body", "error", err.Error())
+			return utils.ResponseError(err, ctx)
+		}
+	}
+	if err := ctx.ParamsParser(request); err != nil {
+		c.Log.Error("WithdrawalController.ReconcileWithdrawal", "Failed to parse params", "error", err.Error())
+		return utils.ResponseError(err, ctx)
+	}
+	result := c.UseCase.ReconcileWithdrawal(ctx.Context(), request)
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Reconcile Withdrawal", fiber.StatusOK, ctx)
+}
+
+// ReceivePayoutNotification takes the payout provider's webhook; Iris signs
+// the body in the Iris-Signature header.
+func (c *WithdrawalController) ReceivePayoutNotification(ctx *fiber.Ctx) error {
+	payload := append([]byte(nil), ctx.Body()...)
+	result := c.UseCase.HandlePayoutNotification(ctx.Context(), payload, ctx.Get("Iris-Signature"))
+	if result.Error != nil {
+		return utils.ResponseError(result.Error, ctx)
+	}
+
+	return utils.Response(result.Data, "Payout Notification", fiber.StatusOK, ctx)
+}
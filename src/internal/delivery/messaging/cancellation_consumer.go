@@ -0,0 +1,69 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"time"
+
+	k "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+type CancellationConsumerHandler struct {
+	logger        log.Log
+	WalletUseCase *usecase.WalletUseCase
+}
+
+func NewCancellationConsumerHandler(
+	logger log.Log,
+	walletUsecase *usecase.WalletUseCase,
+) *CancellationConsumerHandler {
+	return &CancellationConsumerHandler{
+		logger:        logger,
+		WalletUseCase: walletUsecase,
+	}
+}
+
+func (h *CancellationConsumerHandler) HandleMessage(message *k.Message) {
+	h.logger.Info(
+		"cancellation-consumer",
+		fmt.Sprintf("Received message: %s", string(message.Value)),
+		"HandleMessage",
+		"",
+	)
+
+	var event model.OrderCancelledEvent
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		h.logger.Error(
+			"cancellation-consumer",
+			fmt.Sprintf("Failed to unmarshal message: %v", err),
+			"HandleMessage",
+			"",
+		)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := h.WalletUseCase.CancelOrderPayment(ctx, &event)
+	if err != nil {
+		h.logger.Error(
+			"cancellation-consumer",
+			fmt.Sprintf("Failed to handle order cancelled event: %v", err),
+			"HandleMessage",
+			"",
+		)
+		return
+	}
+
+	h.logger.Info(
+		"cancellation-consumer",
+		fmt.Sprintf("Successfully processed order cancelled event: %+v", event),
+		"HandleMessage",
+		"",
+	)
+}
@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"time"
+)
+
+type ExpirySweeper struct {
+	logger   log.Log
+	UseCase  *usecase.PaymentExpiryUseCase
+	Interval time.Duration
+}
+
+func NewExpirySweeper(logger log.Log, useCase *usecase.PaymentExpiryUseCase, interval time.Duration) *ExpirySweeper {
+	return &ExpirySweeper{
+		logger:   logger,
+		UseCase:  useCase,
+		Interval: interval,
+	}
+}
+
+func (s *ExpirySweeper) Name() string {
+	return "payment-expiry-sweeper"
+}
+
+func (s *ExpirySweeper) Run(ctx context.Context) {
+	runCtx, cancel := context.WithTimeout(ctx, s.Interval)
+	defer cancel()
+
+	if err := s.UseCase.ExpirePendingPayments(runCtx); err != nil {
+		s.logger.Error(
+			"expiry-sweeper",
+			fmt.Sprintf("Failed to sweep expired payments: %v", err),
+			"Run",
+			"",
+		)
+	}
+}
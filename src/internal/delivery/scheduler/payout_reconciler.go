@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"time"
+)
+
+type PayoutReconciler struct {
+	logger   log.Log
+	UseCase  *usecase.WithdrawalUseCase
+	Interval time.Duration
+}
+
+func NewPayoutReconciler(logger log.Log, useCase *usecase.WithdrawalUseCase, interval time.Duration) *PayoutReconciler {
+	return &PayoutReconciler{
+		logger:   logger,
+		UseCase:  useCase,
+		Interval: interval,
+	}
+}
+
+func (r *PayoutReconciler) Name() string {
+	return "payout-reconciler"
+}
+
+func (r *PayoutReconciler) Run(ctx context.Context) {
+	runCtx, cancel := context.WithTimeout(ctx, r.Interval)
+	defer cancel()
+
+	if err := r.UseCase.ReconcilePayouts(runCtx); err != nil {
+		r.logger.Error(
+			"payout-reconciler",
+			fmt.Sprintf("Failed to reconcile payouts: %v", err),
+			"Run",
+			"",
+		)
+	}
+}
@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"time"
+)
+
+type RefundRetrier struct {
+	logger   log.Log
+	UseCase  *usecase.RefundUseCase
+	Interval time.Duration
+}
+
+func NewRefundRetrier(logger log.Log, useCase *usecase.RefundUseCase, interval time.Duration) *RefundRetrier {
+	return &RefundRetrier{
+		logger:   logger,
+		UseCase:  useCase,
+		Interval: interval,
+	}
+}
+
+func (r *RefundRetrier) Name() string {
+	return "refund-retrier"
+}
+
+func (r *RefundRetrier) Run(ctx context.Context) {
+	runCtx, cancel := context.WithTimeout(ctx, r.Interval)
+	defer cancel()
+
+	if err := r.UseCase.RetryPendingRefunds(runCtx); err != nil {
+		r.logger.Error(
+			"refund-retrier",
+			fmt.Sprintf("Failed to retry pending refunds: %v", err),
+			"Run",
+			"",
+		)
+	}
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/pkg/log"
+	"time"
+)
+
+type Job interface {
+	Name() string
+	Run(ctx context.Context)
+}
+
+type Scheduler struct {
+	Ctx    context.Context
+	Logger log.Log
+}
+
+// Every runs job on a ticker until the scheduler context is cancelled. Runs
+// never overlap: a slow run delays the next tick instead of stacking up.
+func (s Scheduler) Every(interval time.Duration, job Job) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		s.Logger.Info("scheduler", fmt.Sprintf("Started job %s every %s", job.Name(), interval), "Every", "")
+		for {
+			select {
+			case <-s.Ctx.Done():
+				s.Logger.Info("scheduler", fmt.Sprintf("Stopped job %s", job.Name()), "Every", "")
+				return
+			case <-ticker.C:
+				job.Run(s.Ctx)
+			}
+		}
+	}()
+}
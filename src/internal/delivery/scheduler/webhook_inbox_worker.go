@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/usecase"
+	"payment-service/src/pkg/log"
+	"time"
+)
+
+type WebhookInboxWorker struct {
+	logger   log.Log
+	UseCase  *usecase.WebhookInboxUseCase
+	Interval time.Duration
+}
+
+func NewWebhookInboxWorker(logger log.Log, useCase *usecase.WebhookInboxUseCase, interval time.Duration) *WebhookInboxWorker {
+	return &WebhookInboxWorker{
+		logger:   logger,
+		UseCase:  useCase,
+		Interval: interval,
+	}
+}
+
+func (w *WebhookInboxWorker) Name() string {
+	return "webhook-inbox-worker"
+}
+
+func (w *WebhookInboxWorker) Run(ctx context.Context) {
+	runCtx, cancel := context.WithTimeout(ctx, w.Interval)
+	defer cancel()
+
+	if err := w.UseCase.ProcessDueNotifications(runCtx); err != nil {
+		w.logger.Error(
+			"webhook-inbox-worker",
+			fmt.Sprintf("Failed to process webhook inbox: %v", err),
+			"Run",
+			"",
+		)
+	}
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// CommissionRule is one version of a platform commission rule. An empty City,
+// VehicleType or ServiceTier matches any trip. WindowStart and WindowEnd are
+// times of day ("15:04:05"); a window that ends before it starts runs past
+// midnight. A rule is changed by adding a version with its own effective
+// dates, never by editing one settlements already point at.
+type CommissionRule struct {
+	ID            uint64     `db:"id"`
+	RuleCode      string     `db:"rule_code"`
+	Version       int        `db:"version"`
+	City          *string    `db:"city"`
+	VehicleType   *string    `db:"vehicle_type"`
+	ServiceTier   *string    `db:"service_tier"`
+	WindowStart   *string    `db:"window_start"`
+	WindowEnd     *string    `db:"window_end"`
+	FeeRate       float64    `db:"fee_rate"`
+	Priority      int        `db:"priority"`
+	EffectiveFrom time.Time  `db:"effective_from"`
+	EffectiveTo   *time.Time `db:"effective_to"`
+	Description   *string    `db:"description"`
+	CreatedAt     time.Time  `db:"created_at"`
+}
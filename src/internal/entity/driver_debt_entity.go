@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
+
+const (
+	DriverDebtEntryDebt      = "DEBT"
+	DriverDebtEntryRepayment = "REPAYMENT"
+)
+
+// Sources of driver debt entries.
+const (
+	DriverDebtSourceCashCommission = "CASH_COMMISSION"
+	DriverDebtSourceRefundReversal = "REFUND_REVERSAL"
+	DriverDebtSourceTripEarning    = "TRIP_EARNING"
+	DriverDebtSourceCancellation   = "CANCELLATION_FEE"
+	DriverDebtSourceTopUp          = "TOP_UP"
+)
+
+// DriverDebt is what a driver owes the platform beyond their wallet balance,
+// in the currency of their wallet. BlockedAt is set while Outstanding is
+// above the credit limit; the driver cannot go online until it is cleared.
+type DriverDebt struct {
+	DriverID    string       `db:"driver_id"`
+	Currency    string       `db:"currency"`
+	Outstanding money.Amount `db:"outstanding"`
+	BlockedAt   *time.Time   `db:"blocked_at"`
+	CreatedAt   time.Time    `db:"created_at"`
+	UpdatedAt   time.Time    `db:"updated_at"`
+}
+
+// DriverDebtEntry is one movement of a driver's debt. Amount is always
+// positive; EntryType says which way it went.
+type DriverDebtEntry struct {
+	ID                   uint64       `db:"id"`
+	DriverID             string       `db:"driver_id"`
+	PaymentTransactionID *uint64      `db:"payment_transaction_id"`
+	EntryType            string       `db:"entry_type"`
+	Source               string       `db:"source"`
+	Amount               money.Amount `db:"amount"`
+	OutstandingAfter     money.Amount `db:"outstanding_after"`
+	Description          *string      `db:"description"`
+	CreatedAt            time.Time    `db:"created_at"`
+}
@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
+
+const (
+	// FxPurposeHold prices a passenger wallet debit for a payment in another
+	// currency.
+	FxPurposeHold = "HOLD"
+	// FxPurposeSettlement prices a driver wallet credit for a payment in
+	// another currency.
+	FxPurposeSettlement = "SETTLEMENT"
+)
+
+// PaymentFxConversion records the rate a wallet movement for a payment was
+// priced at. Refunds and releases of that movement reuse it instead of
+// looking the rate up again.
+type PaymentFxConversion struct {
+	ID                   uint64       `db:"id"`
+	PaymentTransactionID uint64       `db:"payment_transaction_id"`
+	Purpose              string       `db:"purpose"`
+	WalletID             string       `db:"wallet_id"`
+	WalletCurrency       string       `db:"wallet_currency"`
+	WalletAmount         money.Amount `db:"wallet_amount"`
+	PaymentCurrency      string       `db:"payment_currency"`
+	PaymentAmount        money.Amount `db:"payment_amount"`
+	RatePair             string       `db:"rate_pair"`
+	Rate                 string       `db:"rate"`
+	RateSource           string       `db:"rate_source"`
+	CreatedAt            time.Time    `db:"created_at"`
+}
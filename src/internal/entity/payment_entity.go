@@ -1,27 +1,54 @@
 package entity
 
 import (
+	"payment-service/src/pkg/money"
 	"time"
 )
 
+const (
+	PaymentTypeTrip = "TRIP"
+	// PaymentTypeTip is a passenger's tip to the driver after a paid trip. It
+	// goes to the driver in full and never touches the order's payment status.
+	// Tips are charged at the provider under their own charge_order_id, as
+	// the ride order id is already taken by the trip.
+	PaymentTypeTip = "TIP"
+)
+
 type PaymentTransaction struct {
-	ID                  uint64     `db:"id"`
-	RideOrderID         uint64     `db:"ride_order_id"`
-	PassengerID         string     `db:"passenger_id"`
-	DriverID            string     `db:"driver_id"`
-	Amount              float64    `db:"amount"`
-	Currency            string     `db:"currency"`
-	PaymentMethod       string     `db:"payment_method"`
-	PaymentStatus       string     `db:"payment_status"`
-	ProviderName        *string    `db:"provider_name"`
-	ProviderReferenceID *string    `db:"provider_reference_id"`
-	ProviderSignature   *string    `db:"provider_signature"`
-	PaidAt              *time.Time `db:"paid_at"`
-	ExpiredAt           *time.Time `db:"expired_at"`
-	RefundedAt          *time.Time `db:"refunded_at"`
-	Metadata            []byte     `db:"metadata"`
-	CreatedAt           time.Time  `db:"created_at"`
-	UpdatedAt           time.Time  `db:"updated_at"`
+	ID                  uint64       `db:"id"`
+	RideOrderID         uint64       `db:"ride_order_id"`
+	ParentPaymentID     *uint64      `db:"parent_payment_id"`
+	PaymentType         string       `db:"payment_type"`
+	ChargeOrderID       *string      `db:"charge_order_id"`
+	PassengerID         string       `db:"passenger_id"`
+	DriverID            string       `db:"driver_id"`
+	Amount              money.Amount `db:"amount"`
+	DiscountAmount      money.Amount `db:"discount_amount"`
+	Currency            string       `db:"currency"`
+	PaymentMethod       string       `db:"payment_method"`
+	PaymentStatus       string       `db:"payment_status"`
+	ProviderName        *string      `db:"provider_name"`
+	ProviderReferenceID *string      `db:"provider_reference_id"`
+	ProviderSignature   *string      `db:"provider_signature"`
+	PaidAt              *time.Time   `db:"paid_at"`
+	ExpiredAt           *time.Time   `db:"expired_at"`
+	RefundedAt          *time.Time   `db:"refunded_at"`
+	Metadata            []byte       `db:"metadata"`
+	CreatedAt           time.Time    `db:"created_at"`
+	UpdatedAt           time.Time    `db:"updated_at"`
+}
+
+// PaymentChargeMetadata is what we keep in payment_transactions.metadata so a
+// pending charge can be handed back to the client without calling the provider.
+type PaymentChargeMetadata struct {
+	Mode          string `json:"mode,omitempty"`
+	SnapToken     string `json:"snap_token,omitempty"`
+	RedirectURL   string `json:"redirect_url,omitempty"`
+	QrString      string `json:"qr_string,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Bank          string `json:"bank,omitempty"`
+	VaNumber      string `json:"va_number,omitempty"`
+	BillerCode    string `json:"biller_code,omitempty"`
 }
 
 type PaymentEventLog struct {
@@ -34,17 +61,25 @@ type PaymentEventLog struct {
 }
 
 type PaymentSettlement struct {
-	ID                   uint64     `db:"id"`
-	PaymentTransactionID uint64     `db:"payment_transaction_id"`
-	DriverID             string     `db:"driver_id"`
-	SettlementAmount     float64    `db:"settlement_amount"`
-	PlatformFee          float64    `db:"platform_fee"`
-	TaxAmount            float64    `db:"tax_amount"`
-	Status               string     `db:"status"`
-	SettlementMethod     string     `db:"settlement_method"`
-	ProviderReferenceID  *string    `db:"provider_reference_id"`
-	SettledAt            *time.Time `db:"settled_at"`
-	Metadata             *string    `db:"metadata"`
-	CreatedAt            time.Time  `db:"created_at"`
-	UpdatedAt            time.Time  `db:"updated_at"`
+	ID                   uint64       `db:"id"`
+	PaymentTransactionID uint64       `db:"payment_transaction_id"`
+	DriverID             string       `db:"driver_id"`
+	SettlementAmount     money.Amount `db:"settlement_amount"`
+	PlatformFee          money.Amount `db:"platform_fee"`
+	TaxAmount            money.Amount `db:"tax_amount"`
+	Status               string       `db:"status"`
+	SettlementMethod     string       `db:"settlement_method"`
+	ProviderReferenceID  *string      `db:"provider_reference_id"`
+	SettledAt            *time.Time   `db:"settled_at"`
+	Metadata             *string      `db:"metadata"`
+	// the commission rule version the platform fee was taken by; a rule id
+	// of nil with code platform.fee is the configured flat rate
+	CommissionRuleID      *uint64  `db:"commission_rule_id"`
+	CommissionRuleCode    *string  `db:"commission_rule_code"`
+	CommissionRuleVersion *int     `db:"commission_rule_version"`
+	CommissionRate        *float64 `db:"commission_rate"`
+	// JSON array of TaxLine; TaxAmount is their sum
+	TaxBreakdown *string   `db:"tax_breakdown"`
+	CreatedAt    time.Time `db:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
 }
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+const (
+	PaymentReviewOpen     = "OPEN"
+	PaymentReviewApproved = "APPROVED"
+	PaymentReviewDenied   = "DENIED"
+)
+
+type PaymentReview struct {
+	ID                   uint64     `db:"id"`
+	PaymentTransactionID uint64     `db:"payment_transaction_id"`
+	OrderID              string     `db:"order_id"`
+	Reason               string     `db:"reason"`
+	Status               string     `db:"status"`
+	ReviewedBy           *string    `db:"reviewed_by"`
+	ReviewNote           *string    `db:"review_note"`
+	ProviderResponse     *string    `db:"provider_response"`
+	ReviewedAt           *time.Time `db:"reviewed_at"`
+	CreatedAt            time.Time  `db:"created_at"`
+	UpdatedAt            time.Time  `db:"updated_at"`
+}
@@ -0,0 +1,75 @@
+package entity
+
+import "fmt"
+
+const (
+	PaymentStatusPending           = "PENDING"
+	PaymentStatusSuccess           = "SUCCESS"
+	PaymentStatusFailed            = "FAILED"
+	PaymentStatusExpired           = "EXPIRED"
+	PaymentStatusRefunded          = "REFUNDED"
+	PaymentStatusPartiallyRefunded = "PARTIALLY_REFUNDED"
+	PaymentStatusChallenge         = "CHALLENGE"
+	// PaymentStatusCancelled ends a wallet hold released because the order
+	// was cancelled.
+	PaymentStatusCancelled = "CANCELLED"
+	// PaymentStatusFlagged holds a payment whose provider notification did not
+	// match what we charged. It waits for an operator and is never marked paid.
+	PaymentStatusFlagged = "FLAGGED"
+)
+
+// paymentTransitions lists, for every payment_status, the statuses it may move
+// to. The empty status is a row that does not exist yet; only cash payments
+// and wallet tips, settled before they are recorded, are born SUCCESS.
+// FAILED, EXPIRED, CANCELLED, REFUNDED and FLAGGED are terminal.
+var paymentTransitions = map[string][]string{
+	"": {
+		PaymentStatusPending,
+		PaymentStatusSuccess,
+	},
+	PaymentStatusPending: {
+		PaymentStatusSuccess,
+		PaymentStatusFailed,
+		PaymentStatusExpired,
+		PaymentStatusChallenge,
+		PaymentStatusFlagged,
+		PaymentStatusCancelled,
+	},
+	PaymentStatusChallenge: {
+		PaymentStatusSuccess,
+		PaymentStatusFailed,
+		PaymentStatusExpired,
+		PaymentStatusFlagged,
+	},
+	PaymentStatusSuccess: {
+		PaymentStatusRefunded,
+		PaymentStatusPartiallyRefunded,
+	},
+	PaymentStatusPartiallyRefunded: {
+		PaymentStatusPartiallyRefunded,
+		PaymentStatusRefunded,
+	},
+}
+
+// CanTransitionPayment reports whether a payment in status from may be moved
+// to status to.
+func CanTransitionPayment(from, to string) bool {
+	for _, allowed := range paymentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PaymentTransitionError is returned when a writer asks for a status change
+// the state machine does not allow.
+type PaymentTransitionError struct {
+	PaymentID uint64
+	From      string
+	To        string
+}
+
+func (e *PaymentTransitionError) Error() string {
+	return fmt.Sprintf("illegal payment status transition %s -> %s for payment %d", e.From, e.To, e.PaymentID)
+}
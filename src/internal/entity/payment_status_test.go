@@ -0,0 +1,102 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransitionPayment(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		// a new row
+		{"", PaymentStatusPending, true},
+		{"", PaymentStatusSuccess, true},
+		{"", PaymentStatusFailed, false},
+		{"", PaymentStatusRefunded, false},
+		{"", PaymentStatusFlagged, false},
+
+		{PaymentStatusPending, PaymentStatusSuccess, true},
+		{PaymentStatusPending, PaymentStatusFailed, true},
+		{PaymentStatusPending, PaymentStatusExpired, true},
+		{PaymentStatusPending, PaymentStatusChallenge, true},
+		{PaymentStatusPending, PaymentStatusFlagged, true},
+		{PaymentStatusPending, PaymentStatusCancelled, true},
+		{PaymentStatusPending, PaymentStatusPending, false},
+		{PaymentStatusPending, PaymentStatusRefunded, false},
+		{PaymentStatusPending, PaymentStatusPartiallyRefunded, false},
+
+		{PaymentStatusChallenge, PaymentStatusSuccess, true},
+		{PaymentStatusChallenge, PaymentStatusFailed, true},
+		{PaymentStatusChallenge, PaymentStatusExpired, true},
+		{PaymentStatusChallenge, PaymentStatusFlagged, true},
+		{PaymentStatusChallenge, PaymentStatusPending, false},
+		{PaymentStatusChallenge, PaymentStatusCancelled, false},
+
+		{PaymentStatusSuccess, PaymentStatusRefunded, true},
+		{PaymentStatusSuccess, PaymentStatusPartiallyRefunded, true},
+		{PaymentStatusSuccess, PaymentStatusSuccess, false},
+		{PaymentStatusSuccess, PaymentStatusPending, false},
+		{PaymentStatusSuccess, PaymentStatusFailed, false},
+		{PaymentStatusSuccess, PaymentStatusExpired, false},
+		{PaymentStatusSuccess, PaymentStatusCancelled, false},
+
+		{PaymentStatusPartiallyRefunded, PaymentStatusPartiallyRefunded, true},
+		{PaymentStatusPartiallyRefunded, PaymentStatusRefunded, true},
+		{PaymentStatusPartiallyRefunded, PaymentStatusSuccess, false},
+
+		// terminal statuses
+		{PaymentStatusFailed, PaymentStatusSuccess, false},
+		{PaymentStatusFailed, PaymentStatusPending, false},
+		{PaymentStatusExpired, PaymentStatusSuccess, false},
+		{PaymentStatusExpired, PaymentStatusPending, false},
+		{PaymentStatusCancelled, PaymentStatusSuccess, false},
+		{PaymentStatusRefunded, PaymentStatusPartiallyRefunded, false},
+		{PaymentStatusRefunded, PaymentStatusSuccess, false},
+		{PaymentStatusFlagged, PaymentStatusSuccess, false},
+		{PaymentStatusFlagged, PaymentStatusFailed, false},
+
+		// unknown statuses
+		{"SETTLED", PaymentStatusSuccess, false},
+		{PaymentStatusPending, "SETTLED", false},
+		{PaymentStatusPending, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			assert.Equal(t, tt.want, CanTransitionPayment(tt.from, tt.to))
+		})
+	}
+}
+
+func TestTerminalPaymentStatuses(t *testing.T) {
+	statuses := []string{
+		PaymentStatusPending,
+		PaymentStatusSuccess,
+		PaymentStatusFailed,
+		PaymentStatusExpired,
+		PaymentStatusRefunded,
+		PaymentStatusPartiallyRefunded,
+		PaymentStatusChallenge,
+		PaymentStatusCancelled,
+		PaymentStatusFlagged,
+	}
+	terminal := []string{
+		PaymentStatusFailed,
+		PaymentStatusExpired,
+		PaymentStatusCancelled,
+		PaymentStatusRefunded,
+		PaymentStatusFlagged,
+	}
+	for _, from := range terminal {
+		for _, to := range statuses {
+			assert.False(t, CanTransitionPayment(from, to), "%s -> %s", from, to)
+		}
+	}
+}
+
+func TestPaymentTransitionError(t *testing.T) {
+	err := &PaymentTransitionError{PaymentID: 42, From: PaymentStatusExpired, To: PaymentStatusSuccess}
+	assert.EqualError(t, err, "illegal payment status transition EXPIRED -> SUCCESS for payment 42")
+}
@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// A payout account is PENDING until the bank's account name inquiry has run,
+// then VERIFIED when the name matches the driver's or REJECTED when it does
+// not or the bank has no such account. A VERIFIED account is only paid out
+// to once AvailableAfter, the end of its cooling-off period, has passed.
+const (
+	PayoutAccountPending  = "PENDING"
+	PayoutAccountVerified = "VERIFIED"
+	PayoutAccountRejected = "REJECTED"
+	PayoutAccountRemoved  = "REMOVED"
+)
+
+type PayoutAccount struct {
+	ID                     uint64     `db:"id"`
+	AccountID              string     `db:"account_id"`
+	DriverID               string     `db:"driver_id"`
+	BankCode               string     `db:"bank_code"`
+	AccountNumberEncrypted string     `db:"account_number_encrypted"`
+	AccountNumberHash      string     `db:"account_number_hash"`
+	AccountNumberLast4     string     `db:"account_number_last4"`
+	HolderNameEncrypted    string     `db:"holder_name_encrypted"`
+	InquiryNameEncrypted   *string    `db:"inquiry_name_encrypted"`
+	NameMatchScore         *float64   `db:"name_match_score"`
+	Status                 string     `db:"status"`
+	RejectionReason        *string    `db:"rejection_reason"`
+	AvailableAfter         *time.Time `db:"available_after"`
+	VerifiedAt             *time.Time `db:"verified_at"`
+	RemovedAt              *time.Time `db:"removed_at"`
+	CreatedAt              time.Time  `db:"created_at"`
+	UpdatedAt              time.Time  `db:"updated_at"`
+}
+
+// Usable reports whether withdrawals may be sent to the account at now.
+func (a *PayoutAccount) Usable(now time.Time) bool {
+	return a.Status == PayoutAccountVerified && a.AvailableAfter != nil && !now.Before(*a.AvailableAfter)
+}
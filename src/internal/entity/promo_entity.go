@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
+
+// Promo discount types. A PERCENTAGE discount_value is a percent of the fare,
+// a FLAT one is rupiah; either is capped by max_discount when it is set.
+const (
+	PromoDiscountPercentage = "PERCENTAGE"
+	PromoDiscountFlat       = "FLAT"
+)
+
+// Promo redemption statuses. The order service attaches a redemption to an
+// order as PENDING; it is APPLIED once a payment takes the discount, REJECTED
+// when the campaign could not give one, and REVERSED when the payment that
+// took it failed or was refunded. Only APPLIED counts against the campaign.
+const (
+	PromoRedemptionPending  = "PENDING"
+	PromoRedemptionApplied  = "APPLIED"
+	PromoRedemptionRejected = "REJECTED"
+	PromoRedemptionReversed = "REVERSED"
+)
+
+type PromoCampaign struct {
+	ID            uint64        `db:"id"`
+	PromoCode     string        `db:"promo_code"`
+	Name          string        `db:"name"`
+	DiscountType  string        `db:"discount_type"`
+	DiscountValue float64       `db:"discount_value"`
+	MaxDiscount   *float64      `db:"max_discount"`
+	PerUserLimit  *int          `db:"per_user_limit"`
+	UsageLimit    *int          `db:"usage_limit"`
+	UsageCount    int           `db:"usage_count"`
+	Budget        *money.Amount `db:"budget"`
+	BudgetUsed    money.Amount  `db:"budget_used"`
+	StartsAt      *time.Time    `db:"starts_at"`
+	EndsAt        *time.Time    `db:"ends_at"`
+}
+
+type PromoRedemption struct {
+	ID                   uint64       `db:"id"`
+	PromoCampaignID      uint64       `db:"promo_campaign_id"`
+	RideOrderID          string       `db:"ride_order_id"`
+	PassengerID          *string      `db:"passenger_id"`
+	PaymentTransactionID *uint64      `db:"payment_transaction_id"`
+	DiscountApplied      money.Amount `db:"discount_applied"`
+	Status               string       `db:"status"`
+	ReversedAt           *time.Time   `db:"reversed_at"`
+	CreatedAt            time.Time    `db:"created_at"`
+}
@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
+
+const (
+	RefundReasonCustomerRequest = "CUSTOMER_REQUEST"
+	RefundReasonDuplicate       = "DUPLICATE_PAYMENT"
+	RefundReasonOvercharge      = "OVERCHARGE"
+	RefundReasonTripCancelled   = "TRIP_CANCELLED"
+	RefundReasonServiceIssue    = "SERVICE_ISSUE"
+	RefundReasonFraud           = "FRAUD"
+	RefundReasonOther           = "OTHER"
+)
+
+var RefundReasonCodes = map[string]bool{
+	RefundReasonCustomerRequest: true,
+	RefundReasonDuplicate:       true,
+	RefundReasonOvercharge:      true,
+	RefundReasonTripCancelled:   true,
+	RefundReasonServiceIssue:    true,
+	RefundReasonFraud:           true,
+	RefundReasonOther:           true,
+}
+
+type PaymentRefund struct {
+	ID                   uint64       `db:"id"`
+	RefundID             string       `db:"refund_id"`
+	PaymentTransactionID uint64       `db:"payment_transaction_id"`
+	Amount               money.Amount `db:"amount"`
+	Currency             string       `db:"currency"`
+	ReasonCode           string       `db:"reason_code"`
+	ReasonNote           *string      `db:"reason_note"`
+	RefundMethod         string       `db:"refund_method"`
+	Status               string       `db:"status"`
+	DriverReversalAmount money.Amount `db:"driver_reversal_amount"`
+	ProviderReferenceID  *string      `db:"provider_reference_id"`
+	RequestedBy          string       `db:"requested_by"`
+	RawPayload           *string      `db:"raw_payload"`
+	CreatedAt            time.Time    `db:"created_at"`
+	UpdatedAt            time.Time    `db:"updated_at"`
+}
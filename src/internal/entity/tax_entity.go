@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
+
+const (
+	// TaxPPN is VAT on the platform's service fee, charged to the driver.
+	TaxPPN = "PPN"
+	// TaxPPh is income tax withheld from the driver's earning; it is what a
+	// bukti potong reports.
+	TaxPPh = "PPH"
+)
+
+// TaxRate is the rate of one tax over a period. BaseRatio is the share of the
+// amount that is taxed (the DPP), e.g. 11/12 for PPN 12% from 2025.
+type TaxRate struct {
+	ID            uint64     `db:"id"`
+	TaxType       string     `db:"tax_type"`
+	Rate          float64    `db:"rate"`
+	BaseRatio     float64    `db:"base_ratio"`
+	EffectiveFrom time.Time  `db:"effective_from"`
+	EffectiveTo   *time.Time `db:"effective_to"`
+	Description   *string    `db:"description"`
+	CreatedAt     time.Time  `db:"created_at"`
+}
+
+// TaxLine is one tax taken on a settlement, stored as JSON in
+// payment_settlements.tax_breakdown. Gross is the amount the tax is on, Base
+// the part of it that is taxed. A nil RateID is the configured platform.tax.
+type TaxLine struct {
+	TaxType string       `json:"tax_type"`
+	RateID  *uint64      `json:"rate_id,omitempty"`
+	Rate    float64      `json:"rate"`
+	Gross   money.Amount `json:"gross"`
+	Base    money.Amount `json:"base"`
+	Amount  money.Amount `json:"amount"`
+}
+
+// WithholdingSettlement is a settlement read for the monthly withholding
+// export, with the driver's name.
+type WithholdingSettlement struct {
+	PaymentSettlement
+	DriverName string `db:"driver_name"`
+}
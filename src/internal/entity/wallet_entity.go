@@ -1,23 +1,57 @@
 package entity
 
-import "time"
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
 
+// Wallet balances are kept apart: Balance is what the owner can spend,
+// HeldBalance is reserved by open wallet holds.
 type Wallet struct {
-	ID          string    `db:"id"        json:"id"`
-	UserID      string    `db:"user_id"   json:"user_id"`
-	Balance     float64   `db:"balance"   json:"balance"`
-	LastUpdated time.Time `db:"last_updated" json:"last_updated"`
-	CreatedAt   time.Time `db:"created_at"   json:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at"   json:"updated_at"`
+	ID          string       `db:"id"        json:"id"`
+	UserID      string       `db:"user_id"   json:"user_id"`
+	Balance     money.Amount `db:"balance"   json:"balance"`
+	HeldBalance money.Amount `db:"held_balance" json:"held_balance"`
+	Currency    string       `db:"currency"  json:"currency"`
+	LastUpdated time.Time    `db:"last_updated" json:"last_updated"`
+	CreatedAt   time.Time    `db:"created_at"   json:"created_at"`
+	UpdatedAt   time.Time    `db:"updated_at"   json:"updated_at"`
 }
 
 type WalletTransaction struct {
-	ID            uint64    `db:"id"             json:"id"`
-	WalletID      string    `db:"wallet_id"      json:"wallet_id"`
-	TransactionID string    `db:"transaction_id" json:"transaction_id"`
-	Amount        float64   `db:"amount"         json:"amount"`
-	Type          string    `db:"type"           json:"type"`
-	Description   string    `db:"description"    json:"description"`
-	Timestamp     time.Time `db:"timestamp"      json:"timestamp"`
-	CreatedAt     time.Time `db:"created_at"     json:"created_at"`
+	ID            uint64       `db:"id"             json:"id"`
+	WalletID      string       `db:"wallet_id"      json:"wallet_id"`
+	TransactionID string       `db:"transaction_id" json:"transaction_id"`
+	Amount        money.Amount `db:"amount"         json:"amount"`
+	Type          string       `db:"type"           json:"type"`
+	Description   string       `db:"description"    json:"description"`
+	Timestamp     time.Time    `db:"timestamp"      json:"timestamp"`
+	CreatedAt     time.Time    `db:"created_at"     json:"created_at"`
+}
+
+// Wallet hold statuses. A hold is HELD until it is CAPTURED, RELEASED or
+// EXPIRED; all three are final.
+const (
+	WalletHoldHeld     = "HELD"
+	WalletHoldCaptured = "CAPTURED"
+	WalletHoldReleased = "RELEASED"
+	WalletHoldExpired  = "EXPIRED"
+)
+
+// WalletHold reserves part of a wallet for a payment. Amount is in the wallet
+// currency; CapturedAmount is the part of it that was charged when the hold
+// was captured, the rest went back to the balance.
+type WalletHold struct {
+	ID                   uint64       `db:"id"`
+	HoldID               string       `db:"hold_id"`
+	WalletID             string       `db:"wallet_id"`
+	PaymentTransactionID uint64       `db:"payment_transaction_id"`
+	Amount               money.Amount `db:"amount"`
+	CapturedAmount       money.Amount `db:"captured_amount"`
+	Status               string       `db:"status"`
+	ExpiresAt            *time.Time   `db:"expires_at"`
+	CapturedAt           *time.Time   `db:"captured_at"`
+	ReleasedAt           *time.Time   `db:"released_at"`
+	CreatedAt            time.Time    `db:"created_at"`
+	UpdatedAt            time.Time    `db:"updated_at"`
 }
@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+const (
+	WebhookInboxReceived  = "RECEIVED"
+	WebhookInboxRetry     = "RETRY"
+	WebhookInboxProcessed = "PROCESSED"
+	WebhookInboxFailed    = "FAILED"
+	// WebhookInboxRejected marks a payload that failed verification. It does
+	// not hold its dedupe key: a later payload with the same key replaces it.
+	WebhookInboxRejected = "REJECTED"
+)
+
+type WebhookInbox struct {
+	ID                uint64     `db:"id"`
+	DedupeKey         string     `db:"dedupe_key"`
+	OrderID           string     `db:"order_id"`
+	TransactionID     *string    `db:"transaction_id"`
+	TransactionStatus *string    `db:"transaction_status"`
+	Payload           string     `db:"payload"`
+	Status            string     `db:"status"`
+	Attempts          int        `db:"attempts"`
+	LastError         *string    `db:"last_error"`
+	NextAttemptAt     time.Time  `db:"next_attempt_at"`
+	ProcessedAt       *time.Time `db:"processed_at"`
+	CreatedAt         time.Time  `db:"created_at"`
+	UpdatedAt         time.Time  `db:"updated_at"`
+}
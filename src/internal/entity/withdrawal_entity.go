@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
+
+// A withdrawal is REQUESTED until an admin approves or rejects it, then
+// PROCESSING while the payout provider sends it, and ends COMPLETED or
+// FAILED. It is PROCESSING before the provider is called, and has no
+// ProviderReference until the provider answers. Its amount is held in the
+// wallet until it ends.
+const (
+	WithdrawalRequested  = "REQUESTED"
+	WithdrawalProcessing = "PROCESSING"
+	WithdrawalCompleted  = "COMPLETED"
+	WithdrawalFailed     = "FAILED"
+	WithdrawalRejected   = "REJECTED"
+)
+
+type DriverWithdrawal struct {
+	ID                uint64       `db:"id"`
+	WithdrawalID      string       `db:"withdrawal_id"`
+	DriverID          string       `db:"driver_id"`
+	WalletID          string       `db:"wallet_id"`
+	PayoutAccountID   *uint64      `db:"payout_account_id"`
+	Amount            money.Amount `db:"amount"`
+	Fee               money.Amount `db:"fee"`
+	NetAmount         money.Amount `db:"net_amount"`
+	Currency          string       `db:"currency"`
+	BankCode          string       `db:"bank_code"`
+	AccountNumber     string       `db:"account_number"`
+	AccountName       string       `db:"account_name"`
+	Status            string       `db:"status"`
+	Provider          *string      `db:"provider"`
+	ProviderReference *string      `db:"provider_reference"`
+	FailureReason     *string      `db:"failure_reason"`
+	ReviewedBy        *string      `db:"reviewed_by"`
+	ReviewNote        *string      `db:"review_note"`
+	ReviewedAt        *time.Time   `db:"reviewed_at"`
+	CompletedAt       *time.Time   `db:"completed_at"`
+	FailedAt          *time.Time   `db:"failed_at"`
+	CreatedAt         time.Time    `db:"created_at"`
+	UpdatedAt         time.Time    `db:"updated_at"`
+}
@@ -0,0 +1,63 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/src/pkg/money"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const SourceStatic = "STATIC"
+
+// ErrConversionNotAllowed is returned for a currency pair the source has no
+// rate for. Only pairs listed explicitly may be converted.
+var ErrConversionNotAllowed = errors.New("currency conversion not allowed")
+
+type RateSource interface {
+	Name() string
+	// Rate returns what one unit of from is worth in to.
+	Rate(ctx context.Context, from, to string) (money.Rate, error)
+}
+
+// StaticRates serves the rates listed under fx.static.rates, keyed FROM_TO:
+//
+//	fx:
+//	  static:
+//	    rates:
+//	      SGD_IDR: "11650.25"
+//
+// A pair allows that direction only; IDR_SGD has to be listed on its own.
+type StaticRates struct {
+	rates map[string]money.Rate
+}
+
+func NewStaticRates(config *viper.Viper) (*StaticRates, error) {
+	s := &StaticRates{rates: make(map[string]money.Rate)}
+	for key, value := range config.GetStringMapString("fx.static.rates") {
+		pair := strings.SplitN(strings.ToUpper(key), "_", 2)
+		if len(pair) != 2 || !money.Known(pair[0]) || !money.Known(pair[1]) {
+			return nil, fmt.Errorf("fx.static.rates: invalid pair %q", key)
+		}
+		rate, err := money.NewRate(pair[0], pair[1], value)
+		if err != nil {
+			return nil, err
+		}
+		s.rates[rate.Pair()] = rate
+	}
+	return s, nil
+}
+
+func (s *StaticRates) Name() string {
+	return SourceStatic
+}
+
+func (s *StaticRates) Rate(ctx context.Context, from, to string) (money.Rate, error) {
+	rate, ok := s.rates[strings.ToUpper(from)+"/"+strings.ToUpper(to)]
+	if !ok {
+		return money.Rate{}, fmt.Errorf("%w: %s to %s", ErrConversionNotAllowed, strings.ToUpper(from), strings.ToUpper(to))
+	}
+	return rate, nil
+}
@@ -0,0 +1,25 @@
+package messaging
+
+import (
+	"payment-service/src/internal/model"
+	kafka "payment-service/src/pkg/kafka/confluent"
+	"payment-service/src/pkg/log"
+)
+
+type PaymentAlertProducer struct {
+	Producer[*model.PaymentAlertEvent]
+}
+
+func NewPaymentAlertProducer(producer kafka.Producer, topic string, log log.Log) *PaymentAlertProducer {
+	return &PaymentAlertProducer{
+		Producer: Producer[*model.PaymentAlertEvent]{
+			Producer: producer,
+			Topic:    topic,
+			Log:      log,
+		},
+	}
+}
+
+func (p *PaymentAlertProducer) SendAlert(event *model.PaymentAlertEvent) error {
+	return p.Send(event)
+}
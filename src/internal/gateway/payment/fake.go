@@ -0,0 +1,194 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"payment-service/src/internal/model"
+	"payment-service/src/pkg/money"
+	"payment-service/src/pkg/utils"
+	"sync"
+	"time"
+)
+
+// FakeProvider is an in-memory provider for local runs and tests. Charges stay
+// PENDING until a notification for them is posted to the webhook, and
+// notifications use the Midtrans payload shape signed with the fake secret.
+type FakeProvider struct {
+	secret string
+
+	mu           sync.Mutex
+	transactions map[string]*StatusResponse
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{
+		secret:       secret,
+		transactions: make(map[string]*StatusResponse),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *FakeProvider) CreateCharge(ctx context.Context, req *ChargeRequest) (*ChargeResponse, error) {
+	transactionID := utils.GenerateUUID().String()
+	expiry := time.Now().Add(15 * time.Minute)
+
+	p.mu.Lock()
+	p.transactions[req.OrderID] = &StatusResponse{
+		OrderID:           req.OrderID,
+		TransactionID:     transactionID,
+		TransactionStatus: "pending",
+		GrossAmount:       fmt.Sprintf("%.2f", money.New(req.Amount, req.Currency).Major()),
+		Currency:          req.Currency,
+		Status:            "PENDING",
+		EventType:         "PENDING",
+	}
+	p.mu.Unlock()
+
+	charge := &ChargeResponse{
+		ProviderName:  p.Name(),
+		ReferenceID:   transactionID,
+		TransactionID: transactionID,
+		Token:         transactionID,
+		RedirectURL:   fmt.Sprintf("https://fake-payment.local/pay/%s", req.OrderID),
+		QrString:      fmt.Sprintf("FAKEQR|%s|%d", req.OrderID, req.Amount),
+		ExpiryTime:    &expiry,
+		Status:        "PENDING",
+		RawPayload:    utils.ConvertString(req),
+	}
+	if bank, ok := VaBank(req.PaymentMethod); ok {
+		charge.QrString = ""
+		charge.VA = &VirtualAccount{
+			Bank:   bank,
+			Number: fmt.Sprintf("8808%012d", time.Now().UnixNano()%1e12),
+		}
+		if bank == BankMandiri {
+			charge.VA.BillerCode = "70012"
+		}
+	}
+	return charge, nil
+}
+
+func (p *FakeProvider) ParseNotification(ctx context.Context, payload []byte) (*Notification, error) {
+	var notif model.MidtransNotification
+	if err := json.Unmarshal(payload, &notif); err != nil {
+		return nil, fmt.Errorf("invalid fake notification: %w", err)
+	}
+
+	if p.secret != "" {
+		expectedSig := utils.GenerateMidtransSignature(notif.OrderID, notif.StatusCode, notif.GrossAmount, p.secret)
+		if notif.SignatureKey != expectedSig {
+			return nil, &SignatureError{Expected: expectedSig, Got: notif.SignatureKey}
+		}
+	}
+
+	status := mapMidtransStatus(notif.TransactionStatus, notif.FraudStatus)
+	p.mu.Lock()
+	if trx, ok := p.transactions[notif.OrderID]; ok {
+		trx.TransactionStatus = notif.TransactionStatus
+		trx.FraudStatus = notif.FraudStatus
+		trx.Status = status
+		trx.EventType = mapMidtransEventType(notif.TransactionStatus)
+	}
+	p.mu.Unlock()
+
+	return &Notification{
+		OrderID:           notif.OrderID,
+		TransactionID:     notif.TransactionID,
+		TransactionStatus: notif.TransactionStatus,
+		TransactionTime:   notif.TransactionTime,
+		FraudStatus:       notif.FraudStatus,
+		GrossAmount:       notif.GrossAmount,
+		Currency:          notif.Currency,
+		PaymentType:       notif.PaymentType,
+		Status:            status,
+		EventType:         mapMidtransEventType(notif.TransactionStatus),
+		Refunds:           midtransRefunds(notif.Refunds),
+		RawPayload:        string(payload),
+	}, nil
+}
+
+func (p *FakeProvider) QueryStatus(ctx context.Context, reference string) (*StatusResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	trx, ok := p.find(reference)
+	if !ok {
+		return nil, fmt.Errorf("fake transaction %s: %w", reference, ErrTransactionNotFound)
+	}
+	resp := *trx
+	resp.RawPayload = utils.ConvertString(trx)
+	return &resp, nil
+}
+
+func (p *FakeProvider) Cancel(ctx context.Context, reference string) (*StatusResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	trx, ok := p.find(reference)
+	if !ok {
+		return nil, fmt.Errorf("fake transaction %s not found", reference)
+	}
+	trx.TransactionStatus = "cancel"
+	trx.Status = mapMidtransStatus(trx.TransactionStatus, "")
+	trx.EventType = mapMidtransEventType(trx.TransactionStatus)
+	resp := *trx
+	resp.RawPayload = utils.ConvertString(trx)
+	return &resp, nil
+}
+
+func (p *FakeProvider) Approve(ctx context.Context, reference string) (*StatusResponse, error) {
+	return p.review(reference, "settlement", "accept")
+}
+
+func (p *FakeProvider) Deny(ctx context.Context, reference string) (*StatusResponse, error) {
+	return p.review(reference, "deny", "deny")
+}
+
+func (p *FakeProvider) review(reference, transactionStatus, fraudStatus string) (*StatusResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	trx, ok := p.find(reference)
+	if !ok {
+		return nil, fmt.Errorf("fake transaction %s not found", reference)
+	}
+	if trx.FraudStatus != "challenge" {
+		return nil, fmt.Errorf("fake transaction %s is not under review", reference)
+	}
+	trx.TransactionStatus = transactionStatus
+	trx.FraudStatus = fraudStatus
+	trx.Status = mapMidtransStatus(trx.TransactionStatus, trx.FraudStatus)
+	trx.EventType = mapMidtransEventType(trx.TransactionStatus)
+	resp := *trx
+	resp.RawPayload = utils.ConvertString(trx)
+	return &resp, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, reference string, req *RefundRequest) (*RefundResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	trx, ok := p.find(reference)
+	if !ok {
+		return nil, fmt.Errorf("fake transaction %s not found", reference)
+	}
+	return &RefundResponse{
+		RefundKey:     req.RefundKey,
+		TransactionID: trx.TransactionID,
+		Amount:        fmt.Sprintf("%.2f", money.New(req.Amount, trx.Currency).Major()),
+		Status:        "refund",
+		RawPayload:    utils.ConvertString(req),
+	}, nil
+}
+
+func (p *FakeProvider) find(reference string) (*StatusResponse, bool) {
+	if trx, ok := p.transactions[reference]; ok {
+		return trx, true
+	}
+	for _, trx := range p.transactions {
+		if trx.TransactionID == reference {
+			return trx, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,194 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"payment-service/src/pkg/money"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	MethodQris    = "QRIS"
+	MethodEwallet = "EWALLET"
+	// MethodCash is paid to the driver in person; the platform's cut is
+	// taken from the driver's wallet instead.
+	MethodCash = "CASH"
+	// MethodVaPrefix prefixes the bank transfer virtual account methods,
+	// VA_<BANK>.
+	MethodVaPrefix = "VA_"
+)
+
+const (
+	BankBCA     = "BCA"
+	BankBNI     = "BNI"
+	BankBRI     = "BRI"
+	BankMandiri = "MANDIRI"
+	BankPermata = "PERMATA"
+)
+
+var vaBanks = []string{BankBCA, BankBNI, BankBRI, BankMandiri, BankPermata}
+
+// VaMethod returns the virtual account method for bank, e.g. VA_BCA.
+func VaMethod(bank string) (string, bool) {
+	bank = strings.ToUpper(strings.TrimSpace(bank))
+	for _, b := range vaBanks {
+		if b == bank {
+			return MethodVaPrefix + bank, true
+		}
+	}
+	return "", false
+}
+
+// VaBank returns the bank of a virtual account method.
+func VaBank(method string) (string, bool) {
+	method = strings.ToUpper(method)
+	if !strings.HasPrefix(method, MethodVaPrefix) {
+		return "", false
+	}
+	bank := strings.TrimPrefix(method, MethodVaPrefix)
+	if _, ok := VaMethod(bank); !ok {
+		return "", false
+	}
+	return bank, true
+}
+
+var ErrMethodNotAvailable = errors.New("payment method not available")
+
+// Method is one entry of the payment method catalog, configured under
+// payment.methods.<CODE>:
+//
+//	payment:
+//	  methods:
+//	    QRIS:
+//	      display_name: "QRIS"
+//	      enabled: true
+//	      min_amount: 1000
+//	      max_amount: 10000000
+//	      cities: ["Jakarta", "Bandung"]
+//	      vehicle_types: ["motor"]
+//
+// Provider names the payment provider charging the method; empty means
+// payment.provider.<code>. Aliases are other codes orders carry for the same
+// method, e.g. WALLET for EWALLET. Empty cities or vehicle_types mean
+// everywhere and every vehicle; a zero min_amount or max_amount means no
+// bound.
+type Method struct {
+	Code         string       `mapstructure:"-"`
+	DisplayName  string       `mapstructure:"display_name"`
+	Provider     string       `mapstructure:"provider"`
+	Enabled      bool         `mapstructure:"enabled"`
+	MinAmount    money.Amount `mapstructure:"min_amount"`
+	MaxAmount    money.Amount `mapstructure:"max_amount"`
+	Cities       []string     `mapstructure:"cities"`
+	VehicleTypes []string     `mapstructure:"vehicle_types"`
+	Aliases      []string     `mapstructure:"aliases"`
+}
+
+// MethodContext is what a method's availability is decided on. City and
+// VehicleType come from the driver and are empty until one is assigned.
+type MethodContext struct {
+	Amount      money.Amount
+	City        string
+	VehicleType string
+}
+
+// Check reports why the method cannot be used in mc, or nil if it can. A
+// method limited to some cities or vehicle types is unavailable while they
+// are unknown.
+func (m Method) Check(mc MethodContext) error {
+	if !m.Enabled {
+		return fmt.Errorf("%w: %s is disabled", ErrMethodNotAvailable, m.Code)
+	}
+	if m.MinAmount > 0 && mc.Amount < m.MinAmount {
+		return fmt.Errorf("%w: %s needs at least %d", ErrMethodNotAvailable, m.Code, m.MinAmount)
+	}
+	if m.MaxAmount > 0 && mc.Amount > m.MaxAmount {
+		return fmt.Errorf("%w: %s allows at most %d", ErrMethodNotAvailable, m.Code, m.MaxAmount)
+	}
+	if len(m.Cities) > 0 && !containsFold(m.Cities, mc.City) {
+		return fmt.Errorf("%w: %s is not offered in %q", ErrMethodNotAvailable, m.Code, mc.City)
+	}
+	if len(m.VehicleTypes) > 0 && !containsFold(m.VehicleTypes, mc.VehicleType) {
+		return fmt.Errorf("%w: %s is not offered for vehicle %q", ErrMethodNotAvailable, m.Code, mc.VehicleType)
+	}
+	return nil
+}
+
+type MethodCatalog struct {
+	methods map[string]Method
+	aliases map[string]string
+}
+
+func NewMethodCatalog(config *viper.Viper) (*MethodCatalog, error) {
+	var raw map[string]Method
+	if err := config.UnmarshalKey("payment.methods", &raw); err != nil {
+		return nil, fmt.Errorf("payment.methods: %v", err)
+	}
+	c := &MethodCatalog{
+		methods: make(map[string]Method),
+		aliases: make(map[string]string),
+	}
+	for key, method := range raw {
+		method.Code = strings.ToUpper(key)
+		if method.DisplayName == "" {
+			method.DisplayName = method.Code
+		}
+		if method.MaxAmount > 0 && method.MinAmount > method.MaxAmount {
+			return nil, fmt.Errorf("payment.methods.%s: min_amount above max_amount", method.Code)
+		}
+		c.methods[method.Code] = method
+	}
+	for _, method := range c.methods {
+		for _, alias := range method.Aliases {
+			alias = strings.ToUpper(alias)
+			if _, ok := c.methods[alias]; ok {
+				return nil, fmt.Errorf("payment.methods.%s: alias %s is a method of its own", method.Code, alias)
+			}
+			c.aliases[alias] = method.Code
+		}
+	}
+	return c, nil
+}
+
+// Get returns the method for code, resolving aliases.
+func (c *MethodCatalog) Get(code string) (Method, bool) {
+	code = strings.ToUpper(code)
+	if canonical, ok := c.aliases[code]; ok {
+		code = canonical
+	}
+	method, ok := c.methods[code]
+	return method, ok
+}
+
+// Check looks code up and checks it against mc.
+func (c *MethodCatalog) Check(code string, mc MethodContext) (Method, error) {
+	method, ok := c.Get(code)
+	if !ok {
+		return Method{}, fmt.Errorf("%w: unknown method %s", ErrMethodNotAvailable, code)
+	}
+	return method, method.Check(mc)
+}
+
+// Available returns the methods usable in mc, ordered by code.
+func (c *MethodCatalog) Available(mc MethodContext) []Method {
+	var methods []Method
+	for _, method := range c.methods {
+		if method.Check(mc) == nil {
+			methods = append(methods, method)
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Code < methods[j].Code })
+	return methods
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
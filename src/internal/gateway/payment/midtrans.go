@@ -0,0 +1,258 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"payment-service/src/internal/model"
+	"payment-service/src/pkg/utils"
+
+	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/coreapi"
+	"github.com/spf13/viper"
+)
+
+// midtransBase holds the parts shared by every Midtrans product: webhook
+// verification and the transaction status / cancel / refund endpoints.
+type midtransBase struct {
+	serverKey string
+	env       midtrans.EnvironmentType
+}
+
+func newMidtransBase(config *viper.Viper) midtransBase {
+	env := midtrans.Sandbox
+	if config.GetBool("midtrans.is_production") {
+		env = midtrans.Production
+	}
+	return midtransBase{
+		serverKey: config.GetString("midtrans.server_key"),
+		env:       env,
+	}
+}
+
+func (m midtransBase) coreClient() (*coreapi.Client, error) {
+	if m.serverKey == "" {
+		return nil, fmt.Errorf("midtrans server key not configured")
+	}
+	client := coreapi.Client{}
+	client.New(m.serverKey, m.env)
+	return &client, nil
+}
+
+func (m midtransBase) ParseNotification(ctx context.Context, payload []byte) (*Notification, error) {
+	if m.serverKey == "" {
+		return nil, fmt.Errorf("midtrans server key not configured")
+	}
+
+	var notif model.MidtransNotification
+	if err := json.Unmarshal(payload, &notif); err != nil {
+		return nil, fmt.Errorf("invalid midtrans notification: %w", err)
+	}
+
+	expectedSig := utils.GenerateMidtransSignature(
+		notif.OrderID,
+		notif.StatusCode,
+		notif.GrossAmount,
+		m.serverKey,
+	)
+	if notif.SignatureKey != expectedSig {
+		return nil, &SignatureError{Expected: expectedSig, Got: notif.SignatureKey}
+	}
+
+	return &Notification{
+		OrderID:           notif.OrderID,
+		TransactionID:     notif.TransactionID,
+		TransactionStatus: notif.TransactionStatus,
+		TransactionTime:   notif.TransactionTime,
+		FraudStatus:       notif.FraudStatus,
+		GrossAmount:       notif.GrossAmount,
+		Currency:          notif.Currency,
+		PaymentType:       notif.PaymentType,
+		Status:            mapMidtransStatus(notif.TransactionStatus, notif.FraudStatus),
+		EventType:         mapMidtransEventType(notif.TransactionStatus),
+		Refunds:           midtransRefunds(notif.Refunds),
+		RawPayload:        string(payload),
+	}, nil
+}
+
+func (m midtransBase) QueryStatus(ctx context.Context, reference string) (*StatusResponse, error) {
+	client, err := m.coreClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, mErr := client.CheckTransaction(reference)
+	if mErr != nil {
+		if mErr.StatusCode == 404 {
+			return nil, fmt.Errorf("midtrans check transaction %s: %w", reference, ErrTransactionNotFound)
+		}
+		return nil, fmt.Errorf("midtrans check transaction: %w", mErr)
+	}
+	return &StatusResponse{
+		OrderID:           resp.OrderID,
+		TransactionID:     resp.TransactionID,
+		TransactionStatus: resp.TransactionStatus,
+		FraudStatus:       resp.FraudStatus,
+		GrossAmount:       resp.GrossAmount,
+		Currency:          resp.Currency,
+		Status:            mapMidtransStatus(resp.TransactionStatus, resp.FraudStatus),
+		EventType:         mapMidtransEventType(resp.TransactionStatus),
+		Refunds:           midtransRefundDetails(resp.Refunds),
+		RawPayload:        utils.ConvertString(resp),
+	}, nil
+}
+
+func (m midtransBase) Cancel(ctx context.Context, reference string) (*StatusResponse, error) {
+	client, err := m.coreClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, mErr := client.CancelTransaction(reference)
+	if mErr != nil {
+		return nil, fmt.Errorf("midtrans cancel transaction: %w", mErr)
+	}
+	return statusFromCharge(resp), nil
+}
+
+func (m midtransBase) Approve(ctx context.Context, reference string) (*StatusResponse, error) {
+	client, err := m.coreClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, mErr := client.ApproveTransaction(reference)
+	if mErr != nil {
+		return nil, fmt.Errorf("midtrans approve transaction: %w", mErr)
+	}
+	return statusFromCharge(resp), nil
+}
+
+func (m midtransBase) Deny(ctx context.Context, reference string) (*StatusResponse, error) {
+	client, err := m.coreClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, mErr := client.DenyTransaction(reference)
+	if mErr != nil {
+		return nil, fmt.Errorf("midtrans deny transaction: %w", mErr)
+	}
+	return statusFromCharge(resp), nil
+}
+
+// statusFromCharge maps the transaction returned by cancel, approve and deny.
+func statusFromCharge(resp *coreapi.ChargeResponse) *StatusResponse {
+	return &StatusResponse{
+		OrderID:           resp.OrderID,
+		TransactionID:     resp.TransactionID,
+		TransactionStatus: resp.TransactionStatus,
+		FraudStatus:       resp.FraudStatus,
+		GrossAmount:       resp.GrossAmount,
+		Currency:          resp.Currency,
+		Status:            mapMidtransStatus(resp.TransactionStatus, resp.FraudStatus),
+		EventType:         mapMidtransEventType(resp.TransactionStatus),
+		RawPayload:        utils.ConvertString(resp),
+	}
+}
+
+func (m midtransBase) Refund(ctx context.Context, reference string, req *RefundRequest) (*RefundResponse, error) {
+	client, err := m.coreClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, mErr := client.RefundTransaction(reference, &coreapi.RefundReq{
+		RefundKey: req.RefundKey,
+		Amount:    req.Amount.Int64(),
+		Reason:    req.Reason,
+	})
+	if mErr != nil {
+		return nil, fmt.Errorf("midtrans refund transaction: %w", mErr)
+	}
+	return &RefundResponse{
+		RefundKey:     resp.RefundKey,
+		TransactionID: resp.TransactionID,
+		Amount:        resp.RefundAmount,
+		Status:        resp.TransactionStatus,
+		RawPayload:    utils.ConvertString(resp),
+	}, nil
+}
+
+// SignatureError is returned by ParseNotification when the payload signature
+// does not match.
+type SignatureError struct {
+	Expected string
+	Got      string
+}
+
+func (e *SignatureError) Error() string {
+	return "invalid signature"
+}
+
+func midtransRefunds(refunds []model.MidtransRefund) []ProviderRefund {
+	mapped := make([]ProviderRefund, 0, len(refunds))
+	for _, r := range refunds {
+		mapped = append(mapped, ProviderRefund{
+			Key:    midtransRefundKey(r.RefundKey, r.RefundChargebackID),
+			Amount: r.RefundAmount,
+			Reason: r.Reason,
+		})
+	}
+	return mapped
+}
+
+func midtransRefundDetails(refunds []coreapi.RefundDetails) []ProviderRefund {
+	mapped := make([]ProviderRefund, 0, len(refunds))
+	for _, r := range refunds {
+		mapped = append(mapped, ProviderRefund{
+			Key:    midtransRefundKey(r.RefundKey, r.RefundChargebackID),
+			Amount: r.RefundAmount,
+			Reason: r.Reason,
+		})
+	}
+	return mapped
+}
+
+// midtransRefundKey falls back on the chargeback id for a refund made without
+// a refund key, as the dashboard may.
+func midtransRefundKey(key string, chargebackID int) string {
+	if key != "" {
+		return key
+	}
+	return fmt.Sprintf("midtrans-refund-%d", chargebackID)
+}
+
+func mapMidtransStatus(transactionStatus, fraudStatus string) string {
+	switch transactionStatus {
+	case "capture", "settlement":
+		if fraudStatus == "challenge" {
+			return "CHALLENGE"
+		}
+		return "SUCCESS"
+	case "pending":
+		return "PENDING"
+	case "deny", "cancel":
+		return "FAILED"
+	case "expire":
+		return "EXPIRED"
+	case "refund":
+		return "REFUNDED"
+	case "partial_refund":
+		return "PARTIALLY_REFUNDED"
+	default:
+		return "PENDING"
+	}
+}
+
+func mapMidtransEventType(status string) string {
+	switch status {
+	case "capture":
+		return "CALLBACK"
+	case "settlement":
+		return "SUCCESS"
+	case "pending":
+		return "PENDING"
+	case "deny", "cancel", "expire":
+		return "FAILED"
+	case "refund", "partial_refund":
+		return "REFUND"
+	default:
+		return "CALLBACK"
+	}
+}
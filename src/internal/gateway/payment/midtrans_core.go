@@ -0,0 +1,176 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/coreapi"
+	"github.com/spf13/viper"
+)
+
+const midtransTimeLayout = "2006-01-02 15:04:05"
+
+type MidtransCoreProvider struct {
+	midtransBase
+	qrisAcquirer      string
+	qrisExpiryMinutes int
+	vaExpiryMinutes   int
+}
+
+func NewMidtransCoreProvider(config *viper.Viper) *MidtransCoreProvider {
+	return &MidtransCoreProvider{
+		midtransBase:      newMidtransBase(config),
+		qrisAcquirer:      config.GetString("midtrans.qris.acquirer"),
+		qrisExpiryMinutes: config.GetInt("midtrans.qris.expiry_minutes"),
+		vaExpiryMinutes:   config.GetInt("midtrans.va.expiry_minutes"),
+	}
+}
+
+func (p *MidtransCoreProvider) Name() string {
+	return ProviderMidtransCore
+}
+
+func (p *MidtransCoreProvider) CreateCharge(ctx context.Context, req *ChargeRequest) (*ChargeResponse, error) {
+	client, err := p.coreClient()
+	if err != nil {
+		return nil, err
+	}
+	if bank, ok := VaBank(req.PaymentMethod); ok {
+		return p.createVaCharge(client, req, bank)
+	}
+
+	chargeReq := &coreapi.ChargeReq{
+		PaymentType: coreapi.PaymentTypeQris,
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  req.OrderID,
+			GrossAmt: req.Amount.Int64(),
+		},
+		CustomerDetails: &midtrans.CustomerDetails{
+			Email: req.CustomerEmail,
+			FName: req.CustomerName,
+		},
+		Qris: &coreapi.QrisDetails{
+			Acquirer: p.qrisAcquirer,
+		},
+	}
+	if p.qrisExpiryMinutes > 0 {
+		chargeReq.CustomExpiry = &coreapi.CustomExpiry{
+			ExpiryDuration: p.qrisExpiryMinutes,
+			Unit:           "minute",
+		}
+	}
+
+	resp, mErr := client.ChargeTransaction(chargeReq)
+	if mErr != nil {
+		return nil, fmt.Errorf("failed create qris via midtrans core api: %w", mErr)
+	}
+	if resp.QRString == "" {
+		return nil, fmt.Errorf("midtrans core api returned no qr string: %s", resp.StatusMessage)
+	}
+
+	charge := &ChargeResponse{
+		ProviderName:  p.Name(),
+		ReferenceID:   resp.TransactionID,
+		TransactionID: resp.TransactionID,
+		QrString:      resp.QRString,
+		Status:        mapMidtransStatus(resp.TransactionStatus, resp.FraudStatus),
+		RawPayload:    utils.ConvertString(resp),
+	}
+	for _, action := range resp.Actions {
+		if action.Name == "generate-qr-code" {
+			charge.RedirectURL = action.URL
+		}
+	}
+	charge.ExpiryTime = expiryTime(resp, p.qrisExpiryMinutes)
+
+	return charge, nil
+}
+
+// createVaCharge opens a bank_transfer virtual account, or for Mandiri an
+// echannel bill payment.
+func (p *MidtransCoreProvider) createVaCharge(client *coreapi.Client, req *ChargeRequest, bank string) (*ChargeResponse, error) {
+	chargeReq := &coreapi.ChargeReq{
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  req.OrderID,
+			GrossAmt: req.Amount.Int64(),
+		},
+		CustomerDetails: &midtrans.CustomerDetails{
+			Email: req.CustomerEmail,
+			FName: req.CustomerName,
+		},
+	}
+	if bank == BankMandiri {
+		chargeReq.PaymentType = coreapi.PaymentTypeEChannel
+		chargeReq.EChannel = &coreapi.EChannelDetail{
+			BillInfo1: "Order:",
+			BillInfo2: req.OrderID,
+		}
+	} else {
+		chargeReq.PaymentType = coreapi.PaymentTypeBankTransfer
+		chargeReq.BankTransfer = &coreapi.BankTransferDetails{
+			Bank: midtrans.Bank(strings.ToLower(bank)),
+		}
+	}
+	if p.vaExpiryMinutes > 0 {
+		chargeReq.CustomExpiry = &coreapi.CustomExpiry{
+			ExpiryDuration: p.vaExpiryMinutes,
+			Unit:           "minute",
+		}
+	}
+
+	resp, mErr := client.ChargeTransaction(chargeReq)
+	if mErr != nil {
+		return nil, fmt.Errorf("failed create %s virtual account via midtrans core api: %w", bank, mErr)
+	}
+
+	va := &VirtualAccount{Bank: bank}
+	switch bank {
+	case BankMandiri:
+		va.Number = resp.BillKey
+		va.BillerCode = resp.BillerCode
+	case BankPermata:
+		va.Number = resp.PermataVaNumber
+	default:
+		for _, number := range resp.VaNumbers {
+			if strings.EqualFold(number.Bank, bank) {
+				va.Number = number.VANumber
+			}
+		}
+	}
+	if va.Number == "" {
+		return nil, fmt.Errorf("midtrans core api returned no %s virtual account: %s", bank, resp.StatusMessage)
+	}
+
+	return &ChargeResponse{
+		ProviderName:  p.Name(),
+		ReferenceID:   resp.TransactionID,
+		TransactionID: resp.TransactionID,
+		VA:            va,
+		ExpiryTime:    expiryTime(resp, p.vaExpiryMinutes),
+		Status:        mapMidtransStatus(resp.TransactionStatus, resp.FraudStatus),
+		RawPayload:    utils.ConvertString(resp),
+	}, nil
+}
+
+// expiryTime prefers the expiry_time Midtrans reports and falls back to the
+// configured custom expiry counted from the transaction time.
+func expiryTime(resp *coreapi.ChargeResponse, expiryMinutes int) *time.Time {
+	if resp.ExpiryTime != "" {
+		if t, err := time.ParseInLocation(midtransTimeLayout, resp.ExpiryTime, time.Local); err == nil {
+			return &t
+		}
+	}
+	if expiryMinutes <= 0 {
+		return nil
+	}
+	start := time.Now()
+	if t, err := time.ParseInLocation(midtransTimeLayout, resp.TransactionTime, time.Local); err == nil {
+		start = t
+	}
+	expiry := start.Add(time.Duration(expiryMinutes) * time.Minute)
+	return &expiry
+}
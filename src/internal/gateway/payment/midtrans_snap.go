@@ -0,0 +1,74 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/pkg/utils"
+	"time"
+
+	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/snap"
+	"github.com/spf13/viper"
+)
+
+type MidtransSnapProvider struct {
+	midtransBase
+	expiryMinutes int
+}
+
+func NewMidtransSnapProvider(config *viper.Viper) *MidtransSnapProvider {
+	return &MidtransSnapProvider{
+		midtransBase:  newMidtransBase(config),
+		expiryMinutes: config.GetInt("midtrans.snap.expiry_minutes"),
+	}
+}
+
+func (p *MidtransSnapProvider) Name() string {
+	return ProviderMidtransSnap
+}
+
+func (p *MidtransSnapProvider) CreateCharge(ctx context.Context, req *ChargeRequest) (*ChargeResponse, error) {
+	if p.serverKey == "" {
+		return nil, fmt.Errorf("midtrans server key not configured")
+	}
+
+	snapClient := snap.Client{}
+	snapClient.New(p.serverKey, p.env)
+
+	snapReq := &snap.Request{
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  req.OrderID,
+			GrossAmt: req.Amount.Int64(),
+		},
+		CustomerDetail: &midtrans.CustomerDetails{
+			Email: req.CustomerEmail,
+			FName: req.CustomerName,
+		},
+	}
+
+	var expiry *time.Time
+	if p.expiryMinutes > 0 {
+		start := time.Now()
+		end := start.Add(time.Duration(p.expiryMinutes) * time.Minute)
+		expiry = &end
+		snapReq.Expiry = &snap.ExpiryDetails{
+			StartTime: start.Format("2006-01-02 15:04:05 -0700"),
+			Unit:      "minute",
+			Duration:  int64(p.expiryMinutes),
+		}
+	}
+
+	snapResp, err := snapClient.CreateTransaction(snapReq)
+	if snapResp == nil {
+		return nil, fmt.Errorf("failed create qris via midtrans snap: %v", err)
+	}
+
+	return &ChargeResponse{
+		ProviderName: p.Name(),
+		Token:        snapResp.Token,
+		RedirectURL:  snapResp.RedirectURL,
+		ExpiryTime:   expiry,
+		Status:       "PENDING",
+		RawPayload:   utils.ConvertString(snapResp),
+	}, nil
+}
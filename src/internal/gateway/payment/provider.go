@@ -0,0 +1,222 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"payment-service/src/pkg/money"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	ProviderMidtransSnap = "MIDTRANS_SNAP"
+	ProviderMidtransCore = "MIDTRANS_CORE"
+	ProviderFake         = "FAKE"
+)
+
+type ChargeRequest struct {
+	OrderID       string
+	Amount        money.Amount
+	Currency      string
+	PaymentMethod string
+	CustomerName  string
+	CustomerEmail string
+}
+
+// VirtualAccount is where a bank transfer charge is to be paid. Mandiri bill
+// payments are paid to a biller code and bill key; the bill key is kept in
+// Number.
+type VirtualAccount struct {
+	Bank       string
+	Number     string
+	BillerCode string
+}
+
+type ChargeResponse struct {
+	ProviderName  string
+	ReferenceID   string
+	Token         string
+	RedirectURL   string
+	QrString      string
+	VA            *VirtualAccount
+	ExpiryTime    *time.Time
+	Status        string
+	RawPayload    string
+	TransactionID string
+}
+
+// Notification is a provider webhook payload that has already been verified
+// and mapped onto our payment_status values.
+type Notification struct {
+	OrderID           string
+	TransactionID     string
+	TransactionStatus string
+	TransactionTime   string
+	FraudStatus       string
+	GrossAmount       string
+	Currency          string
+	PaymentType       string
+	Status            string
+	EventType         string
+	Refunds           []ProviderRefund
+	RawPayload        string
+}
+
+// ProviderRefund is a refund the provider reports on a transaction. Key is the
+// refund key it was created with: our refund id for refunds we sent, the
+// provider's own for refunds made on its side, e.g. from its dashboard.
+type ProviderRefund struct {
+	Key    string
+	Amount string
+	Reason string
+}
+
+type StatusResponse struct {
+	OrderID           string
+	TransactionID     string
+	TransactionStatus string
+	FraudStatus       string
+	GrossAmount       string
+	Currency          string
+	Status            string
+	EventType         string
+	Refunds           []ProviderRefund
+	RawPayload        string
+}
+
+// Notification lets a status query be applied through the same path as a
+// webhook.
+func (s *StatusResponse) Notification() *Notification {
+	return &Notification{
+		OrderID:           s.OrderID,
+		TransactionID:     s.TransactionID,
+		TransactionStatus: s.TransactionStatus,
+		FraudStatus:       s.FraudStatus,
+		GrossAmount:       s.GrossAmount,
+		Currency:          s.Currency,
+		Status:            s.Status,
+		EventType:         s.EventType,
+		Refunds:           s.Refunds,
+		RawPayload:        s.RawPayload,
+	}
+}
+
+type RefundRequest struct {
+	RefundKey string
+	Amount    money.Amount
+	Reason    string
+}
+
+type RefundResponse struct {
+	RefundKey     string
+	TransactionID string
+	Amount        string
+	Status        string
+	RawPayload    string
+}
+
+// ErrTransactionNotFound is returned by QueryStatus when the provider has no
+// transaction under the reference, as for a Snap page that was never opened.
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+type PaymentProvider interface {
+	Name() string
+	CreateCharge(ctx context.Context, req *ChargeRequest) (*ChargeResponse, error)
+	ParseNotification(ctx context.Context, payload []byte) (*Notification, error)
+	QueryStatus(ctx context.Context, reference string) (*StatusResponse, error)
+	Cancel(ctx context.Context, reference string) (*StatusResponse, error)
+	// Approve and Deny settle a transaction held for fraud review.
+	Approve(ctx context.Context, reference string) (*StatusResponse, error)
+	Deny(ctx context.Context, reference string) (*StatusResponse, error)
+	Refund(ctx context.Context, reference string, req *RefundRequest) (*RefundResponse, error)
+}
+
+type Registry struct {
+	Config    *viper.Viper
+	providers map[string]PaymentProvider
+}
+
+func NewRegistry(config *viper.Viper, providers ...PaymentProvider) *Registry {
+	r := &Registry{
+		Config:    config,
+		providers: make(map[string]PaymentProvider),
+	}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+func (r *Registry) Register(provider PaymentProvider) {
+	r.providers[provider.Name()] = provider
+}
+
+func (r *Registry) Get(name string) (PaymentProvider, error) {
+	provider, ok := r.providers[strings.ToUpper(name)]
+	if !ok {
+		return nil, fmt.Errorf("payment provider %s is not registered", name)
+	}
+	return provider, nil
+}
+
+// ForMethod returns the provider configured under payment.provider.<method>,
+// falling back to payment.provider.default.
+func (r *Registry) ForMethod(method string) (PaymentProvider, error) {
+	name := r.Config.GetString("payment.provider." + strings.ToLower(method))
+	if name == "" {
+		name = r.Config.GetString("payment.provider.default")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no payment provider configured for method %s", method)
+	}
+	return r.Get(name)
+}
+
+// NotificationEnvelope is the unverified identity of a webhook payload, enough
+// to store and deduplicate it before any processing.
+type NotificationEnvelope struct {
+	OrderID           string `json:"order_id"`
+	TransactionID     string `json:"transaction_id"`
+	TransactionStatus string `json:"transaction_status"`
+	FraudStatus       string `json:"fraud_status"`
+}
+
+// DedupeKey identifies one provider event: the same transaction reported in
+// the same state. Without a transaction id the order id stands in.
+func (e *NotificationEnvelope) DedupeKey() string {
+	reference := e.TransactionID
+	if reference == "" {
+		reference = e.OrderID
+	}
+	key := reference + ":" + e.TransactionStatus
+	if e.FraudStatus != "" {
+		key += ":" + e.FraudStatus
+	}
+	return key
+}
+
+func PeekNotification(payload []byte) (*NotificationEnvelope, error) {
+	var envelope NotificationEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid notification payload: %w", err)
+	}
+	if envelope.OrderID == "" {
+		return nil, fmt.Errorf("notification payload has no order_id")
+	}
+	return &envelope, nil
+}
+
+// ExtractOrderID reads the merchant order id from a raw webhook payload so the
+// matching payment row, and with it the provider, can be looked up before the
+// payload is verified.
+func ExtractOrderID(payload []byte) (string, error) {
+	envelope, err := PeekNotification(payload)
+	if err != nil {
+		return "", err
+	}
+	return envelope.OrderID, nil
+}
@@ -0,0 +1,90 @@
+package payout
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/pkg/utils"
+	"strings"
+	"sync"
+)
+
+// FakeProvider is an in-memory payout provider for local runs. Payouts stay
+// PROCESSING until a notification for them is posted to the payout webhook;
+// notifications use the Iris body and signature, keyed with the fake secret.
+// Account inquiries answer with the holder name entered, in capitals as banks
+// return it, except for account numbers starting 000, which do not exist.
+type FakeProvider struct {
+	secret string
+
+	mu      sync.Mutex
+	payouts map[string]*PayoutResponse
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{
+		secret:  secret,
+		payouts: make(map[string]*PayoutResponse),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *FakeProvider) CreatePayout(ctx context.Context, req *PayoutRequest) (*PayoutResponse, error) {
+	payout := &PayoutResponse{
+		ProviderName: p.Name(),
+		ReferenceNo:  "fake-" + utils.GenerateUUID().String(),
+		Status:       StatusProcessing,
+		RawPayload:   utils.ConvertString(req),
+	}
+	p.mu.Lock()
+	p.payouts[payout.ReferenceNo] = payout
+	p.mu.Unlock()
+	resp := *payout
+	return &resp, nil
+}
+
+func (p *FakeProvider) InquireAccount(ctx context.Context, bankCode, accountNumber, holderName string) (*AccountInquiry, error) {
+	if strings.HasPrefix(accountNumber, "000") {
+		return nil, ErrAccountNotFound
+	}
+	return &AccountInquiry{
+		BankCode:      bankCode,
+		AccountNumber: accountNumber,
+		AccountName:   strings.ToUpper(holderName),
+	}, nil
+}
+
+func (p *FakeProvider) ParseNotification(ctx context.Context, payload []byte, signature string) (*Notification, error) {
+	if p.secret != "" {
+		expected := irisSignature(payload, p.secret)
+		if signature != expected {
+			return nil, &SignatureError{Expected: expected, Got: signature}
+		}
+	}
+	notif, err := parseIrisNotification(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if payout, ok := p.payouts[notif.ReferenceNo]; ok {
+		payout.Status = notif.Status
+		payout.FailReason = notif.FailReason
+	}
+	p.mu.Unlock()
+	return notif, nil
+}
+
+func (p *FakeProvider) QueryStatus(ctx context.Context, referenceNo string) (*PayoutResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payout, ok := p.payouts[referenceNo]
+	if !ok {
+		return nil, fmt.Errorf("fake payout %s not found", referenceNo)
+	}
+	resp := *payout
+	resp.RawPayload = utils.ConvertString(payout)
+	return &resp, nil
+}
@@ -0,0 +1,179 @@
+package payout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"payment-service/src/pkg/utils"
+
+	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/iris"
+	"github.com/spf13/viper"
+)
+
+// IrisProvider disburses through Midtrans Iris. Payouts are created with the
+// creator key and, since a withdrawal is only sent once it is approved here,
+// approved straight away with the approver key when one is configured;
+// otherwise they wait for approval in the Iris dashboard.
+type IrisProvider struct {
+	creatorKey  string
+	approverKey string
+	merchantKey string
+	env         midtrans.EnvironmentType
+}
+
+func NewIrisProvider(config *viper.Viper) *IrisProvider {
+	env := midtrans.Sandbox
+	if config.GetBool("midtrans.is_production") {
+		env = midtrans.Production
+	}
+	return &IrisProvider{
+		creatorKey:  config.GetString("payout.iris.creator_key"),
+		approverKey: config.GetString("payout.iris.approver_key"),
+		merchantKey: config.GetString("payout.iris.merchant_key"),
+		env:         env,
+	}
+}
+
+func (p *IrisProvider) Name() string {
+	return ProviderMidtransIris
+}
+
+func (p *IrisProvider) client(key string) (*iris.Client, error) {
+	if key == "" {
+		return nil, fmt.Errorf("midtrans iris key not configured")
+	}
+	client := iris.Client{}
+	client.New(key, p.env)
+	return &client, nil
+}
+
+func (p *IrisProvider) CreatePayout(ctx context.Context, req *PayoutRequest) (*PayoutResponse, error) {
+	creator, err := p.client(p.creatorKey)
+	if err != nil {
+		return nil, &RejectedError{Reason: err.Error()}
+	}
+	resp, mErr := creator.CreatePayout(iris.CreatePayoutReq{
+		Payouts: []iris.CreatePayoutDetailReq{{
+			BeneficiaryName:    req.BeneficiaryName,
+			BeneficiaryAccount: req.BeneficiaryAccount,
+			BeneficiaryBank:    req.BeneficiaryBank,
+			BeneficiaryEmail:   req.BeneficiaryEmail,
+			Amount:             majorAmount(req.Amount, req.Currency),
+			Notes:              req.Notes,
+		}},
+	})
+	if mErr != nil {
+		// a 4xx is a validation or auth failure and creates nothing; timeouts,
+		// throttling and 5xx leave it open whether the payout was made
+		if mErr.StatusCode >= 400 && mErr.StatusCode < 500 && mErr.StatusCode != 408 && mErr.StatusCode != 429 {
+			return nil, &RejectedError{Reason: mErr.Error()}
+		}
+		return nil, fmt.Errorf("midtrans iris create payout: %w", mErr)
+	}
+	if len(resp.Payouts) == 0 {
+		return nil, fmt.Errorf("midtrans iris create payout: %s", resp.ErrorMessage)
+	}
+	payout := &PayoutResponse{
+		ProviderName: p.Name(),
+		ReferenceNo:  resp.Payouts[0].ReferenceNo,
+		Status:       mapIrisStatus(resp.Payouts[0].Status),
+		RawPayload:   utils.ConvertString(resp),
+	}
+
+	if p.approverKey != "" {
+		// the payout exists from here on; if approval fails it waits for
+		// approval in the Iris dashboard
+		approver, err := p.client(p.approverKey)
+		if err != nil {
+			return payout, err
+		}
+		if _, mErr := approver.ApprovePayout(iris.ApprovePayoutReq{ReferenceNo: []string{payout.ReferenceNo}}); mErr != nil {
+			return payout, fmt.Errorf("midtrans iris approve payout %s: %w", payout.ReferenceNo, mErr)
+		}
+	}
+	return payout, nil
+}
+
+func (p *IrisProvider) InquireAccount(ctx context.Context, bankCode, accountNumber, holderName string) (*AccountInquiry, error) {
+	creator, err := p.client(p.creatorKey)
+	if err != nil {
+		return nil, err
+	}
+	resp, mErr := creator.ValidateBankAccount(bankCode, accountNumber)
+	if mErr != nil {
+		if mErr.StatusCode >= 400 && mErr.StatusCode < 500 {
+			return nil, ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("midtrans iris account validation: %w", mErr)
+	}
+	if resp.AccountName == "" {
+		return nil, ErrAccountNotFound
+	}
+	return &AccountInquiry{
+		BankCode:      bankCode,
+		AccountNumber: resp.AccountNo,
+		AccountName:   resp.AccountName,
+		RawPayload:    utils.ConvertString(resp),
+	}, nil
+}
+
+func (p *IrisProvider) ParseNotification(ctx context.Context, payload []byte, signature string) (*Notification, error) {
+	if p.merchantKey == "" {
+		return nil, fmt.Errorf("midtrans iris merchant key not configured")
+	}
+	expected := irisSignature(payload, p.merchantKey)
+	if signature != expected {
+		return nil, &SignatureError{Expected: expected, Got: signature}
+	}
+	return parseIrisNotification(payload)
+}
+
+func (p *IrisProvider) QueryStatus(ctx context.Context, referenceNo string) (*PayoutResponse, error) {
+	creator, err := p.client(p.creatorKey)
+	if err != nil {
+		return nil, err
+	}
+	resp, mErr := creator.GetPayoutDetails(referenceNo)
+	if mErr != nil {
+		return nil, fmt.Errorf("midtrans iris payout details: %w", mErr)
+	}
+	return &PayoutResponse{
+		ProviderName: p.Name(),
+		ReferenceNo:  resp.ReferenceNo,
+		Status:       mapIrisStatus(resp.Status),
+		FailReason:   resp.ErrorMessage,
+		RawPayload:   utils.ConvertString(resp),
+	}, nil
+}
+
+// irisNotification is the body Iris posts when a payout changes state.
+type irisNotification struct {
+	ReferenceNo  string `json:"reference_no"`
+	Amount       string `json:"amount"`
+	Status       string `json:"status"`
+	UpdatedAt    string `json:"updated_at"`
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+func parseIrisNotification(payload []byte) (*Notification, error) {
+	var notif irisNotification
+	if err := json.Unmarshal(payload, &notif); err != nil {
+		return nil, fmt.Errorf("invalid payout notification: %w", err)
+	}
+	if notif.ReferenceNo == "" {
+		return nil, fmt.Errorf("payout notification has no reference_no")
+	}
+	reason := notif.ErrorMessage
+	if notif.ErrorCode != "" {
+		reason = notif.ErrorCode + ": " + reason
+	}
+	return &Notification{
+		ReferenceNo: notif.ReferenceNo,
+		Amount:      notif.Amount,
+		Status:      mapIrisStatus(notif.Status),
+		FailReason:  reason,
+		RawPayload:  string(payload),
+	}, nil
+}
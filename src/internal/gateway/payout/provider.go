@@ -0,0 +1,124 @@
+package payout
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"payment-service/src/pkg/money"
+	"strings"
+)
+
+const (
+	ProviderMidtransIris = "MIDTRANS_IRIS"
+	ProviderFake         = "FAKE"
+)
+
+// Payout states a provider's statuses are mapped onto.
+const (
+	StatusProcessing = "PROCESSING"
+	StatusCompleted  = "COMPLETED"
+	StatusFailed     = "FAILED"
+)
+
+type PayoutRequest struct {
+	WithdrawalID       string
+	Amount             money.Amount
+	Currency           string
+	BeneficiaryName    string
+	BeneficiaryAccount string
+	BeneficiaryBank    string
+	BeneficiaryEmail   string
+	Notes              string
+}
+
+type PayoutResponse struct {
+	ProviderName string
+	ReferenceNo  string
+	Status       string
+	FailReason   string
+	RawPayload   string
+}
+
+// Notification is a payout webhook payload that has already been verified
+// and mapped onto our payout states.
+type Notification struct {
+	ReferenceNo string
+	Amount      string
+	Status      string
+	FailReason  string
+	RawPayload  string
+}
+
+// AccountInquiry is the bank's record of an account, looked up before the
+// account is paid out to.
+type AccountInquiry struct {
+	BankCode      string
+	AccountNumber string
+	AccountName   string
+	RawPayload    string
+}
+
+// ErrAccountNotFound is returned by InquireAccount when the bank has no such
+// account.
+var ErrAccountNotFound = errors.New("bank account not found")
+
+type PayoutProvider interface {
+	Name() string
+	// CreatePayout sends a payout. A *RejectedError means the provider refused
+	// it and nothing was created; after any other error the payout may exist.
+	// When the payout was created but a later step failed, the response is
+	// returned along with the error.
+	CreatePayout(ctx context.Context, req *PayoutRequest) (*PayoutResponse, error)
+	// InquireAccount asks the bank for an account's holder name. holderName is
+	// what the driver entered; only the fake uses it, to answer with.
+	InquireAccount(ctx context.Context, bankCode, accountNumber, holderName string) (*AccountInquiry, error)
+	// ParseNotification verifies a webhook payload against the signature the
+	// provider sent with it.
+	ParseNotification(ctx context.Context, payload []byte, signature string) (*Notification, error)
+	QueryStatus(ctx context.Context, referenceNo string) (*PayoutResponse, error)
+}
+
+// SignatureError is returned by ParseNotification when the payload signature
+// does not match.
+type SignatureError struct {
+	Expected string
+	Got      string
+}
+
+func (e *SignatureError) Error() string {
+	return "invalid signature"
+}
+
+// RejectedError is returned by CreatePayout when the provider refused the
+// payout outright, so no payout exists for it.
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "payout rejected: " + e.Reason
+}
+
+// irisSignature is how Iris signs a notification: SHA-512 of the raw body
+// followed by the merchant key.
+func irisSignature(payload []byte, key string) string {
+	sum := sha512.Sum512(append(append([]byte(nil), payload...), key...))
+	return hex.EncodeToString(sum[:])
+}
+
+func mapIrisStatus(status string) string {
+	switch strings.ToLower(status) {
+	case "completed":
+		return StatusCompleted
+	case "failed", "rejected":
+		return StatusFailed
+	default:
+		return StatusProcessing
+	}
+}
+
+func majorAmount(amount money.Amount, currency string) string {
+	return fmt.Sprintf("%.2f", money.New(amount, currency).Major())
+}
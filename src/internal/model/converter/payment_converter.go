@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/model"
+)
+
+func PaymentToStatusResponse(orderID string, payment *entity.PaymentTransaction, parts []entity.PaymentTransaction, events []entity.PaymentEventLog) *model.PaymentStatusResponse {
+	response := &model.PaymentStatusResponse{
+		OrderID:       orderID,
+		PaymentID:     payment.ID,
+		Amount:        payment.Amount,
+		Currency:      payment.Currency,
+		PaymentMethod: payment.PaymentMethod,
+		PaymentStatus: payment.PaymentStatus,
+		PaidAt:        payment.PaidAt,
+		ExpiredAt:     payment.ExpiredAt,
+		RefundedAt:    payment.RefundedAt,
+		CreatedAt:     payment.CreatedAt,
+		UpdatedAt:     payment.UpdatedAt,
+		Events:        make([]model.PaymentEventResponse, 0, len(events)),
+	}
+	if payment.ProviderName != nil {
+		response.ProviderName = *payment.ProviderName
+	}
+	if payment.ProviderReferenceID != nil {
+		response.ProviderReferenceID = *payment.ProviderReferenceID
+	}
+	for _, part := range parts {
+		partResponse := model.PaymentPartResponse{
+			PaymentID:     part.ID,
+			Amount:        part.Amount,
+			PaymentMethod: part.PaymentMethod,
+			PaymentStatus: part.PaymentStatus,
+			PaidAt:        part.PaidAt,
+			ExpiredAt:     part.ExpiredAt,
+		}
+		if part.ProviderName != nil {
+			partResponse.ProviderName = *part.ProviderName
+		}
+		response.Parts = append(response.Parts, partResponse)
+	}
+	for _, e := range events {
+		response.Events = append(response.Events, model.PaymentEventResponse{
+			EventType:        e.EventType,
+			EventDescription: e.EventDescription,
+			CreatedAt:        e.CreatedAt,
+		})
+	}
+	return response
+}
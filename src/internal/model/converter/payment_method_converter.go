@@ -0,0 +1,16 @@
+package converter
+
+import (
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/model"
+)
+
+func PaymentMethodToResponse(method paymentGateway.Method, provider string) model.PaymentMethodResponse {
+	return model.PaymentMethodResponse{
+		Code:        method.Code,
+		DisplayName: method.DisplayName,
+		Provider:    provider,
+		MinAmount:   method.MinAmount,
+		MaxAmount:   method.MaxAmount,
+	}
+}
@@ -0,0 +1,25 @@
+package converter
+
+import (
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/model"
+)
+
+func PaymentReviewToResponse(review *entity.PaymentReview) *model.PaymentReviewResponse {
+	response := &model.PaymentReviewResponse{
+		ID:         review.ID,
+		PaymentID:  review.PaymentTransactionID,
+		OrderID:    review.OrderID,
+		Reason:     review.Reason,
+		Status:     review.Status,
+		ReviewedAt: review.ReviewedAt,
+		CreatedAt:  review.CreatedAt,
+	}
+	if review.ReviewedBy != nil {
+		response.ReviewedBy = *review.ReviewedBy
+	}
+	if review.ReviewNote != nil {
+		response.ReviewNote = *review.ReviewNote
+	}
+	return response
+}
@@ -0,0 +1,38 @@
+package converter
+
+import (
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/model"
+	"time"
+)
+
+// PayoutAccountToResponse takes the holder name already decrypted.
+func PayoutAccountToResponse(account *entity.PayoutAccount, holderName string) *model.PayoutAccountResponse {
+	response := &model.PayoutAccountResponse{
+		AccountID:      account.AccountID,
+		BankCode:       account.BankCode,
+		AccountNumber:  MaskAccountNumber(account.AccountNumberLast4),
+		HolderName:     holderName,
+		Status:         account.Status,
+		AvailableAfter: account.AvailableAfter,
+		Usable:         account.Usable(time.Now()),
+		CreatedAt:      account.CreatedAt,
+	}
+	if account.RejectionReason != nil {
+		response.RejectionReason = *account.RejectionReason
+	}
+	return response
+}
+
+func MaskAccountNumber(last4 string) string {
+	return "****" + last4
+}
+
+// MaskWithdrawalAccount masks the account number stored on a withdrawal.
+// Withdrawals made before the payout account registry hold the full number.
+func MaskWithdrawalAccount(number string) string {
+	if len(number) > 4 {
+		number = number[len(number)-4:]
+	}
+	return MaskAccountNumber(number)
+}
@@ -0,0 +1,30 @@
+package converter
+
+import (
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/model"
+)
+
+func WebhookInboxToResponse(inbox *entity.WebhookInbox) *model.WebhookInboxResponse {
+	response := &model.WebhookInboxResponse{
+		ID:            inbox.ID,
+		OrderID:       inbox.OrderID,
+		Status:        inbox.Status,
+		Attempts:      inbox.Attempts,
+		Payload:       inbox.Payload,
+		NextAttemptAt: inbox.NextAttemptAt,
+		ProcessedAt:   inbox.ProcessedAt,
+		CreatedAt:     inbox.CreatedAt,
+		UpdatedAt:     inbox.UpdatedAt,
+	}
+	if inbox.TransactionID != nil {
+		response.TransactionID = *inbox.TransactionID
+	}
+	if inbox.TransactionStatus != nil {
+		response.TransactionStatus = *inbox.TransactionStatus
+	}
+	if inbox.LastError != nil {
+		response.LastError = *inbox.LastError
+	}
+	return response
+}
@@ -0,0 +1,32 @@
+package converter
+
+import (
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/model"
+)
+
+func WithdrawalToResponse(w *entity.DriverWithdrawal) *model.WithdrawalResponse {
+	response := &model.WithdrawalResponse{
+		ID:            w.ID,
+		WithdrawalID:  w.WithdrawalID,
+		DriverID:      w.DriverID,
+		Amount:        w.Amount,
+		Fee:           w.Fee,
+		NetAmount:     w.NetAmount,
+		Currency:      w.Currency,
+		BankCode:      w.BankCode,
+		AccountNumber: MaskWithdrawalAccount(w.AccountNumber),
+		AccountName:   w.AccountName,
+		Status:        w.Status,
+		ReviewedAt:    w.ReviewedAt,
+		CompletedAt:   w.CompletedAt,
+		CreatedAt:     w.CreatedAt,
+	}
+	if w.FailureReason != nil {
+		response.FailureReason = *w.FailureReason
+	}
+	if w.ReviewNote != nil {
+		response.ReviewNote = *w.ReviewNote
+	}
+	return response
+}
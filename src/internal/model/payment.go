@@ -1,28 +1,56 @@
 package model
 
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
+
 type CreateQrisPaymentRequest struct {
 	OrderID string `json:"orderId" validate:"required"`
 	UserID  string `json:"userId" validate:"required"`
+	Mode    string `json:"mode" validate:"omitempty,oneof=snap qris"`
+
+	IdempotencyKey string `json:"-"`
+}
+
+type CreateVaPaymentRequest struct {
+	OrderID string `json:"orderId" validate:"required"`
+	UserID  string `json:"userId" validate:"required"`
+	Bank    string `json:"bank" validate:"required"`
+
+	IdempotencyKey string `json:"-"`
 }
 
 type QrisSnapPaymentResponse struct {
-	OrderID       string  `json:"order_id"`
-	Amount        float64 `json:"amount"`
-	SnapToken     string  `json:"snap_token"`
-	RedirectURL   string  `json:"redirect_url"`
-	TransactionID string  `json:"transaction_id,omitempty"`
-	Status        string  `json:"status,omitempty"`
+	OrderID       string       `json:"order_id"`
+	Amount        money.Amount `json:"amount"`
+	SnapToken     string       `json:"snap_token"`
+	RedirectURL   string       `json:"redirect_url"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	Status        string       `json:"status,omitempty"`
 }
 
 type QrisPaymentResponse struct {
-	OrderID            string  `json:"order_id"`
-	Amount             float64 `json:"amount"`
-	PaymentURL         string  `json:"payment_url,omitempty"`
-	QrString           string  `json:"qr_string,omitempty"`
-	TransactionID      string  `json:"transaction_id"`
-	TransactionStatus  string  `json:"transaction_status"`
-	ExpiryTime         string  `json:"expiry_time,omitempty"`
-	PaymentProviderRef string  `json:"payment_provider_ref,omitempty"`
+	OrderID            string       `json:"order_id"`
+	Amount             money.Amount `json:"amount"`
+	PaymentURL         string       `json:"payment_url,omitempty"`
+	QrString           string       `json:"qr_string,omitempty"`
+	TransactionID      string       `json:"transaction_id"`
+	TransactionStatus  string       `json:"transaction_status"`
+	ExpiryTime         string       `json:"expiry_time,omitempty"`
+	PaymentProviderRef string       `json:"payment_provider_ref,omitempty"`
+}
+
+type VaPaymentResponse struct {
+	OrderID           string       `json:"order_id"`
+	Amount            money.Amount `json:"amount"`
+	PaymentMethod     string       `json:"payment_method"`
+	Bank              string       `json:"bank"`
+	VaNumber          string       `json:"va_number"`
+	BillerCode        string       `json:"biller_code,omitempty"`
+	TransactionID     string       `json:"transaction_id"`
+	TransactionStatus string       `json:"transaction_status"`
+	ExpiryTime        string       `json:"expiry_time,omitempty"`
 }
 
 type MidtransNotification struct {
@@ -37,5 +65,94 @@ type MidtransNotification struct {
 	OrderID           string `json:"order_id"`
 	MerchantID        string `json:"merchant_id"`
 	GrossAmount       string `json:"gross_amount"`
+	Currency          string `json:"currency"`
 	FraudStatus       string `json:"fraud_status"`
+	// the refunds on the transaction, sent with refund and partial_refund
+	Refunds []MidtransRefund `json:"refunds,omitempty"`
+}
+
+type MidtransRefund struct {
+	RefundChargebackID int    `json:"refund_chargeback_id"`
+	RefundAmount       string `json:"refund_amount"`
+	Reason             string `json:"reason"`
+	RefundKey          string `json:"refund_key"`
+}
+
+type GetPaymentStatusRequest struct {
+	OrderID string `json:"orderId" validate:"required"`
+	UserID  string `json:"userId" validate:"required"`
+}
+
+type PaymentEventResponse struct {
+	EventType        string    `json:"event_type"`
+	EventDescription string    `json:"event_description,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type PaymentStatusResponse struct {
+	OrderID             string                 `json:"order_id"`
+	PaymentID           uint64                 `json:"payment_id"`
+	Amount              money.Amount           `json:"amount"`
+	Currency            string                 `json:"currency"`
+	PaymentMethod       string                 `json:"payment_method"`
+	PaymentStatus       string                 `json:"payment_status"`
+	ProviderName        string                 `json:"provider_name,omitempty"`
+	ProviderReferenceID string                 `json:"provider_reference_id,omitempty"`
+	PaidAt              *time.Time             `json:"paid_at,omitempty"`
+	ExpiredAt           *time.Time             `json:"expired_at,omitempty"`
+	RefundedAt          *time.Time             `json:"refunded_at,omitempty"`
+	CreatedAt           time.Time              `json:"created_at"`
+	UpdatedAt           time.Time              `json:"updated_at"`
+	Parts               []PaymentPartResponse  `json:"parts,omitempty"`
+	Events              []PaymentEventResponse `json:"events"`
+}
+
+// PaymentPartResponse is one leg of a split payment.
+type PaymentPartResponse struct {
+	PaymentID     uint64       `json:"payment_id"`
+	Amount        money.Amount `json:"amount"`
+	PaymentMethod string       `json:"payment_method"`
+	PaymentStatus string       `json:"payment_status"`
+	ProviderName  string       `json:"provider_name,omitempty"`
+	PaidAt        *time.Time   `json:"paid_at,omitempty"`
+	ExpiredAt     *time.Time   `json:"expired_at,omitempty"`
+}
+
+type ListPaymentMethodsRequest struct {
+	OrderID string `json:"orderId" validate:"required"`
+	UserID  string `json:"userId" validate:"required"`
+}
+
+type PaymentMethodResponse struct {
+	Code        string       `json:"code"`
+	DisplayName string       `json:"display_name"`
+	Provider    string       `json:"provider,omitempty"`
+	MinAmount   money.Amount `json:"min_amount,omitempty"`
+	MaxAmount   money.Amount `json:"max_amount,omitempty"`
+}
+
+type PaymentMethodsResponse struct {
+	OrderID  string                  `json:"order_id"`
+	Amount   money.Amount            `json:"amount"`
+	Currency string                  `json:"currency"`
+	Methods  []PaymentMethodResponse `json:"methods"`
+}
+
+type TipRequest struct {
+	OrderID string       `json:"orderId" validate:"required"`
+	UserID  string       `json:"userId" validate:"required"`
+	Amount  money.Amount `json:"amount" validate:"required"`
+	Method  string       `json:"method" validate:"required"`
+	Mode    string       `json:"mode" validate:"omitempty,oneof=snap qris"`
+
+	IdempotencyKey string `json:"-"`
+}
+
+type TipResponse struct {
+	OrderID       string       `json:"order_id"`
+	DriverID      string       `json:"driver_id"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"`
+	PaymentMethod string       `json:"payment_method"`
+	Status        string       `json:"status"`
 }
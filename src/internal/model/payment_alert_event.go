@@ -0,0 +1,19 @@
+package model
+
+type PaymentAlertEvent struct {
+	ID               string `json:"id"`
+	AlertType        string `json:"alert_type"`
+	PaymentID        uint64 `json:"payment_id"`
+	OrderID          string `json:"order_id"`
+	ProviderName     string `json:"provider_name,omitempty"`
+	ExpectedAmount   string `json:"expected_amount"`
+	ExpectedCurrency string `json:"expected_currency"`
+	NotifiedAmount   string `json:"notified_amount"`
+	NotifiedCurrency string `json:"notified_currency,omitempty"`
+	Reason           string `json:"reason"`
+	CreatedAt        int64  `json:"created_at"`
+}
+
+func (e *PaymentAlertEvent) GetId() string {
+	return e.ID
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+type ListPaymentReviewRequest struct {
+	Status string `query:"status"`
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
+
+type DecidePaymentReviewRequest struct {
+	ID         uint64 `json:"-" params:"id"`
+	ReviewedBy string `json:"reviewedBy" validate:"required"`
+	Note       string `json:"note" validate:"max=255"`
+	Approve    bool   `json:"-"`
+}
+
+type PaymentReviewResponse struct {
+	ID            uint64     `json:"id"`
+	PaymentID     uint64     `json:"payment_id"`
+	OrderID       string     `json:"order_id"`
+	Reason        string     `json:"reason"`
+	Status        string     `json:"status"`
+	PaymentStatus string     `json:"payment_status,omitempty"`
+	ReviewedBy    string     `json:"reviewed_by,omitempty"`
+	ReviewNote    string     `json:"review_note,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
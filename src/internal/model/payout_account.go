@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type PayoutAccountRequest struct {
+	DriverID      string `json:"-"`
+	AccountID     string `json:"-" params:"id"`
+	BankCode      string `json:"bankCode"`
+	AccountNumber string `json:"accountNumber"`
+	HolderName    string `json:"holderName"`
+}
+
+// PayoutAccountResponse shows only the last digits of the account number.
+// Withdrawals can be sent to the account when Usable is set; a verified
+// account in its cooling-off period becomes usable at AvailableAfter.
+type PayoutAccountResponse struct {
+	AccountID       string     `json:"account_id"`
+	BankCode        string     `json:"bank_code"`
+	AccountNumber   string     `json:"account_number"`
+	HolderName      string     `json:"holder_name"`
+	Status          string     `json:"status"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	AvailableAfter  *time.Time `json:"available_after,omitempty"`
+	Usable          bool       `json:"usable"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
+
+type RefundPaymentRequest struct {
+	OrderID    string       `json:"orderId" validate:"required"`
+	Amount     money.Amount `json:"amount" validate:"gte=0"`
+	ReasonCode string       `json:"reasonCode" validate:"required"`
+	ReasonNote string       `json:"reasonNote" validate:"max=255"`
+	UserID     string       `json:"-"`
+}
+
+type RefundPaymentResponse struct {
+	RefundID             string       `json:"refund_id"`
+	OrderID              string       `json:"order_id"`
+	PaymentID            uint64       `json:"payment_id"`
+	Amount               money.Amount `json:"amount"`
+	Currency             string       `json:"currency"`
+	ReasonCode           string       `json:"reason_code"`
+	RefundMethod         string       `json:"refund_method"`
+	Status               string       `json:"status"`
+	PaymentStatus        string       `json:"payment_status"`
+	RemainingAmount      money.Amount `json:"remaining_amount"`
+	DriverReversalAmount money.Amount `json:"driver_reversal_amount"`
+	CreatedAt            time.Time    `json:"created_at"`
+}
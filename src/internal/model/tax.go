@@ -0,0 +1,22 @@
+package model
+
+import "payment-service/src/pkg/money"
+
+type WithholdingExportRequest struct {
+	Period   string `query:"period"`
+	DriverID string `query:"driver_id"`
+	Format   string `query:"format"`
+}
+
+// WithholdingSlipResponse is one driver's month of PPh withholding, the
+// figures a bukti potong is issued for. Refund reversals are netted in.
+type WithholdingSlipResponse struct {
+	DriverID    string       `json:"driver_id"`
+	DriverName  string       `json:"driver_name"`
+	Period      string       `json:"period"`
+	Trips       int          `json:"trips"`
+	GrossIncome money.Amount `json:"gross_income"`
+	TaxBase     money.Amount `json:"tax_base"`
+	PPhWithheld money.Amount `json:"pph_withheld"`
+	PPNCharged  money.Amount `json:"ppn_charged"`
+}
@@ -20,3 +20,18 @@ type NotificationUser struct {
 	PassengerID string    `json:"passangerId"`
 	Timestamp   time.Time `json:"timestamp"`
 }
+
+// OrderCancelledEvent is published by the order service when an order is
+// cancelled. PreviousStatus is the order status it was cancelled from, e.g.
+// ON_GOING once the passenger was picked up; CancelledBy is PASSENGER, DRIVER
+// or SYSTEM.
+type OrderCancelledEvent struct {
+	EventType      string    `json:"eventType"`
+	OrderID        string    `json:"orderId"`
+	DriverID       string    `json:"driverId"`
+	PassengerID    string    `json:"passangerId"`
+	PreviousStatus string    `json:"previousStatus"`
+	CancelledBy    string    `json:"cancelledBy"`
+	Reason         string    `json:"reason"`
+	Timestamp      time.Time `json:"timestamp"`
+}
@@ -1,23 +1,32 @@
 package model
 
-import "time"
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
 
 type WalletRequest struct {
-	UserID string `json:"userId"`
-	Amount int64  `json:"amount"`
+	UserID   string       `json:"userId"`
+	Amount   money.Amount `json:"amount"`
+	Currency string       `json:"currency"`
 }
 
 type WalletTransactionHistory struct {
-	TransactionID string    `json:"transaction_id"`
-	Amount        float64   `json:"amount"`
-	Type          string    `json:"type"` // credit / debit
-	Description   string    `json:"description"`
-	Timestamp     time.Time `json:"timestamp"`
+	TransactionID string       `json:"transaction_id"`
+	Amount        money.Amount `json:"amount"`
+	Type          string       `json:"type"` // credit / debit
+	Description   string       `json:"description"`
+	Timestamp     time.Time    `json:"timestamp"`
 }
 
+// WalletResponse shows the available balance and, apart from it, what open
+// wallet holds have reserved.
 type WalletResponse struct {
 	UserID       string                     `json:"user_id"`
-	Balance      float64                    `json:"balance"`
+	Balance      money.Amount               `json:"balance"`
+	HeldBalance  money.Amount               `json:"held_balance"`
+	Currency     string                     `json:"currency"`
+	Debt         money.Amount               `json:"debt,omitempty"`
 	Transactions []WalletTransactionHistory `json:"transactions"`
 }
 
@@ -31,6 +40,7 @@ type OrderNotificationMessage struct {
 	DriverID    string `json:"driverId"`
 	PassengerID string `json:"passangerId"`
 	OrderID     string `json:"orderId"`
+	ServiceTier string `json:"serviceTier,omitempty"`
 }
 
 type OrderNotificationEvent struct {
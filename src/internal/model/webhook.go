@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+type ListWebhookInboxRequest struct {
+	Status string `query:"status"`
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
+
+type ReplayWebhookRequest struct {
+	ID uint64 `params:"id"`
+}
+
+type WebhookInboxResponse struct {
+	ID                uint64     `json:"id"`
+	OrderID           string     `json:"order_id"`
+	TransactionID     string     `json:"transaction_id,omitempty"`
+	TransactionStatus string     `json:"transaction_status,omitempty"`
+	Status            string     `json:"status"`
+	Attempts          int        `json:"attempts"`
+	LastError         string     `json:"last_error,omitempty"`
+	Payload           string     `json:"payload"`
+	NextAttemptAt     time.Time  `json:"next_attempt_at"`
+	ProcessedAt       *time.Time `json:"processed_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
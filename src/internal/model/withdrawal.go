@@ -0,0 +1,56 @@
+package model
+
+import (
+	"payment-service/src/pkg/money"
+	"time"
+)
+
+type WithdrawalRequest struct {
+	DriverID        string       `json:"-"`
+	Amount          money.Amount `json:"amount"`
+	PayoutAccountID string       `json:"payoutAccountId"`
+}
+
+type ListWithdrawalRequest struct {
+	DriverID string `json:"-"`
+	Status   string `query:"status"`
+	Limit    int    `query:"limit"`
+	Offset   int    `query:"offset"`
+}
+
+type DecideWithdrawalRequest struct {
+	ID         uint64 `json:"-" params:"id"`
+	ReviewedBy string `json:"reviewedBy" validate:"required"`
+	Note       string `json:"note" validate:"max=255"`
+	Approve    bool   `json:"-"`
+}
+
+// ReconcileWithdrawalRequest settles a PROCESSING withdrawal with the
+// provider's status of its payout. ReferenceNo attaches the payout found in
+// the provider's dashboard to a withdrawal whose payout call never returned
+// one.
+type ReconcileWithdrawalRequest struct {
+	ID          uint64 `json:"-" params:"id"`
+	ReferenceNo string `json:"referenceNo"`
+}
+
+// WithdrawalResponse shows a withdrawal; the bank receives NetAmount, the
+// amount less the fee. AccountNumber is masked.
+type WithdrawalResponse struct {
+	ID            uint64       `json:"id"`
+	WithdrawalID  string       `json:"withdrawal_id"`
+	DriverID      string       `json:"driver_id"`
+	Amount        money.Amount `json:"amount"`
+	Fee           money.Amount `json:"fee"`
+	NetAmount     money.Amount `json:"net_amount"`
+	Currency      string       `json:"currency"`
+	BankCode      string       `json:"bank_code"`
+	AccountNumber string       `json:"account_number"`
+	AccountName   string       `json:"account_name"`
+	Status        string       `json:"status"`
+	FailureReason string       `json:"failure_reason,omitempty"`
+	ReviewNote    string       `json:"review_note,omitempty"`
+	ReviewedAt    *time.Time   `json:"reviewed_at,omitempty"`
+	CompletedAt   *time.Time   `json:"completed_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
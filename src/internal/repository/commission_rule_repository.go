@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"payment-service/src/internal/entity"
+	"payment-service/src/pkg/databases/mysql"
+	"time"
+)
+
+type CommissionRuleRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewCommissionRuleRepository(db mysql.DBInterface) *CommissionRuleRepository {
+	return &CommissionRuleRepository{DB: db}
+}
+
+// FindEffectiveRules returns every rule version in effect at.
+func (r *CommissionRuleRepository) FindEffectiveRules(ctx context.Context, at time.Time) ([]entity.CommissionRule, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []entity.CommissionRule
+	query := `
+		SELECT *
+		FROM commission_rules
+		WHERE effective_from <= ? AND (effective_to IS NULL OR effective_to > ?)
+		ORDER BY rule_code, version DESC
+	`
+	if err := db.SelectContext(ctx, &rules, query, at, at); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SaveOrderServiceTier records the service tier an order event carried.
+func (r *CommissionRuleRepository) SaveOrderServiceTier(ctx context.Context, orderID, serviceTier string) error {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO payment_order_service_tiers (order_id, service_tier)
+		VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE service_tier = VALUES(service_tier)
+	`
+	_, err = db.ExecContext(ctx, query, orderID, serviceTier)
+	return err
+}
+
+// FindOrderServiceTier returns the recorded service tier of an order, or ""
+// when none was recorded.
+func (r *CommissionRuleRepository) FindOrderServiceTier(ctx context.Context, orderID string) (string, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return "", err
+	}
+
+	var serviceTier string
+	query := `
+		SELECT service_tier
+		FROM payment_order_service_tiers
+		WHERE order_id = ?
+	`
+	if err := db.GetContext(ctx, &serviceTier, query, orderID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return serviceTier, nil
+}
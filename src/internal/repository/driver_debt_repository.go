@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"payment-service/src/internal/entity"
+	"payment-service/src/pkg/databases/mysql"
+	"payment-service/src/pkg/money"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type DriverDebtRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewDriverDebtRepository(db mysql.DBInterface) *DriverDebtRepository {
+	return &DriverDebtRepository{DB: db}
+}
+
+func (r *DriverDebtRepository) GetDebtByDriverID(ctx context.Context, driverID string) (*entity.DriverDebt, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var d entity.DriverDebt
+	query := `
+		SELECT *
+		FROM driver_debts
+		WHERE driver_id = ?
+		LIMIT 1
+	`
+	if err := db.GetContext(ctx, &d, query, driverID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *DriverDebtRepository) GetDebtForUpdate(ctx context.Context, tx *sqlx.Tx, driverID string) (*entity.DriverDebt, error) {
+	var d entity.DriverDebt
+	query := `
+		SELECT *
+		FROM driver_debts
+		WHERE driver_id = ?
+		FOR UPDATE
+	`
+	if err := tx.GetContext(ctx, &d, query, driverID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *DriverDebtRepository) InsertDebt(ctx context.Context, tx *sqlx.Tx, d *entity.DriverDebt) error {
+	query := `
+		INSERT INTO driver_debts (driver_id, currency, outstanding, blocked_at)
+		VALUES (?, ?, ?, ?)
+	`
+	_, err := tx.ExecContext(ctx, query, d.DriverID, d.Currency, d.Outstanding, d.BlockedAt)
+	return err
+}
+
+func (r *DriverDebtRepository) UpdateDebt(ctx context.Context, tx *sqlx.Tx, driverID string, outstanding money.Amount, blockedAt *time.Time) error {
+	query := `
+		UPDATE driver_debts
+		SET outstanding = ?, blocked_at = ?
+		WHERE driver_id = ?
+	`
+	_, err := tx.ExecContext(ctx, query, outstanding, blockedAt, driverID)
+	return err
+}
+
+func (r *DriverDebtRepository) InsertEntry(ctx context.Context, tx *sqlx.Tx, e *entity.DriverDebtEntry) error {
+	query := `
+		INSERT INTO driver_debt_entries (
+			driver_id, payment_transaction_id, entry_type, source, amount, outstanding_after, description
+		) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	res, err := tx.ExecContext(ctx, query,
+		e.DriverID, e.PaymentTransactionID, e.EntryType, e.Source, e.Amount, e.OutstandingAfter, e.Description,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	e.ID = uint64(id)
+	return nil
+}
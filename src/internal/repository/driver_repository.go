@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"payment-service/src/internal/entity"
 	"payment-service/src/pkg/databases/mysql"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type DriverRepository struct {
@@ -117,6 +120,10 @@ func (r *DriverRepository) SetOnTrip(ctx context.Context, driverID string) error
 	return nil
 }
 
+// ErrDriverBlockedForDebt is returned by SetOnline while the driver's debt
+// is above the credit limit.
+var ErrDriverBlockedForDebt = errors.New("driver is blocked until their debt is paid down")
+
 func (r *DriverRepository) SetOnline(ctx context.Context, driverID string) error {
 	db, err := r.DB.GetDB()
 	if err != nil {
@@ -129,8 +136,49 @@ func (r *DriverRepository) SetOnline(ctx context.Context, driverID string) error
 		    status = 'online',
 		    last_seen_at = NOW()
 		WHERE driver_id = ?
+		  AND NOT EXISTS (
+			SELECT 1 FROM driver_debts
+			WHERE driver_debts.driver_id = driver_availability.driver_id
+			  AND driver_debts.blocked_at IS NOT NULL
+		  )
 	`
 
-	_, err = db.ExecContext(ctx, q, driverID)
-	return err
+	res, err := db.ExecContext(ctx, q, driverID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed get rows affected: %w", err)
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var blocked int
+	blockedQ := `SELECT COUNT(1) FROM driver_debts WHERE driver_id = ? AND blocked_at IS NOT NULL`
+	if err := db.GetContext(ctx, &blocked, blockedQ, driverID); err != nil {
+		return fmt.Errorf("failed get driver debt: %w", err)
+	}
+	if blocked > 0 {
+		return ErrDriverBlockedForDebt
+	}
+	return nil
+}
+
+// SetOfflineTx takes the driver off the road in tx. A driver on a trip is
+// left to finish it; SetOnline refuses them afterwards.
+func (r *DriverRepository) SetOfflineTx(ctx context.Context, tx *sqlx.Tx, driverID string) error {
+	q := `
+		UPDATE driver_availability
+		SET is_available = 0,
+		    status = 'offline',
+		    last_seen_at = NOW()
+		WHERE driver_id = ?
+		  AND status <> 'on_trip'
+	`
+	if _, err := tx.ExecContext(ctx, q, driverID); err != nil {
+		return fmt.Errorf("failed update driver_availability: %w", err)
+	}
+	return nil
 }
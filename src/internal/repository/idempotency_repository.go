@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"payment-service/src/internal/entity"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const idempotencyKeyPrefix = "idempotency:"
+
+type IdempotencyRepository struct {
+	Redis redis.UniversalClient
+}
+
+func NewIdempotencyRepository(redisClient redis.UniversalClient) *IdempotencyRepository {
+	return &IdempotencyRepository{Redis: redisClient}
+}
+
+// Reserve claims key for requestHash. When the key is already taken it returns
+// the stored record and reserved=false.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*entity.IdempotencyRecord, bool, error) {
+	record := &entity.IdempotencyRecord{
+		RequestHash: requestHash,
+		Status:      entity.IdempotencyStatusInProgress,
+		CreatedAt:   time.Now(),
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, err := r.Redis.SetNX(ctx, idempotencyKeyPrefix+key, value, ttl).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		return record, true, nil
+	}
+
+	raw, err := r.Redis.Get(ctx, idempotencyKeyPrefix+key).Bytes()
+	if err == redis.Nil {
+		// expired between SETNX and GET, try once more
+		ok, err = r.Redis.SetNX(ctx, idempotencyKeyPrefix+key, value, ttl).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		return record, ok, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var existing entity.IdempotencyRecord
+	if err := json.Unmarshal(raw, &existing); err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (r *IdempotencyRepository) Complete(ctx context.Context, key, requestHash string, response interface{}, ttl time.Duration) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	record := &entity.IdempotencyRecord{
+		RequestHash: requestHash,
+		Status:      entity.IdempotencyStatusCompleted,
+		Response:    body,
+		CreatedAt:   time.Now(),
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return r.Redis.Set(ctx, idempotencyKeyPrefix+key, value, ttl).Err()
+}
+
+func (r *IdempotencyRepository) Release(ctx context.Context, key string) error {
+	return r.Redis.Del(ctx, idempotencyKeyPrefix+key).Err()
+}
@@ -59,6 +59,10 @@ func (r *OrderRepository) FindOrders(ctx context.Context, f entity.OrderFilter)
 		args  []interface{}
 	)
 
+	if f.ID != nil {
+		conds = append(conds, "o.id = ?")
+		args = append(args, *f.ID)
+	}
 	if f.OrderID != nil {
 		conds = append(conds, "o.order_id = ?")
 		args = append(args, *f.OrderID)
@@ -454,6 +458,12 @@ func (r *OrderRepository) CompleteTrip(ctx context.Context, orderID, driverID st
 	return rows > 0, nil
 }
 
+func (r *OrderRepository) LockOrderTx(ctx context.Context, tx *sql.Tx, id uint64) error {
+	var lockedID uint64
+	query := `SELECT id FROM orders WHERE id = ? FOR UPDATE`
+	return tx.QueryRowContext(ctx, query, id).Scan(&lockedID)
+}
+
 func defaultString(s, def string) string {
 	if s == "" {
 		return def
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"payment-service/src/internal/entity"
 	"payment-service/src/pkg/databases/mysql"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -22,12 +23,16 @@ func (r *PaymentRepository) InsertPaymentTransactionTx(
 	tx *sqlx.Tx,
 	p *entity.PaymentTransaction,
 ) (uint64, error) {
+	if !entity.CanTransitionPayment("", p.PaymentStatus) {
+		return 0, &entity.PaymentTransitionError{From: "", To: p.PaymentStatus}
+	}
 	query := `
 		INSERT INTO payment_transactions (
 			ride_order_id,
 			passenger_id,
 			driver_id,
 			amount,
+			discount_amount,
 			currency,
 			payment_method,
 			payment_status,
@@ -36,14 +41,22 @@ func (r *PaymentRepository) InsertPaymentTransactionTx(
 			provider_signature,
 			paid_at,
 			expired_at,
-			metadata
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			metadata,
+			parent_payment_id,
+			payment_type,
+			charge_order_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
+	paymentType := p.PaymentType
+	if paymentType == "" {
+		paymentType = entity.PaymentTypeTrip
+	}
 	res, err := tx.ExecContext(ctx, query,
 		p.RideOrderID,
 		p.PassengerID,
 		p.DriverID,
 		p.Amount,
+		p.DiscountAmount,
 		p.Currency,
 		p.PaymentMethod,
 		p.PaymentStatus,
@@ -53,6 +66,9 @@ func (r *PaymentRepository) InsertPaymentTransactionTx(
 		p.PaidAt,
 		p.ExpiredAt,
 		p.Metadata,
+		p.ParentPaymentID,
+		paymentType,
+		p.ChargeOrderID,
 	)
 	if err != nil {
 		return 0, err
@@ -70,6 +86,7 @@ func (r *PaymentRepository) FindPendingPaymentByOrder(ctx context.Context, tx *s
 			passenger_id,
 			driver_id,
 			amount,
+			discount_amount,
 			currency,
 			payment_method,
 			payment_status,
@@ -80,10 +97,13 @@ func (r *PaymentRepository) FindPendingPaymentByOrder(ctx context.Context, tx *s
 			expired_at,
 			refunded_at,
 			metadata,
+			parent_payment_id,
+			payment_type,
+			charge_order_id,
 			created_at,
 			updated_at
 		FROM payment_transactions
-		WHERE ride_order_id = ? AND payment_status = 'PENDING'
+		WHERE ride_order_id = ? AND payment_status = 'PENDING' AND payment_type = 'TRIP'
 		LIMIT 1;
 	`
 
@@ -95,6 +115,7 @@ func (r *PaymentRepository) FindPendingPaymentByOrder(ctx context.Context, tx *s
 			&payment.PassengerID,
 			&payment.DriverID,
 			&payment.Amount,
+			&payment.DiscountAmount,
 			&payment.Currency,
 			&payment.PaymentMethod,
 			&payment.PaymentStatus,
@@ -105,6 +126,9 @@ func (r *PaymentRepository) FindPendingPaymentByOrder(ctx context.Context, tx *s
 			&payment.ExpiredAt,
 			&payment.RefundedAt,
 			&payment.Metadata,
+			&payment.ParentPaymentID,
+			&payment.PaymentType,
+			&payment.ChargeOrderID,
 			&payment.CreatedAt,
 			&payment.UpdatedAt,
 		)
@@ -119,6 +143,7 @@ func (r *PaymentRepository) FindPendingPaymentByOrder(ctx context.Context, tx *s
 			&payment.PassengerID,
 			&payment.DriverID,
 			&payment.Amount,
+			&payment.DiscountAmount,
 			&payment.Currency,
 			&payment.PaymentMethod,
 			&payment.PaymentStatus,
@@ -129,6 +154,9 @@ func (r *PaymentRepository) FindPendingPaymentByOrder(ctx context.Context, tx *s
 			&payment.ExpiredAt,
 			&payment.RefundedAt,
 			&payment.Metadata,
+			&payment.ParentPaymentID,
+			&payment.PaymentType,
+			&payment.ChargeOrderID,
 			&payment.CreatedAt,
 			&payment.UpdatedAt,
 		)
@@ -172,6 +200,7 @@ func (r *PaymentRepository) UpdatePaymentTransactionTx(
 		UPDATE payment_transactions
 		SET
 			amount              = ?,
+			discount_amount     = ?,
 			currency            = ?,
 			payment_method      = ?,
 			payment_status      = ?,
@@ -190,6 +219,7 @@ func (r *PaymentRepository) UpdatePaymentTransactionTx(
 		ctx,
 		query,
 		p.Amount,
+		p.DiscountAmount,
 		p.Currency,
 		p.PaymentMethod,
 		p.PaymentStatus,
@@ -233,19 +263,169 @@ func (r *OrderRepository) MarkOrderPaidTx(ctx context.Context, tx *sql.Tx, order
 	return rows > 0, nil
 }
 
+// FindByOrderID is FindByOrderIDForUpdate without the lock.
+func (r *PaymentRepository) FindByOrderID(ctx context.Context, orderID string) (*entity.PaymentTransaction, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE (provider_reference_id = ? OR charge_order_id = ? OR (payment_type = 'TRIP' AND charge_order_id IS NULL AND ride_order_id = (
+			SELECT id FROM orders WHERE order_id = ?
+		)))
+		  AND payment_method NOT IN ('SPLIT', 'WALLET', 'EWALLET', 'CASH')
+		ORDER BY id DESC
+		LIMIT 1
+	`
+
+	var p entity.PaymentTransaction
+	err = db.GetContext(ctx, &p, query, orderID, orderID, orderID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindByOrderIDForUpdate finds the provider payment a notification's order_id
+// belongs to: its charge order id, or the ride order id for trip charges made
+// before each charge had its own.
 func (r *PaymentRepository) FindByOrderIDForUpdate(ctx context.Context, tx *sqlx.Tx, orderID string) (*entity.PaymentTransaction, error) {
 	query := `
 		SELECT *
 		FROM payment_transactions
-		WHERE provider_reference_id = ? OR ride_order_id = (
+		WHERE (provider_reference_id = ? OR charge_order_id = ? OR (payment_type = 'TRIP' AND charge_order_id IS NULL AND ride_order_id = (
 			SELECT id FROM orders WHERE order_id = ?
-		)
+		)))
+		  AND payment_method NOT IN ('SPLIT', 'WALLET', 'EWALLET', 'CASH')
+		ORDER BY id DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	var p entity.PaymentTransaction
+	err := tx.GetContext(ctx, &p, query, orderID, orderID, orderID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindReusablePaymentTx returns the latest PENDING payment of the given type
+// and method for an order that has not expired yet.
+func (r *PaymentRepository) FindReusablePaymentTx(ctx context.Context, tx *sqlx.Tx, rideOrderID uint64, paymentType, method string) (*entity.PaymentTransaction, error) {
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE ride_order_id = ?
+		  AND payment_type = ?
+		  AND payment_method = ?
+		  AND payment_status = 'PENDING'
+		  AND (expired_at IS NULL OR expired_at > NOW())
+		ORDER BY id DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	var p entity.PaymentTransaction
+	err := tx.GetContext(ctx, &p, query, rideOrderID, paymentType, method)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *PaymentRepository) FindLatestByRideOrderID(ctx context.Context, rideOrderID uint64) (*entity.PaymentTransaction, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE ride_order_id = ?
+		  AND parent_payment_id IS NULL
+		  AND payment_type = 'TRIP'
+		ORDER BY id DESC
 		LIMIT 1
+	`
+
+	var p entity.PaymentTransaction
+	err = db.GetContext(ctx, &p, query, rideOrderID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *PaymentRepository) FindEventLogsByPaymentID(ctx context.Context, paymentID uint64) ([]entity.PaymentEventLog, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, payment_transaction_id, event_type, event_description, raw_payload, created_at
+		FROM payment_event_logs
+		WHERE payment_transaction_id = ?
+		ORDER BY created_at ASC, id ASC
+	`
+
+	var logs []entity.PaymentEventLog
+	if err := db.SelectContext(ctx, &logs, query, paymentID); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (r *PaymentRepository) FindByIDForUpdate(ctx context.Context, tx *sqlx.Tx, id uint64) (*entity.PaymentTransaction, error) {
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE id = ?
 		FOR UPDATE
 	`
 
 	var p entity.PaymentTransaction
-	err := tx.GetContext(ctx, &p, query, orderID, orderID)
+	err := tx.GetContext(ctx, &p, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *PaymentRepository) FindByID(ctx context.Context, id uint64) (*entity.PaymentTransaction, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE id = ?
+	`
+
+	var p entity.PaymentTransaction
+	err = db.GetContext(ctx, &p, query, id)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -255,6 +435,82 @@ func (r *PaymentRepository) FindByOrderIDForUpdate(ctx context.Context, tx *sqlx
 	return &p, nil
 }
 
+// FindExpiredPendingPayments returns PENDING payments whose expired_at has
+// passed, oldest first.
+func (r *PaymentRepository) FindExpiredPendingPayments(ctx context.Context, limit int) ([]entity.PaymentTransaction, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE payment_status = 'PENDING'
+		  AND expired_at IS NOT NULL
+		  AND expired_at <= NOW()
+		ORDER BY expired_at ASC
+		LIMIT ?
+	`
+
+	var payments []entity.PaymentTransaction
+	if err := db.SelectContext(ctx, &payments, query, limit); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// FindRefundablePaymentForUpdate returns the latest settled payment of an order
+// that still has something left to refund. A split payment is refunded part by
+// part, so its parent row is never returned; tips are not refunded.
+func (r *PaymentRepository) FindRefundablePaymentForUpdate(ctx context.Context, tx *sqlx.Tx, rideOrderID uint64) (*entity.PaymentTransaction, error) {
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE ride_order_id = ?
+		  AND payment_status IN ('SUCCESS', 'PARTIALLY_REFUNDED')
+		  AND payment_method <> 'SPLIT'
+		  AND payment_type = 'TRIP'
+		ORDER BY id DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	var p entity.PaymentTransaction
+	err := tx.GetContext(ctx, &p, query, rideOrderID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindSettlementByPaymentIDTx returns the original driver settlement of a
+// payment, ignoring reversal rows.
+func (r *PaymentRepository) FindSettlementByPaymentIDTx(ctx context.Context, tx *sqlx.Tx, paymentID uint64) (*entity.PaymentSettlement, error) {
+	query := `
+		SELECT *
+		FROM payment_settlements
+		WHERE payment_transaction_id = ?
+		  AND settlement_amount >= 0
+		ORDER BY id ASC
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	var s entity.PaymentSettlement
+	err := tx.GetContext(ctx, &s, query, paymentID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
 func (r *PaymentRepository) InsertPaymentSettlementTx(
 	ctx context.Context,
 	tx *sqlx.Tx,
@@ -271,8 +527,13 @@ func (r *PaymentRepository) InsertPaymentSettlementTx(
 			settlement_method,
 			provider_reference_id,
 			settled_at,
-			metadata
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			metadata,
+			commission_rule_id,
+			commission_rule_code,
+			commission_rule_version,
+			commission_rate,
+			tax_breakdown
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	res, err := tx.ExecContext(
@@ -288,6 +549,11 @@ func (r *PaymentRepository) InsertPaymentSettlementTx(
 		s.ProviderReferenceID,
 		s.SettledAt,
 		s.Metadata,
+		s.CommissionRuleID,
+		s.CommissionRuleCode,
+		s.CommissionRuleVersion,
+		s.CommissionRate,
+		s.TaxBreakdown,
 	)
 	if err != nil {
 		return err
@@ -301,3 +567,180 @@ func (r *PaymentRepository) InsertPaymentSettlementTx(
 	s.ID = uint64(id)
 	return nil
 }
+
+// UpdateSettlementStatusTx moves a settlement on, e.g. a split payment's
+// settlement from PENDING to PAID once the driver has been credited.
+func (r *PaymentRepository) UpdateSettlementStatusTx(ctx context.Context, tx *sqlx.Tx, id uint64, status string, settledAt *time.Time) error {
+	query := `
+		UPDATE payment_settlements
+		SET status = ?, settled_at = ?, updated_at = NOW()
+		WHERE id = ?
+	`
+	_, err := tx.ExecContext(ctx, query, status, settledAt, id)
+	return err
+}
+
+// FindOpenSplitParentTx returns the PENDING split payment of an order, if any.
+func (r *PaymentRepository) FindOpenSplitParentTx(ctx context.Context, tx *sqlx.Tx, rideOrderID uint64) (*entity.PaymentTransaction, error) {
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE ride_order_id = ?
+		  AND payment_method = 'SPLIT'
+		  AND payment_status = 'PENDING'
+		ORDER BY id DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	var p entity.PaymentTransaction
+	err := tx.GetContext(ctx, &p, query, rideOrderID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindWalletHoldsForUpdate returns the PENDING wallet payments of an order:
+// the hold taken when a driver was assigned, or the wallet part of a split
+// payment.
+func (r *PaymentRepository) FindWalletHoldsForUpdate(ctx context.Context, tx *sqlx.Tx, rideOrderID uint64) ([]entity.PaymentTransaction, error) {
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE ride_order_id = ?
+		  AND payment_type = 'TRIP'
+		  AND payment_status = 'PENDING'
+		  AND payment_method IN ('WALLET', 'EWALLET')
+		ORDER BY id ASC
+		FOR UPDATE
+	`
+
+	var payments []entity.PaymentTransaction
+	if err := tx.SelectContext(ctx, &payments, query, rideOrderID); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// FindLiveTipsForUpdate returns the tips of an order that are paid, being
+// paid, or still waiting on an unexpired charge.
+func (r *PaymentRepository) FindLiveTipsForUpdate(ctx context.Context, tx *sqlx.Tx, rideOrderID uint64) ([]entity.PaymentTransaction, error) {
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE ride_order_id = ?
+		  AND payment_type = 'TIP'
+		  AND payment_status NOT IN ('FAILED', 'EXPIRED')
+		  AND (payment_status <> 'PENDING' OR expired_at IS NULL OR expired_at > NOW())
+		ORDER BY id ASC
+		FOR UPDATE
+	`
+
+	var payments []entity.PaymentTransaction
+	if err := tx.SelectContext(ctx, &payments, query, rideOrderID); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *PaymentRepository) FindChildPaymentsForUpdate(ctx context.Context, tx *sqlx.Tx, parentID uint64) ([]entity.PaymentTransaction, error) {
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE parent_payment_id = ?
+		ORDER BY id ASC
+		FOR UPDATE
+	`
+
+	var payments []entity.PaymentTransaction
+	if err := tx.SelectContext(ctx, &payments, query, parentID); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *PaymentRepository) FindChildPayments(ctx context.Context, parentID uint64) ([]entity.PaymentTransaction, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM payment_transactions
+		WHERE parent_payment_id = ?
+		ORDER BY id ASC
+	`
+
+	var payments []entity.PaymentTransaction
+	if err := db.SelectContext(ctx, &payments, query, parentID); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *PaymentRepository) InsertFxConversionTx(ctx context.Context, tx *sqlx.Tx, c *entity.PaymentFxConversion) error {
+	query := `
+		INSERT INTO payment_fx_conversions (
+			payment_transaction_id,
+			purpose,
+			wallet_id,
+			wallet_currency,
+			wallet_amount,
+			payment_currency,
+			payment_amount,
+			rate_pair,
+			rate,
+			rate_source
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	res, err := tx.ExecContext(
+		ctx,
+		query,
+		c.PaymentTransactionID,
+		c.Purpose,
+		c.WalletID,
+		c.WalletCurrency,
+		c.WalletAmount,
+		c.PaymentCurrency,
+		c.PaymentAmount,
+		c.RatePair,
+		c.Rate,
+		c.RateSource,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = uint64(id)
+	return nil
+}
+
+func (r *PaymentRepository) FindFxConversionTx(ctx context.Context, tx *sqlx.Tx, paymentID uint64, purpose string) (*entity.PaymentFxConversion, error) {
+	query := `
+		SELECT *
+		FROM payment_fx_conversions
+		WHERE payment_transaction_id = ?
+		  AND purpose = ?
+		LIMIT 1
+	`
+
+	var c entity.PaymentFxConversion
+	err := tx.GetContext(ctx, &c, query, paymentID, purpose)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"payment-service/src/internal/entity"
+	"payment-service/src/pkg/databases/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type PaymentReviewRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewPaymentReviewRepository(db mysql.DBInterface) *PaymentReviewRepository {
+	return &PaymentReviewRepository{DB: db}
+}
+
+// InsertReviewTx queues a payment for review. A payment is only queued once;
+// repeated challenge notifications leave the existing review alone.
+func (r *PaymentReviewRepository) InsertReviewTx(ctx context.Context, tx *sqlx.Tx, review *entity.PaymentReview) error {
+	query := `
+		INSERT INTO payment_reviews (
+			payment_transaction_id,
+			order_id,
+			reason,
+			status
+		) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id = id
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		review.PaymentTransactionID,
+		review.OrderID,
+		review.Reason,
+		review.Status,
+	)
+	return err
+}
+
+func (r *PaymentReviewRepository) FindByIDForUpdate(ctx context.Context, tx *sqlx.Tx, id uint64) (*entity.PaymentReview, error) {
+	query := `
+		SELECT *
+		FROM payment_reviews
+		WHERE id = ?
+		FOR UPDATE
+	`
+
+	var review entity.PaymentReview
+	err := tx.GetContext(ctx, &review, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *PaymentReviewRepository) UpdateReviewTx(ctx context.Context, tx *sqlx.Tx, review *entity.PaymentReview) error {
+	query := `
+		UPDATE payment_reviews
+		SET status = ?,
+			reviewed_by = ?,
+			review_note = ?,
+			provider_response = ?,
+			reviewed_at = ?
+		WHERE id = ?
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		review.Status,
+		review.ReviewedBy,
+		review.ReviewNote,
+		review.ProviderResponse,
+		review.ReviewedAt,
+		review.ID,
+	)
+	return err
+}
+
+func (r *PaymentReviewRepository) FindByStatus(ctx context.Context, status string, limit, offset int) ([]entity.PaymentReview, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM payment_reviews
+		WHERE status = ?
+		ORDER BY created_at ASC, id ASC
+		LIMIT ? OFFSET ?
+	`
+
+	var reviews []entity.PaymentReview
+	if err := db.SelectContext(ctx, &reviews, query, status, limit, offset); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
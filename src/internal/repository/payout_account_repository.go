@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"payment-service/src/internal/entity"
+	"payment-service/src/pkg/databases/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type PayoutAccountRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewPayoutAccountRepository(db mysql.DBInterface) *PayoutAccountRepository {
+	return &PayoutAccountRepository{DB: db}
+}
+
+func (r *PayoutAccountRepository) InsertAccountTx(ctx context.Context, tx *sqlx.Tx, a *entity.PayoutAccount) (uint64, error) {
+	query := `
+		INSERT INTO payout_accounts (
+			account_id,
+			driver_id,
+			bank_code,
+			account_number_encrypted,
+			account_number_hash,
+			account_number_last4,
+			holder_name_encrypted,
+			status
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	res, err := tx.ExecContext(ctx, query,
+		a.AccountID,
+		a.DriverID,
+		a.BankCode,
+		a.AccountNumberEncrypted,
+		a.AccountNumberHash,
+		a.AccountNumberLast4,
+		a.HolderNameEncrypted,
+		a.Status,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+func (r *PayoutAccountRepository) UpdateAccountTx(ctx context.Context, tx *sqlx.Tx, a *entity.PayoutAccount) error {
+	query := `
+		UPDATE payout_accounts
+		SET bank_code = ?,
+			account_number_encrypted = ?,
+			account_number_hash = ?,
+			account_number_last4 = ?,
+			holder_name_encrypted = ?,
+			inquiry_name_encrypted = ?,
+			name_match_score = ?,
+			status = ?,
+			rejection_reason = ?,
+			available_after = ?,
+			verified_at = ?,
+			removed_at = ?
+		WHERE id = ?
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		a.BankCode,
+		a.AccountNumberEncrypted,
+		a.AccountNumberHash,
+		a.AccountNumberLast4,
+		a.HolderNameEncrypted,
+		a.InquiryNameEncrypted,
+		a.NameMatchScore,
+		a.Status,
+		a.RejectionReason,
+		a.AvailableAfter,
+		a.VerifiedAt,
+		a.RemovedAt,
+		a.ID,
+	)
+	return err
+}
+
+// FindDriverAccountForUpdate returns one of the driver's accounts by its
+// account id, removed ones included.
+func (r *PayoutAccountRepository) FindDriverAccountForUpdate(ctx context.Context, tx *sqlx.Tx, driverID, accountID string) (*entity.PayoutAccount, error) {
+	query := `
+		SELECT *
+		FROM payout_accounts
+		WHERE driver_id = ? AND account_id = ?
+		FOR UPDATE
+	`
+
+	var account entity.PayoutAccount
+	err := tx.GetContext(ctx, &account, query, driverID, accountID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *PayoutAccountRepository) FindByIDTx(ctx context.Context, tx *sqlx.Tx, id uint64) (*entity.PayoutAccount, error) {
+	query := `
+		SELECT *
+		FROM payout_accounts
+		WHERE id = ?
+	`
+
+	var account entity.PayoutAccount
+	err := tx.GetContext(ctx, &account, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CountDriverDuplicatesTx counts the driver's other live accounts with the
+// same bank and account number.
+func (r *PayoutAccountRepository) CountDriverDuplicatesTx(ctx context.Context, tx *sqlx.Tx, driverID, bankCode, numberHash string, excludeID uint64) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM payout_accounts
+		WHERE driver_id = ? AND bank_code = ? AND account_number_hash = ? AND status <> 'REMOVED' AND id <> ?
+	`
+
+	var count int
+	if err := tx.GetContext(ctx, &count, query, driverID, bankCode, numberHash, excludeID); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *PayoutAccountRepository) FindByDriver(ctx context.Context, driverID string) ([]entity.PayoutAccount, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM payout_accounts
+		WHERE driver_id = ? AND status <> 'REMOVED'
+		ORDER BY created_at ASC, id ASC
+	`
+
+	var accounts []entity.PayoutAccount
+	if err := db.SelectContext(ctx, &accounts, query, driverID); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
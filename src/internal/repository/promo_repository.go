@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"payment-service/src/internal/entity"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// The promo tables are shared with the order service and only read here;
+// what this service records about a redemption or a campaign lives in
+// payment_promo_redemptions and payment_promo_campaigns.
+const promoRedemptionColumns = `
+	promo_redemption_id AS id, promo_campaign_id, ride_order_id, passenger_id, payment_transaction_id,
+	discount_amount AS discount_applied, status, reversed_at, created_at
+`
+
+// FindOrderRedemptionForUpdate returns the latest promo redemption the order
+// service attached to an order, with this service's record of it locked.
+func (r *PaymentRepository) FindOrderRedemptionForUpdate(ctx context.Context, tx *sqlx.Tx, orderID string) (*entity.PromoRedemption, error) {
+	var attached entity.PromoRedemption
+	query := `
+		SELECT id, promo_campaign_id, ride_order_id
+		FROM promo_redemptions
+		WHERE ride_order_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	if err := tx.GetContext(ctx, &attached, query, orderID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	query = `
+		INSERT IGNORE INTO payment_promo_redemptions (promo_redemption_id, promo_campaign_id, ride_order_id, status)
+		VALUES (?, ?, ?, 'PENDING')
+	`
+	if _, err := tx.ExecContext(ctx, query, attached.ID, attached.PromoCampaignID, attached.RideOrderID); err != nil {
+		return nil, err
+	}
+
+	var redemption entity.PromoRedemption
+	query = `
+		SELECT ` + promoRedemptionColumns + `
+		FROM payment_promo_redemptions
+		WHERE promo_redemption_id = ?
+		FOR UPDATE
+	`
+	if err := tx.GetContext(ctx, &redemption, query, attached.ID); err != nil {
+		return nil, err
+	}
+	return &redemption, nil
+}
+
+// FindAppliedRedemptionByPaymentForUpdate returns the redemption whose
+// discount a payment took, if any.
+func (r *PaymentRepository) FindAppliedRedemptionByPaymentForUpdate(ctx context.Context, tx *sqlx.Tx, paymentID uint64) (*entity.PromoRedemption, error) {
+	var redemption entity.PromoRedemption
+	query := `
+		SELECT ` + promoRedemptionColumns + `
+		FROM payment_promo_redemptions
+		WHERE payment_transaction_id = ? AND status = 'APPLIED'
+		LIMIT 1
+		FOR UPDATE
+	`
+	if err := tx.GetContext(ctx, &redemption, query, paymentID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &redemption, nil
+}
+
+// FindPromoCampaignForUpdate returns a campaign with its limits and usage.
+// Only this service's usage row is locked; the order service's campaign row is
+// read as it is.
+func (r *PaymentRepository) FindPromoCampaignForUpdate(ctx context.Context, tx *sqlx.Tx, id uint64) (*entity.PromoCampaign, error) {
+	query := `
+		INSERT IGNORE INTO payment_promo_campaigns (promo_campaign_id)
+		VALUES (?)
+	`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return nil, err
+	}
+
+	var campaign entity.PromoCampaign
+	query = `
+		SELECT
+			promo_campaign_id AS id, per_user_limit, usage_limit, usage_count, budget, budget_used, starts_at, ends_at
+		FROM payment_promo_campaigns
+		WHERE promo_campaign_id = ?
+		FOR UPDATE
+	`
+	if err := tx.GetContext(ctx, &campaign, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	query = `
+		SELECT promo_code, name, discount_type, discount_value, max_discount
+		FROM promo_campaigns
+		WHERE id = ?
+	`
+	if err := tx.GetContext(ctx, &campaign, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// CountPassengerRedemptionsTx counts the passenger's applied redemptions of a
+// campaign other than excludeID.
+func (r *PaymentRepository) CountPassengerRedemptionsTx(ctx context.Context, tx *sqlx.Tx, campaignID uint64, passengerID string, excludeID uint64) (int, error) {
+	var count int
+	query := `
+		SELECT COUNT(*)
+		FROM payment_promo_redemptions
+		WHERE promo_campaign_id = ? AND passenger_id = ? AND status = 'APPLIED' AND promo_redemption_id <> ?
+	`
+	if err := tx.GetContext(ctx, &count, query, campaignID, passengerID, excludeID); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *PaymentRepository) UpdatePromoRedemptionTx(ctx context.Context, tx *sqlx.Tx, redemption *entity.PromoRedemption) error {
+	query := `
+		UPDATE payment_promo_redemptions
+		SET
+			passenger_id           = ?,
+			payment_transaction_id = ?,
+			discount_amount        = ?,
+			status                 = ?,
+			reversed_at            = ?
+		WHERE promo_redemption_id = ?
+	`
+	_, err := tx.ExecContext(ctx, query,
+		redemption.PassengerID,
+		redemption.PaymentTransactionID,
+		redemption.DiscountApplied,
+		redemption.Status,
+		redemption.ReversedAt,
+		redemption.ID,
+	)
+	return err
+}
+
+func (r *PaymentRepository) UpdatePromoCampaignUsageTx(ctx context.Context, tx *sqlx.Tx, campaign *entity.PromoCampaign) error {
+	query := `
+		UPDATE payment_promo_campaigns
+		SET usage_count = ?, budget_used = ?
+		WHERE promo_campaign_id = ?
+	`
+	_, err := tx.ExecContext(ctx, query, campaign.UsageCount, campaign.BudgetUsed, campaign.ID)
+	return err
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"payment-service/src/internal/entity"
+	"payment-service/src/pkg/databases/mysql"
+	"payment-service/src/pkg/money"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type RefundRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewRefundRepository(db mysql.DBInterface) *RefundRepository {
+	return &RefundRepository{DB: db}
+}
+
+func (r *RefundRepository) InsertRefundTx(ctx context.Context, tx *sqlx.Tx, refund *entity.PaymentRefund) error {
+	query := `
+		INSERT INTO payment_refunds (
+			refund_id,
+			payment_transaction_id,
+			amount,
+			currency,
+			reason_code,
+			reason_note,
+			refund_method,
+			status,
+			driver_reversal_amount,
+			provider_reference_id,
+			requested_by,
+			raw_payload
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	res, err := tx.ExecContext(ctx, query,
+		refund.RefundID,
+		refund.PaymentTransactionID,
+		refund.Amount,
+		refund.Currency,
+		refund.ReasonCode,
+		refund.ReasonNote,
+		refund.RefundMethod,
+		refund.Status,
+		refund.DriverReversalAmount,
+		refund.ProviderReferenceID,
+		refund.RequestedBy,
+		refund.RawPayload,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	refund.ID = uint64(id)
+	return nil
+}
+
+// UpdateRefundTx stores the outcome of a PENDING provider refund.
+func (r *RefundRepository) UpdateRefundTx(ctx context.Context, tx *sqlx.Tx, refund *entity.PaymentRefund) error {
+	query := `
+		UPDATE payment_refunds
+		SET status = ?,
+			driver_reversal_amount = ?,
+			provider_reference_id = ?,
+			raw_payload = ?
+		WHERE id = ?
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		refund.Status,
+		refund.DriverReversalAmount,
+		refund.ProviderReferenceID,
+		refund.RawPayload,
+		refund.ID,
+	)
+	return err
+}
+
+// FindPendingRefundTx returns the provider refund of a payment that was
+// started but not yet completed, if any.
+func (r *RefundRepository) FindPendingRefundTx(ctx context.Context, tx *sqlx.Tx, paymentID uint64) (*entity.PaymentRefund, error) {
+	query := `
+		SELECT *
+		FROM payment_refunds
+		WHERE payment_transaction_id = ?
+		  AND status = 'PENDING'
+		ORDER BY id ASC
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	var refund entity.PaymentRefund
+	err := tx.GetContext(ctx, &refund, query, paymentID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *RefundRepository) FindByRefundIDForUpdate(ctx context.Context, tx *sqlx.Tx, refundID string) (*entity.PaymentRefund, error) {
+	query := `
+		SELECT *
+		FROM payment_refunds
+		WHERE refund_id = ?
+		FOR UPDATE
+	`
+
+	var refund entity.PaymentRefund
+	err := tx.GetContext(ctx, &refund, query, refundID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// FindStalePendingRefunds lists PENDING refunds created before a time, oldest
+// first, for the refund retrier.
+func (r *RefundRepository) FindStalePendingRefunds(ctx context.Context, before time.Time, limit int) ([]entity.PaymentRefund, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM payment_refunds
+		WHERE status = 'PENDING' AND created_at < ?
+		ORDER BY created_at ASC, id ASC
+		LIMIT ?
+	`
+
+	var refunds []entity.PaymentRefund
+	if err := db.SelectContext(ctx, &refunds, query, before, limit); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// SumRefundedAmountTx returns how much of a payment has already been refunded
+// or is being refunded.
+func (r *RefundRepository) SumRefundedAmountTx(ctx context.Context, tx *sqlx.Tx, paymentID uint64) (money.Amount, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM payment_refunds
+		WHERE payment_transaction_id = ?
+		  AND status IN ('PENDING', 'SUCCESS')
+	`
+
+	var total money.Amount
+	if err := tx.GetContext(ctx, &total, query, paymentID); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (r *RefundRepository) FindRefundsByPaymentID(ctx context.Context, paymentID uint64) ([]entity.PaymentRefund, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM payment_refunds
+		WHERE payment_transaction_id = ?
+		ORDER BY id ASC
+	`
+
+	var refunds []entity.PaymentRefund
+	if err := db.SelectContext(ctx, &refunds, query, paymentID); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"payment-service/src/internal/entity"
+	"payment-service/src/pkg/databases/mysql"
+	"time"
+)
+
+type TaxRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewTaxRepository(db mysql.DBInterface) *TaxRepository {
+	return &TaxRepository{DB: db}
+}
+
+// FindEffectiveRates returns the tax rates in effect at, the latest first.
+func (r *TaxRepository) FindEffectiveRates(ctx context.Context, at time.Time) ([]entity.TaxRate, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []entity.TaxRate
+	query := `
+		SELECT *
+		FROM tax_rates
+		WHERE effective_from <= ? AND (effective_to IS NULL OR effective_to > ?)
+		ORDER BY effective_from DESC, id DESC
+	`
+	if err := db.SelectContext(ctx, &rates, query, at, at); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// FindWithholdingSettlements returns the settlements with a tax breakdown
+// created in [from, to), of one driver when driverID is set.
+func (r *TaxRepository) FindWithholdingSettlements(ctx context.Context, from, to time.Time, driverID string) ([]entity.WithholdingSettlement, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var settlements []entity.WithholdingSettlement
+	query := `
+		SELECT s.*, COALESCE(u.full_name, '') AS driver_name
+		FROM payment_settlements s
+		LEFT JOIN users u ON u.user_id = s.driver_id
+		WHERE s.created_at >= ? AND s.created_at < ? AND s.tax_breakdown IS NOT NULL
+	`
+	args := []interface{}{from, to}
+	if driverID != "" {
+		query += ` AND s.driver_id = ?`
+		args = append(args, driverID)
+	}
+	query += ` ORDER BY s.driver_id, s.id`
+	if err := db.SelectContext(ctx, &settlements, query, args...); err != nil {
+		return nil, err
+	}
+	return settlements, nil
+}
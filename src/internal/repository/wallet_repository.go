@@ -3,8 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"payment-service/src/internal/entity"
 	"payment-service/src/pkg/databases/mysql"
+	"payment-service/src/pkg/money"
 )
 
 type WalletRepository struct {
@@ -23,7 +25,7 @@ func (r *WalletRepository) GetWalletByUserID(ctx context.Context, userID string)
 
 	var w entity.Wallet
 	query := `
-		SELECT id, user_id, balance, last_updated, created_at, updated_at
+		SELECT id, user_id, balance, held_balance, currency, last_updated, created_at, updated_at
 		FROM wallets
 		WHERE user_id = ?
 		LIMIT 1
@@ -40,13 +42,13 @@ func (r *WalletRepository) GetWalletByUserID(ctx context.Context, userID string)
 func (r *WalletRepository) GetWalletForUpdate(ctx context.Context, tx *sql.Tx, userID string) (*entity.Wallet, error) {
 	var w entity.Wallet
 	query := `
-		SELECT id, user_id, balance, last_updated, created_at, updated_at
+		SELECT id, user_id, balance, held_balance, currency, last_updated, created_at, updated_at
 		FROM wallets
 		WHERE user_id = ?
 		FOR UPDATE
 	`
 	err := tx.QueryRowContext(ctx, query, userID).Scan(
-		&w.ID, &w.UserID, &w.Balance, &w.LastUpdated, &w.CreatedAt, &w.UpdatedAt,
+		&w.ID, &w.UserID, &w.Balance, &w.HeldBalance, &w.Currency, &w.LastUpdated, &w.CreatedAt, &w.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -59,14 +61,21 @@ func (r *WalletRepository) GetWalletForUpdate(ctx context.Context, tx *sql.Tx, u
 
 func (r *WalletRepository) InsertWallet(ctx context.Context, tx *sql.Tx, w *entity.Wallet) error {
 	query := `
-		INSERT INTO wallets (id, user_id, balance, last_updated, created_at, updated_at)
-		VALUES (?, ?, ?, NOW(6), NOW(6), NOW(6))
+		INSERT INTO wallets (id, user_id, balance, currency, last_updated, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NOW(6), NOW(6), NOW(6))
 	`
-	_, err := tx.ExecContext(ctx, query, w.ID, w.UserID, w.Balance)
+	_, err := tx.ExecContext(ctx, query, w.ID, w.UserID, w.Balance, w.Currency)
 	return err
 }
 
-func (r *WalletRepository) UpdateWalletBalance(ctx context.Context, tx *sql.Tx, walletID string, newBalance float64) error {
+// ErrNegativeBalance is returned instead of writing a balance below zero. What
+// a driver owes beyond their balance is kept as driver debt.
+var ErrNegativeBalance = errors.New("wallet balance cannot go below zero")
+
+func (r *WalletRepository) UpdateWalletBalance(ctx context.Context, tx *sql.Tx, walletID string, newBalance money.Amount) error {
+	if newBalance < 0 {
+		return ErrNegativeBalance
+	}
 	query := `
 		UPDATE wallets
 		SET balance = ?, last_updated = NOW(6)
@@ -76,6 +85,88 @@ func (r *WalletRepository) UpdateWalletBalance(ctx context.Context, tx *sql.Tx,
 	return err
 }
 
+// UpdateWalletBalances writes both balances of a wallet, for moves between
+// the available and the held balance.
+func (r *WalletRepository) UpdateWalletBalances(ctx context.Context, tx *sql.Tx, walletID string, balance, heldBalance money.Amount) error {
+	if balance < 0 || heldBalance < 0 {
+		return ErrNegativeBalance
+	}
+	query := `
+		UPDATE wallets
+		SET balance = ?, held_balance = ?, last_updated = NOW(6)
+		WHERE id = ?
+	`
+	_, err := tx.ExecContext(ctx, query, balance, heldBalance, walletID)
+	return err
+}
+
+func (r *WalletRepository) InsertHold(ctx context.Context, tx *sql.Tx, h *entity.WalletHold) error {
+	query := `
+		INSERT INTO wallet_holds (
+			hold_id, wallet_id, payment_transaction_id, amount, status, expires_at, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, NOW(6), NOW(6))
+	`
+	res, err := tx.ExecContext(ctx, query,
+		h.HoldID, h.WalletID, h.PaymentTransactionID, h.Amount, h.Status, h.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	h.ID = uint64(id)
+	return nil
+}
+
+func (r *WalletRepository) GetHoldByPaymentIDForUpdate(ctx context.Context, tx *sql.Tx, paymentID uint64) (*entity.WalletHold, error) {
+	var h entity.WalletHold
+	query := `
+		SELECT id, hold_id, wallet_id, payment_transaction_id, amount, captured_amount, status,
+			expires_at, captured_at, released_at, created_at, updated_at
+		FROM wallet_holds
+		WHERE payment_transaction_id = ?
+		FOR UPDATE
+	`
+	err := tx.QueryRowContext(ctx, query, paymentID).Scan(
+		&h.ID, &h.HoldID, &h.WalletID, &h.PaymentTransactionID, &h.Amount, &h.CapturedAmount, &h.Status,
+		&h.ExpiresAt, &h.CapturedAt, &h.ReleasedAt, &h.CreatedAt, &h.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &h, nil
+}
+
+// CloseHold moves an open hold to its final status.
+func (r *WalletRepository) CloseHold(ctx context.Context, tx *sql.Tx, h *entity.WalletHold) error {
+	query := `
+		UPDATE wallet_holds
+		SET status = ?, captured_amount = ?, captured_at = ?, released_at = ?, updated_at = NOW(6)
+		WHERE id = ? AND status = 'HELD'
+	`
+	res, err := tx.ExecContext(ctx, query, h.Status, h.CapturedAmount, h.CapturedAt, h.ReleasedAt, h.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrHoldNotOpen
+	}
+	return nil
+}
+
+// ErrHoldNotOpen is returned when a hold was already captured, released or
+// expired.
+var ErrHoldNotOpen = errors.New("wallet hold is not open")
+
 func (r *WalletRepository) InsertWalletTransaction(ctx context.Context, tx *sql.Tx, trx *entity.WalletTransaction) error {
 	query := `
 		INSERT INTO wallet_transactions (
@@ -101,7 +192,7 @@ func (r *WalletRepository) GetLastTransactions(ctx context.Context, walletID str
 		SELECT 
 			id, wallet_id, transaction_id, amount, type, description, timestamp, created_at
 		FROM wallet_transactions
-		WHERE wallet_id = ?
+		WHERE wallet_id = ? AND superseded_by_hold_id IS NULL
 		ORDER BY timestamp DESC
 		LIMIT ?
 	`
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"payment-service/src/internal/entity"
+	"payment-service/src/pkg/databases/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type WebhookInboxRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewWebhookInboxRepository(db mysql.DBInterface) *WebhookInboxRepository {
+	return &WebhookInboxRepository{DB: db}
+}
+
+// Insert stores a new inbox entry. It returns false without an error when an
+// entry with the same dedupe key already exists.
+func (r *WebhookInboxRepository) Insert(ctx context.Context, inbox *entity.WebhookInbox) (bool, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO payment_webhook_inbox (
+			dedupe_key,
+			order_id,
+			transaction_id,
+			transaction_status,
+			payload,
+			status
+		) VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id = id
+	`
+
+	res, err := db.ExecContext(ctx, query,
+		inbox.DedupeKey,
+		inbox.OrderID,
+		inbox.TransactionID,
+		inbox.TransactionStatus,
+		inbox.Payload,
+		inbox.Status,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	inbox.ID = uint64(id)
+	return true, nil
+}
+
+// ReplaceRejected puts a new payload into a REJECTED entry holding the same
+// dedupe key and queues it again, so a forged notification cannot block the
+// genuine one.
+func (r *WebhookInboxRepository) ReplaceRejected(ctx context.Context, dedupeKey, payload string) (uint64, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		UPDATE payment_webhook_inbox
+		SET payload = ?,
+			status = 'RECEIVED',
+			attempts = 0,
+			last_error = NULL,
+			next_attempt_at = NOW(6)
+		WHERE dedupe_key = ?
+		  AND status = 'REJECTED'
+	`
+
+	res, err := db.ExecContext(ctx, query, payload, dedupeKey)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return 0, err
+	}
+
+	var id uint64
+	if err := db.GetContext(ctx, &id, `SELECT id FROM payment_webhook_inbox WHERE dedupe_key = ?`, dedupeKey); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// FindDueIDs returns entries waiting for a (re)try, oldest first.
+func (r *WebhookInboxRepository) FindDueIDs(ctx context.Context, limit int) ([]uint64, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id
+		FROM payment_webhook_inbox
+		WHERE status IN ('RECEIVED', 'RETRY')
+		  AND next_attempt_at <= NOW(6)
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	var ids []uint64
+	if err := db.SelectContext(ctx, &ids, query, limit); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *WebhookInboxRepository) FindByIDForUpdate(ctx context.Context, tx *sqlx.Tx, id uint64) (*entity.WebhookInbox, error) {
+	query := `
+		SELECT *
+		FROM payment_webhook_inbox
+		WHERE id = ?
+		FOR UPDATE
+	`
+
+	var inbox entity.WebhookInbox
+	err := tx.GetContext(ctx, &inbox, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inbox, nil
+}
+
+func (r *WebhookInboxRepository) UpdateTx(ctx context.Context, tx *sqlx.Tx, inbox *entity.WebhookInbox) error {
+	query := `
+		UPDATE payment_webhook_inbox
+		SET status = ?,
+			attempts = ?,
+			last_error = ?,
+			next_attempt_at = ?,
+			processed_at = ?
+		WHERE id = ?
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		inbox.Status,
+		inbox.Attempts,
+		inbox.LastError,
+		inbox.NextAttemptAt,
+		inbox.ProcessedAt,
+		inbox.ID,
+	)
+	return err
+}
+
+func (r *WebhookInboxRepository) FindByStatuses(ctx context.Context, statuses []string, limit, offset int) ([]entity.WebhookInbox, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT *
+		FROM payment_webhook_inbox
+		WHERE status IN (?)
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, statuses, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entity.WebhookInbox
+	if err := db.SelectContext(ctx, &entries, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
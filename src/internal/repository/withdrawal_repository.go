@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"payment-service/src/internal/entity"
+	"payment-service/src/pkg/databases/mysql"
+	"payment-service/src/pkg/money"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type WithdrawalRepository struct {
+	DB mysql.DBInterface
+}
+
+func NewWithdrawalRepository(db mysql.DBInterface) *WithdrawalRepository {
+	return &WithdrawalRepository{DB: db}
+}
+
+func (r *WithdrawalRepository) InsertWithdrawalTx(ctx context.Context, tx *sqlx.Tx, w *entity.DriverWithdrawal) (uint64, error) {
+	query := `
+		INSERT INTO driver_withdrawals (
+			withdrawal_id,
+			driver_id,
+			wallet_id,
+			payout_account_id,
+			amount,
+			fee,
+			net_amount,
+			currency,
+			bank_code,
+			account_number,
+			account_name,
+			status
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	res, err := tx.ExecContext(ctx, query,
+		w.WithdrawalID,
+		w.DriverID,
+		w.WalletID,
+		w.PayoutAccountID,
+		w.Amount,
+		w.Fee,
+		w.NetAmount,
+		w.Currency,
+		w.BankCode,
+		w.AccountNumber,
+		w.AccountName,
+		w.Status,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+func (r *WithdrawalRepository) UpdateWithdrawalTx(ctx context.Context, tx *sqlx.Tx, w *entity.DriverWithdrawal) error {
+	query := `
+		UPDATE driver_withdrawals
+		SET status = ?,
+			provider = ?,
+			provider_reference = ?,
+			failure_reason = ?,
+			reviewed_by = ?,
+			review_note = ?,
+			reviewed_at = ?,
+			completed_at = ?,
+			failed_at = ?
+		WHERE id = ?
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		w.Status,
+		w.Provider,
+		w.ProviderReference,
+		w.FailureReason,
+		w.ReviewedBy,
+		w.ReviewNote,
+		w.ReviewedAt,
+		w.CompletedAt,
+		w.FailedAt,
+		w.ID,
+	)
+	return err
+}
+
+func (r *WithdrawalRepository) FindByIDForUpdate(ctx context.Context, tx *sqlx.Tx, id uint64) (*entity.DriverWithdrawal, error) {
+	query := `
+		SELECT *
+		FROM driver_withdrawals
+		WHERE id = ?
+		FOR UPDATE
+	`
+
+	var withdrawal entity.DriverWithdrawal
+	err := tx.GetContext(ctx, &withdrawal, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+func (r *WithdrawalRepository) FindByProviderReferenceForUpdate(ctx context.Context, tx *sqlx.Tx, provider, reference string) (*entity.DriverWithdrawal, error) {
+	query := `
+		SELECT *
+		FROM driver_withdrawals
+		WHERE provider = ? AND provider_reference = ?
+		FOR UPDATE
+	`
+
+	var withdrawal entity.DriverWithdrawal
+	err := tx.GetContext(ctx, &withdrawal, query, provider, reference)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+// SumDriverWithdrawalsSinceTx counts and sums the driver's withdrawals since
+// a time that were not rejected or failed, for the daily limits.
+func (r *WithdrawalRepository) SumDriverWithdrawalsSinceTx(ctx context.Context, tx *sqlx.Tx, driverID string, since time.Time) (int, money.Amount, error) {
+	query := `
+		SELECT COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total
+		FROM driver_withdrawals
+		WHERE driver_id = ? AND created_at >= ? AND status NOT IN ('REJECTED', 'FAILED')
+	`
+
+	var sum struct {
+		Count int          `db:"count"`
+		Total money.Amount `db:"total"`
+	}
+	if err := tx.GetContext(ctx, &sum, query, driverID, since); err != nil {
+		return 0, 0, err
+	}
+	return sum.Count, sum.Total, nil
+}
+
+func (r *WithdrawalRepository) FindByDriver(ctx context.Context, driverID string, limit, offset int) ([]entity.DriverWithdrawal, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM driver_withdrawals
+		WHERE driver_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	var withdrawals []entity.DriverWithdrawal
+	if err := db.SelectContext(ctx, &withdrawals, query, driverID, limit, offset); err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
+
+// FindStaleProcessing lists PROCESSING withdrawals last updated before a
+// time, oldest first, for payout reconciliation.
+func (r *WithdrawalRepository) FindStaleProcessing(ctx context.Context, before time.Time, limit int) ([]entity.DriverWithdrawal, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM driver_withdrawals
+		WHERE status = 'PROCESSING' AND updated_at < ?
+		ORDER BY updated_at ASC, id ASC
+		LIMIT ?
+	`
+
+	var withdrawals []entity.DriverWithdrawal
+	if err := db.SelectContext(ctx, &withdrawals, query, before, limit); err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
+
+func (r *WithdrawalRepository) FindByStatus(ctx context.Context, status string, limit, offset int) ([]entity.DriverWithdrawal, error) {
+	db, err := r.DB.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+		FROM driver_withdrawals
+		WHERE status = ?
+		ORDER BY created_at ASC, id ASC
+		LIMIT ? OFFSET ?
+	`
+
+	var withdrawals []entity.DriverWithdrawal
+	if err := db.SelectContext(ctx, &withdrawals, query, status, limit, offset); err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/model"
+	"payment-service/src/pkg/money"
+	"payment-service/src/pkg/utils"
+	"strings"
+	"time"
+)
+
+const SettlementMethodCashCommission = "CASH_COMMISSION"
+
+func isCashMethod(method string) bool {
+	return strings.EqualFold(method, paymentGateway.MethodCash)
+}
+
+// settleCashTrip records a completed cash trip. The passenger paid the driver
+// in person, so the CASH payment is born SUCCESS and the platform fee and tax
+// are debited from the driver's wallet; what the balance cannot cover becomes
+// driver debt. Marking the order paid is what keeps a redelivered event from
+// charging twice.
+func (uc *WalletUseCase) settleCashTrip(ctx context.Context, req *model.NotificationUser, order *entity.Order) error {
+	actualPaid, _, err := tripFare(uc.Config, order)
+	if err != nil {
+		uc.Log.Error("wallet-usecase", err.Error(), "SettleCashTrip", utils.ConvertString(order))
+		return err
+	}
+	rule, err := uc.resolveCommission(ctx, order, req.DriverID)
+	if err != nil {
+		uc.Log.Error("wallet-usecase", "failed to resolve commission rule", "SettleCashTrip", utils.ConvertString(err))
+		return err
+	}
+	platformFee, taxes, driverShare, err := uc.platformCut(ctx, actualPaid, rule, "SettleCashTrip")
+	if err != nil {
+		uc.Log.Error("wallet-usecase", "failed to compute trip taxes", "SettleCashTrip", utils.ConvertString(err))
+		return err
+	}
+	taxAmount := taxes.Total()
+	currency := paymentCurrency(uc.Config)
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		uc.Log.Error("wallet-usecase", "failed to get db connection", "SettleCashTrip", utils.ConvertString(err))
+		return fmt.Errorf("failed to get db connection: %v", err)
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		uc.Log.Error("wallet-usecase", "failed to start transaction", "SettleCashTrip", utils.ConvertString(err))
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	ok, err := uc.OrderRepository.MarkOrderPaidTx(ctx, tx.Tx, req.OrderID, req.PassengerID, req.DriverID)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to update order payment status", "SettleCashTrip", utils.ConvertString(err))
+		return fmt.Errorf("failed to update order payment status: %v", err)
+	}
+	if !ok {
+		_ = tx.Rollback()
+		uc.Log.Info("wallet-usecase", "Cash order already settled or not completed, skip", "SettleCashTrip", req.OrderID)
+		return nil
+	}
+
+	now := time.Now()
+	payment := &entity.PaymentTransaction{
+		RideOrderID:   order.ID,
+		PassengerID:   req.PassengerID,
+		DriverID:      req.DriverID,
+		Amount:        actualPaid,
+		Currency:      currency,
+		PaymentMethod: paymentGateway.MethodCash,
+		PaymentStatus: entity.PaymentStatusSuccess,
+		PaidAt:        &now,
+	}
+	paymentID, err := uc.PaymentRepository.InsertPaymentTransactionTx(ctx, tx, payment)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to create payment transaction", "SettleCashTrip", utils.ConvertString(err))
+		return fmt.Errorf("failed to create payment transaction: %v", err)
+	}
+
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentID,
+		EventType:            "SUCCESS",
+		EventDescription:     fmt.Sprintf("Cash %d collected by driver for order %s", actualPaid, req.OrderID),
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to insert success event log", "SettleCashTrip", utils.ConvertString(err))
+		return fmt.Errorf("failed to insert success event log: %v", err)
+	}
+
+	commission := platformFee + taxAmount
+	if commission > 0 {
+		driverWallet, err := uc.driverWalletForUpdate(ctx, tx, req.DriverID, currency)
+		if err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to get driver wallet", "SettleCashTrip", utils.ConvertString(err))
+			return err
+		}
+		commissionMoney := money.New(commission, currency)
+		debit, rate, err := quoteWalletDebit(ctx, uc.Rates, driverWallet, commissionMoney)
+		if err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "Driver wallet currency cannot pay the commission", "SettleCashTrip", utils.ConvertString(err))
+			return err
+		}
+		if err := recordFxConversion(ctx, tx, uc.PaymentRepository, paymentID, entity.FxPurposeSettlement, driverWallet, debit, commissionMoney, rate, uc.Rates); err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to record fx conversion", "SettleCashTrip", utils.ConvertString(err))
+			return err
+		}
+
+		description := fmt.Sprintf("Cash trip commission for order %s", req.OrderID)
+		fromWallet, err := uc.debtLedger().charge(ctx, tx, driverWallet, debit, &paymentID, entity.DriverDebtSourceCashCommission, description)
+		if err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to record driver debt", "SettleCashTrip", utils.ConvertString(err))
+			return err
+		}
+		if fromWallet > 0 {
+			if err := uc.WalletRepository.UpdateWalletBalance(ctx, tx.Tx, driverWallet.ID, driverWallet.Balance-fromWallet); err != nil {
+				_ = tx.Rollback()
+				uc.Log.Error("wallet-usecase", "failed to update driver wallet balance", "SettleCashTrip", utils.ConvertString(err))
+				return fmt.Errorf("failed to update driver wallet balance: %v", err)
+			}
+			trx := &entity.WalletTransaction{
+				WalletID:      driverWallet.ID,
+				TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+				Amount:        fromWallet,
+				Type:          "debit",
+				Description:   description,
+				Timestamp:     now,
+			}
+			if err := uc.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, trx); err != nil {
+				_ = tx.Rollback()
+				uc.Log.Error("wallet-usecase", "failed to insert commission transaction", "SettleCashTrip", utils.ConvertString(err))
+				return fmt.Errorf("failed to insert commission transaction: %v", err)
+			}
+		}
+	}
+
+	// SettlementAmount is what the driver kept of the cash
+	settlement := &entity.PaymentSettlement{
+		PaymentTransactionID: paymentID,
+		DriverID:             req.DriverID,
+		SettlementAmount:     driverShare,
+		PlatformFee:          platformFee,
+		Status:               "PAID",
+		SettlementMethod:     SettlementMethodCashCommission,
+		SettledAt:            &now,
+		CreatedAt:            now,
+	}
+	applyCommission(settlement, rule)
+	if err := taxes.apply(settlement); err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to record trip taxes", "SettleCashTrip", utils.ConvertString(err))
+		return err
+	}
+	if err := uc.PaymentRepository.InsertPaymentSettlementTx(ctx, tx, settlement); err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to insert payment settlement", "SettleCashTrip", utils.ConvertString(err))
+		return fmt.Errorf("failed to insert payment settlement: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		uc.Log.Error("wallet-usecase", "failed to commit transaction", "SettleCashTrip", utils.ConvertString(err))
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	uc.Log.Info(
+		"wallet-usecase",
+		fmt.Sprintf("Cash trip settled. order=%s paid=%d commission=%d", req.OrderID, actualPaid, commission),
+		"SettleCashTrip",
+		"",
+	)
+	return nil
+}
@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"strings"
+	"time"
+)
+
+// CommissionFallbackCode is recorded on settlements whose fee came from the
+// configured platform.fee because no commission rule matched the trip.
+const CommissionFallbackCode = "platform.fee"
+
+// commissionTrip is what a commission rule is matched on.
+type commissionTrip struct {
+	City        string
+	VehicleType string
+	ServiceTier string
+	At          time.Time
+}
+
+// resolveCommission picks the commission rule for a completed trip. City and
+// vehicle type are the driver's, the service tier the one its order event
+// carried, and the rule must be in effect when the order was created, with
+// that time of day, in commission.timezone, inside its window. The most specific match wins, then
+// the highest priority, then the latest version. Without a match the trip
+// pays the configured platform.fee.
+func (uc *WalletUseCase) resolveCommission(ctx context.Context, order *entity.Order, driverID string) (*entity.CommissionRule, error) {
+	serviceTier, err := uc.CommissionRules.FindOrderServiceTier(ctx, order.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order service tier: %v", err)
+	}
+	trip := commissionTrip{ServiceTier: serviceTier, At: order.CreatedAt}
+	if driverID != "" {
+		// a driver without an info_driver row only matches rules that leave
+		// city and vehicle type open
+		driver, err := uc.DriverRepository.GetDetailDriver(ctx, driverID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get driver info: %v", err)
+		}
+		if driver != nil {
+			trip.City = driver.City
+			trip.VehicleType = driver.JenisKendaraan
+		}
+	}
+	if loc, err := time.LoadLocation(uc.Config.GetString("commission.timezone")); err == nil {
+		trip.At = trip.At.In(loc)
+	}
+
+	rules, err := uc.CommissionRules.FindEffectiveRules(ctx, order.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commission rules: %v", err)
+	}
+
+	var best *entity.CommissionRule
+	bestScore := -1
+	for i := range rules {
+		rule := &rules[i]
+		score, ok := matchCommissionRule(rule, trip)
+		if !ok {
+			continue
+		}
+		if best == nil || score > bestScore ||
+			(score == bestScore && (rule.Priority > best.Priority ||
+				(rule.Priority == best.Priority && rule.Version > best.Version))) {
+			best, bestScore = rule, score
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+	return &entity.CommissionRule{
+		RuleCode: CommissionFallbackCode,
+		FeeRate:  uc.Config.GetFloat64("platform.fee"),
+	}, nil
+}
+
+// matchCommissionRule reports whether the rule applies to the trip and how
+// many of its criteria were set, so a rule for a city and vehicle type beats
+// one for the city alone.
+func matchCommissionRule(rule *entity.CommissionRule, trip commissionTrip) (int, bool) {
+	score := 0
+	for _, c := range []struct {
+		want *string
+		got  string
+	}{
+		{rule.City, trip.City},
+		{rule.VehicleType, trip.VehicleType},
+		{rule.ServiceTier, trip.ServiceTier},
+	} {
+		if c.want == nil || *c.want == "" {
+			continue
+		}
+		if !strings.EqualFold(*c.want, c.got) {
+			return 0, false
+		}
+		score++
+	}
+
+	if rule.WindowStart != nil && rule.WindowEnd != nil {
+		start, err := clockSeconds(*rule.WindowStart)
+		if err != nil {
+			return 0, false
+		}
+		end, err := clockSeconds(*rule.WindowEnd)
+		if err != nil {
+			return 0, false
+		}
+		at := trip.At.Hour()*3600 + trip.At.Minute()*60 + trip.At.Second()
+		if start <= end {
+			if at < start || at >= end {
+				return 0, false
+			}
+		} else if at < start && at >= end {
+			// the window runs past midnight
+			return 0, false
+		}
+		score++
+	}
+	return score, true
+}
+
+// clockSeconds reads a MySQL TIME of day as seconds since midnight.
+func clockSeconds(s string) (int, error) {
+	t, err := time.Parse("15:04:05", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*3600 + t.Minute()*60 + t.Second(), nil
+}
+
+// applyCommission records on a settlement the rule its platform fee was
+// taken by.
+func applyCommission(settlement *entity.PaymentSettlement, rule *entity.CommissionRule) {
+	if rule.ID != 0 {
+		id := rule.ID
+		settlement.CommissionRuleID = &id
+	}
+	code, version, rate := rule.RuleCode, rule.Version, rule.FeeRate
+	settlement.CommissionRuleCode = &code
+	settlement.CommissionRuleVersion = &version
+	settlement.CommissionRate = &rate
+}
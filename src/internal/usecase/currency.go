@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/gateway/fx"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/money"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+)
+
+// paymentCurrency is the currency orders are charged in, payment.currency.
+// Orders carry no currency of their own.
+func paymentCurrency(config *viper.Viper) string {
+	currency := strings.ToUpper(config.GetString("payment.currency"))
+	if currency == "" {
+		return money.DefaultCurrency
+	}
+	return currency
+}
+
+// methodSupportsCurrency reports whether currency is listed for a provider
+// method, under payment.qris.currencies or, for every VA_<BANK>,
+// payment.va.currencies. Both are rupiah schemes, so only IDR is listed by
+// default.
+func methodSupportsCurrency(config *viper.Viper, method, currency string) bool {
+	key := "payment.qris.currencies"
+	if _, ok := paymentGateway.VaBank(method); ok {
+		key = "payment.va.currencies"
+	}
+	for _, c := range config.GetStringSlice(key) {
+		if strings.EqualFold(c, currency) {
+			return true
+		}
+	}
+	return false
+}
+
+// walletCurrency treats wallets from before the currency column as IDR.
+func walletCurrency(wallet *entity.Wallet) string {
+	if wallet.Currency == "" {
+		return money.DefaultCurrency
+	}
+	return strings.ToUpper(wallet.Currency)
+}
+
+// quoteWalletDebit prices amount, in the payment currency, in the wallet's
+// currency. Across currencies the rate source must list wallet->payment; the
+// debit is rounded up so it always covers the payment. The rate is nil when
+// no conversion was needed.
+func quoteWalletDebit(ctx context.Context, rates fx.RateSource, wallet *entity.Wallet, amount money.Money) (money.Amount, *money.Rate, error) {
+	if walletCurrency(wallet) == amount.Currency {
+		return amount.Amount, nil, nil
+	}
+	if rates == nil {
+		return 0, nil, fmt.Errorf("%w: %s to %s", fx.ErrConversionNotAllowed, walletCurrency(wallet), amount.Currency)
+	}
+	rate, err := rates.Rate(ctx, walletCurrency(wallet), amount.Currency)
+	if err != nil {
+		return 0, nil, err
+	}
+	debit, err := rate.Reverse(amount, money.RoundUp)
+	if err != nil {
+		return 0, nil, err
+	}
+	return debit.Amount, &rate, nil
+}
+
+// quoteWalletCredit prices amount, in the payment currency, in the wallet's
+// currency. Across currencies the rate source must list payment->wallet; the
+// credit is rounded down.
+func quoteWalletCredit(ctx context.Context, rates fx.RateSource, wallet *entity.Wallet, amount money.Money) (money.Amount, *money.Rate, error) {
+	if walletCurrency(wallet) == amount.Currency {
+		return amount.Amount, nil, nil
+	}
+	if rates == nil {
+		return 0, nil, fmt.Errorf("%w: %s to %s", fx.ErrConversionNotAllowed, amount.Currency, walletCurrency(wallet))
+	}
+	rate, err := rates.Rate(ctx, amount.Currency, walletCurrency(wallet))
+	if err != nil {
+		return 0, nil, err
+	}
+	credit, err := rate.Convert(amount, money.RoundDown)
+	if err != nil {
+		return 0, nil, err
+	}
+	return credit.Amount, &rate, nil
+}
+
+// recordFxConversion keeps the rate a wallet movement for a payment was priced
+// at. Nothing is written when no conversion took place.
+func recordFxConversion(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	repo *repository.PaymentRepository,
+	paymentID uint64,
+	purpose string,
+	wallet *entity.Wallet,
+	walletAmount money.Amount,
+	amount money.Money,
+	rate *money.Rate,
+	rates fx.RateSource,
+) error {
+	if rate == nil || rates == nil {
+		return nil
+	}
+	conversion := &entity.PaymentFxConversion{
+		PaymentTransactionID: paymentID,
+		Purpose:              purpose,
+		WalletID:             wallet.ID,
+		WalletCurrency:       walletCurrency(wallet),
+		WalletAmount:         walletAmount,
+		PaymentCurrency:      amount.Currency,
+		PaymentAmount:        amount.Amount,
+		RatePair:             rate.Pair(),
+		Rate:                 rate.String(),
+		RateSource:           rates.Name(),
+	}
+	if err := repo.InsertFxConversionTx(ctx, tx, conversion); err != nil {
+		return fmt.Errorf("failed to record fx conversion: %v", err)
+	}
+	return nil
+}
+
+// walletShare converts part of a payment back into the wallet's currency in
+// proportion to the recorded conversion, so releasing or refunding a wallet
+// movement returns what was actually moved, at the rate it was priced at.
+func walletShare(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	repo *repository.PaymentRepository,
+	paymentID uint64,
+	purpose string,
+	wallet *entity.Wallet,
+	amount money.Money,
+) (money.Amount, error) {
+	if walletCurrency(wallet) == amount.Currency {
+		return amount.Amount, nil
+	}
+	conversion, err := repo.FindFxConversionTx(ctx, tx, paymentID, purpose)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get fx conversion: %v", err)
+	}
+	if conversion == nil || conversion.WalletCurrency != walletCurrency(wallet) {
+		return 0, fmt.Errorf("no %s fx conversion recorded for payment %d in %s", purpose, paymentID, walletCurrency(wallet))
+	}
+	return conversion.WalletAmount.Prorate(amount.Amount, conversion.PaymentAmount, money.RoundHalfUp), nil
+}
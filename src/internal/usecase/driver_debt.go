@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/money"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+)
+
+// driverDebtLedger keeps what a driver owes the platform once their wallet
+// cannot cover it. It runs in the caller's transaction with the driver's
+// wallet already locked; the caller moves the wallet balance itself.
+type driverDebtLedger struct {
+	DebtRepository   *repository.DriverDebtRepository
+	DriverRepository *repository.DriverRepository
+	// CreditLimit is the debt, in minor units of the wallet currency, a
+	// driver may carry and stay online (driver.debt.credit_limit).
+	CreditLimit money.Amount
+}
+
+func newDriverDebtLedger(config *viper.Viper, debtRepo *repository.DriverDebtRepository, driverRepo *repository.DriverRepository) driverDebtLedger {
+	return driverDebtLedger{
+		DebtRepository:   debtRepo,
+		DriverRepository: driverRepo,
+		CreditLimit:      money.Amount(config.GetInt64("driver.debt.credit_limit")),
+	}
+}
+
+// charge takes amount from the driver's wallet as far as its balance goes and
+// books the rest as debt. It returns the part to debit from the wallet. A
+// driver whose debt goes above the credit limit is taken offline.
+func (l driverDebtLedger) charge(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	wallet *entity.Wallet,
+	amount money.Amount,
+	paymentID *uint64,
+	source string,
+	description string,
+) (money.Amount, error) {
+	fromWallet := amount
+	if fromWallet > wallet.Balance {
+		fromWallet = max(wallet.Balance, 0)
+	}
+	shortfall := amount - fromWallet
+	if shortfall <= 0 {
+		return fromWallet, nil
+	}
+
+	debt, err := l.debtForUpdate(ctx, tx, wallet)
+	if err != nil {
+		return 0, err
+	}
+	outstanding := debt.Outstanding + shortfall
+	blockedAt := debt.BlockedAt
+	if blockedAt == nil && outstanding > l.CreditLimit {
+		now := time.Now()
+		blockedAt = &now
+		if err := l.DriverRepository.SetOfflineTx(ctx, tx, wallet.UserID); err != nil {
+			return 0, err
+		}
+	}
+	if err := l.record(ctx, tx, wallet.UserID, entity.DriverDebtEntryDebt, shortfall, outstanding, blockedAt, paymentID, source, description); err != nil {
+		return 0, err
+	}
+	return fromWallet, nil
+}
+
+// repay pays the driver's debt first out of amount, money about to be
+// credited to their wallet. It returns the part of amount the debt took. Once
+// the debt is back within the credit limit the driver may go online again.
+func (l driverDebtLedger) repay(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	wallet *entity.Wallet,
+	amount money.Amount,
+	paymentID *uint64,
+	source string,
+	description string,
+) (money.Amount, error) {
+	if amount <= 0 {
+		return 0, nil
+	}
+	debt, err := l.DebtRepository.GetDebtForUpdate(ctx, tx, wallet.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get driver debt: %v", err)
+	}
+	if debt == nil || debt.Outstanding <= 0 {
+		return 0, nil
+	}
+	if debt.Currency != walletCurrency(wallet) {
+		return 0, fmt.Errorf("driver debt is in %s, wallet is in %s", debt.Currency, walletCurrency(wallet))
+	}
+
+	repaid := min(amount, debt.Outstanding)
+	outstanding := debt.Outstanding - repaid
+	blockedAt := debt.BlockedAt
+	if outstanding <= l.CreditLimit {
+		blockedAt = nil
+	}
+	if err := l.record(ctx, tx, wallet.UserID, entity.DriverDebtEntryRepayment, repaid, outstanding, blockedAt, paymentID, source, description); err != nil {
+		return 0, err
+	}
+	return repaid, nil
+}
+
+func (l driverDebtLedger) debtForUpdate(ctx context.Context, tx *sqlx.Tx, wallet *entity.Wallet) (*entity.DriverDebt, error) {
+	debt, err := l.DebtRepository.GetDebtForUpdate(ctx, tx, wallet.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver debt: %v", err)
+	}
+	if debt != nil {
+		if debt.Currency != walletCurrency(wallet) {
+			return nil, fmt.Errorf("driver debt is in %s, wallet is in %s", debt.Currency, walletCurrency(wallet))
+		}
+		return debt, nil
+	}
+
+	debt = &entity.DriverDebt{
+		DriverID: wallet.UserID,
+		Currency: walletCurrency(wallet),
+	}
+	if err := l.DebtRepository.InsertDebt(ctx, tx, debt); err != nil {
+		return nil, fmt.Errorf("failed to create driver debt: %v", err)
+	}
+	return debt, nil
+}
+
+func (l driverDebtLedger) record(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	driverID string,
+	entryType string,
+	amount money.Amount,
+	outstanding money.Amount,
+	blockedAt *time.Time,
+	paymentID *uint64,
+	source string,
+	description string,
+) error {
+	if err := l.DebtRepository.UpdateDebt(ctx, tx, driverID, outstanding, blockedAt); err != nil {
+		return fmt.Errorf("failed to update driver debt: %v", err)
+	}
+	entry := &entity.DriverDebtEntry{
+		DriverID:             driverID,
+		PaymentTransactionID: paymentID,
+		EntryType:            entryType,
+		Source:               source,
+		Amount:               amount,
+		OutstandingAfter:     outstanding,
+		Description:          &description,
+	}
+	if err := l.DebtRepository.InsertEntry(ctx, tx, entry); err != nil {
+		return fmt.Errorf("failed to insert driver debt entry: %v", err)
+	}
+	return nil
+}
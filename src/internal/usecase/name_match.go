@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"strings"
+	"unicode"
+)
+
+// nameTitles are honorifics banks and users add to names; they say nothing
+// about who holds an account.
+var nameTitles = map[string]bool{
+	"BPK": true, "BAPAK": true, "IBU": true, "SDR": true, "SDRI": true,
+	"TN": true, "NY": true, "NN": true, "MR": true, "MRS": true, "MS": true,
+	"DR": true, "IR": true, "H": true, "HJ": true, "ST": true, "SE": true,
+}
+
+// nameMatchScore compares the holder name a bank returned with the driver's
+// name, from 0 to 1. Names are compared word by word regardless of order,
+// counting an initial or a truncated word as a match for the full word and
+// tolerating a typo in a longer one; the score is the Dice coefficient of the
+// matched words.
+func nameMatchScore(a, b string) float64 {
+	left, right := nameTokens(a), nameTokens(b)
+	if len(left) == 0 || len(right) == 0 {
+		return 0
+	}
+	used := make([]bool, len(right))
+	var matched float64
+	for _, l := range left {
+		best, bestIdx := 0.0, -1
+		for i, r := range right {
+			if used[i] {
+				continue
+			}
+			if score := tokenScore(l, r); score > best {
+				best, bestIdx = score, i
+			}
+		}
+		if bestIdx >= 0 {
+			used[bestIdx] = true
+			matched += best
+		}
+	}
+	score := 2 * matched / float64(len(left)+len(right))
+
+	// names written without spaces, e.g. NURHALIZA against NUR HALIZA
+	if joined := similarity(strings.Join(left, ""), strings.Join(right, "")); joined > score {
+		score = joined
+	}
+	return score
+}
+
+func nameTokens(name string) []string {
+	fields := strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	tokens := fields[:0]
+	for _, f := range fields {
+		if !nameTitles[f] {
+			tokens = append(tokens, f)
+		}
+	}
+	return tokens
+}
+
+func tokenScore(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	short, long := a, b
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	// an initial, or a word the bank cut short
+	if strings.HasPrefix(long, short) && (len(short) == 1 || len(short) >= 3) {
+		return 1
+	}
+	if sim := similarity(a, b); sim >= 0.8 {
+		return sim
+	}
+	return 0
+}
+
+// similarity is 1 less the Levenshtein distance over the longer length.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameMatchScore(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"identical", "BUDI SANTOSO", "BUDI SANTOSO", 1},
+		{"case and punctuation", "budi-santoso", "BUDI SANTOSO", 1},
+		{"word order", "SANTOSO BUDI", "Budi Santoso", 1},
+		{"title", "BPK BUDI SANTOSO", "Budi Santoso", 1},
+		{"titles on both sides", "Dr. Ir. Siti Aminah", "IBU SITI AMINAH", 1},
+		{"initial", "B SANTOSO", "BUDI SANTOSO", 1},
+		{"truncated by the bank", "BUDI SANTO", "BUDI SANTOSO", 1},
+		{"typo in a long word", "BUDI SANTOZO", "BUDI SANTOSO", 13.0 / 14},
+		{"written without a space", "NURHALIZA", "NUR HALIZA", 1},
+		// a two-letter prefix is not an initial; the joined names carry it
+		{"two letter prefix", "BU SANTOSO", "BUDI SANTOSO", 9.0 / 11},
+		{"missing surname", "BUDI", "BUDI SANTOSO", 2.0 / 3},
+		{"empty", "", "BUDI SANTOSO", 0},
+		{"only a title", "BPK", "BUDI SANTOSO", 0},
+		{"no letters", "12345", "67890", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, nameMatchScore(tt.a, tt.b), 1e-9)
+			assert.InDelta(t, tt.want, nameMatchScore(tt.b, tt.a), 1e-9, "score is symmetric")
+		})
+	}
+}
+
+func TestNameMatchScoreRejectsOtherPeople(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"different person", "BUDI SANTOSO", "SITI AMINAH"},
+		{"shared first name", "BUDI SANTOSO", "BUDI HARTONO"},
+		{"short different words", "ANI", "IRA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Less(t, nameMatchScore(tt.a, tt.b), 0.8)
+		})
+	}
+}
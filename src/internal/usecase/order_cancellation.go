@@ -0,0 +1,283 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/model"
+	"payment-service/src/pkg/money"
+	"payment-service/src/pkg/utils"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SettlementMethodCancellationFee marks the settlement that pays a driver
+// their share of a cancellation fee.
+const SettlementMethodCancellationFee = "CANCELLATION_FEE"
+
+// CancelledByPassenger is the only party a cancellation fee is charged to.
+const CancelledByPassenger = "PASSENGER"
+
+// CancelOrderPayment settles the wallet hold of a cancelled order. The hold is
+// released back to the passenger unless the passenger cancelled after pickup or
+// more than cancellation.free_minutes after the hold was taken; then
+// cancellation.fee is kept from the hold and cancellation.driver_share of it
+// goes to the driver. Holds that are already settled are left alone, so a
+// redelivered event changes nothing.
+func (uc *WalletUseCase) CancelOrderPayment(ctx context.Context, req *model.OrderCancelledEvent) error {
+	if req.EventType != "ORDER_CANCELLED" {
+		uc.Log.Info("wallet-usecase", fmt.Sprintf("Skipping event %s for order %s", req.EventType, req.OrderID), "CancelOrderPayment", "")
+		return nil
+	}
+	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &req.OrderID, PassengerID: &req.PassengerID})
+	if err != nil || order == nil {
+		uc.Log.Error("wallet-usecase", "Order not found", "CancelOrderPayment", utils.ConvertString(err))
+		return fmt.Errorf("order not found")
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		uc.Log.Error("wallet-usecase", "failed to get db connection", "CancelOrderPayment", utils.ConvertString(err))
+		return fmt.Errorf("failed to get db connection, error : %v", err)
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		uc.Log.Error("wallet-usecase", "failed to start transaction", "CancelOrderPayment", utils.ConvertString(err))
+		return fmt.Errorf("failed to start transaction, error : %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := uc.OrderRepository.LockOrderTx(ctx, tx.Tx, order.ID); err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to lock order", "CancelOrderPayment", utils.ConvertString(err))
+		return fmt.Errorf("failed to lock order, error : %v", err)
+	}
+	holds, err := uc.PaymentRepository.FindWalletHoldsForUpdate(ctx, tx, order.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to get wallet holds", "CancelOrderPayment", utils.ConvertString(err))
+		return fmt.Errorf("failed to get wallet holds, error : %v", err)
+	}
+	if len(holds) == 0 {
+		_ = tx.Rollback()
+		uc.Log.Info("wallet-usecase", fmt.Sprintf("No wallet hold left for cancelled order %s", req.OrderID), "CancelOrderPayment", "")
+		return nil
+	}
+
+	wallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, req.PassengerID)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to get wallet", "CancelOrderPayment", utils.ConvertString(err))
+		return fmt.Errorf("failed to get wallet, error : %v", err)
+	}
+	if wallet == nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "Wallet not found for passenger", "CancelOrderPayment", req.PassengerID)
+		return fmt.Errorf("wallet not found for passenger")
+	}
+
+	driverID := req.DriverID
+	if order.DriverID != nil && *order.DriverID != "" {
+		driverID = *order.DriverID
+	}
+	fee := uc.cancellationFee(req, holds)
+	rawPayload := utils.ConvertString(req)
+	var parentID *uint64
+	for i := range holds {
+		hold := &holds[i]
+		take := min(fee, hold.Amount)
+		fee -= take
+		if err := uc.cancelHold(ctx, tx, req, wallet, hold, take, driverID, &rawPayload); err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to settle wallet hold", "CancelOrderPayment", utils.ConvertString(err))
+			return err
+		}
+		if hold.ParentPaymentID != nil {
+			parentID = hold.ParentPaymentID
+		}
+	}
+	if parentID != nil {
+		if err := uc.cancelSplitParent(ctx, tx, req, *parentID, &rawPayload); err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to cancel split payment", "CancelOrderPayment", utils.ConvertString(err))
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		uc.Log.Error("wallet-usecase", "failed to commit transaction", "CancelOrderPayment", utils.ConvertString(err))
+		return err
+	}
+	uc.Log.Info("wallet-usecase", fmt.Sprintf("Settled wallet hold for cancelled order %s", req.OrderID), "CancelOrderPayment", rawPayload)
+	return nil
+}
+
+// cancellationFee is the fee owed for the cancellation, in minor units of the
+// payment currency. Only the passenger pays one, and only once the driver has
+// picked them up or the free cancellation window since the hold has passed.
+func (uc *WalletUseCase) cancellationFee(req *model.OrderCancelledEvent, holds []entity.PaymentTransaction) money.Amount {
+	if req.CancelledBy != CancelledByPassenger {
+		return 0
+	}
+	cancelledAt := req.Timestamp
+	if cancelledAt.IsZero() {
+		cancelledAt = time.Now()
+	}
+	freeWindow := time.Duration(uc.Config.GetInt("cancellation.free_minutes")) * time.Minute
+	if req.PreviousStatus != "ON_GOING" && cancelledAt.Sub(holds[0].CreatedAt) <= freeWindow {
+		return 0
+	}
+	return money.Amount(uc.Config.GetInt64("cancellation.fee"))
+}
+
+// cancelHold releases a wallet hold back to the passenger, capturing fee from
+// it when one is due. A hold that pays a fee succeeds for the fee amount and the
+// driver is credited their share; otherwise it is CANCELLED.
+func (uc *WalletUseCase) cancelHold(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	req *model.OrderCancelledEvent,
+	wallet *entity.Wallet,
+	hold *entity.PaymentTransaction,
+	fee money.Amount,
+	driverID string,
+	rawPayload *string,
+) error {
+	source := fmt.Sprintf("order cancelled by %s", req.CancelledBy)
+	if fee > 0 {
+		walletFee, err := walletShare(ctx, tx, uc.PaymentRepository, hold.ID, entity.FxPurposeHold, wallet, money.New(fee, hold.Currency))
+		if err != nil {
+			return err
+		}
+		if _, err := captureHold(ctx, tx, uc.WalletRepository, wallet, hold.ID, walletFee, fmt.Sprintf("Cancellation fee for order %s", req.OrderID)); err != nil {
+			return err
+		}
+	} else if _, err := releaseHold(ctx, tx, uc.WalletRepository, wallet, hold.ID, entity.WalletHoldReleased); err != nil {
+		return err
+	}
+	if released := hold.Amount - fee; released > 0 {
+		event := &entity.PaymentEventLog{
+			PaymentTransactionID: hold.ID,
+			EventType:            "RELEASE",
+			EventDescription:     fmt.Sprintf("Order cancelled, released %d to passenger", released),
+			RawPayload:           rawPayload,
+		}
+		if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+			return fmt.Errorf("failed to insert release event log: %v", err)
+		}
+	}
+
+	if fee <= 0 {
+		if err := transitionPayment(ctx, uc.PaymentRepository, tx, hold, entity.PaymentStatusCancelled, source, rawPayload); err != nil {
+			return err
+		}
+		event := &entity.PaymentEventLog{
+			PaymentTransactionID: hold.ID,
+			EventType:            "CANCELLED",
+			EventDescription:     fmt.Sprintf("Order cancelled by %s: %s", req.CancelledBy, req.Reason),
+			RawPayload:           rawPayload,
+		}
+		if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+			return fmt.Errorf("failed to insert cancelled event log: %v", err)
+		}
+		return nil
+	}
+
+	hold.Amount = fee
+	if err := transitionPayment(ctx, uc.PaymentRepository, tx, hold, entity.PaymentStatusSuccess, source, rawPayload); err != nil {
+		return err
+	}
+	// the fee is not the trip, so the promo it was discounted by goes back
+	if err := reversePaymentPromo(ctx, tx, uc.PaymentRepository, hold.ID, source); err != nil {
+		return err
+	}
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: hold.ID,
+		EventType:            "CANCELLATION_FEE",
+		EventDescription:     fmt.Sprintf("Order cancelled by %s after the free window (%s), kept %d as cancellation fee", req.CancelledBy, req.PreviousStatus, fee),
+		RawPayload:           rawPayload,
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return fmt.Errorf("failed to insert cancellation fee event log: %v", err)
+	}
+	if driverID == "" {
+		return nil
+	}
+	return uc.compensateDriver(ctx, tx, req, hold, driverID)
+}
+
+// compensateDriver pays the driver cancellation.driver_share of the fee kept
+// on hold and records it as a CANCELLATION_FEE settlement.
+func (uc *WalletUseCase) compensateDriver(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	req *model.OrderCancelledEvent,
+	hold *entity.PaymentTransaction,
+	driverID string,
+) error {
+	share := hold.Amount.MulRate(uc.Config.GetFloat64("cancellation.driver_share"), money.RoundDown)
+	if share > 0 {
+		wallet, err := uc.driverWalletForUpdate(ctx, tx, driverID, hold.Currency)
+		if err != nil {
+			return err
+		}
+		shareMoney := money.New(share, hold.Currency)
+		credit, rate, err := quoteWalletCredit(ctx, uc.Rates, wallet, shareMoney)
+		if err != nil {
+			return err
+		}
+		if err := recordFxConversion(ctx, tx, uc.PaymentRepository, hold.ID, entity.FxPurposeSettlement, wallet, credit, shareMoney, rate, uc.Rates); err != nil {
+			return err
+		}
+		if err := uc.creditDriverEarning(ctx, tx, wallet, credit, hold.ID, entity.DriverDebtSourceCancellation, req.OrderID, fmt.Sprintf("Cancellation compensation for order %s", req.OrderID)); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	settlement := &entity.PaymentSettlement{
+		PaymentTransactionID: hold.ID,
+		DriverID:             driverID,
+		SettlementAmount:     share,
+		PlatformFee:          hold.Amount - share,
+		TaxAmount:            0,
+		Status:               "PAID",
+		SettlementMethod:     SettlementMethodCancellationFee,
+		SettledAt:            &now,
+		CreatedAt:            now,
+	}
+	if err := uc.PaymentRepository.InsertPaymentSettlementTx(ctx, tx, settlement); err != nil {
+		return fmt.Errorf("failed to insert cancellation settlement: %v", err)
+	}
+	return nil
+}
+
+// cancelSplitParent closes the split payment a cancelled wallet part belonged
+// to. Provider parts still open are left to expire.
+func (uc *WalletUseCase) cancelSplitParent(ctx context.Context, tx *sqlx.Tx, req *model.OrderCancelledEvent, parentID uint64, rawPayload *string) error {
+	parent, err := uc.PaymentRepository.FindByIDForUpdate(ctx, tx, parentID)
+	if err != nil {
+		return fmt.Errorf("failed to get split parent payment: %v", err)
+	}
+	if parent == nil || parent.PaymentStatus != entity.PaymentStatusPending {
+		return nil
+	}
+	if err := transitionPayment(ctx, uc.PaymentRepository, tx, parent, entity.PaymentStatusCancelled, fmt.Sprintf("order cancelled by %s", req.CancelledBy), rawPayload); err != nil {
+		return err
+	}
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: parent.ID,
+		EventType:            "CANCELLED",
+		EventDescription:     fmt.Sprintf("Order cancelled by %s: %s", req.CancelledBy, req.Reason),
+		RawPayload:           rawPayload,
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return fmt.Errorf("failed to insert cancelled event log: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/gateway/fx"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/databases/mysql"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+)
+
+type PaymentExpiryUseCase struct {
+	Log               log.Log
+	Config            *viper.Viper
+	OrderRepository   *repository.OrderRepository
+	WalletRepository  *repository.WalletRepository
+	PaymentRepository *repository.PaymentRepository
+	DriverRepository  *repository.DriverRepository
+	DebtRepository    *repository.DriverDebtRepository
+	DB                mysql.DBInterface
+	Providers         *paymentGateway.Registry
+	Rates             fx.RateSource
+}
+
+func NewPaymentExpiryUseCase(
+	log log.Log,
+	config *viper.Viper,
+	orderRepo *repository.OrderRepository,
+	walletRepo *repository.WalletRepository,
+	paymentRepo *repository.PaymentRepository,
+	driverRepo *repository.DriverRepository,
+	debtRepo *repository.DriverDebtRepository,
+	db mysql.DBInterface,
+	providers *paymentGateway.Registry,
+	rates fx.RateSource,
+) *PaymentExpiryUseCase {
+	return &PaymentExpiryUseCase{
+		Log:               log,
+		Config:            config,
+		OrderRepository:   orderRepo,
+		WalletRepository:  walletRepo,
+		PaymentRepository: paymentRepo,
+		DriverRepository:  driverRepo,
+		DebtRepository:    debtRepo,
+		DB:                db,
+		Providers:         providers,
+		Rates:             rates,
+	}
+}
+
+// ExpirePendingPayments moves one batch of PENDING payments past their
+// expired_at to EXPIRED. A failure on one payment is logged and does not stop
+// the rest of the batch.
+func (uc *PaymentExpiryUseCase) ExpirePendingPayments(ctx context.Context) error {
+	batchSize := uc.Config.GetInt("scheduler.payment_expiry.batch_size")
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	payments, err := uc.PaymentRepository.FindExpiredPendingPayments(ctx, batchSize)
+	if err != nil {
+		uc.Log.Error("payment-expiry-usecase", "failed to get expired payments", "ExpirePendingPayments", utils.ConvertString(err))
+		return fmt.Errorf("failed to get expired payments: %v", err)
+	}
+
+	expired := 0
+	for i := range payments {
+		ok, err := uc.expirePayment(ctx, payments[i].ID)
+		if err != nil {
+			uc.Log.Error("payment-expiry-usecase", fmt.Sprintf("failed to expire payment %d", payments[i].ID), "ExpirePendingPayments", utils.ConvertString(err))
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	if len(payments) > 0 {
+		uc.Log.Info("payment-expiry-usecase",
+			fmt.Sprintf("Expired %d of %d stale pending payments", expired, len(payments)),
+			"ExpirePendingPayments", "")
+	}
+	return nil
+}
+
+// expirePayment closes one payment. A provider payment is queried, and
+// cancelled when still open, before any row is locked; the payment is then
+// locked and checked again, so a webhook that settled it meanwhile wins.
+func (uc *PaymentExpiryUseCase) expirePayment(ctx context.Context, paymentID uint64) (bool, error) {
+	candidate, err := uc.PaymentRepository.FindByID(ctx, paymentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get payment transaction: %v", err)
+	}
+	if !expiredPending(candidate, time.Now()) {
+		return false, nil
+	}
+
+	var status *paymentGateway.StatusResponse
+	if !isWalletMethod(candidate.PaymentMethod) {
+		status, err = uc.closeAtProvider(ctx, candidate)
+		if err != nil {
+			return false, err
+		}
+		if status != nil && status.Status != entity.PaymentStatusPending && status.Status != entity.PaymentStatusFailed && status.Status != entity.PaymentStatusExpired {
+			// the provider has moved on (e.g. settled); leave it for the webhook
+			uc.Log.Info("payment-expiry-usecase",
+				fmt.Sprintf("Payment %d is %s at provider, not expiring", candidate.ID, status.TransactionStatus),
+				"expirePayment", "")
+			return false, nil
+		}
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		return false, fmt.Errorf("failed to get db connection: %v", err)
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	paymentTx, err := uc.PaymentRepository.FindByIDForUpdate(ctx, tx, paymentID)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, fmt.Errorf("failed to get payment transaction: %v", err)
+	}
+	if !expiredPending(paymentTx, time.Now()) {
+		// settled or extended while the provider was asked
+		_ = tx.Rollback()
+		return false, nil
+	}
+
+	description := "Pending payment expired"
+	var rawPayload *string
+
+	if isWalletMethod(paymentTx.PaymentMethod) {
+		if err := uc.releaseWalletHold(ctx, tx, paymentTx); err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+		description = fmt.Sprintf("Wallet hold expired, released %d to passenger", paymentTx.Amount)
+	} else if status != nil {
+		rawPayload = &status.RawPayload
+		description = fmt.Sprintf("Pending payment expired, provider status: %s", status.TransactionStatus)
+	}
+
+	if err := transitionPayment(ctx, uc.PaymentRepository, tx, paymentTx, entity.PaymentStatusExpired, "expiry sweeper", rawPayload); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentTx.ID,
+		EventType:            "EXPIRE",
+		EventDescription:     description,
+		RawPayload:           rawPayload,
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		_ = tx.Rollback()
+		return false, fmt.Errorf("failed to insert expire event log: %v", err)
+	}
+
+	reconciler := splitReconciler{
+		PaymentRepository: uc.PaymentRepository,
+		WalletRepository:  uc.WalletRepository,
+		OrderRepository:   uc.OrderRepository,
+		DebtLedger:        newDriverDebtLedger(uc.Config, uc.DebtRepository, uc.DriverRepository),
+		Rates:             uc.Rates,
+	}
+	if err := reconciler.reconcile(ctx, tx, paymentTx); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return true, nil
+}
+
+// expiredPending reports whether p is still PENDING past its expired_at.
+func expiredPending(p *entity.PaymentTransaction, now time.Time) bool {
+	return p != nil && p.PaymentStatus == entity.PaymentStatusPending && p.ExpiredAt != nil && !p.ExpiredAt.After(now)
+}
+
+// closeAtProvider asks the provider for the latest state and cancels the
+// charge when it is still open there; it is called with no row locked. A nil
+// status means the provider has no such transaction, which happens when a
+// Snap page was never opened. Any other failure to ask is returned, so the
+// payment is left for the next sweep rather than expired while it may still
+// settle.
+func (uc *PaymentExpiryUseCase) closeAtProvider(ctx context.Context, paymentTx *entity.PaymentTransaction) (*paymentGateway.StatusResponse, error) {
+	provider, err := providerForPayment(uc.Providers, paymentTx)
+	if err != nil {
+		return nil, fmt.Errorf("payment provider not configured: %v", err)
+	}
+
+	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{ID: &paymentTx.RideOrderID})
+	if err != nil || order == nil {
+		return nil, fmt.Errorf("order not found for payment %d", paymentTx.ID)
+	}
+	reference := providerReference(paymentTx, order.OrderID)
+
+	status, err := provider.QueryStatus(ctx, reference)
+	if errors.Is(err, paymentGateway.ErrTransactionNotFound) {
+		uc.Log.Info("payment-expiry-usecase",
+			fmt.Sprintf("Provider has no transaction for payment %d: %v", paymentTx.ID, err),
+			"closeAtProvider", "")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider status: %v", err)
+	}
+	if status.Status != entity.PaymentStatusPending {
+		return status, nil
+	}
+
+	cancelled, err := provider.Cancel(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel payment at provider: %v", err)
+	}
+	return cancelled, nil
+}
+
+func (uc *PaymentExpiryUseCase) releaseWalletHold(ctx context.Context, tx *sqlx.Tx, paymentTx *entity.PaymentTransaction) error {
+	wallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, paymentTx.PassengerID)
+	if err != nil {
+		return fmt.Errorf("failed to get passenger wallet: %v", err)
+	}
+	if wallet == nil {
+		return fmt.Errorf("passenger wallet not found")
+	}
+
+	if _, err := releaseHold(ctx, tx, uc.WalletRepository, wallet, paymentTx.ID, entity.WalletHoldExpired); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"payment-service/src/internal/entity"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/model/converter"
+	"payment-service/src/internal/repository"
+	httpError "payment-service/src/pkg/http-error"
+	"payment-service/src/pkg/money"
+	"payment-service/src/pkg/utils"
+)
+
+// ListPaymentMethods returns the catalog methods the passenger can pay the
+// order with, judged on the order amount and the assigned driver's city and
+// vehicle.
+func (uc *PaymentUseCase) ListPaymentMethods(ctx context.Context, req *model.ListPaymentMethodsRequest) utils.Result {
+	var result utils.Result
+
+	if req.OrderID == "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "orderId is required"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "ListPaymentMethods", utils.ConvertString(req))
+		return result
+	}
+
+	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{
+		OrderID:     &req.OrderID,
+		PassengerID: &req.UserID,
+	})
+	if err != nil || order == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "order not found"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "ListPaymentMethods", utils.ConvertString(err))
+		return result
+	}
+
+	amount := uc.calculateFinalAmount(order)
+	driverID := ""
+	if order.DriverID != nil {
+		driverID = *order.DriverID
+	}
+	mc := methodContext(ctx, uc.DriverRepository, driverID, amount)
+
+	response := &model.PaymentMethodsResponse{
+		OrderID:  order.OrderID,
+		Amount:   amount,
+		Currency: paymentCurrency(uc.Config),
+		Methods:  make([]model.PaymentMethodResponse, 0),
+	}
+	for _, method := range uc.Methods.Available(mc) {
+		response.Methods = append(response.Methods, converter.PaymentMethodToResponse(method, uc.methodProvider(method)))
+	}
+
+	result.Data = response
+	return result
+}
+
+// methodProvider names the provider charging method: the one configured on
+// the method, else whatever payment.provider.<code> resolves to.
+func (uc *PaymentUseCase) methodProvider(method paymentGateway.Method) string {
+	if method.Provider != "" {
+		return method.Provider
+	}
+	provider, err := uc.Providers.ForMethod(method.Code)
+	if err != nil {
+		return ""
+	}
+	return provider.Name()
+}
+
+// methodContext describes an order to the payment method catalog. City and
+// vehicle type are the driver's; without a driver, or when the driver's info
+// cannot be read, they stay empty.
+func methodContext(ctx context.Context, drivers *repository.DriverRepository, driverID string, amount money.Amount) paymentGateway.MethodContext {
+	mc := paymentGateway.MethodContext{Amount: amount}
+	if drivers == nil || driverID == "" {
+		return mc
+	}
+	driver, err := drivers.GetDetailDriver(ctx, driverID)
+	if err != nil || driver == nil {
+		return mc
+	}
+	mc.City = driver.City
+	mc.VehicleType = driver.JenisKendaraan
+	return mc
+}
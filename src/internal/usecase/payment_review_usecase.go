@@ -0,0 +1,276 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/model/converter"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/databases/mysql"
+	httpError "payment-service/src/pkg/http-error"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type PaymentReviewUseCase struct {
+	Log                     log.Log
+	Config                  *viper.Viper
+	PaymentReviewRepository *repository.PaymentReviewRepository
+	PaymentUseCase          *PaymentUseCase
+	DB                      mysql.DBInterface
+}
+
+func NewPaymentReviewUseCase(
+	log log.Log,
+	config *viper.Viper,
+	reviewRepo *repository.PaymentReviewRepository,
+	paymentUseCase *PaymentUseCase,
+	db mysql.DBInterface,
+) *PaymentReviewUseCase {
+	return &PaymentReviewUseCase{
+		Log:                     log,
+		Config:                  config,
+		PaymentReviewRepository: reviewRepo,
+		PaymentUseCase:          paymentUseCase,
+		DB:                      db,
+	}
+}
+
+func (uc *PaymentReviewUseCase) ListReviews(ctx context.Context, req *model.ListPaymentReviewRequest) utils.Result {
+	var result utils.Result
+
+	status := entity.PaymentReviewOpen
+	if req.Status != "" {
+		status = strings.ToUpper(req.Status)
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	reviews, err := uc.PaymentReviewRepository.FindByStatus(ctx, status, limit, offset)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payment reviews"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "ListReviews", utils.ConvertString(err))
+		return result
+	}
+
+	response := make([]*model.PaymentReviewResponse, 0, len(reviews))
+	for i := range reviews {
+		response = append(response, converter.PaymentReviewToResponse(&reviews[i]))
+	}
+	result.Data = response
+	return result
+}
+
+// DecideReview approves or denies a challenged payment at the provider and
+// then applies the provider's answer through the same path as a webhook, so
+// an approval marks the order PAID exactly like a normal settlement.
+func (uc *PaymentReviewUseCase) DecideReview(ctx context.Context, req *model.DecidePaymentReviewRequest) utils.Result {
+	var result utils.Result
+
+	if req.ReviewedBy == "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "reviewedBy is required"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(req))
+		return result
+	}
+
+	decision := "DENY"
+	if req.Approve {
+		decision = "APPROVE"
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	review, err := uc.PaymentReviewRepository.FindByIDForUpdate(ctx, tx, req.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payment review"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+	if review == nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewNotFound()
+		errObj.Message = "payment review not found"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", fmt.Sprintf("%d", req.ID))
+		return result
+	}
+	if review.Status != entity.PaymentReviewOpen {
+		_ = tx.Rollback()
+		errObj := httpError.NewConflict()
+		errObj.Message = "payment review already " + strings.ToLower(review.Status)
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", fmt.Sprintf("%d", req.ID))
+		return result
+	}
+
+	paymentRepo := uc.PaymentUseCase.PaymentRepository
+	paymentTx, err := paymentRepo.FindByIDForUpdate(ctx, tx, review.PaymentTransactionID)
+	if err != nil || paymentTx == nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payment transaction"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+	if paymentTx.PaymentStatus != entity.PaymentStatusChallenge {
+		_ = tx.Rollback()
+		errObj := httpError.NewConflict()
+		errObj.Message = "payment is " + paymentTx.PaymentStatus + ", not under challenge"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", fmt.Sprintf("%d", paymentTx.ID))
+		return result
+	}
+
+	requested := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentTx.ID,
+		EventType:            "REVIEW_" + decision,
+		EventDescription:     fmt.Sprintf("Review %d: %s requested by %s. %s", review.ID, decision, req.ReviewedBy, req.Note),
+	}
+	if err := paymentRepo.InsertPaymentEventLogTx(ctx, tx.Tx, requested); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to insert payment event log"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+
+	provider, err := providerForPayment(uc.PaymentUseCase.Providers, paymentTx)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "payment provider not configured"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+
+	reference := providerReference(paymentTx, review.OrderID)
+
+	call := provider.Deny
+	if req.Approve {
+		call = provider.Approve
+	}
+	status, err := call(ctx, reference)
+	if err != nil {
+		// keep the request and the failure on record; the review stays open
+		failed := &entity.PaymentEventLog{
+			PaymentTransactionID: paymentTx.ID,
+			EventType:            "REVIEW_" + decision + "_FAILED",
+			EventDescription:     fmt.Sprintf("Review %d: provider %s failed: %v", review.ID, strings.ToLower(decision), err),
+		}
+		if logErr := paymentRepo.InsertPaymentEventLogTx(ctx, tx.Tx, failed); logErr != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to " + strings.ToLower(decision) + " payment at provider"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+
+	notif := status.Notification()
+	outcome, errObj := uc.PaymentUseCase.applyProviderUpdate(ctx, tx, paymentTx, provider.Name(), notif, "DecideReview")
+	if errObj != nil {
+		_ = tx.Rollback()
+		result.Error = errObj
+		return result
+	}
+
+	now := time.Now()
+	providerResponse := status.RawPayload
+	review.Status = entity.PaymentReviewDenied
+	if req.Approve {
+		review.Status = entity.PaymentReviewApproved
+	}
+	review.ReviewedBy = &req.ReviewedBy
+	if req.Note != "" {
+		review.ReviewNote = &req.Note
+	}
+	review.ProviderResponse = &providerResponse
+	review.ReviewedAt = &now
+	if err := uc.PaymentReviewRepository.UpdateReviewTx(ctx, tx, review); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to update payment review"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+
+	decided := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentTx.ID,
+		EventType:            "REVIEW_" + review.Status,
+		EventDescription: fmt.Sprintf("Review %d %s by %s, provider status %s, payment %s",
+			review.ID, strings.ToLower(review.Status), req.ReviewedBy, status.TransactionStatus, paymentTx.PaymentStatus),
+		RawPayload: &providerResponse,
+	}
+	if err := paymentRepo.InsertPaymentEventLogTx(ctx, tx.Tx, decided); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to insert payment event log"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("payment-review-usecase", errObj.Message, "DecideReview", utils.ConvertString(err))
+		return result
+	}
+
+	if outcome == providerUpdateFlagged {
+		uc.PaymentUseCase.sendFlaggedAlert(paymentTx, provider.Name(), notif)
+	}
+
+	response := converter.PaymentReviewToResponse(review)
+	response.PaymentStatus = paymentTx.PaymentStatus
+	result.Data = response
+	return result
+}
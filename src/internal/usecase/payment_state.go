@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/repository"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// transitionPayment is the single way to change payment_status on an existing
+// row. An allowed move updates paymentTx (with paid_at / refunded_at) and
+// saves it. An illegal one leaves paymentTx as it was, records an
+// ILLEGAL_TRANSITION event in tx and returns *entity.PaymentTransitionError;
+// callers that want that event kept must commit tx instead of rolling back.
+// A payment that fails, expires, is cancelled or is fully refunded gives its
+// promo redemption back.
+func transitionPayment(
+	ctx context.Context,
+	repo *repository.PaymentRepository,
+	tx *sqlx.Tx,
+	paymentTx *entity.PaymentTransaction,
+	to string,
+	source string,
+	rawPayload *string,
+) error {
+	from := paymentTx.PaymentStatus
+	if !entity.CanTransitionPayment(from, to) {
+		event := &entity.PaymentEventLog{
+			PaymentTransactionID: paymentTx.ID,
+			EventType:            "ILLEGAL_TRANSITION",
+			EventDescription:     fmt.Sprintf("%s: rejected %s -> %s", source, from, to),
+			RawPayload:           rawPayload,
+		}
+		if err := repo.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+			return fmt.Errorf("failed to insert illegal transition event log: %v", err)
+		}
+		return &entity.PaymentTransitionError{PaymentID: paymentTx.ID, From: from, To: to}
+	}
+
+	now := time.Now()
+	paymentTx.PaymentStatus = to
+	switch to {
+	case entity.PaymentStatusSuccess:
+		paymentTx.PaidAt = &now
+	case entity.PaymentStatusRefunded, entity.PaymentStatusPartiallyRefunded:
+		paymentTx.RefundedAt = &now
+	}
+
+	if err := repo.UpdatePaymentTransactionTx(ctx, tx, paymentTx); err != nil {
+		paymentTx.PaymentStatus = from
+		return fmt.Errorf("failed to update payment transaction: %v", err)
+	}
+
+	switch to {
+	case entity.PaymentStatusFailed, entity.PaymentStatusExpired, entity.PaymentStatusCancelled, entity.PaymentStatusRefunded:
+		if err := reversePaymentPromo(ctx, tx, repo, paymentTx.ID, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
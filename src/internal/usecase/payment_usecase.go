@@ -2,31 +2,53 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"payment-service/src/pkg/databases/mysql"
 	httpError "payment-service/src/pkg/http-error"
 	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/money"
 	"payment-service/src/pkg/utils"
+	"strings"
 	"time"
 
 	"payment-service/src/internal/entity"
+	"payment-service/src/internal/gateway/fx"
+	"payment-service/src/internal/gateway/messaging"
+	paymentGateway "payment-service/src/internal/gateway/payment"
 	"payment-service/src/internal/model"
+	"payment-service/src/internal/model/converter"
 	"payment-service/src/internal/repository"
 
-	"github.com/midtrans/midtrans-go"
-	"github.com/midtrans/midtrans-go/snap"
+	"github.com/jmoiron/sqlx"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 )
 
+const (
+	ChargeModeSnap = "snap"
+	ChargeModeQris = "qris"
+)
+
 type PaymentUseCase struct {
-	Log               log.Log
-	UserRepository    *repository.UserRepository
-	OrderRepository   *repository.OrderRepository
-	PaymentRepository *repository.PaymentRepository
-	Config            *viper.Viper
-	DB                mysql.DBInterface
-	Redis             redis.UniversalClient
+	Log                     log.Log
+	UserRepository          *repository.UserRepository
+	OrderRepository         *repository.OrderRepository
+	PaymentRepository       *repository.PaymentRepository
+	IdempotencyRepository   *repository.IdempotencyRepository
+	PaymentReviewRepository *repository.PaymentReviewRepository
+	WalletRepository        *repository.WalletRepository
+	DriverRepository        *repository.DriverRepository
+	DebtRepository          *repository.DriverDebtRepository
+	Config                  *viper.Viper
+	DB                      mysql.DBInterface
+	Redis                   redis.UniversalClient
+	Providers               *paymentGateway.Registry
+	Methods                 *paymentGateway.MethodCatalog
+	Rates                   fx.RateSource
+	AlertProducer           *messaging.PaymentAlertProducer
+	Refunds                 *RefundUseCase
 }
 
 func NewPaymentUseCase(
@@ -35,17 +57,37 @@ func NewPaymentUseCase(
 	userRepository *repository.UserRepository,
 	paymentRepository *repository.PaymentRepository,
 	orderRepository *repository.OrderRepository,
+	idempotencyRepository *repository.IdempotencyRepository,
+	reviewRepository *repository.PaymentReviewRepository,
+	walletRepository *repository.WalletRepository,
+	driverRepository *repository.DriverRepository,
+	debtRepository *repository.DriverDebtRepository,
 	db mysql.DBInterface,
 	redisClient redis.UniversalClient,
+	providers *paymentGateway.Registry,
+	methods *paymentGateway.MethodCatalog,
+	rates fx.RateSource,
+	alertProducer *messaging.PaymentAlertProducer,
+	refunds *RefundUseCase,
 ) *PaymentUseCase {
 	return &PaymentUseCase{
-		Log:               logger,
-		Config:            config,
-		UserRepository:    userRepository,
-		PaymentRepository: paymentRepository,
-		OrderRepository:   orderRepository,
-		DB:                db,
-		Redis:             redisClient,
+		Log:                     logger,
+		Config:                  config,
+		UserRepository:          userRepository,
+		PaymentRepository:       paymentRepository,
+		OrderRepository:         orderRepository,
+		IdempotencyRepository:   idempotencyRepository,
+		PaymentReviewRepository: reviewRepository,
+		WalletRepository:        walletRepository,
+		DriverRepository:        driverRepository,
+		DebtRepository:          debtRepository,
+		DB:                      db,
+		Redis:                   redisClient,
+		Providers:               providers,
+		Methods:                 methods,
+		Rates:                   rates,
+		AlertProducer:           alertProducer,
+		Refunds:                 refunds,
 	}
 }
 
@@ -59,6 +101,143 @@ func (uc *PaymentUseCase) GenerateQrisPayment(ctx context.Context, req *model.Cr
 		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(req))
 		return result
 	}
+	if req.Mode == "" {
+		req.Mode = ChargeModeSnap
+	}
+	if req.Mode != ChargeModeSnap && req.Mode != ChargeModeQris {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "mode must be snap or qris"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(req))
+		return result
+	}
+
+	charge := &providerCharge{
+		OrderID: req.OrderID,
+		UserID:  req.UserID,
+		Method:  paymentGateway.MethodQris,
+		Mode:    req.Mode,
+		scope:   "GenerateQrisSnap",
+	}
+	return uc.idempotentCharge(ctx, req.IdempotencyKey, map[string]string{
+		"orderId": req.OrderID,
+		"userId":  req.UserID,
+		"mode":    req.Mode,
+	}, charge)
+}
+
+// GenerateVaPayment opens a bank transfer virtual account for the order. It
+// goes through the same idempotency, locking and reuse rules as QRIS.
+func (uc *PaymentUseCase) GenerateVaPayment(ctx context.Context, req *model.CreateVaPaymentRequest) utils.Result {
+	var result utils.Result
+
+	if req.OrderID == "" || req.UserID == "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "orderId and userId are required"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "GenerateVaPayment", utils.ConvertString(req))
+		return result
+	}
+	method, ok := paymentGateway.VaMethod(req.Bank)
+	if !ok {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "bank must be one of BCA, BNI, BRI, MANDIRI or PERMATA"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "GenerateVaPayment", utils.ConvertString(req))
+		return result
+	}
+
+	charge := &providerCharge{
+		OrderID: req.OrderID,
+		UserID:  req.UserID,
+		Method:  method,
+		scope:   "GenerateVaPayment",
+	}
+	return uc.idempotentCharge(ctx, req.IdempotencyKey, map[string]string{
+		"orderId": req.OrderID,
+		"userId":  req.UserID,
+		"method":  method,
+	}, charge)
+}
+
+// providerCharge is a request for a provider-backed payment: QRIS, in snap
+// or core API mode, or a VA_<BANK> virtual account. Type is TRIP unless set;
+// a TIP charges Amount instead of the trip fare.
+type providerCharge struct {
+	OrderID string
+	UserID  string
+	Method  string
+	Mode    string
+	Type    string
+	Amount  money.Amount
+
+	// scope is the log scope of the entry point
+	scope string
+}
+
+// idempotentCharge replays the stored response for a repeated
+// Idempotency-Key and creates the payment otherwise. Without a key every call
+// goes straight through.
+func (uc *PaymentUseCase) idempotentCharge(ctx context.Context, key string, fields map[string]string, charge *providerCharge) utils.Result {
+	var result utils.Result
+
+	if key == "" {
+		return uc.createProviderPayment(ctx, charge)
+	}
+
+	idempotencyKey := fmt.Sprintf("payment:%s:%s", charge.UserID, key)
+	requestHash := utils.HashRequest(fields)
+	ttl := time.Duration(uc.Config.GetInt("payment.idempotency.ttl_hours")) * time.Hour
+
+	record, reserved, err := uc.IdempotencyRepository.Reserve(ctx, idempotencyKey, requestHash, ttl)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to reserve idempotency key"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, charge.scope, utils.ConvertString(err))
+		return result
+	}
+	if !reserved {
+		if record.RequestHash != requestHash {
+			errObj := httpError.NewConflict()
+			errObj.Message = "Idempotency-Key was already used with a different request"
+			result.Error = errObj
+			uc.Log.Error("payment-usecase", errObj.Message, charge.scope, idempotencyKey)
+			return result
+		}
+		if record.Status != entity.IdempotencyStatusCompleted {
+			errObj := httpError.NewConflict()
+			errObj.Message = "a request with this Idempotency-Key is still in progress"
+			result.Error = errObj
+			uc.Log.Error("payment-usecase", errObj.Message, charge.scope, idempotencyKey)
+			return result
+		}
+		result.Data = record.Response
+		return result
+	}
+
+	result = uc.createProviderPayment(ctx, charge)
+	if result.Error != nil {
+		if err := uc.IdempotencyRepository.Release(ctx, idempotencyKey); err != nil {
+			uc.Log.Error("payment-usecase", "failed to release idempotency key", charge.scope, utils.ConvertString(err))
+		}
+		return result
+	}
+
+	if err := uc.IdempotencyRepository.Complete(ctx, idempotencyKey, requestHash, result.Data, ttl); err != nil {
+		uc.Log.Error("payment-usecase", "failed to store idempotent response", charge.scope, utils.ConvertString(err))
+	}
+
+	return result
+}
+
+// createProviderPayment locks the order row so concurrent calls for the same
+// order serialise, and hands back the existing unexpired PENDING payment of
+// the same method instead of opening a second charge at the provider. The
+// payment row is committed before the provider is called, see
+// openProviderCharge.
+func (uc *PaymentUseCase) createProviderPayment(ctx context.Context, req *providerCharge) utils.Result {
+	var result utils.Result
 
 	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{
 		OrderID:     &req.OrderID,
@@ -68,7 +247,7 @@ func (uc *PaymentUseCase) GenerateQrisPayment(ctx context.Context, req *model.Cr
 		errObj := httpError.NewNotFound()
 		errObj.Message = "order not found"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(err))
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
 		return result
 	}
 
@@ -77,66 +256,70 @@ func (uc *PaymentUseCase) GenerateQrisPayment(ctx context.Context, req *model.Cr
 		errObj := httpError.NewNotFound()
 		errObj.Message = "user not found"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(err))
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
 		return result
 	}
 
+	paymentType := req.Type
+	if paymentType == "" {
+		paymentType = entity.PaymentTypeTrip
+	}
 	amount := uc.calculateFinalAmount(order)
+	if paymentType == entity.PaymentTypeTip {
+		amount = req.Amount
+	}
 	if amount <= 0 {
 		errObj := httpError.NewBadRequest()
 		errObj.Message = "invalid order amount"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(order))
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(order))
 		return result
 	}
-
-	serverKey := uc.Config.GetString("midtrans.server_key")
-	if serverKey == "" {
-		errObj := httpError.NewInternalServerError()
-		errObj.Message = "midtrans server key not configured"
+	currency := paymentCurrency(uc.Config)
+	if !methodSupportsCurrency(uc.Config, req.Method, currency) {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("%s does not support %s payments", req.Method, currency)
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", "")
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, order.OrderID)
 		return result
 	}
-	isProd := uc.Config.GetBool("midtrans.is_production")
 
-	env := midtrans.Sandbox
-	if isProd {
-		env = midtrans.Production
+	driverID := ""
+	if order.DriverID != nil {
+		driverID = *order.DriverID
 	}
-
-	snapClient := snap.Client{}
-	snapClient.New(serverKey, env)
-
-	snapReq := &snap.Request{
-		TransactionDetails: midtrans.TransactionDetails{
-			OrderID:  order.OrderID,
-			GrossAmt: int64(amount),
-		},
-		CustomerDetail: &midtrans.CustomerDetails{
-			Email: user.Email,
-			FName: user.FullName,
-		},
+	method, err := uc.Methods.Check(req.Method, methodContext(ctx, uc.DriverRepository, driverID, amount))
+	if err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = err.Error()
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, order.OrderID)
+		return result
 	}
 
-	snapResp, err := snapClient.CreateTransaction(snapReq)
-	if snapResp == nil {
+	// the QRIS core API mode keeps its own provider, payment.provider.qris_core
+	var provider paymentGateway.PaymentProvider
+	if req.Method == paymentGateway.MethodQris && req.Mode == ChargeModeQris {
+		provider, err = uc.Providers.ForMethod("QRIS_CORE")
+	} else if method.Provider != "" {
+		provider, err = uc.Providers.Get(method.Provider)
+	} else {
+		provider, err = uc.Providers.ForMethod(method.Code)
+	}
+	if err != nil {
 		errObj := httpError.NewInternalServerError()
-		errObj.Message = fmt.Sprintf("failed create qris via midtrans snap: %v", err)
+		errObj.Message = "payment provider not configured"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(err))
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
 		return result
 	}
 
-	snapToken := snapResp.Token
-	redirectURL := snapResp.RedirectURL
-
 	db, err := uc.DB.GetDB()
 	if err != nil {
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = "failed to get db connection"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(err))
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
 		return result
 	}
 
@@ -145,7 +328,7 @@ func (uc *PaymentUseCase) GenerateQrisPayment(ctx context.Context, req *model.Cr
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = "failed to start transaction"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(err))
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
 		return result
 	}
 	defer func() {
@@ -155,20 +338,123 @@ func (uc *PaymentUseCase) GenerateQrisPayment(ctx context.Context, req *model.Cr
 		}
 	}()
 
-	providerName := "MIDTRANS_SNAP"
-	driverID := ""
-	if order.DriverID != nil {
-		driverID = *order.DriverID
+	if err := uc.OrderRepository.LockOrderTx(ctx, tx.Tx, order.ID); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to lock order"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
+		return result
+	}
+
+	if paymentType == entity.PaymentTypeTip {
+		if errObj := uc.checkTipConflict(ctx, tx, order, req.Method, amount, req.scope); errObj != nil {
+			_ = tx.Rollback()
+			result.Error = errObj
+			return result
+		}
+	}
+
+	// the QRIS part of a split payment is opened with its wallet hold; it is
+	// handed back like any pending payment, never charged a second time
+	var split *entity.PaymentTransaction
+	if paymentType == entity.PaymentTypeTrip {
+		split, err = uc.PaymentRepository.FindOpenSplitParentTx(ctx, tx, order.ID)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get split payment"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
+		return result
+	}
+
+	existing, err := uc.PaymentRepository.FindReusablePaymentTx(ctx, tx, order.ID, paymentType, req.Method)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get pending payment"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
+		return result
+	}
+	if existing != nil {
+		_ = tx.Rollback()
+		var meta entity.PaymentChargeMetadata
+		if len(existing.Metadata) > 0 {
+			_ = json.Unmarshal(existing.Metadata, &meta)
+		}
+		if existing.ProviderName == nil || *existing.ProviderName != provider.Name() || meta.Mode != req.Mode {
+			errObj := httpError.NewConflict()
+			errObj.Message = "order already has a pending payment with another payment mode"
+			result.Error = errObj
+			uc.Log.Error("payment-usecase", errObj.Message, req.scope, order.OrderID)
+			return result
+		}
+		if !chargeOpened(&meta) {
+			errObj := httpError.NewConflict()
+			errObj.Message = "a payment for this order is still being created, try again shortly"
+			result.Error = errObj
+			uc.Log.Error("payment-usecase", errObj.Message, req.scope, order.OrderID)
+			return result
+		}
+		uc.Log.Info("payment-usecase", fmt.Sprintf("Reusing pending payment %d for order %s", existing.ID, order.OrderID), req.scope, "")
+		result.Data = buildChargeResponse(order.OrderID, existing.PaymentMethod, existing.Amount, existing.ExpiredAt, &meta)
+		return result
+	}
+
+	if split != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewConflict()
+		errObj.Message = "the QRIS part of this order's split payment is no longer open"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, order.OrderID)
+		return result
+	}
+
+	var redemption *entity.PromoRedemption
+	var discount money.Amount
+	if paymentType == entity.PaymentTypeTrip {
+		redemption, discount, err = redeemPromo(ctx, tx, uc.PaymentRepository, order, amount, currency)
+		if err != nil {
+			_ = tx.Rollback()
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to apply promo"
+			result.Error = errObj
+			uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
+			return result
+		}
+		amount -= discount
 	}
+
+	// the provider takes an order_id once, so every charge gets its own: an
+	// order can then move between QRIS and VA, or be charged again after a
+	// charge expired or failed
+	chargeOrderID := utils.GenerateUniqueIDWithPrefix("payment")
+	if paymentType == entity.PaymentTypeTip {
+		chargeOrderID = utils.GenerateUniqueIDWithPrefix("tip")
+	}
+
+	// the row is committed before the provider is called, so a charge the
+	// provider opens always has a payment to land on
+	providerName := provider.Name()
+	pendingMeta, _ := json.Marshal(&entity.PaymentChargeMetadata{Mode: req.Mode})
+	pendingUntil := chargePendingUntil(uc.Config)
 	payment := &entity.PaymentTransaction{
-		RideOrderID:   order.ID,
-		PassengerID:   order.PassengerID,
-		DriverID:      driverID,
-		Amount:        amount,
-		Currency:      "IDR",
-		PaymentMethod: "QRIS",
-		PaymentStatus: "PENDING",
-		ProviderName:  &providerName,
+		RideOrderID:    order.ID,
+		PassengerID:    order.PassengerID,
+		DriverID:       driverID,
+		Amount:         amount,
+		DiscountAmount: discount,
+		Currency:       currency,
+		PaymentMethod:  req.Method,
+		PaymentStatus:  entity.PaymentStatusPending,
+		ProviderName:   &providerName,
+		ExpiredAt:      &pendingUntil,
+		Metadata:       pendingMeta,
+		PaymentType:    paymentType,
+		ChargeOrderID:  &chargeOrderID,
 	}
 
 	paymentID, err := uc.PaymentRepository.InsertPaymentTransactionTx(ctx, tx, payment)
@@ -177,23 +463,16 @@ func (uc *PaymentUseCase) GenerateQrisPayment(ctx context.Context, req *model.Cr
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = "failed to save payment transaction"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(err))
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
 		return result
 	}
 
-	rawPayload := utils.ConvertString(snapResp)
-	event := &entity.PaymentEventLog{
-		PaymentTransactionID: paymentID,
-		EventType:            "CREATE",
-		EventDescription:     "Create QRIS Snap payment via Midtrans",
-		RawPayload:           &rawPayload,
-	}
-	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+	if err := attachPromo(ctx, tx, uc.PaymentRepository, redemption, paymentID); err != nil {
 		_ = tx.Rollback()
 		errObj := httpError.NewInternalServerError()
-		errObj.Message = "failed to save payment event log"
+		errObj.Message = "failed to apply promo"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(err))
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
 		return result
 	}
 
@@ -201,51 +480,87 @@ func (uc *PaymentUseCase) GenerateQrisPayment(ctx context.Context, req *model.Cr
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = "failed to commit transaction"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "GenerateQrisSnap", utils.ConvertString(err))
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
 		return result
 	}
 
-	result.Data = model.QrisSnapPaymentResponse{
-		OrderID:     order.OrderID,
-		Amount:      amount,
-		SnapToken:   snapToken,
-		RedirectURL: redirectURL,
-		Status:      "PENDING",
+	payment, meta, err := openProviderCharge(ctx, db, uc.PaymentRepository, provider, paymentID, &paymentGateway.ChargeRequest{
+		OrderID:       chargeOrderID,
+		Amount:        amount,
+		Currency:      currency,
+		PaymentMethod: req.Method,
+		CustomerName:  user.FullName,
+		CustomerEmail: user.Email,
+	}, req.Mode)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = err.Error()
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, req.scope, utils.ConvertString(err))
+		return result
 	}
 
+	result.Data = buildChargeResponse(order.OrderID, req.Method, amount, payment.ExpiredAt, meta)
 	return result
 }
 
-func (uc *PaymentUseCase) CallbackPayment(ctx context.Context, notif *model.MidtransNotification) utils.Result {
+func buildChargeResponse(orderID, method string, amount money.Amount, expiredAt *time.Time, meta *entity.PaymentChargeMetadata) interface{} {
+	if _, ok := paymentGateway.VaBank(method); ok {
+		response := model.VaPaymentResponse{
+			OrderID:           orderID,
+			Amount:            amount,
+			PaymentMethod:     method,
+			Bank:              meta.Bank,
+			VaNumber:          meta.VaNumber,
+			BillerCode:        meta.BillerCode,
+			TransactionID:     meta.TransactionID,
+			TransactionStatus: "PENDING",
+		}
+		if expiredAt != nil {
+			response.ExpiryTime = expiredAt.Format(time.RFC3339)
+		}
+		return response
+	}
+	if meta.Mode == ChargeModeQris {
+		response := model.QrisPaymentResponse{
+			OrderID:            orderID,
+			Amount:             amount,
+			PaymentURL:         meta.RedirectURL,
+			QrString:           meta.QrString,
+			TransactionID:      meta.TransactionID,
+			TransactionStatus:  "PENDING",
+			PaymentProviderRef: meta.TransactionID,
+		}
+		if expiredAt != nil {
+			response.ExpiryTime = expiredAt.Format(time.RFC3339)
+		}
+		return response
+	}
+
+	return model.QrisSnapPaymentResponse{
+		OrderID:     orderID,
+		Amount:      amount,
+		SnapToken:   meta.SnapToken,
+		RedirectURL: meta.RedirectURL,
+		Status:      "PENDING",
+	}
+}
+
+func (uc *PaymentUseCase) CallbackPayment(ctx context.Context, payload []byte) utils.Result {
 	var result utils.Result
 
 	uc.Log.Info("payment-usecase",
-		fmt.Sprintf("Received Midtrans webhook: %+v", notif),
+		fmt.Sprintf("Received payment webhook: %s", string(payload)),
 		"HandleMidtransWebhook",
 		"",
 	)
 
-	serverKey := uc.Config.GetString("midtrans.server_key")
-	if serverKey == "" {
-		errObj := httpError.NewInternalServerError()
-		errObj.Message = "midtrans server key not configured"
-		result.Error = errObj
-		return result
-	}
-
-	expectedSig := utils.GenerateMidtransSignature(
-		notif.OrderID,
-		notif.StatusCode,
-		notif.GrossAmount,
-		serverKey,
-	)
-
-	if notif.SignatureKey != expectedSig {
-		errObj := httpError.NewUnauthorized()
-		errObj.Message = "invalid signature"
+	orderID, err := paymentGateway.ExtractOrderID(payload)
+	if err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = err.Error()
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook",
-			fmt.Sprintf("expected=%s got=%s", expectedSig, notif.SignatureKey))
+		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", string(payload))
 		return result
 	}
 
@@ -273,7 +588,7 @@ func (uc *PaymentUseCase) CallbackPayment(ctx context.Context, notif *model.Midt
 		}
 	}()
 
-	paymentTx, err := uc.PaymentRepository.FindByOrderIDForUpdate(ctx, tx, notif.OrderID)
+	paymentTx, err := uc.PaymentRepository.FindByOrderIDForUpdate(ctx, tx, orderID)
 	if err != nil {
 		_ = tx.Rollback()
 		errObj := httpError.NewInternalServerError()
@@ -287,133 +602,643 @@ func (uc *PaymentUseCase) CallbackPayment(ctx context.Context, notif *model.Midt
 		errObj := httpError.NewNotFound()
 		errObj.Message = "payment transaction not found"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", notif.OrderID)
+		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", orderID)
 		return result
 	}
 
-	var newStatus string
-	switch notif.TransactionStatus {
-	case "capture", "settlement":
-		if notif.FraudStatus == "challenge" {
-			newStatus = "PENDING"
-		} else {
-			newStatus = "SUCCESS"
+	provider, err := providerForPayment(uc.Providers, paymentTx)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "payment provider not configured"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", utils.ConvertString(err))
+		return result
+	}
+
+	notif, err := provider.ParseNotification(ctx, payload)
+	if err != nil {
+		_ = tx.Rollback()
+		var sigErr *paymentGateway.SignatureError
+		if errors.As(err, &sigErr) {
+			errObj := httpError.NewUnauthorized()
+			errObj.Message = "invalid signature"
+			result.Error = errObj
+			uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook",
+				fmt.Sprintf("expected=%s got=%s", sigErr.Expected, sigErr.Got))
+			return result
 		}
-	case "pending":
-		newStatus = "PENDING"
-	case "deny", "cancel", "expire":
-		newStatus = "FAILED"
-	case "refund", "partial_refund":
-		newStatus = "REFUNDED"
-	default:
-		newStatus = "PENDING"
+		errObj := httpError.NewBadRequest()
+		errObj.Message = err.Error()
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", utils.ConvertString(err))
+		return result
 	}
 
-	if paymentTx.PaymentStatus == newStatus {
+	outcome, errObj := uc.applyProviderUpdate(ctx, tx, paymentTx, provider.Name(), notif, "HandleMidtransWebhook")
+	if errObj != nil {
+		_ = tx.Rollback()
+		result.Error = errObj
+		return result
+	}
+	switch outcome {
+	case providerUpdateUnchanged:
 		result.Data = map[string]string{"message": "status unchanged"}
 		_ = tx.Commit()
 		return result
+	case providerUpdateRejected:
+		// acknowledged so the provider stops retrying; the rejection is in the event log
+		result.Data = map[string]string{
+			"message":        "status transition rejected",
+			"payment_status": paymentTx.PaymentStatus,
+		}
+		_ = tx.Commit()
+		return result
+	case providerUpdateFlagged:
+		if err := tx.Commit(); err != nil {
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to commit transaction"
+			result.Error = errObj
+			uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", utils.ConvertString(err))
+			return result
+		}
+		uc.sendFlaggedAlert(paymentTx, provider.Name(), notif)
+		result.Data = map[string]string{
+			"message":        "payment flagged for review",
+			"payment_status": paymentTx.PaymentStatus,
+		}
+		return result
 	}
 
-	now := time.Now()
-	paymentTx.PaymentStatus = newStatus
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", utils.ConvertString(err))
+		return result
+	}
 
-	if newStatus == "SUCCESS" {
-		paymentTx.PaidAt = &now
+	result.Data = map[string]string{
+		"message":          "webhook processed",
+		"transaction_id":   notif.TransactionID,
+		"payment_status":   paymentTx.PaymentStatus,
+		"transaction_time": notif.TransactionTime,
 	}
+	return result
+}
 
-	if err := uc.PaymentRepository.UpdatePaymentTransactionTx(ctx, tx, paymentTx); err != nil {
-		_ = tx.Rollback()
+// VerifyNotification checks a raw provider notification against the
+// signature of the provider its payment was made with, without applying it.
+func (uc *PaymentUseCase) VerifyNotification(ctx context.Context, payload []byte) utils.Result {
+	var result utils.Result
+
+	orderID, err := paymentGateway.ExtractOrderID(payload)
+	if err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = err.Error()
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "VerifyNotification", string(payload))
+		return result
+	}
+
+	paymentTx, err := uc.PaymentRepository.FindByOrderID(ctx, orderID)
+	if err != nil {
 		errObj := httpError.NewInternalServerError()
-		errObj.Message = "failed to update payment transaction"
+		errObj.Message = "failed to get payment transaction"
 		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", utils.ConvertString(err))
+		uc.Log.Error("payment-usecase", errObj.Message, "VerifyNotification", utils.ConvertString(err))
+		return result
+	}
+	if paymentTx == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "payment transaction not found"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "VerifyNotification", orderID)
+		return result
+	}
+
+	provider, err := providerForPayment(uc.Providers, paymentTx)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "payment provider not configured"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "VerifyNotification", utils.ConvertString(err))
 		return result
 	}
 
-	rawPayload := utils.ConvertString(notif)
+	if _, err := provider.ParseNotification(ctx, payload); err != nil {
+		var sigErr *paymentGateway.SignatureError
+		if errors.As(err, &sigErr) {
+			errObj := httpError.NewUnauthorized()
+			errObj.Message = "invalid signature"
+			result.Error = errObj
+			uc.Log.Error("payment-usecase", errObj.Message, "VerifyNotification",
+				fmt.Sprintf("expected=%s got=%s", sigErr.Expected, sigErr.Got))
+			return result
+		}
+		errObj := httpError.NewBadRequest()
+		errObj.Message = err.Error()
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "VerifyNotification", utils.ConvertString(err))
+		return result
+	}
+	return result
+}
+
+func (uc *PaymentUseCase) GetPaymentStatus(ctx context.Context, req *model.GetPaymentStatusRequest) utils.Result {
+	var result utils.Result
+
+	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &req.OrderID})
+	if err != nil || order == nil || (order.PassengerID != req.UserID && (order.DriverID == nil || *order.DriverID != req.UserID)) {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "order not found"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "GetPaymentStatus", utils.ConvertString(err))
+		return result
+	}
+
+	paymentTx, err := uc.PaymentRepository.FindLatestByRideOrderID(ctx, order.ID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payment transaction"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "GetPaymentStatus", utils.ConvertString(err))
+		return result
+	}
+	if paymentTx == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "payment transaction not found"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "GetPaymentStatus", req.OrderID)
+		return result
+	}
+
+	requeryAfter := time.Duration(uc.Config.GetInt("payment.status.requery_after_seconds")) * time.Second
+	if paymentTx.PaymentStatus == entity.PaymentStatusPending && paymentTx.ProviderName != nil && time.Since(paymentTx.CreatedAt) > requeryAfter {
+		if refreshed := uc.refreshPendingPayment(ctx, order, paymentTx.ID); refreshed != nil {
+			paymentTx = refreshed
+		}
+	}
+
+	var parts []entity.PaymentTransaction
+	if paymentTx.PaymentMethod == PaymentMethodSplit {
+		parts, err = uc.PaymentRepository.FindChildPayments(ctx, paymentTx.ID)
+		if err != nil {
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to get split payment parts"
+			result.Error = errObj
+			uc.Log.Error("payment-usecase", errObj.Message, "GetPaymentStatus", utils.ConvertString(err))
+			return result
+		}
+
+		healed := false
+		for i := range parts {
+			part := &parts[i]
+			if part.PaymentStatus != entity.PaymentStatusPending || part.ProviderName == nil || time.Since(part.CreatedAt) <= requeryAfter {
+				continue
+			}
+			if refreshed := uc.refreshPendingPayment(ctx, order, part.ID); refreshed != nil {
+				parts[i] = *refreshed
+				healed = true
+			}
+		}
+		if healed {
+			// settling a part may have settled the parent as well
+			if latest, err := uc.PaymentRepository.FindLatestByRideOrderID(ctx, order.ID); err == nil && latest != nil {
+				paymentTx = latest
+			}
+		}
+	}
+
+	events, err := uc.PaymentRepository.FindEventLogsByPaymentID(ctx, paymentTx.ID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payment events"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "GetPaymentStatus", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = converter.PaymentToStatusResponse(order.OrderID, paymentTx, parts, events)
+	return result
+}
+
+// refreshPendingPayment re-queries the provider for a payment that is still
+// PENDING and applies whatever it reports. Failures are only logged: the
+// caller falls back to the stored row.
+func (uc *PaymentUseCase) refreshPendingPayment(ctx context.Context, order *entity.Order, paymentID uint64) *entity.PaymentTransaction {
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		uc.Log.Error("payment-usecase", "failed to get db connection", "refreshPendingPayment", utils.ConvertString(err))
+		return nil
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		uc.Log.Error("payment-usecase", "failed to start transaction", "refreshPendingPayment", utils.ConvertString(err))
+		return nil
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	paymentTx, err := uc.PaymentRepository.FindByIDForUpdate(ctx, tx, paymentID)
+	if err != nil || paymentTx == nil {
+		_ = tx.Rollback()
+		uc.Log.Error("payment-usecase", "failed to get payment transaction", "refreshPendingPayment", utils.ConvertString(err))
+		return nil
+	}
+	if paymentTx.PaymentStatus != entity.PaymentStatusPending {
+		_ = tx.Rollback()
+		return paymentTx
+	}
+
+	provider, err := providerForPayment(uc.Providers, paymentTx)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("payment-usecase", "payment provider not configured", "refreshPendingPayment", utils.ConvertString(err))
+		return nil
+	}
+
+	reference := providerReference(paymentTx, order.OrderID)
+	status, err := provider.QueryStatus(ctx, reference)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("payment-usecase", "failed to query provider status", "refreshPendingPayment", utils.ConvertString(err))
+		return nil
+	}
+
+	outcome, errObj := uc.applyProviderUpdate(ctx, tx, paymentTx, provider.Name(), status.Notification(), "refreshPendingPayment")
+	if errObj != nil {
+		_ = tx.Rollback()
+		return nil
+	}
+	if outcome == providerUpdateUnchanged {
+		_ = tx.Rollback()
+		return paymentTx
+	}
+	if outcome == providerUpdateRejected {
+		_ = tx.Commit()
+		return paymentTx
+	}
+	if outcome == providerUpdateFlagged {
+		if err := tx.Commit(); err != nil {
+			uc.Log.Error("payment-usecase", "failed to commit transaction", "refreshPendingPayment", utils.ConvertString(err))
+			return nil
+		}
+		uc.sendFlaggedAlert(paymentTx, provider.Name(), status.Notification())
+		return paymentTx
+	}
+
+	if err := tx.Commit(); err != nil {
+		uc.Log.Error("payment-usecase", "failed to commit transaction", "refreshPendingPayment", utils.ConvertString(err))
+		return nil
+	}
+
+	uc.Log.Info("payment-usecase",
+		fmt.Sprintf("Payment %d healed from provider status %s", paymentTx.ID, status.TransactionStatus),
+		"refreshPendingPayment", "")
+	return paymentTx
+}
+
+type providerUpdateOutcome int
+
+const (
+	providerUpdateUnchanged providerUpdateOutcome = iota
+	providerUpdateApplied
+	providerUpdateRejected
+	providerUpdateFlagged
+)
+
+// applyProviderUpdate moves paymentTx to the status the provider reported,
+// logs the event and marks the order PAID on success. The webhook and the
+// status re-query both go through here so a lost webhook heals the same way.
+// A move the state machine rejects is only logged; the caller should still
+// commit tx so that log is kept. On error the caller must roll tx back.
+func (uc *PaymentUseCase) applyProviderUpdate(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	paymentTx *entity.PaymentTransaction,
+	providerName string,
+	notif *paymentGateway.Notification,
+	scope string,
+) (providerUpdateOutcome, interface{}) {
+	newStatus := notif.Status
+
+	if newStatus == entity.PaymentStatusRefunded || newStatus == entity.PaymentStatusPartiallyRefunded {
+		return uc.applyProviderRefunds(ctx, tx, paymentTx, providerName, notif, scope)
+	}
+
+	if paymentTx.PaymentStatus == newStatus {
+		return providerUpdateUnchanged, nil
+	}
+
+	rawPayload := notif.RawPayload
+	source := fmt.Sprintf("%s notif %s", providerName, notif.TransactionStatus)
+
+	if newStatus == entity.PaymentStatusSuccess || newStatus == entity.PaymentStatusChallenge {
+		if reason := notifiedAmountMismatch(paymentTx, notif); reason != "" {
+			return uc.flagPayment(ctx, tx, paymentTx, source, reason, &rawPayload, scope)
+		}
+	}
+
+	if err := transitionPayment(ctx, uc.PaymentRepository, tx, paymentTx, newStatus, source, &rawPayload); err != nil {
+		var transitionErr *entity.PaymentTransitionError
+		if errors.As(err, &transitionErr) {
+			uc.Log.Info("payment-usecase", transitionErr.Error(), scope, notif.OrderID)
+			return providerUpdateRejected, nil
+		}
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to update payment transaction"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return providerUpdateUnchanged, errObj
+	}
+
 	event := &entity.PaymentEventLog{
 		PaymentTransactionID: paymentTx.ID,
-		EventType:            mapMidtransEventType(notif.TransactionStatus),
-		EventDescription:     fmt.Sprintf("Midtrans notif: %s", notif.TransactionStatus),
+		EventType:            notif.EventType,
+		EventDescription:     fmt.Sprintf("%s notif: %s", providerName, notif.TransactionStatus),
 		RawPayload:           &rawPayload,
 	}
 	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
-		_ = tx.Rollback()
 		errObj := httpError.NewInternalServerError()
 		errObj.Message = "failed to insert payment event log"
-		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", utils.ConvertString(err))
-		return result
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return providerUpdateUnchanged, errObj
+	}
+
+	if newStatus == entity.PaymentStatusChallenge {
+		if errObj := uc.queueForReview(ctx, tx, paymentTx, notif, scope); errObj != nil {
+			return providerUpdateUnchanged, errObj
+		}
 	}
 
-	if newStatus == "SUCCESS" {
+	if paymentTx.PaymentType == entity.PaymentTypeTip {
+		// a tip is the driver's alone and leaves the order as it is
+		if newStatus == entity.PaymentStatusSuccess {
+			if errObj := uc.settleTip(ctx, tx, paymentTx, scope); errObj != nil {
+				return providerUpdateUnchanged, errObj
+			}
+		}
+		return providerUpdateApplied, nil
+	}
+
+	if paymentTx.ParentPaymentID != nil {
+		// a part of a split payment: the parent decides when the order is paid
+		if errObj := uc.reconcileSplit(ctx, tx, paymentTx, scope); errObj != nil {
+			return providerUpdateUnchanged, errObj
+		}
+		return providerUpdateApplied, nil
+	}
+
+	if newStatus == entity.PaymentStatusSuccess {
 		order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{ID: &paymentTx.RideOrderID})
 		if err != nil || order == nil {
-			_ = tx.Rollback()
 			errObj := httpError.NewInternalServerError()
 			errObj.Message = "failed to get order for payment"
-			result.Error = errObj
-			uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", utils.ConvertString(err))
-			return result
+			uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+			return providerUpdateUnchanged, errObj
 		}
 
-		if order.PaymentMethod != "WALLET" && order.PaymentMethod != "EWALLET" {
+		if !isWalletMethod(order.PaymentMethod) {
 			ok, err := uc.OrderRepository.MarkOrderPaidTx(ctx, tx.Tx, order.OrderID, order.PassengerID, *order.DriverID)
 			if err != nil {
-				_ = tx.Rollback()
 				errObj := httpError.NewInternalServerError()
 				errObj.Message = "failed to update order payment status"
-				result.Error = errObj
-				uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", utils.ConvertString(err))
-				return result
+				uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+				return providerUpdateUnchanged, errObj
 			}
 			if !ok {
-				_ = tx.Rollback()
 				errObj := httpError.NewConflict()
 				errObj.Message = "order payment status not updated (maybe already paid/invalid state)"
-				result.Error = errObj
-				uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", order.OrderID)
-				return result
+				uc.Log.Error("payment-usecase", errObj.Message, scope, order.OrderID)
+				return providerUpdateUnchanged, errObj
 			}
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	return providerUpdateApplied, nil
+}
+
+// applyProviderRefunds books the refunds a refund notification reports instead
+// of moving the payment itself, so each refund is booked once with its driver
+// reversal whether we sent it or it was made at the provider; the payment
+// status follows from what has been refunded.
+func (uc *PaymentUseCase) applyProviderRefunds(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	paymentTx *entity.PaymentTransaction,
+	providerName string,
+	notif *paymentGateway.Notification,
+	scope string,
+) (providerUpdateOutcome, interface{}) {
+	if paymentTx.PaymentStatus != entity.PaymentStatusRefunded &&
+		!entity.CanTransitionPayment(paymentTx.PaymentStatus, entity.PaymentStatusPartiallyRefunded) {
+		uc.Log.Info("payment-usecase",
+			fmt.Sprintf("Refund reported on %s payment %d, not booked", paymentTx.PaymentStatus, paymentTx.ID),
+			scope, notif.OrderID)
+		return providerUpdateRejected, nil
+	}
+
+	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{ID: &paymentTx.RideOrderID})
+	if err != nil || order == nil {
 		errObj := httpError.NewInternalServerError()
-		errObj.Message = "failed to commit transaction"
-		result.Error = errObj
-		uc.Log.Error("payment-usecase", errObj.Message, "HandleMidtransWebhook", utils.ConvertString(err))
-		return result
+		errObj.Message = "failed to get order for payment"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return providerUpdateUnchanged, errObj
 	}
 
-	result.Data = map[string]string{
-		"message":          "webhook processed",
-		"transaction_id":   notif.TransactionID,
-		"payment_status":   newStatus,
-		"transaction_time": notif.TransactionTime,
+	booked, err := uc.Refunds.BookProviderRefunds(ctx, tx, paymentTx, providerName, notif.Refunds, notif.RawPayload, order.OrderID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to book provider refund"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return providerUpdateUnchanged, errObj
 	}
-	return result
+	if booked == 0 {
+		return providerUpdateUnchanged, nil
+	}
+	return providerUpdateApplied, nil
+}
+
+// reconcileSplit settles the split payment paymentTx is a part of.
+func (uc *PaymentUseCase) reconcileSplit(ctx context.Context, tx *sqlx.Tx, paymentTx *entity.PaymentTransaction, scope string) interface{} {
+	reconciler := splitReconciler{
+		PaymentRepository: uc.PaymentRepository,
+		WalletRepository:  uc.WalletRepository,
+		OrderRepository:   uc.OrderRepository,
+		DebtLedger:        newDriverDebtLedger(uc.Config, uc.DebtRepository, uc.DriverRepository),
+		Rates:             uc.Rates,
+	}
+	if err := reconciler.reconcile(ctx, tx, paymentTx); err != nil {
+		if errors.Is(err, errSplitOrderNotPaid) {
+			errObj := httpError.NewConflict()
+			errObj.Message = err.Error()
+			uc.Log.Error("payment-usecase", errObj.Message, scope, fmt.Sprintf("%d", paymentTx.RideOrderID))
+			return errObj
+		}
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to settle split payment"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return errObj
+	}
+	return nil
+}
+
+// queueForReview opens a manual review for a payment the provider's fraud
+// detection challenged. Nothing is paid out until an operator approves it.
+func (uc *PaymentUseCase) queueForReview(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	paymentTx *entity.PaymentTransaction,
+	notif *paymentGateway.Notification,
+	scope string,
+) interface{} {
+	review := &entity.PaymentReview{
+		PaymentTransactionID: paymentTx.ID,
+		OrderID:              notif.OrderID,
+		Reason:               fmt.Sprintf("fraud status %s on %s", notif.FraudStatus, notif.TransactionStatus),
+		Status:               entity.PaymentReviewOpen,
+	}
+	if err := uc.PaymentReviewRepository.InsertReviewTx(ctx, tx, review); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to queue payment for review"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return errObj
+	}
+
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentTx.ID,
+		EventType:            "REVIEW_QUEUED",
+		EventDescription:     "Queued for manual review: " + review.Reason,
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to insert payment event log"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return errObj
+	}
+	return nil
+}
+
+// flagPayment moves paymentTx to FLAGGED instead of the status the provider
+// reported. The alert goes out once the caller has committed.
+func (uc *PaymentUseCase) flagPayment(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	paymentTx *entity.PaymentTransaction,
+	source string,
+	reason string,
+	rawPayload *string,
+	scope string,
+) (providerUpdateOutcome, interface{}) {
+	if err := transitionPayment(ctx, uc.PaymentRepository, tx, paymentTx, entity.PaymentStatusFlagged, source, rawPayload); err != nil {
+		var transitionErr *entity.PaymentTransitionError
+		if errors.As(err, &transitionErr) {
+			uc.Log.Info("payment-usecase", transitionErr.Error(), scope, reason)
+			return providerUpdateRejected, nil
+		}
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to update payment transaction"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return providerUpdateUnchanged, errObj
+	}
+
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentTx.ID,
+		EventType:            "FLAGGED",
+		EventDescription:     fmt.Sprintf("%s: %s", source, reason),
+		RawPayload:           rawPayload,
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to insert payment event log"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return providerUpdateUnchanged, errObj
+	}
+
+	uc.Log.Error("payment-usecase", "payment flagged: "+reason, scope, fmt.Sprintf("%d", paymentTx.ID))
+	return providerUpdateFlagged, nil
 }
 
-func mapMidtransEventType(status string) string {
-	switch status {
-	case "capture":
-		return "CALLBACK"
-	case "settlement":
-		return "SUCCESS"
-	case "pending":
-		return "PENDING"
-	case "deny", "cancel", "expire":
-		return "FAILED"
-	case "refund", "partial_refund":
-		return "REFUND"
-	default:
-		return "CALLBACK"
+// sendFlaggedAlert publishes a payment alert for a flagged notification. An
+// alert that cannot be sent is only logged; the FLAGGED status and its event
+// log are what operators work from.
+func (uc *PaymentUseCase) sendFlaggedAlert(paymentTx *entity.PaymentTransaction, providerName string, notif *paymentGateway.Notification) {
+	event := &model.PaymentAlertEvent{
+		ID:               utils.GenerateUniqueIDWithPrefix("palert"),
+		AlertType:        "AMOUNT_MISMATCH",
+		PaymentID:        paymentTx.ID,
+		OrderID:          notif.OrderID,
+		ProviderName:     providerName,
+		ExpectedAmount:   money.New(paymentTx.Amount, paymentTx.Currency).Decimal(),
+		ExpectedCurrency: paymentTx.Currency,
+		NotifiedAmount:   notif.GrossAmount,
+		NotifiedCurrency: notif.Currency,
+		Reason:           notifiedAmountMismatch(paymentTx, notif),
+		CreatedAt:        time.Now().Unix(),
+	}
+	if uc.AlertProducer == nil {
+		uc.Log.Error("payment-usecase", "payment alert producer not configured", "sendFlaggedAlert", utils.ConvertString(event))
+		return
+	}
+	if err := uc.AlertProducer.SendAlert(event); err != nil {
+		uc.Log.Error("payment-usecase", "failed to send payment alert", "sendFlaggedAlert", utils.ConvertString(err))
+	}
+}
+
+// notifiedAmountMismatch compares the amount and currency a provider reports
+// with the stored payment and describes the difference, or returns "" when
+// they match. The notified amount is read in major units and must come to
+// the same number of minor units. A notification without a currency is taken
+// to be in the payment currency.
+func notifiedAmountMismatch(paymentTx *entity.PaymentTransaction, notif *paymentGateway.Notification) string {
+	if notif.Currency != "" && !strings.EqualFold(notif.Currency, paymentTx.Currency) {
+		return fmt.Sprintf("currency mismatch: expected %s, notified %s", paymentTx.Currency, notif.Currency)
+	}
+
+	notified, err := money.ParseMajor(notif.GrossAmount, paymentTx.Currency)
+	if err != nil {
+		return fmt.Sprintf("unreadable gross amount %q", notif.GrossAmount)
+	}
+
+	expected := money.New(paymentTx.Amount, paymentTx.Currency)
+	if notified.Amount != expected.Amount {
+		return fmt.Sprintf("amount mismatch: expected %s, notified %s", expected, notif.GrossAmount)
+	}
+	return ""
+}
+
+// providerForPayment picks the adapter recorded on the payment row, falling
+// back to the one configured for its payment method for rows created before
+// provider_name was filled in.
+func providerForPayment(providers *paymentGateway.Registry, p *entity.PaymentTransaction) (paymentGateway.PaymentProvider, error) {
+	if p.ProviderName != nil && *p.ProviderName != "" {
+		return providers.Get(*p.ProviderName)
+	}
+	return providers.ForMethod(p.PaymentMethod)
+}
+
+// providerReference is what the provider knows a payment by: the reference
+// it returned, else the order_id the payment was charged under, which for a
+// tip is its own charge order id, else the ride order id trip charges used
+// before either was stored.
+func providerReference(p *entity.PaymentTransaction, orderID string) string {
+	if p.ProviderReferenceID != nil && *p.ProviderReferenceID != "" {
+		return *p.ProviderReferenceID
+	}
+	if p.ChargeOrderID != nil && *p.ChargeOrderID != "" {
+		return *p.ChargeOrderID
 	}
+	return orderID
 }
 
-func (uc *PaymentUseCase) calculateFinalAmount(order *entity.Order) float64 {
+// calculateFinalAmount prices the order from the order service fares, which
+// are major-unit floats, capped at the max price.
+func (uc *PaymentUseCase) calculateFinalAmount(order *entity.Order) money.Amount {
 	maxPrice := order.MaxPrice
 
 	actualPrice := maxPrice
@@ -424,10 +1249,10 @@ func (uc *PaymentUseCase) calculateFinalAmount(order *entity.Order) float64 {
 	}
 
 	if actualPrice > maxPrice {
-		return maxPrice
+		actualPrice = maxPrice
 	}
 	if actualPrice <= 0 {
-		return maxPrice
+		actualPrice = maxPrice
 	}
-	return actualPrice
+	return orderAmount(uc.Config, actualPrice)
 }
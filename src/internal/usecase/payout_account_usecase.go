@@ -0,0 +1,467 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/gateway/payout"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/model/converter"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/databases/mysql"
+	httpError "payment-service/src/pkg/http-error"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+)
+
+type PayoutAccountUseCase struct {
+	Log                     log.Log
+	Config                  *viper.Viper
+	PayoutAccountRepository *repository.PayoutAccountRepository
+	UserRepository          *repository.UserRepository
+	DB                      mysql.DBInterface
+	Payouts                 payout.PayoutProvider
+	Cipher                  *utils.FieldCipher
+}
+
+func NewPayoutAccountUseCase(
+	log log.Log,
+	config *viper.Viper,
+	accountRepo *repository.PayoutAccountRepository,
+	userRepo *repository.UserRepository,
+	db mysql.DBInterface,
+	payouts payout.PayoutProvider,
+	cipher *utils.FieldCipher,
+) *PayoutAccountUseCase {
+	return &PayoutAccountUseCase{
+		Log:                     log,
+		Config:                  config,
+		PayoutAccountRepository: accountRepo,
+		UserRepository:          userRepo,
+		DB:                      db,
+		Payouts:                 payouts,
+		Cipher:                  cipher,
+	}
+}
+
+// AddAccount registers a payout account for a driver and verifies it with an
+// account name inquiry.
+func (uc *PayoutAccountUseCase) AddAccount(ctx context.Context, req *model.PayoutAccountRequest) utils.Result {
+	return uc.saveAccount(ctx, req, "AddAccount")
+}
+
+// UpdateAccount replaces an account's bank details. The account is verified
+// again and starts a new cooling-off period, as a new account would.
+func (uc *PayoutAccountUseCase) UpdateAccount(ctx context.Context, req *model.PayoutAccountRequest) utils.Result {
+	return uc.saveAccount(ctx, req, "UpdateAccount")
+}
+
+func (uc *PayoutAccountUseCase) saveAccount(ctx context.Context, req *model.PayoutAccountRequest, scope string) utils.Result {
+	var result utils.Result
+
+	bankCode := strings.ToLower(strings.TrimSpace(req.BankCode))
+	number := strings.ReplaceAll(strings.TrimSpace(req.AccountNumber), " ", "")
+	holder := strings.TrimSpace(req.HolderName)
+	if bankCode == "" || number == "" || holder == "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "bankCode, accountNumber and holderName are required"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, req.DriverID)
+		return result
+	}
+	if len(number) < 6 || strings.IndexFunc(number, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "accountNumber must be at least 6 digits"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, req.DriverID)
+		return result
+	}
+
+	user, err := uc.UserRepository.FindByID(ctx, req.DriverID)
+	if err != nil || user == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "user not found"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return result
+	}
+	if !user.IsMitra {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "only drivers can register payout accounts"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, req.DriverID)
+		return result
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	account := &entity.PayoutAccount{DriverID: req.DriverID}
+	if req.AccountID != "" {
+		account, err = uc.PayoutAccountRepository.FindDriverAccountForUpdate(ctx, tx, req.DriverID, req.AccountID)
+		if err != nil {
+			_ = tx.Rollback()
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to get payout account"
+			result.Error = errObj
+			uc.Log.Error("payout-account-usecase", errObj.Message, scope, utils.ConvertString(err))
+			return result
+		}
+		if account == nil || account.Status == entity.PayoutAccountRemoved {
+			_ = tx.Rollback()
+			errObj := httpError.NewNotFound()
+			errObj.Message = "payout account not found"
+			result.Error = errObj
+			uc.Log.Error("payout-account-usecase", errObj.Message, scope, req.AccountID)
+			return result
+		}
+	}
+
+	numberHash := uc.Cipher.Hash(bankCode + ":" + number)
+	duplicates, err := uc.PayoutAccountRepository.CountDriverDuplicatesTx(ctx, tx, req.DriverID, bankCode, numberHash, account.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to check payout accounts"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return result
+	}
+	if duplicates > 0 {
+		_ = tx.Rollback()
+		errObj := httpError.NewConflict()
+		errObj.Message = "this bank account is already registered"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, req.DriverID)
+		return result
+	}
+
+	encryptedNumber, err := uc.Cipher.Encrypt(number)
+	if err == nil {
+		account.HolderNameEncrypted, err = uc.Cipher.Encrypt(holder)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to encrypt payout account"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return result
+	}
+	account.BankCode = bankCode
+	account.AccountNumberEncrypted = encryptedNumber
+	account.AccountNumberHash = numberHash
+	account.AccountNumberLast4 = number[len(number)-4:]
+	account.Status = entity.PayoutAccountPending
+	account.InquiryNameEncrypted = nil
+	account.NameMatchScore = nil
+	account.RejectionReason = nil
+	account.AvailableAfter = nil
+	account.VerifiedAt = nil
+
+	if account.ID == 0 {
+		account.AccountID = utils.GenerateUniqueIDWithPrefix("payout_account")
+		account.CreatedAt = time.Now()
+		id, err := uc.PayoutAccountRepository.InsertAccountTx(ctx, tx, account)
+		if err != nil {
+			_ = tx.Rollback()
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to create payout account"
+			result.Error = errObj
+			uc.Log.Error("payout-account-usecase", errObj.Message, scope, utils.ConvertString(err))
+			return result
+		}
+		account.ID = id
+	}
+
+	if err := uc.verify(ctx, tx, account, number, holder, user.FullName); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to verify payout account"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return result
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return result
+	}
+
+	uc.Log.Info("payout-account-usecase",
+		fmt.Sprintf("Payout account %s saved. driver=%s bank=%s status=%s", account.AccountID, req.DriverID, bankCode, account.Status),
+		scope, "")
+	result.Data = converter.PayoutAccountToResponse(account, holder)
+	return result
+}
+
+// VerifyAccount runs the name inquiry again for an account still PENDING
+// because the payout provider could not be reached.
+func (uc *PayoutAccountUseCase) VerifyAccount(ctx context.Context, req *model.PayoutAccountRequest) utils.Result {
+	var result utils.Result
+
+	user, err := uc.UserRepository.FindByID(ctx, req.DriverID)
+	if err != nil || user == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "user not found"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "VerifyAccount", utils.ConvertString(err))
+		return result
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "VerifyAccount", utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "VerifyAccount", utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	account, err := uc.PayoutAccountRepository.FindDriverAccountForUpdate(ctx, tx, req.DriverID, req.AccountID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payout account"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "VerifyAccount", utils.ConvertString(err))
+		return result
+	}
+	if account == nil || account.Status == entity.PayoutAccountRemoved {
+		_ = tx.Rollback()
+		errObj := httpError.NewNotFound()
+		errObj.Message = "payout account not found"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "VerifyAccount", req.AccountID)
+		return result
+	}
+	if account.Status != entity.PayoutAccountPending {
+		_ = tx.Rollback()
+		errObj := httpError.NewConflict()
+		errObj.Message = "payout account already " + strings.ToLower(account.Status)
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "VerifyAccount", req.AccountID)
+		return result
+	}
+
+	number, err := uc.Cipher.Decrypt(account.AccountNumberEncrypted)
+	var holder string
+	if err == nil {
+		holder, err = uc.Cipher.Decrypt(account.HolderNameEncrypted)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to decrypt payout account"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "VerifyAccount", utils.ConvertString(err))
+		return result
+	}
+
+	if err := uc.verify(ctx, tx, account, number, holder, user.FullName); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to verify payout account"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "VerifyAccount", utils.ConvertString(err))
+		return result
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "VerifyAccount", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = converter.PayoutAccountToResponse(account, holder)
+	return result
+}
+
+func (uc *PayoutAccountUseCase) ListAccounts(ctx context.Context, req *model.PayoutAccountRequest) utils.Result {
+	var result utils.Result
+
+	accounts, err := uc.PayoutAccountRepository.FindByDriver(ctx, req.DriverID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payout accounts"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "ListAccounts", utils.ConvertString(err))
+		return result
+	}
+
+	response := make([]*model.PayoutAccountResponse, 0, len(accounts))
+	for i := range accounts {
+		holder, err := uc.Cipher.Decrypt(accounts[i].HolderNameEncrypted)
+		if err != nil {
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to decrypt payout account"
+			result.Error = errObj
+			uc.Log.Error("payout-account-usecase", errObj.Message, "ListAccounts", utils.ConvertString(err))
+			return result
+		}
+		response = append(response, converter.PayoutAccountToResponse(&accounts[i], holder))
+	}
+	result.Data = response
+	return result
+}
+
+// RemoveAccount retires an account. The row is kept, encrypted, for the
+// withdrawals already sent to it; withdrawals still waiting for approval
+// fail when they are sent.
+func (uc *PayoutAccountUseCase) RemoveAccount(ctx context.Context, req *model.PayoutAccountRequest) utils.Result {
+	var result utils.Result
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "RemoveAccount", utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "RemoveAccount", utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	account, err := uc.PayoutAccountRepository.FindDriverAccountForUpdate(ctx, tx, req.DriverID, req.AccountID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payout account"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "RemoveAccount", utils.ConvertString(err))
+		return result
+	}
+	if account == nil || account.Status == entity.PayoutAccountRemoved {
+		_ = tx.Rollback()
+		errObj := httpError.NewNotFound()
+		errObj.Message = "payout account not found"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "RemoveAccount", req.AccountID)
+		return result
+	}
+
+	now := time.Now()
+	account.Status = entity.PayoutAccountRemoved
+	account.RemovedAt = &now
+	if err := uc.PayoutAccountRepository.UpdateAccountTx(ctx, tx, account); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to remove payout account"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "RemoveAccount", utils.ConvertString(err))
+		return result
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("payout-account-usecase", errObj.Message, "RemoveAccount", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = map[string]string{"account_id": account.AccountID, "status": account.Status}
+	return result
+}
+
+// verify asks the bank for the account holder's name and compares it with
+// the driver's full name. A match of payout_account.name_match_threshold or
+// better verifies the account, usable once payout_account.cooling_off_hours
+// have passed; a weaker match, or no such account, rejects it. When the
+// provider cannot answer the account stays PENDING for VerifyAccount.
+func (uc *PayoutAccountUseCase) verify(ctx context.Context, tx *sqlx.Tx, account *entity.PayoutAccount, number, holder, fullName string) error {
+	inquiry, err := uc.Payouts.InquireAccount(ctx, account.BankCode, number, holder)
+	switch {
+	case errors.Is(err, payout.ErrAccountNotFound):
+		reason := "bank account not found"
+		account.Status = entity.PayoutAccountRejected
+		account.RejectionReason = &reason
+	case err != nil:
+		uc.Log.Error("payout-account-usecase", "account name inquiry failed", "Verify", utils.ConvertString(err))
+		account.Status = entity.PayoutAccountPending
+	default:
+		inquiryName, err := uc.Cipher.Encrypt(inquiry.AccountName)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt inquiry name: %v", err)
+		}
+		score := nameMatchScore(inquiry.AccountName, fullName)
+		account.InquiryNameEncrypted = &inquiryName
+		account.NameMatchScore = &score
+		if score >= uc.Config.GetFloat64("payout_account.name_match_threshold") {
+			now := time.Now()
+			availableAfter := now.Add(time.Duration(uc.Config.GetInt("payout_account.cooling_off_hours")) * time.Hour)
+			account.Status = entity.PayoutAccountVerified
+			account.VerifiedAt = &now
+			account.AvailableAfter = &availableAfter
+		} else {
+			reason := "account holder name does not match the driver's name"
+			account.Status = entity.PayoutAccountRejected
+			account.RejectionReason = &reason
+		}
+	}
+
+	if err := uc.PayoutAccountRepository.UpdateAccountTx(ctx, tx, account); err != nil {
+		return fmt.Errorf("failed to update payout account: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/money"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// redeemPromo evaluates the promo the order service attached to an order
+// against amount, the trip price before any discount, and returns the
+// redemption and the discount it gives. The campaign's usage and budget are
+// taken inside tx, so a payment that is rolled back gives them back. A promo
+// the campaign can no longer honour is marked REJECTED and the order is
+// charged in full. The caller ties the redemption to its payment with
+// attachPromo once the payment row exists.
+func redeemPromo(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	repo *repository.PaymentRepository,
+	order *entity.Order,
+	amount money.Amount,
+	currency string,
+) (*entity.PromoRedemption, money.Amount, error) {
+	redemption, err := repo.FindOrderRedemptionForUpdate(ctx, tx, order.OrderID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get promo redemption: %v", err)
+	}
+	if redemption == nil {
+		return nil, 0, nil
+	}
+	campaign, err := repo.FindPromoCampaignForUpdate(ctx, tx, redemption.PromoCampaignID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get promo campaign: %v", err)
+	}
+	if campaign == nil {
+		return nil, 0, fmt.Errorf("promo campaign %d not found", redemption.PromoCampaignID)
+	}
+
+	// a redemption another live payment of the order took moves to this one
+	var previous money.Amount
+	alreadyApplied := redemption.Status == entity.PromoRedemptionApplied
+	if alreadyApplied {
+		previous = redemption.DiscountApplied
+	} else {
+		ok, err := promoEligible(ctx, tx, repo, campaign, redemption, order.PassengerID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			return nil, 0, rejectPromo(ctx, tx, repo, redemption)
+		}
+	}
+
+	discount := promoDiscount(campaign, amount, currency)
+	if campaign.Budget != nil {
+		discount = min(discount, *campaign.Budget-campaign.BudgetUsed+previous)
+	}
+	if discount <= 0 {
+		if alreadyApplied {
+			return nil, 0, reversePromo(ctx, tx, repo, redemption, "campaign budget spent")
+		}
+		return nil, 0, rejectPromo(ctx, tx, repo, redemption)
+	}
+
+	if !alreadyApplied {
+		campaign.UsageCount++
+	}
+	campaign.BudgetUsed += discount - previous
+	if err := repo.UpdatePromoCampaignUsageTx(ctx, tx, campaign); err != nil {
+		return nil, 0, fmt.Errorf("failed to update promo campaign usage: %v", err)
+	}
+	redemption.PassengerID = &order.PassengerID
+	redemption.DiscountApplied = discount
+	redemption.Status = entity.PromoRedemptionApplied
+	redemption.ReversedAt = nil
+	if err := repo.UpdatePromoRedemptionTx(ctx, tx, redemption); err != nil {
+		return nil, 0, fmt.Errorf("failed to update promo redemption: %v", err)
+	}
+	return redemption, discount, nil
+}
+
+// attachPromo records on the redemption the payment that took its discount.
+func attachPromo(ctx context.Context, tx *sqlx.Tx, repo *repository.PaymentRepository, redemption *entity.PromoRedemption, paymentID uint64) error {
+	if redemption == nil {
+		return nil
+	}
+	redemption.PaymentTransactionID = &paymentID
+	if err := repo.UpdatePromoRedemptionTx(ctx, tx, redemption); err != nil {
+		return fmt.Errorf("failed to update promo redemption: %v", err)
+	}
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentID,
+		EventType:            "PROMO_APPLIED",
+		EventDescription:     fmt.Sprintf("Promo redemption %d applied, discount %d", redemption.ID, redemption.DiscountApplied),
+	}
+	if err := repo.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return fmt.Errorf("failed to insert promo event log: %v", err)
+	}
+	return nil
+}
+
+// settlePromo prices a payment's discount again once the final amount is
+// known, e.g. the fare a wallet hold is captured for. The discount never grows
+// past what was taken when the payment was created; what it shrinks by goes
+// back to the campaign budget.
+func settlePromo(ctx context.Context, tx *sqlx.Tx, repo *repository.PaymentRepository, paymentID uint64, amount money.Amount, currency string) (money.Amount, error) {
+	redemption, err := repo.FindAppliedRedemptionByPaymentForUpdate(ctx, tx, paymentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get promo redemption: %v", err)
+	}
+	if redemption == nil {
+		return 0, nil
+	}
+	campaign, err := repo.FindPromoCampaignForUpdate(ctx, tx, redemption.PromoCampaignID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get promo campaign: %v", err)
+	}
+	if campaign == nil {
+		return 0, fmt.Errorf("promo campaign %d not found", redemption.PromoCampaignID)
+	}
+
+	discount := min(promoDiscount(campaign, amount, currency), redemption.DiscountApplied)
+	if discount == redemption.DiscountApplied {
+		return discount, nil
+	}
+	campaign.BudgetUsed -= redemption.DiscountApplied - discount
+	if err := repo.UpdatePromoCampaignUsageTx(ctx, tx, campaign); err != nil {
+		return 0, fmt.Errorf("failed to update promo campaign usage: %v", err)
+	}
+	redemption.DiscountApplied = discount
+	if err := repo.UpdatePromoRedemptionTx(ctx, tx, redemption); err != nil {
+		return 0, fmt.Errorf("failed to update promo redemption: %v", err)
+	}
+	return discount, nil
+}
+
+// reversePaymentPromo gives back the promo a payment took, when it failed or
+// was refunded, so the passenger may use it again.
+func reversePaymentPromo(ctx context.Context, tx *sqlx.Tx, repo *repository.PaymentRepository, paymentID uint64, reason string) error {
+	redemption, err := repo.FindAppliedRedemptionByPaymentForUpdate(ctx, tx, paymentID)
+	if err != nil {
+		return fmt.Errorf("failed to get promo redemption: %v", err)
+	}
+	if redemption == nil {
+		return nil
+	}
+	return reversePromo(ctx, tx, repo, redemption, reason)
+}
+
+func reversePromo(ctx context.Context, tx *sqlx.Tx, repo *repository.PaymentRepository, redemption *entity.PromoRedemption, reason string) error {
+	campaign, err := repo.FindPromoCampaignForUpdate(ctx, tx, redemption.PromoCampaignID)
+	if err != nil {
+		return fmt.Errorf("failed to get promo campaign: %v", err)
+	}
+	if campaign != nil {
+		campaign.UsageCount = max(campaign.UsageCount-1, 0)
+		campaign.BudgetUsed = max(campaign.BudgetUsed-redemption.DiscountApplied, 0)
+		if err := repo.UpdatePromoCampaignUsageTx(ctx, tx, campaign); err != nil {
+			return fmt.Errorf("failed to update promo campaign usage: %v", err)
+		}
+	}
+
+	now := time.Now()
+	discount := redemption.DiscountApplied
+	redemption.Status = entity.PromoRedemptionReversed
+	redemption.ReversedAt = &now
+	if err := repo.UpdatePromoRedemptionTx(ctx, tx, redemption); err != nil {
+		return fmt.Errorf("failed to update promo redemption: %v", err)
+	}
+	if redemption.PaymentTransactionID == nil {
+		return nil
+	}
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: *redemption.PaymentTransactionID,
+		EventType:            "PROMO_REVERSED",
+		EventDescription:     fmt.Sprintf("Promo redemption %d reversed, discount %d returned to campaign: %s", redemption.ID, discount, reason),
+	}
+	if err := repo.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return fmt.Errorf("failed to insert promo event log: %v", err)
+	}
+	return nil
+}
+
+func rejectPromo(ctx context.Context, tx *sqlx.Tx, repo *repository.PaymentRepository, redemption *entity.PromoRedemption) error {
+	redemption.Status = entity.PromoRedemptionRejected
+	redemption.DiscountApplied = 0
+	if err := repo.UpdatePromoRedemptionTx(ctx, tx, redemption); err != nil {
+		return fmt.Errorf("failed to update promo redemption: %v", err)
+	}
+	return nil
+}
+
+// promoEligible checks the campaign window and its global and per-passenger
+// usage limits.
+func promoEligible(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	repo *repository.PaymentRepository,
+	campaign *entity.PromoCampaign,
+	redemption *entity.PromoRedemption,
+	passengerID string,
+) (bool, error) {
+	now := time.Now()
+	if campaign.StartsAt != nil && now.Before(*campaign.StartsAt) {
+		return false, nil
+	}
+	if campaign.EndsAt != nil && now.After(*campaign.EndsAt) {
+		return false, nil
+	}
+	if campaign.UsageLimit != nil && campaign.UsageCount >= *campaign.UsageLimit {
+		return false, nil
+	}
+	if campaign.PerUserLimit != nil {
+		used, err := repo.CountPassengerRedemptionsTx(ctx, tx, campaign.ID, passengerID, redemption.ID)
+		if err != nil {
+			return false, fmt.Errorf("failed to count promo redemptions: %v", err)
+		}
+		if used >= *campaign.PerUserLimit {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// promoDiscount is what the campaign takes off amount, capped by its max
+// discount and never more than amount itself. Flat values are major units of
+// currency.
+func promoDiscount(campaign *entity.PromoCampaign, amount money.Amount, currency string) money.Amount {
+	var discount money.Amount
+	switch campaign.DiscountType {
+	case entity.PromoDiscountPercentage:
+		discount = amount.MulRate(campaign.DiscountValue/100, money.RoundDown)
+	case entity.PromoDiscountFlat:
+		discount = money.FromMajor(campaign.DiscountValue, currency).Amount
+	}
+	if campaign.MaxDiscount != nil && *campaign.MaxDiscount > 0 {
+		discount = min(discount, money.FromMajor(*campaign.MaxDiscount, currency).Amount)
+	}
+	return max(min(discount, amount), 0)
+}
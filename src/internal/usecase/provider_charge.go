@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"payment-service/src/internal/entity"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/repository"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+)
+
+// chargePendingUntil is the expired_at a payment row is committed with before
+// its provider charge exists. Should the charge never be stored, e.g. the
+// process stopped while the provider was called, the expiry sweeper picks the
+// row up after this window and closes whatever the provider opened for it.
+func chargePendingUntil(config *viper.Viper) time.Time {
+	seconds := config.GetInt("payment.charge.pending_seconds")
+	if seconds <= 0 {
+		seconds = 300
+	}
+	return time.Now().Add(time.Duration(seconds) * time.Second)
+}
+
+// chargeOpened reports whether the provider charge of a payment has been
+// stored; until then its metadata only carries the charge mode.
+func chargeOpened(meta *entity.PaymentChargeMetadata) bool {
+	return meta.SnapToken != "" || meta.RedirectURL != "" || meta.QrString != "" ||
+		meta.TransactionID != "" || meta.VaNumber != ""
+}
+
+// openProviderCharge creates the provider charge of a PENDING payment that has
+// already been committed with its charge_order_id, and stores what the
+// provider handed back. No row is locked while the provider is called. When
+// the charge could not be created the payment is expired at once, so the
+// expiry sweeper asks the provider about it and cancels anything it opened
+// before the call failed.
+func openProviderCharge(
+	ctx context.Context,
+	db *sqlx.DB,
+	repo *repository.PaymentRepository,
+	provider paymentGateway.PaymentProvider,
+	paymentID uint64,
+	req *paymentGateway.ChargeRequest,
+	mode string,
+) (*entity.PaymentTransaction, *entity.PaymentChargeMetadata, error) {
+	charge, chargeErr := provider.CreateCharge(ctx, req)
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	paymentTx, err := repo.FindByIDForUpdate(ctx, tx, paymentID)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to get payment transaction: %v", err)
+	}
+	if paymentTx == nil {
+		_ = tx.Rollback()
+		return nil, nil, fmt.Errorf("payment transaction %d not found", paymentID)
+	}
+
+	if chargeErr != nil {
+		if paymentTx.PaymentStatus == entity.PaymentStatusPending {
+			now := time.Now()
+			paymentTx.ExpiredAt = &now
+			if err := repo.UpdatePaymentTransactionTx(ctx, tx, paymentTx); err != nil {
+				_ = tx.Rollback()
+				return nil, nil, fmt.Errorf("failed to update payment transaction: %v", err)
+			}
+		}
+		event := &entity.PaymentEventLog{
+			PaymentTransactionID: paymentTx.ID,
+			EventType:            "CREATE_FAILED",
+			EventDescription:     fmt.Sprintf("Create %s payment via %s failed: %v", req.PaymentMethod, provider.Name(), chargeErr),
+		}
+		if err := repo.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+			_ = tx.Rollback()
+			return nil, nil, fmt.Errorf("failed to insert payment event log: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		return nil, nil, chargeErr
+	}
+
+	meta := &entity.PaymentChargeMetadata{
+		Mode:          mode,
+		SnapToken:     charge.Token,
+		RedirectURL:   charge.RedirectURL,
+		QrString:      charge.QrString,
+		TransactionID: charge.TransactionID,
+	}
+	if charge.VA != nil {
+		meta.Bank = charge.VA.Bank
+		meta.VaNumber = charge.VA.Number
+		meta.BillerCode = charge.VA.BillerCode
+	}
+	metadata, _ := json.Marshal(meta)
+
+	// a webhook may already have moved the payment on; the charge details are
+	// kept either way, the expiry only while it is still open
+	providerName := charge.ProviderName
+	paymentTx.ProviderName = &providerName
+	paymentTx.Metadata = metadata
+	if charge.ReferenceID != "" {
+		paymentTx.ProviderReferenceID = &charge.ReferenceID
+	}
+	if paymentTx.PaymentStatus == entity.PaymentStatusPending {
+		paymentTx.ExpiredAt = charge.ExpiryTime
+	}
+	if err := repo.UpdatePaymentTransactionTx(ctx, tx, paymentTx); err != nil {
+		_ = tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to update payment transaction: %v", err)
+	}
+
+	rawPayload := charge.RawPayload
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentTx.ID,
+		EventType:            "CREATE",
+		EventDescription:     fmt.Sprintf("Create %s payment via %s", req.PaymentMethod, providerName),
+		RawPayload:           &rawPayload,
+	}
+	if err := repo.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		_ = tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to insert payment event log: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return paymentTx, meta, nil
+}
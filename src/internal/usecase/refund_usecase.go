@@ -0,0 +1,679 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/databases/mysql"
+	httpError "payment-service/src/pkg/http-error"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/money"
+	"payment-service/src/pkg/utils"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+)
+
+type RefundUseCase struct {
+	Log               log.Log
+	Config            *viper.Viper
+	OrderRepository   *repository.OrderRepository
+	WalletRepository  *repository.WalletRepository
+	PaymentRepository *repository.PaymentRepository
+	RefundRepository  *repository.RefundRepository
+	DriverRepository  *repository.DriverRepository
+	DebtRepository    *repository.DriverDebtRepository
+	DB                mysql.DBInterface
+	Providers         *paymentGateway.Registry
+}
+
+func NewRefundUseCase(
+	log log.Log,
+	config *viper.Viper,
+	orderRepo *repository.OrderRepository,
+	walletRepo *repository.WalletRepository,
+	paymentRepo *repository.PaymentRepository,
+	refundRepo *repository.RefundRepository,
+	driverRepo *repository.DriverRepository,
+	debtRepo *repository.DriverDebtRepository,
+	db mysql.DBInterface,
+	providers *paymentGateway.Registry,
+) *RefundUseCase {
+	return &RefundUseCase{
+		Log:               log,
+		Config:            config,
+		OrderRepository:   orderRepo,
+		WalletRepository:  walletRepo,
+		PaymentRepository: paymentRepo,
+		RefundRepository:  refundRepo,
+		DriverRepository:  driverRepo,
+		DebtRepository:    debtRepo,
+		DB:                db,
+		Providers:         providers,
+	}
+}
+
+// RefundPayment refunds all (amount 0) or part of the settled payment of an
+// order. QRIS payments are refunded through the provider, wallet payments by
+// crediting the passenger wallet. The driver's share of the refunded amount is
+// taken back from their wallet pro rata.
+//
+// A provider refund is first committed as PENDING and only then sent to the
+// provider; calling again while it is pending retries the same refund.
+func (uc *RefundUseCase) RefundPayment(ctx context.Context, req *model.RefundPaymentRequest) utils.Result {
+	var result utils.Result
+
+	if req.OrderID == "" || req.Amount < 0 || !entity.RefundReasonCodes[req.ReasonCode] {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "orderId, a non-negative amount and a valid reasonCode are required"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(req))
+		return result
+	}
+
+	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{OrderID: &req.OrderID})
+	if err != nil || order == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "order not found"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+		return result
+	}
+
+	if !uc.canRefund(order, req.UserID) {
+		errObj := httpError.NewUnauthorized()
+		errObj.Message = "not allowed to refund this order"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", req.UserID)
+		return result
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	paymentTx, err := uc.PaymentRepository.FindRefundablePaymentForUpdate(ctx, tx, order.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payment transaction"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+		return result
+	}
+	if paymentTx == nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewNotFound()
+		errObj.Message = "no refundable payment for order"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", order.OrderID)
+		return result
+	}
+	if isCashMethod(paymentTx.PaymentMethod) {
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "cash payments are settled with the driver and cannot be refunded"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", order.OrderID)
+		return result
+	}
+
+	pending, err := uc.RefundRepository.FindPendingRefundTx(ctx, tx, paymentTx.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get pending refund"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+		return result
+	}
+
+	var refund *entity.PaymentRefund
+	if pending != nil {
+		// a provider refund that did not complete is retried under its own
+		// refund id, so the provider never pays it out twice
+		if req.Amount != 0 && req.Amount != pending.Amount {
+			_ = tx.Rollback()
+			errObj := httpError.NewConflict()
+			errObj.Message = fmt.Sprintf("refund %s of %d is still pending, retry it before refunding another amount", pending.RefundID, pending.Amount)
+			result.Error = errObj
+			uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(req))
+			return result
+		}
+		refund = pending
+	} else {
+		refunded, err := uc.RefundRepository.SumRefundedAmountTx(ctx, tx, paymentTx.ID)
+		if err != nil {
+			_ = tx.Rollback()
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to get refunded amount"
+			result.Error = errObj
+			uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+			return result
+		}
+
+		remaining := paymentTx.Amount - refunded
+		amount := req.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			_ = tx.Rollback()
+			errObj := httpError.NewBadRequest()
+			errObj.Message = fmt.Sprintf("refund amount must be between 0 and %d", remaining)
+			result.Error = errObj
+			uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(req))
+			return result
+		}
+
+		refund = &entity.PaymentRefund{
+			RefundID:             utils.GenerateUniqueIDWithPrefix("refund"),
+			PaymentTransactionID: paymentTx.ID,
+			Amount:               amount,
+			Currency:             paymentTx.Currency,
+			ReasonCode:           req.ReasonCode,
+			Status:               "PENDING",
+			RequestedBy:          req.UserID,
+			CreatedAt:            time.Now(),
+		}
+		if req.ReasonNote != "" {
+			refund.ReasonNote = &req.ReasonNote
+		}
+
+		if isWalletMethod(paymentTx.PaymentMethod) {
+			refund.RefundMethod = "WALLET"
+			refund.Status = "SUCCESS"
+			if err := uc.creditPassenger(ctx, tx, paymentTx, amount, order.OrderID); err != nil {
+				_ = tx.Rollback()
+				errObj := httpError.NewInternalServerError()
+				errObj.Message = "failed to credit passenger wallet"
+				result.Error = errObj
+				uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+				return result
+			}
+
+			remaining, err := uc.completeRefund(ctx, tx, paymentTx, refund, order.OrderID)
+			if err != nil {
+				_ = tx.Rollback()
+				errObj := httpError.NewInternalServerError()
+				errObj.Message = "failed to complete refund"
+				result.Error = errObj
+				uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+				return result
+			}
+
+			if err := tx.Commit(); err != nil {
+				errObj := httpError.NewInternalServerError()
+				errObj.Message = "failed to commit transaction"
+				result.Error = errObj
+				uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+				return result
+			}
+
+			result.Data = refundResponse(order, paymentTx, refund, remaining)
+			return result
+		}
+
+		// the PENDING row reserves the amount before the provider is called
+		refund.RefundMethod = "PROVIDER"
+		if err := uc.RefundRepository.InsertRefundTx(ctx, tx, refund); err != nil {
+			_ = tx.Rollback()
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to save refund"
+			result.Error = errObj
+			uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+			return result
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+		return result
+	}
+
+	response, err := uc.completeProviderRefund(ctx, order, paymentTx, refund)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = fmt.Sprintf("refund %s is pending, retry it to complete", refund.RefundID)
+		result.Error = errObj
+		uc.Log.Error("refund-usecase", errObj.Message, "RefundPayment", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = *response
+	return result
+}
+
+// RetryPendingRefunds completes provider refunds left PENDING by a failed or
+// interrupted request, calling the provider again with the same refund id.
+func (uc *RefundUseCase) RetryPendingRefunds(ctx context.Context) error {
+	batchSize := uc.Config.GetInt("scheduler.refund_retry.batch_size")
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	before := time.Now().Add(-time.Duration(uc.Config.GetInt("payment.refund.retry_after_seconds")) * time.Second)
+
+	refunds, err := uc.RefundRepository.FindStalePendingRefunds(ctx, before, batchSize)
+	if err != nil {
+		uc.Log.Error("refund-usecase", "failed to get pending refunds", "RetryPendingRefunds", utils.ConvertString(err))
+		return fmt.Errorf("failed to get pending refunds: %v", err)
+	}
+
+	completed := 0
+	for i := range refunds {
+		refund := &refunds[i]
+		if err := uc.retryRefund(ctx, refund); err != nil {
+			uc.Log.Error("refund-usecase", fmt.Sprintf("failed to complete refund %s", refund.RefundID), "RetryPendingRefunds", utils.ConvertString(err))
+			continue
+		}
+		completed++
+	}
+
+	if len(refunds) > 0 {
+		uc.Log.Info("refund-usecase",
+			fmt.Sprintf("Completed %d of %d pending refunds", completed, len(refunds)),
+			"RetryPendingRefunds", "")
+	}
+	return nil
+}
+
+func (uc *RefundUseCase) retryRefund(ctx context.Context, refund *entity.PaymentRefund) error {
+	paymentTx, err := uc.PaymentRepository.FindByID(ctx, refund.PaymentTransactionID)
+	if err != nil {
+		return fmt.Errorf("failed to get payment transaction: %v", err)
+	}
+	if paymentTx == nil {
+		return fmt.Errorf("payment transaction %d not found", refund.PaymentTransactionID)
+	}
+	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{ID: &paymentTx.RideOrderID})
+	if err != nil {
+		return fmt.Errorf("failed to get order: %v", err)
+	}
+	if order == nil {
+		return fmt.Errorf("order %d not found", paymentTx.RideOrderID)
+	}
+	_, err = uc.completeProviderRefund(ctx, order, paymentTx, refund)
+	return err
+}
+
+// completeProviderRefund sends a committed PENDING refund to the provider,
+// outside any transaction, then settles it. The refund id is the provider's
+// refund key, so calling this again after a failure cannot refund twice; on
+// error the refund stays PENDING for the next retry.
+func (uc *RefundUseCase) completeProviderRefund(ctx context.Context, order *entity.Order, paymentTx *entity.PaymentTransaction, refund *entity.PaymentRefund) (*model.RefundPaymentResponse, error) {
+	providerResp, err := uc.refundAtProvider(ctx, order, paymentTx, refund)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund payment at provider: %v", err)
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db connection: %v", err)
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	locked, err := uc.PaymentRepository.FindByIDForUpdate(ctx, tx, paymentTx.ID)
+	if err != nil || locked == nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to get payment transaction: %v", err)
+	}
+	current, err := uc.RefundRepository.FindByRefundIDForUpdate(ctx, tx, refund.RefundID)
+	if err != nil || current == nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to get refund %s: %v", refund.RefundID, err)
+	}
+
+	var remaining money.Amount
+	if current.Status != "PENDING" {
+		// a concurrent retry already completed it
+		refunded, err := uc.RefundRepository.SumRefundedAmountTx(ctx, tx, locked.ID)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, fmt.Errorf("failed to get refunded amount: %v", err)
+		}
+		remaining = locked.Amount - refunded
+	} else {
+		current.Status = "SUCCESS"
+		current.RawPayload = &providerResp.RawPayload
+		if providerResp.TransactionID != "" {
+			current.ProviderReferenceID = &providerResp.TransactionID
+		}
+		remaining, err = uc.completeRefund(ctx, tx, locked, current, order.OrderID)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	response := refundResponse(order, locked, current, remaining)
+	return &response, nil
+}
+
+// completeRefund books a refund whose money has been returned: it takes back
+// the driver's share, saves the refund and moves the payment to
+// PARTIALLY_REFUNDED or REFUNDED. It returns the amount left to refund.
+func (uc *RefundUseCase) completeRefund(ctx context.Context, tx *sqlx.Tx, paymentTx *entity.PaymentTransaction, refund *entity.PaymentRefund, orderID string) (money.Amount, error) {
+	reversal, err := uc.reverseDriverShare(ctx, tx, paymentTx, refund, orderID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reverse driver settlement: %v", err)
+	}
+	refund.DriverReversalAmount = reversal
+
+	if refund.ID == 0 {
+		err = uc.RefundRepository.InsertRefundTx(ctx, tx, refund)
+	} else {
+		err = uc.RefundRepository.UpdateRefundTx(ctx, tx, refund)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save refund: %v", err)
+	}
+
+	refunded, err := uc.RefundRepository.SumRefundedAmountTx(ctx, tx, paymentTx.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get refunded amount: %v", err)
+	}
+	remaining := paymentTx.Amount - refunded
+	newStatus := entity.PaymentStatusPartiallyRefunded
+	if remaining <= 0 {
+		newStatus = entity.PaymentStatusRefunded
+	}
+	// a payment the provider already reported fully refunded has reached the
+	// end of the state machine; the refund is still booked against it
+	if paymentTx.PaymentStatus != entity.PaymentStatusRefunded {
+		if err := transitionPayment(ctx, uc.PaymentRepository, tx, paymentTx, newStatus, "refund "+refund.RefundID, nil); err != nil {
+			return 0, fmt.Errorf("failed to update payment transaction: %v", err)
+		}
+	}
+
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentTx.ID,
+		EventType:            "REFUND",
+		EventDescription: fmt.Sprintf("Refund %s of %d via %s (%s), driver reversal %d",
+			refund.RefundID, refund.Amount, refund.RefundMethod, refund.ReasonCode, reversal),
+		RawPayload: refund.RawPayload,
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return 0, fmt.Errorf("failed to insert refund event log: %v", err)
+	}
+	return remaining, nil
+}
+
+// BookProviderRefunds books the refunds a provider reports on a payment, from
+// a refund notification or a status query, in tx on the locked paymentTx. A
+// refund we sent carries our refund id as its key: still PENDING here, it is
+// completed now, and one already booked is left as it is. A key we do not
+// know is a refund made at the provider, e.g. from its dashboard, and is
+// booked as a refund of its own, the driver's share taken back with it. It
+// returns how many refunds it booked.
+func (uc *RefundUseCase) BookProviderRefunds(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	paymentTx *entity.PaymentTransaction,
+	providerName string,
+	refunds []paymentGateway.ProviderRefund,
+	rawPayload string,
+	orderID string,
+) (int, error) {
+	booked := 0
+	for _, r := range refunds {
+		refund, err := uc.RefundRepository.FindByRefundIDForUpdate(ctx, tx, r.Key)
+		if err != nil {
+			return booked, fmt.Errorf("failed to get refund: %v", err)
+		}
+		if refund != nil && (refund.Status != "PENDING" || refund.PaymentTransactionID != paymentTx.ID) {
+			continue
+		}
+
+		if refund == nil {
+			amount, err := money.ParseMajor(r.Amount, paymentTx.Currency)
+			if err != nil {
+				return booked, fmt.Errorf("invalid amount %q on provider refund %s: %v", r.Amount, r.Key, err)
+			}
+			note := fmt.Sprintf("refunded at %s", providerName)
+			if r.Reason != "" {
+				note += ": " + r.Reason
+			}
+			refund = &entity.PaymentRefund{
+				RefundID:             r.Key,
+				PaymentTransactionID: paymentTx.ID,
+				Amount:               amount.Amount,
+				Currency:             paymentTx.Currency,
+				ReasonCode:           entity.RefundReasonOther,
+				ReasonNote:           &note,
+				RefundMethod:         "PROVIDER",
+				RequestedBy:          providerName,
+				CreatedAt:            time.Now(),
+			}
+		}
+		refund.Status = "SUCCESS"
+		refund.RawPayload = &rawPayload
+		if _, err := uc.completeRefund(ctx, tx, paymentTx, refund, orderID); err != nil {
+			return booked, err
+		}
+		booked++
+	}
+	return booked, nil
+}
+
+func refundResponse(order *entity.Order, paymentTx *entity.PaymentTransaction, refund *entity.PaymentRefund, remaining money.Amount) model.RefundPaymentResponse {
+	return model.RefundPaymentResponse{
+		RefundID:             refund.RefundID,
+		OrderID:              order.OrderID,
+		PaymentID:            paymentTx.ID,
+		Amount:               refund.Amount,
+		Currency:             refund.Currency,
+		ReasonCode:           refund.ReasonCode,
+		RefundMethod:         refund.RefundMethod,
+		Status:               refund.Status,
+		PaymentStatus:        paymentTx.PaymentStatus,
+		RemainingAmount:      remaining,
+		DriverReversalAmount: refund.DriverReversalAmount,
+		CreatedAt:            refund.CreatedAt,
+	}
+}
+
+// canRefund allows the driver who was paid for the order and the operators
+// listed in payment.refund.operator_ids.
+func (uc *RefundUseCase) canRefund(order *entity.Order, userID string) bool {
+	if order.DriverID != nil && *order.DriverID == userID {
+		return true
+	}
+	for _, operatorID := range uc.Config.GetStringSlice("payment.refund.operator_ids") {
+		if operatorID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (uc *RefundUseCase) refundAtProvider(ctx context.Context, order *entity.Order, paymentTx *entity.PaymentTransaction, refund *entity.PaymentRefund) (*paymentGateway.RefundResponse, error) {
+	provider, err := providerForPayment(uc.Providers, paymentTx)
+	if err != nil {
+		return nil, err
+	}
+	reference := providerReference(paymentTx, order.OrderID)
+	return provider.Refund(ctx, reference, &paymentGateway.RefundRequest{
+		RefundKey: refund.RefundID,
+		Amount:    refund.Amount,
+		Reason:    refund.ReasonCode,
+	})
+}
+
+// creditPassenger returns amount, in the payment currency, to the passenger's
+// wallet at the rate the wallet hold was priced at.
+func (uc *RefundUseCase) creditPassenger(ctx context.Context, tx *sqlx.Tx, paymentTx *entity.PaymentTransaction, refundAmount money.Amount, orderID string) error {
+	wallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, paymentTx.PassengerID)
+	if err != nil {
+		return fmt.Errorf("failed to get passenger wallet: %v", err)
+	}
+	if wallet == nil {
+		return fmt.Errorf("passenger wallet not found")
+	}
+	amount, err := walletShare(ctx, tx, uc.PaymentRepository, paymentTx.ID, entity.FxPurposeHold, wallet, money.New(refundAmount, paymentTx.Currency))
+	if err != nil {
+		return err
+	}
+
+	if err := uc.WalletRepository.UpdateWalletBalance(ctx, tx.Tx, wallet.ID, wallet.Balance+amount); err != nil {
+		return fmt.Errorf("failed to update passenger wallet balance: %v", err)
+	}
+
+	trx := &entity.WalletTransaction{
+		WalletID:      wallet.ID,
+		TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+		Amount:        amount,
+		Type:          "credit",
+		Description:   fmt.Sprintf("Refund for order %s", orderID),
+		Timestamp:     time.Now(),
+	}
+	if err := uc.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, trx); err != nil {
+		return fmt.Errorf("failed to insert refund transaction: %v", err)
+	}
+	return nil
+}
+
+// reverseDriverShare takes back the driver's share of the refunded amount and
+// records it as a negative REFUND_REVERSAL settlement row, so summing a
+// driver's settlements still gives their real earnings. Whatever the driver's
+// wallet no longer holds becomes driver debt. The parts of a split payment are
+// refunded one by one while the driver was settled on the split payment, so
+// their refunds are taken pro rata of the split payment's amount.
+func (uc *RefundUseCase) reverseDriverShare(ctx context.Context, tx *sqlx.Tx, paymentTx *entity.PaymentTransaction, refund *entity.PaymentRefund, orderID string) (money.Amount, error) {
+	settled := paymentTx
+	if paymentTx.ParentPaymentID != nil {
+		parent, err := uc.PaymentRepository.FindByIDForUpdate(ctx, tx, *paymentTx.ParentPaymentID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get split parent payment: %v", err)
+		}
+		if parent == nil {
+			return 0, fmt.Errorf("split parent payment %d not found", *paymentTx.ParentPaymentID)
+		}
+		settled = parent
+	}
+
+	settlement, err := uc.PaymentRepository.FindSettlementByPaymentIDTx(ctx, tx, settled.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get payment settlement: %v", err)
+	}
+	if settlement == nil || settlement.Status != "PAID" || settlement.SettlementAmount <= 0 || settled.Amount <= 0 {
+		return 0, nil
+	}
+
+	driverShare := settlement.SettlementAmount.Prorate(refund.Amount, settled.Amount, money.RoundHalfUp)
+	platformFee := settlement.PlatformFee.Prorate(refund.Amount, settled.Amount, money.RoundHalfUp)
+	taxAmount := settlement.TaxAmount.Prorate(refund.Amount, settled.Amount, money.RoundHalfUp)
+	// the reversal takes back each tax line, so a month's withholding nets out
+	taxes, err := parseTaxBreakdown(settlement)
+	if err != nil {
+		return 0, err
+	}
+	var reversedTaxes taxBreakdown
+	if taxes != nil {
+		reversedTaxes = taxes.prorate(refund.Amount, settled.Amount, true)
+		taxAmount = -reversedTaxes.Total()
+	}
+
+	driverWallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, settlement.DriverID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get driver wallet: %v", err)
+	}
+	if driverWallet == nil {
+		return 0, fmt.Errorf("driver wallet not found")
+	}
+	walletDebit, err := walletShare(ctx, tx, uc.PaymentRepository, settled.ID, entity.FxPurposeSettlement, driverWallet, money.New(driverShare, settled.Currency))
+	if err != nil {
+		return 0, err
+	}
+
+	description := fmt.Sprintf("Refund reversal for order %s", orderID)
+	fromWallet, err := newDriverDebtLedger(uc.Config, uc.DebtRepository, uc.DriverRepository).charge(ctx, tx, driverWallet, walletDebit, &settled.ID, entity.DriverDebtSourceRefundReversal, description)
+	if err != nil {
+		return 0, err
+	}
+	if fromWallet > 0 {
+		if err := uc.WalletRepository.UpdateWalletBalance(ctx, tx.Tx, driverWallet.ID, driverWallet.Balance-fromWallet); err != nil {
+			return 0, fmt.Errorf("failed to update driver wallet balance: %v", err)
+		}
+
+		trx := &entity.WalletTransaction{
+			WalletID:      driverWallet.ID,
+			TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+			Amount:        fromWallet,
+			Type:          "debit",
+			Description:   description,
+			Timestamp:     time.Now(),
+		}
+		if err := uc.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, trx); err != nil {
+			return 0, fmt.Errorf("failed to insert reversal transaction: %v", err)
+		}
+	}
+
+	now := time.Now()
+	reversal := &entity.PaymentSettlement{
+		PaymentTransactionID: settled.ID,
+		DriverID:             settlement.DriverID,
+		SettlementAmount:     -driverShare,
+		PlatformFee:          -platformFee,
+		TaxAmount:            -taxAmount,
+		Status:               "REVERSED",
+		SettlementMethod:     "REFUND_REVERSAL",
+		ProviderReferenceID:  &refund.RefundID,
+		SettledAt:            &now,
+		CreatedAt:            now,
+		// the reversal carries the rule the original fee was taken by
+		CommissionRuleID:      settlement.CommissionRuleID,
+		CommissionRuleCode:    settlement.CommissionRuleCode,
+		CommissionRuleVersion: settlement.CommissionRuleVersion,
+		CommissionRate:        settlement.CommissionRate,
+	}
+	if reversedTaxes != nil {
+		if err := reversedTaxes.apply(reversal); err != nil {
+			return 0, err
+		}
+	}
+	if err := uc.PaymentRepository.InsertPaymentSettlementTx(ctx, tx, reversal); err != nil {
+		return 0, fmt.Errorf("failed to insert settlement reversal: %v", err)
+	}
+
+	return driverShare, nil
+}
@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"payment-service/src/internal/entity"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/money"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookProviderRefunds(t *testing.T) {
+	refundColumns := []string{"id", "refund_id", "payment_transaction_id", "amount", "currency", "status"}
+
+	tests := []struct {
+		name       string
+		status     string
+		amount     money.Amount
+		refund     paymentGateway.ProviderRefund
+		expect     func(mock sqlmock.Sqlmock)
+		wantBooked int
+		wantStatus string
+	}{
+		{
+			// the refund notification moved the payment to REFUNDED before
+			// the refund we started was completed
+			name:   "pending refund on a payment the webhook refunded",
+			status: entity.PaymentStatusRefunded,
+			amount: 50000,
+			refund: paymentGateway.ProviderRefund{Key: "NBJ_RFD_1", Amount: "50000.00"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM payment_refunds\s+WHERE refund_id = \?`).WithArgs("NBJ_RFD_1").
+					WillReturnRows(sqlmock.NewRows(refundColumns).AddRow(7, "NBJ_RFD_1", 1, 50000, "IDR", "PENDING"))
+				mock.ExpectQuery(`FROM payment_settlements`).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectExec(`UPDATE payment_refunds`).WithArgs("SUCCESS", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\)`).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(50000))
+				mock.ExpectExec(`INSERT INTO payment_event_logs`).WithArgs(1, "REFUND", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantBooked: 1,
+			wantStatus: entity.PaymentStatusRefunded,
+		},
+		{
+			name:   "pending refund of part of a payment",
+			status: entity.PaymentStatusSuccess,
+			amount: 100000,
+			refund: paymentGateway.ProviderRefund{Key: "NBJ_RFD_1", Amount: "40000.00"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM payment_refunds\s+WHERE refund_id = \?`).WithArgs("NBJ_RFD_1").
+					WillReturnRows(sqlmock.NewRows(refundColumns).AddRow(7, "NBJ_RFD_1", 1, 40000, "IDR", "PENDING"))
+				mock.ExpectQuery(`FROM payment_settlements`).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectExec(`UPDATE payment_refunds`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\)`).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(40000))
+				mock.ExpectExec(`UPDATE payment_transactions`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO payment_event_logs`).WithArgs(1, "REFUND", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantBooked: 1,
+			wantStatus: entity.PaymentStatusPartiallyRefunded,
+		},
+		{
+			name:   "refund made in the provider dashboard",
+			status: entity.PaymentStatusSuccess,
+			amount: 100000,
+			refund: paymentGateway.ProviderRefund{Key: "midtrans-refund-9", Amount: "100000.00", Reason: "customer request"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM payment_refunds\s+WHERE refund_id = \?`).WithArgs("midtrans-refund-9").
+					WillReturnRows(sqlmock.NewRows(refundColumns))
+				mock.ExpectQuery(`FROM payment_settlements`).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectExec(`INSERT INTO payment_refunds`).
+					WithArgs("midtrans-refund-9", 1, 100000, "IDR", entity.RefundReasonOther, sqlmock.AnyArg(), "PROVIDER", "SUCCESS", 0, nil, "midtrans", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(9, 1))
+				mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\)`).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(100000))
+				mock.ExpectExec(`UPDATE payment_transactions`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`FROM payment_promo_redemptions`).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectExec(`INSERT INTO payment_event_logs`).WithArgs(1, "REFUND", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantBooked: 1,
+			wantStatus: entity.PaymentStatusRefunded,
+		},
+		{
+			name:   "refund already completed",
+			status: entity.PaymentStatusRefunded,
+			amount: 50000,
+			refund: paymentGateway.ProviderRefund{Key: "NBJ_RFD_1", Amount: "50000.00"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM payment_refunds\s+WHERE refund_id = \?`).WithArgs("NBJ_RFD_1").
+					WillReturnRows(sqlmock.NewRows(refundColumns).AddRow(7, "NBJ_RFD_1", 1, 50000, "IDR", "SUCCESS"))
+			},
+			wantBooked: 0,
+			wantStatus: entity.PaymentStatusRefunded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, _, mock := newMockTx(t)
+			tt.expect(mock)
+
+			uc := &RefundUseCase{
+				Log:               log.Log{LogLevel: 3},
+				PaymentRepository: &repository.PaymentRepository{},
+				RefundRepository:  &repository.RefundRepository{},
+			}
+			paymentTx := &entity.PaymentTransaction{
+				ID:            1,
+				Amount:        tt.amount,
+				Currency:      "IDR",
+				PaymentStatus: tt.status,
+			}
+
+			booked, err := uc.BookProviderRefunds(context.Background(), tx, paymentTx, "midtrans",
+				[]paymentGateway.ProviderRefund{tt.refund}, `{"transaction_status":"refund"}`, "ORD-1")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBooked, booked)
+			assert.Equal(t, tt.wantStatus, paymentTx.PaymentStatus)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package usecase
+
+import (
+	"payment-service/src/pkg/money"
+
+	"github.com/spf13/viper"
+)
+
+// roundingMode reads the rounding mode configured under key. An unknown value
+// falls back to HALF_UP, the same as an unset one.
+func roundingMode(config *viper.Viper, key string) money.RoundingMode {
+	mode, err := money.ParseRoundingMode(config.GetString(key))
+	if err != nil {
+		return money.RoundHalfUp
+	}
+	return mode
+}
+
+// orderAmount converts a fare from the order service, which keeps major units
+// as DOUBLE, to minor units of the payment currency.
+func orderAmount(config *viper.Viper, value float64) money.Amount {
+	return money.FromMajor(value, paymentCurrency(config)).Amount
+}
@@ -0,0 +1,428 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/gateway/fx"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/money"
+	"payment-service/src/pkg/utils"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PaymentMethodSplit marks the order-level payment of a split tender. It never
+// moves money itself; its parts are child payments pointing at it through
+// parent_payment_id.
+const PaymentMethodSplit = "SPLIT"
+
+// errSplitOrderNotPaid is returned when every part settled but the order could
+// not be marked PAID.
+var errSplitOrderNotPaid = errors.New("order payment status not updated (maybe already paid/invalid state)")
+
+// splitReconciler settles the parent of a split payment from the state of its
+// parts. It runs in the transaction that changed a part.
+type splitReconciler struct {
+	PaymentRepository *repository.PaymentRepository
+	WalletRepository  *repository.WalletRepository
+	OrderRepository   *repository.OrderRepository
+	// the driver is paid from these once every part has paid
+	DebtLedger driverDebtLedger
+	Rates      fx.RateSource
+}
+
+// reconcile looks at every part of the split payment child belongs to. A part
+// that has FAILED or EXPIRED releases the wallet part still on hold and fails
+// the parent. Once the trip has completed, which leaves a PENDING driver
+// settlement on the parent, the QRIS part paying completes the split: the
+// wallet part is captured, the driver paid and the order marked PAID.
+func (s splitReconciler) reconcile(ctx context.Context, tx *sqlx.Tx, child *entity.PaymentTransaction) error {
+	if child.ParentPaymentID == nil {
+		return nil
+	}
+
+	parent, err := s.PaymentRepository.FindByIDForUpdate(ctx, tx, *child.ParentPaymentID)
+	if err != nil {
+		return fmt.Errorf("failed to get split parent payment: %v", err)
+	}
+	if parent == nil {
+		return fmt.Errorf("split parent payment %d not found", *child.ParentPaymentID)
+	}
+	if parent.PaymentStatus != entity.PaymentStatusPending {
+		if child.PaymentStatus == entity.PaymentStatusSuccess {
+			// paid after the split already failed; leave it to an operator
+			late := &entity.PaymentEventLog{
+				PaymentTransactionID: parent.ID,
+				EventType:            "LATE_PART",
+				EventDescription:     fmt.Sprintf("Part %d settled after split payment became %s", child.ID, parent.PaymentStatus),
+			}
+			if err := s.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, late); err != nil {
+				return fmt.Errorf("failed to insert late part event log: %v", err)
+			}
+		}
+		return nil
+	}
+
+	parts, err := s.PaymentRepository.FindChildPaymentsForUpdate(ctx, tx, parent.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get split payment parts: %v", err)
+	}
+
+	order, err := s.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{ID: &parent.RideOrderID})
+	if err != nil || order == nil {
+		return fmt.Errorf("order not found for split payment %d", parent.ID)
+	}
+
+	for i := range parts {
+		part := &parts[i]
+		if part.PaymentStatus == entity.PaymentStatusFailed || part.PaymentStatus == entity.PaymentStatusExpired {
+			return s.failSplit(ctx, tx, parent, parts, part, order.OrderID)
+		}
+	}
+
+	settlement, err := s.PaymentRepository.FindSettlementByPaymentIDTx(ctx, tx, parent.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get split settlement: %v", err)
+	}
+	if settlement == nil || settlement.Status != "PENDING" || !splitProviderPartsPaid(parts) {
+		// the trip's completion or the QRIS part settles the split
+		return nil
+	}
+	return s.completeSplit(ctx, tx, parent, parts, settlement, order)
+}
+
+// failSplit releases the wallet part and fails the parent. The wallet part is
+// only captured by completeSplit, once every other part has paid, so a part
+// failing always finds it still on hold. A driver settlement left PENDING by
+// the completed trip is cancelled; nothing was credited on it.
+func (s splitReconciler) failSplit(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	parent *entity.PaymentTransaction,
+	parts []entity.PaymentTransaction,
+	failedPart *entity.PaymentTransaction,
+	orderID string,
+) error {
+	source := fmt.Sprintf("split part %d %s", failedPart.ID, failedPart.PaymentStatus)
+
+	for i := range parts {
+		part := &parts[i]
+		if !isWalletMethod(part.PaymentMethod) || part.PaymentStatus != entity.PaymentStatusPending {
+			continue
+		}
+		if err := s.releaseWalletPart(ctx, tx, part); err != nil {
+			return err
+		}
+		if err := transitionPayment(ctx, s.PaymentRepository, tx, part, entity.PaymentStatusFailed, source, nil); err != nil {
+			return err
+		}
+		event := &entity.PaymentEventLog{
+			PaymentTransactionID: part.ID,
+			EventType:            "RELEASE",
+			EventDescription:     fmt.Sprintf("Split payment failed, released wallet part %d", part.Amount),
+		}
+		if err := s.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+			return fmt.Errorf("failed to insert refund event log: %v", err)
+		}
+	}
+
+	settlement, err := s.PaymentRepository.FindSettlementByPaymentIDTx(ctx, tx, parent.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get split settlement: %v", err)
+	}
+	if settlement != nil && settlement.Status == "PENDING" {
+		if err := s.PaymentRepository.UpdateSettlementStatusTx(ctx, tx, settlement.ID, "CANCELLED", nil); err != nil {
+			return fmt.Errorf("failed to cancel split settlement: %v", err)
+		}
+	}
+
+	if err := transitionPayment(ctx, s.PaymentRepository, tx, parent, entity.PaymentStatusFailed, source, nil); err != nil {
+		return err
+	}
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: parent.ID,
+		EventType:            "FAILED",
+		EventDescription:     fmt.Sprintf("Split payment for order %s failed: part %d is %s", orderID, failedPart.ID, failedPart.PaymentStatus),
+	}
+	if err := s.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return fmt.Errorf("failed to insert split failed event log: %v", err)
+	}
+	return nil
+}
+
+// completeSplit settles a split payment whose trip has completed and whose
+// provider parts have all paid. parent.Amount is the final fare by now: the
+// wallet part is captured for what the provider parts left of it and the rest
+// of its hold released. Should the fare have come in below what was already
+// paid by QRIS, the difference goes back to the passenger's wallet. The driver
+// is then paid on the settlement and the order marked PAID.
+func (s splitReconciler) completeSplit(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	parent *entity.PaymentTransaction,
+	parts []entity.PaymentTransaction,
+	settlement *entity.PaymentSettlement,
+	order *entity.Order,
+) error {
+	var providerPaid money.Amount
+	for _, part := range parts {
+		if !isWalletMethod(part.PaymentMethod) && part.PaymentStatus == entity.PaymentStatusSuccess {
+			providerPaid += part.Amount
+		}
+	}
+
+	due := max(parent.Amount-providerPaid, 0)
+	for i := range parts {
+		part := &parts[i]
+		if !isWalletMethod(part.PaymentMethod) || part.PaymentStatus != entity.PaymentStatusPending {
+			continue
+		}
+		capture := min(part.Amount, due)
+		if err := s.captureWalletPart(ctx, tx, part, capture, order.OrderID); err != nil {
+			return err
+		}
+		due -= capture
+	}
+	if due > 0 {
+		return fmt.Errorf("split payment %d is %d short of its fare", parent.ID, due)
+	}
+	if overpaid := providerPaid - parent.Amount; overpaid > 0 {
+		if err := s.returnOverpayment(ctx, tx, parent, overpaid, order.OrderID); err != nil {
+			return err
+		}
+	}
+
+	if err := payDriverSettlement(ctx, tx, s.PaymentRepository, s.WalletRepository, s.DebtLedger, s.Rates, settlement, parent.Currency, order.OrderID); err != nil {
+		return err
+	}
+	return s.settleSplit(ctx, tx, parent, order)
+}
+
+// returnOverpayment credits the passenger's wallet with what the provider
+// parts paid above the final fare.
+func (s splitReconciler) returnOverpayment(ctx context.Context, tx *sqlx.Tx, parent *entity.PaymentTransaction, overpaid money.Amount, orderID string) error {
+	wallet, err := s.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, parent.PassengerID)
+	if err != nil {
+		return fmt.Errorf("failed to get passenger wallet: %v", err)
+	}
+	if wallet == nil {
+		return fmt.Errorf("passenger wallet not found")
+	}
+	credit, _, err := quoteWalletCredit(ctx, s.Rates, wallet, money.New(overpaid, parent.Currency))
+	if err != nil {
+		return err
+	}
+	if err := s.WalletRepository.UpdateWalletBalance(ctx, tx.Tx, wallet.ID, wallet.Balance+credit); err != nil {
+		return fmt.Errorf("failed to update passenger wallet balance: %v", err)
+	}
+	wallet.Balance += credit
+
+	trx := &entity.WalletTransaction{
+		WalletID:      wallet.ID,
+		TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+		Amount:        credit,
+		Type:          "credit",
+		Description:   fmt.Sprintf("Split payment overpaid for order %s", orderID),
+		Timestamp:     time.Now(),
+	}
+	if err := s.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, trx); err != nil {
+		return fmt.Errorf("failed to insert overpayment transaction: %v", err)
+	}
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: parent.ID,
+		EventType:            "OVERPAID",
+		EventDescription:     fmt.Sprintf("Returned %d paid above the fare of %d to the passenger wallet", overpaid, parent.Amount),
+	}
+	if err := s.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return fmt.Errorf("failed to insert overpayment event log: %v", err)
+	}
+	return nil
+}
+
+// settleSplit closes a split payment whose parts have paid for the trip and
+// marks the order PAID.
+func (s splitReconciler) settleSplit(ctx context.Context, tx *sqlx.Tx, parent *entity.PaymentTransaction, order *entity.Order) error {
+	if err := transitionPayment(ctx, s.PaymentRepository, tx, parent, entity.PaymentStatusSuccess, "split settled", nil); err != nil {
+		return err
+	}
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: parent.ID,
+		EventType:            "SUCCESS",
+		EventDescription:     fmt.Sprintf("Split payment of %d paid for order %s", parent.Amount, order.OrderID),
+	}
+	if err := s.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return fmt.Errorf("failed to insert split success event log: %v", err)
+	}
+
+	driverID := ""
+	if order.DriverID != nil {
+		driverID = *order.DriverID
+	}
+	ok, err := s.OrderRepository.MarkOrderPaidTx(ctx, tx.Tx, order.OrderID, order.PassengerID, driverID)
+	if err != nil {
+		return fmt.Errorf("failed to update order payment status: %v", err)
+	}
+	if !ok {
+		return errSplitOrderNotPaid
+	}
+	return nil
+}
+
+func (s splitReconciler) releaseWalletPart(ctx context.Context, tx *sqlx.Tx, part *entity.PaymentTransaction) error {
+	wallet, err := s.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, part.PassengerID)
+	if err != nil {
+		return fmt.Errorf("failed to get passenger wallet: %v", err)
+	}
+	if wallet == nil {
+		return fmt.Errorf("passenger wallet not found")
+	}
+	if _, err := releaseHold(ctx, tx, s.WalletRepository, wallet, part.ID, entity.WalletHoldReleased); err != nil {
+		return err
+	}
+	return nil
+}
+
+// captureWalletPart charges amount, in the payment currency, of a split's
+// wallet part and gives the rest of its hold back. A part the fare does not
+// need at all is released and cancelled.
+func (s splitReconciler) captureWalletPart(ctx context.Context, tx *sqlx.Tx, part *entity.PaymentTransaction, amount money.Amount, orderID string) error {
+	wallet, err := s.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, part.PassengerID)
+	if err != nil {
+		return fmt.Errorf("failed to get passenger wallet: %v", err)
+	}
+	if wallet == nil {
+		return fmt.Errorf("passenger wallet not found")
+	}
+	if amount <= 0 {
+		if _, err := releaseHold(ctx, tx, s.WalletRepository, wallet, part.ID, entity.WalletHoldReleased); err != nil {
+			return err
+		}
+		return transitionPayment(ctx, s.PaymentRepository, tx, part, entity.PaymentStatusCancelled, "split settled, wallet part not needed", nil)
+	}
+
+	debit, err := walletShare(ctx, tx, s.PaymentRepository, part.ID, entity.FxPurposeHold, wallet, money.New(amount, part.Currency))
+	if err != nil {
+		return err
+	}
+	if _, err := captureHold(ctx, tx, s.WalletRepository, wallet, part.ID, debit, fmt.Sprintf("Split wallet payment for order %s", orderID)); err != nil {
+		return err
+	}
+	part.Amount = amount
+	if err := transitionPayment(ctx, s.PaymentRepository, tx, part, entity.PaymentStatusSuccess, "split settled", nil); err != nil {
+		return err
+	}
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: part.ID,
+		EventType:            "SUCCESS",
+		EventDescription:     fmt.Sprintf("Split wallet part captured %d for order %s", amount, orderID),
+	}
+	if err := s.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return fmt.Errorf("failed to insert capture event log: %v", err)
+	}
+	return nil
+}
+
+// settleSplitTrip settles a split payment when its trip completes, as
+// DebetWallet does a wallet payment. The promo is priced again on the final
+// fare, which becomes the parent amount, and the driver's share is recorded as
+// a PENDING settlement. Nothing is captured or credited until the QRIS part has
+// paid: if it already has, the split completes now, otherwise the webhook that
+// reports it paid completes it.
+func (uc *WalletUseCase) settleSplitTrip(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	req *model.NotificationUser,
+	split *entity.PaymentTransaction,
+	actualPaid money.Amount,
+	commission *entity.CommissionRule,
+) error {
+	settlement, err := uc.PaymentRepository.FindSettlementByPaymentIDTx(ctx, tx, split.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get split settlement: %v", err)
+	}
+	if settlement != nil {
+		uc.Log.Info("wallet-usecase", "Split payment already settled, skip debit", "DebetWallet", req.OrderID)
+		return nil
+	}
+	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{ID: &split.RideOrderID})
+	if err != nil || order == nil {
+		return fmt.Errorf("order not found for split payment %d", split.ID)
+	}
+	parts, err := uc.PaymentRepository.FindChildPaymentsForUpdate(ctx, tx, split.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get split payment parts: %v", err)
+	}
+
+	discount, err := settlePromo(ctx, tx, uc.PaymentRepository, split.ID, actualPaid, split.Currency)
+	if err != nil {
+		return err
+	}
+	payable := actualPaid - discount
+	split.Amount = payable
+	split.DiscountAmount = discount
+	if err := uc.PaymentRepository.UpdatePaymentTransactionTx(ctx, tx, split); err != nil {
+		return fmt.Errorf("failed to update split payment: %v", err)
+	}
+
+	settlement, err = uc.tripSettlement(ctx, split, req.DriverID, actualPaid, commission, "DebetWallet")
+	if err != nil {
+		return err
+	}
+	if err := uc.PaymentRepository.InsertPaymentSettlementTx(ctx, tx, settlement); err != nil {
+		return fmt.Errorf("failed to insert payment settlement: %v", err)
+	}
+
+	if splitProviderPartsPaid(parts) {
+		uc.Log.Info("wallet-usecase",
+			fmt.Sprintf("Split payment settled for order %s: fare=%d discount=%d", req.OrderID, actualPaid, discount),
+			"DebetWallet", "")
+		return uc.splitReconciler().completeSplit(ctx, tx, split, parts, settlement, order)
+	}
+
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: split.ID,
+		EventType:            "DUE",
+		EventDescription:     fmt.Sprintf("Trip completed at %d, waiting for the QRIS part to be paid", payable),
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		return fmt.Errorf("failed to insert split due event log: %v", err)
+	}
+	uc.Log.Info("wallet-usecase",
+		fmt.Sprintf("Split payment for order %s waits for its QRIS part: fare=%d discount=%d", req.OrderID, actualPaid, discount),
+		"DebetWallet", "")
+	return nil
+}
+
+// splitReconciler is the reconciler a split settled from the wallet side
+// completes with.
+func (uc *WalletUseCase) splitReconciler() splitReconciler {
+	return splitReconciler{
+		PaymentRepository: uc.PaymentRepository,
+		WalletRepository:  uc.WalletRepository,
+		OrderRepository:   uc.OrderRepository,
+		DebtLedger:        uc.debtLedger(),
+		Rates:             uc.Rates,
+	}
+}
+
+// splitProviderPartsPaid reports whether every part of a split payment other
+// than its wallet part has paid.
+func splitProviderPartsPaid(parts []entity.PaymentTransaction) bool {
+	for _, part := range parts {
+		if !isWalletMethod(part.PaymentMethod) && part.PaymentStatus != entity.PaymentStatusSuccess {
+			return false
+		}
+	}
+	return true
+}
+
+// isWalletMethod matches both codes the wallet goes by: orders default to
+// WALLET, payment rows are written as EWALLET.
+func isWalletMethod(method string) bool {
+	return method == "WALLET" || method == paymentGateway.MethodEwallet
+}
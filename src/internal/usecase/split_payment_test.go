@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"context"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitReconcile(t *testing.T) {
+	paymentColumns := []string{"id", "parent_payment_id", "ride_order_id", "passenger_id", "amount", "currency", "payment_method", "payment_status"}
+	settlementColumns := []string{"id", "payment_transaction_id", "driver_id", "settlement_amount", "status"}
+	walletColumns := []string{"id", "user_id", "balance", "held_balance", "currency", "last_updated", "created_at", "updated_at"}
+	holdColumns := []string{"id", "hold_id", "wallet_id", "payment_transaction_id", "amount", "captured_amount", "status",
+		"expires_at", "captured_at", "released_at", "created_at", "updated_at"}
+	now := time.Now()
+
+	expectParent := func(mock sqlmock.Sqlmock, amount int, status string) {
+		mock.ExpectQuery(`FROM payment_transactions\s+WHERE id = \?`).WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(paymentColumns).AddRow(1, nil, 100, "psg-1", amount, "IDR", PaymentMethodSplit, status))
+	}
+	expectParts := func(mock sqlmock.Sqlmock, qrisStatus string) {
+		mock.ExpectQuery(`WHERE parent_payment_id = \?`).WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(paymentColumns).
+				AddRow(2, 1, 100, "psg-1", 20000, "IDR", "EWALLET", entity.PaymentStatusPending).
+				AddRow(3, 1, 100, "psg-1", 30000, "IDR", "QRIS", qrisStatus))
+		mock.ExpectQuery(`FROM orders o`).WithArgs(100).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "passenger_id", "driver_id"}).AddRow(100, "ORD-1", "psg-1", "drv-1"))
+	}
+	expectWallet := func(mock sqlmock.Sqlmock, id, userID string, balance, held int) {
+		mock.ExpectQuery(`FROM wallets\s+WHERE user_id = \?`).WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows(walletColumns).AddRow(id, userID, balance, held, "IDR", now, now, now))
+	}
+	expectHold := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(`FROM wallet_holds`).WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(holdColumns).AddRow(21, "hold-1", "wlt-psg", 2, 20000, 0, entity.WalletHoldHeld, nil, nil, nil, now, now))
+	}
+	expectEvent := func(mock sqlmock.Sqlmock, paymentID int, eventType string) {
+		mock.ExpectExec(`INSERT INTO payment_event_logs`).WithArgs(paymentID, eventType, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	expectStatus := func(mock sqlmock.Sqlmock, paymentID, amount int, status string) {
+		anyArg := sqlmock.AnyArg()
+		mock.ExpectExec(`UPDATE payment_transactions`).
+			WithArgs(amount, 0, "IDR", anyArg, status, anyArg, anyArg, anyArg, anyArg, anyArg, anyArg, anyArg, paymentID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	expectNoPromo := func(mock sqlmock.Sqlmock, paymentID int) {
+		mock.ExpectQuery(`FROM payment_promo_redemptions`).WithArgs(paymentID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	tests := []struct {
+		name   string
+		child  string
+		expect func(mock sqlmock.Sqlmock)
+	}{
+		{
+			// the QRIS part opened with the hold pays before the trip has
+			// completed; the trip's completion settles the split
+			name:  "QRIS part paid before the trip completed",
+			child: entity.PaymentStatusSuccess,
+			expect: func(mock sqlmock.Sqlmock) {
+				expectParent(mock, 50000, entity.PaymentStatusPending)
+				expectParts(mock, entity.PaymentStatusSuccess)
+				mock.ExpectQuery(`FROM payment_settlements`).WithArgs(1).WillReturnRows(sqlmock.NewRows(settlementColumns))
+			},
+		},
+		{
+			// the fare came in at 45000: the wallet part is captured for the
+			// 15000 QRIS left and the driver paid on the PENDING settlement
+			name:  "QRIS part paid after the trip completed",
+			child: entity.PaymentStatusSuccess,
+			expect: func(mock sqlmock.Sqlmock) {
+				expectParent(mock, 45000, entity.PaymentStatusPending)
+				expectParts(mock, entity.PaymentStatusSuccess)
+				mock.ExpectQuery(`FROM payment_settlements`).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(settlementColumns).AddRow(11, 1, "drv-1", 36000, "PENDING"))
+
+				expectWallet(mock, "wlt-psg", "psg-1", 5000, 20000)
+				expectHold(mock)
+				mock.ExpectExec(`UPDATE wallet_holds`).WithArgs(entity.WalletHoldCaptured, 15000, sqlmock.AnyArg(), nil, 21).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`SET balance = \?, held_balance = \?`).WithArgs(10000, 0, "wlt-psg").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO wallet_transactions`).
+					WithArgs("wlt-psg", sqlmock.AnyArg(), 15000, "debit", "Split wallet payment for order ORD-1").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				expectStatus(mock, 2, 15000, entity.PaymentStatusSuccess)
+				expectEvent(mock, 2, "SUCCESS")
+
+				expectWallet(mock, "wlt-drv", "drv-1", 1000, 0)
+				mock.ExpectQuery(`FROM driver_debts`).WithArgs("drv-1").WillReturnRows(sqlmock.NewRows([]string{"driver_id"}))
+				mock.ExpectExec(`SET balance = \?, last_updated`).WithArgs(37000, "wlt-drv").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO wallet_transactions`).
+					WithArgs("wlt-drv", sqlmock.AnyArg(), 36000, "credit", "Trip earning for order ORD-1").
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectExec(`UPDATE payment_settlements`).WithArgs("PAID", sqlmock.AnyArg(), 11).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				expectStatus(mock, 1, 45000, entity.PaymentStatusSuccess)
+				expectEvent(mock, 1, "SUCCESS")
+				mock.ExpectExec(`UPDATE orders`).WithArgs("ORD-1", "psg-1", "drv-1").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			// the wallet part is released and the settlement the completed
+			// trip left is cancelled; the driver was never credited
+			name:  "QRIS part expired",
+			child: entity.PaymentStatusExpired,
+			expect: func(mock sqlmock.Sqlmock) {
+				expectParent(mock, 50000, entity.PaymentStatusPending)
+				expectParts(mock, entity.PaymentStatusExpired)
+
+				expectWallet(mock, "wlt-psg", "psg-1", 5000, 20000)
+				expectHold(mock)
+				mock.ExpectExec(`UPDATE wallet_holds`).WithArgs(entity.WalletHoldReleased, 0, nil, sqlmock.AnyArg(), 21).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`SET balance = \?, held_balance = \?`).WithArgs(25000, 0, "wlt-psg").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectStatus(mock, 2, 20000, entity.PaymentStatusFailed)
+				expectNoPromo(mock, 2)
+				expectEvent(mock, 2, "RELEASE")
+
+				mock.ExpectQuery(`FROM payment_settlements`).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(settlementColumns).AddRow(11, 1, "drv-1", 36000, "PENDING"))
+				mock.ExpectExec(`UPDATE payment_settlements`).WithArgs("CANCELLED", nil, 11).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				expectStatus(mock, 1, 50000, entity.PaymentStatusFailed)
+				expectNoPromo(mock, 1)
+				expectEvent(mock, 1, "FAILED")
+			},
+		},
+		{
+			name:  "QRIS part paid after the split failed",
+			child: entity.PaymentStatusSuccess,
+			expect: func(mock sqlmock.Sqlmock) {
+				expectParent(mock, 50000, entity.PaymentStatusFailed)
+				expectEvent(mock, 1, "LATE_PART")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, db, mock := newMockTx(t)
+			tt.expect(mock)
+
+			reconciler := splitReconciler{
+				PaymentRepository: &repository.PaymentRepository{},
+				WalletRepository:  &repository.WalletRepository{},
+				OrderRepository:   &repository.OrderRepository{DB: db},
+				DebtLedger: driverDebtLedger{
+					DebtRepository:   &repository.DriverDebtRepository{},
+					DriverRepository: &repository.DriverRepository{},
+				},
+			}
+			parentID := uint64(1)
+			child := &entity.PaymentTransaction{
+				ID:              3,
+				ParentPaymentID: &parentID,
+				RideOrderID:     100,
+				PassengerID:     "psg-1",
+				Amount:          money.Amount(30000),
+				Currency:        "IDR",
+				PaymentMethod:   "QRIS",
+				PaymentStatus:   tt.child,
+			}
+
+			require.NoError(t, reconciler.reconcile(context.Background(), tx, child))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"payment-service/src/pkg/databases/mysql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// mockDB hands the repositories that read outside a transaction the same
+// sqlmock database the transaction runs on.
+type mockDB struct {
+	db *sqlx.DB
+}
+
+func (m mockDB) Connect(string) *mysql.DatabaseConnection {
+	return &mysql.DatabaseConnection{Connection: m.db}
+}
+
+func (m mockDB) GetDB() (*sqlx.DB, error) {
+	return m.db, nil
+}
+
+// newMockTx opens a transaction on a sqlmock database, the way the usecases
+// do before handing tx to their helpers. Expectations set on the returned
+// mock follow the BEGIN.
+func newMockTx(t *testing.T) (*sqlx.Tx, mockDB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	db := sqlx.NewDb(conn, "mysql")
+	mock.ExpectBegin()
+	tx, err := db.Beginx()
+	require.NoError(t, err)
+	return tx, mockDB{db: db}, mock
+}
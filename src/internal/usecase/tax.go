@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/money"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// taxBreakdown is the taxes taken on one settlement, one line per tax.
+type taxBreakdown []entity.TaxLine
+
+// tripTaxes computes the taxes on a trip settled at at: PPN on the platform
+// fee and PPh withheld from what the driver earned, the paid amount less the
+// platform fee. Each tax uses the rate in effect at that time; without a PPh
+// rate the configured platform.tax is withheld on the whole earning, as it
+// was before rates were kept.
+func tripTaxes(ctx context.Context, config *viper.Viper, taxes *repository.TaxRepository, paid, platformFee money.Amount, at time.Time) (taxBreakdown, error) {
+	rates, err := taxes.FindEffectiveRates(ctx, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tax rates: %v", err)
+	}
+	mode := roundingMode(config, "platform.tax_rounding")
+
+	var lines taxBreakdown
+	if rate := effectiveTaxRate(rates, entity.TaxPPN); rate != nil {
+		lines = append(lines, taxLine(rate, platformFee, mode))
+	}
+	earning := max(paid-platformFee, 0)
+	if rate := effectiveTaxRate(rates, entity.TaxPPh); rate != nil {
+		lines = append(lines, taxLine(rate, earning, mode))
+	} else if legacy := config.GetFloat64("platform.tax"); legacy > 0 {
+		lines = append(lines, taxLine(&entity.TaxRate{TaxType: entity.TaxPPh, Rate: legacy, BaseRatio: 1}, earning, mode))
+	}
+	return lines, nil
+}
+
+func effectiveTaxRate(rates []entity.TaxRate, taxType string) *entity.TaxRate {
+	for i := range rates {
+		if rates[i].TaxType == taxType {
+			return &rates[i]
+		}
+	}
+	return nil
+}
+
+func taxLine(rate *entity.TaxRate, gross money.Amount, mode money.RoundingMode) entity.TaxLine {
+	line := entity.TaxLine{
+		TaxType: rate.TaxType,
+		Rate:    rate.Rate,
+		Gross:   gross,
+		Base:    gross.MulRate(rate.BaseRatio, mode),
+	}
+	if rate.ID != 0 {
+		id := rate.ID
+		line.RateID = &id
+	}
+	line.Amount = line.Base.MulRate(rate.Rate, mode)
+	return line
+}
+
+// Total is the tax taken across all lines.
+func (b taxBreakdown) Total() money.Amount {
+	var total money.Amount
+	for _, line := range b {
+		total += line.Amount
+	}
+	return total
+}
+
+// Of sums the lines of one tax.
+func (b taxBreakdown) Of(taxType string) entity.TaxLine {
+	sum := entity.TaxLine{TaxType: taxType}
+	for _, line := range b {
+		if line.TaxType != taxType {
+			continue
+		}
+		sum.Gross += line.Gross
+		sum.Base += line.Base
+		sum.Amount += line.Amount
+	}
+	return sum
+}
+
+// prorate gives the lines' part of whole, e.g. the taxes on a partial
+// refund, negated when reverse is set.
+func (b taxBreakdown) prorate(part, whole money.Amount, reverse bool) taxBreakdown {
+	out := make(taxBreakdown, 0, len(b))
+	for _, line := range b {
+		line.Gross = line.Gross.Prorate(part, whole, money.RoundHalfUp)
+		line.Base = line.Base.Prorate(part, whole, money.RoundHalfUp)
+		line.Amount = line.Amount.Prorate(part, whole, money.RoundHalfUp)
+		if reverse {
+			line.Gross, line.Base, line.Amount = -line.Gross, -line.Base, -line.Amount
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// apply stores the lines on a settlement and sets its tax amount to their
+// total.
+func (b taxBreakdown) apply(settlement *entity.PaymentSettlement) error {
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to encode tax breakdown: %v", err)
+	}
+	breakdown := string(raw)
+	settlement.TaxAmount = b.Total()
+	settlement.TaxBreakdown = &breakdown
+	return nil
+}
+
+// parseTaxBreakdown reads a settlement's tax lines; settlements from before
+// the breakdown was kept have none.
+func parseTaxBreakdown(settlement *entity.PaymentSettlement) (taxBreakdown, error) {
+	if settlement.TaxBreakdown == nil || *settlement.TaxBreakdown == "" {
+		return nil, nil
+	}
+	var lines taxBreakdown
+	if err := json.Unmarshal([]byte(*settlement.TaxBreakdown), &lines); err != nil {
+		return nil, fmt.Errorf("failed to decode tax breakdown of settlement %d: %v", settlement.ID, err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/repository"
+	httpError "payment-service/src/pkg/http-error"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type TaxUseCase struct {
+	Log           log.Log
+	Config        *viper.Viper
+	TaxRepository *repository.TaxRepository
+}
+
+func NewTaxUseCase(log log.Log, config *viper.Viper, taxRepo *repository.TaxRepository) *TaxUseCase {
+	return &TaxUseCase{
+		Log:           log,
+		Config:        config,
+		TaxRepository: taxRepo,
+	}
+}
+
+// WithholdingSlips sums each driver's tax lines over a calendar month in
+// tax.timezone, one slip per driver, for issuing bukti potong.
+func (uc *TaxUseCase) WithholdingSlips(ctx context.Context, req *model.WithholdingExportRequest) utils.Result {
+	var result utils.Result
+
+	loc, err := time.LoadLocation(uc.Config.GetString("tax.timezone"))
+	if err != nil {
+		loc = time.UTC
+	}
+	from, err := time.ParseInLocation("2006-01", req.Period, loc)
+	if err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "period must be a month as YYYY-MM"
+		result.Error = errObj
+		uc.Log.Error("tax-usecase", errObj.Message, "WithholdingSlips", utils.ConvertString(req))
+		return result
+	}
+	to := from.AddDate(0, 1, 0)
+
+	settlements, err := uc.TaxRepository.FindWithholdingSettlements(ctx, from, to, req.DriverID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get settlements"
+		result.Error = errObj
+		uc.Log.Error("tax-usecase", errObj.Message, "WithholdingSlips", utils.ConvertString(err))
+		return result
+	}
+
+	slips := make([]*model.WithholdingSlipResponse, 0)
+	var slip *model.WithholdingSlipResponse
+	for i := range settlements {
+		s := &settlements[i]
+		taxes, err := parseTaxBreakdown(&s.PaymentSettlement)
+		if err != nil {
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to read settlement taxes"
+			result.Error = errObj
+			uc.Log.Error("tax-usecase", errObj.Message, "WithholdingSlips", utils.ConvertString(err))
+			return result
+		}
+		// settlements come ordered by driver
+		if slip == nil || slip.DriverID != s.DriverID {
+			slip = &model.WithholdingSlipResponse{
+				DriverID:   s.DriverID,
+				DriverName: s.DriverName,
+				Period:     req.Period,
+			}
+			slips = append(slips, slip)
+		}
+		if s.SettlementAmount > 0 {
+			slip.Trips++
+		}
+		pph := taxes.Of(entity.TaxPPh)
+		slip.GrossIncome += pph.Gross
+		slip.TaxBase += pph.Base
+		slip.PPhWithheld += pph.Amount
+		slip.PPNCharged += taxes.Of(entity.TaxPPN).Amount
+	}
+	result.Data = slips
+	return result
+}
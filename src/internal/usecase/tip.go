@@ -0,0 +1,408 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/gateway/fx"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/repository"
+	httpError "payment-service/src/pkg/http-error"
+	"payment-service/src/pkg/money"
+	"payment-service/src/pkg/utils"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const SettlementMethodTip = "TIP"
+
+// TipDriver tips the driver of a COMPLETED and PAID order, within
+// tip.window_minutes of the trip being paid. A wallet tip is settled at once;
+// a QRIS tip is charged like a trip and settled when the provider reports it
+// paid. Either way the driver gets the whole tip, without platform fee or
+// tax. An order takes one tip.
+func (uc *PaymentUseCase) TipDriver(ctx context.Context, req *model.TipRequest) utils.Result {
+	var result utils.Result
+
+	if req.OrderID == "" || req.UserID == "" || req.Method == "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "orderId, userId and method are required"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(req))
+		return result
+	}
+	minAmount := money.Amount(uc.Config.GetInt64("tip.min_amount"))
+	maxAmount := money.Amount(uc.Config.GetInt64("tip.max_amount"))
+	if req.Amount <= 0 || req.Amount < minAmount || (maxAmount > 0 && req.Amount > maxAmount) {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("tip amount must be at least %d", max(minAmount, 1))
+		if maxAmount > 0 {
+			errObj.Message = fmt.Sprintf("tip amount must be between %d and %d", max(minAmount, 1), maxAmount)
+		}
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(req))
+		return result
+	}
+	method, ok := uc.Methods.Get(req.Method)
+	if !ok || (method.Code != paymentGateway.MethodEwallet && method.Code != paymentGateway.MethodQris) {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "tips can be paid by wallet or QRIS"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(req))
+		return result
+	}
+
+	order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{
+		OrderID:     &req.OrderID,
+		PassengerID: &req.UserID,
+	})
+	if err != nil || order == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "order not found"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+	if order.Status != "COMPLETED" || order.PaymentStatus != "PAID" || order.DriverID == nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "only completed and paid orders can be tipped"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", order.OrderID)
+		return result
+	}
+	window := time.Duration(uc.Config.GetInt("tip.window_minutes")) * time.Minute
+	if time.Since(uc.tripPaidAt(ctx, order)) > window {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "the tip window for this order has closed"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", order.OrderID)
+		return result
+	}
+
+	if method.Code == paymentGateway.MethodEwallet {
+		return uc.tipFromWallet(ctx, order, req.Amount)
+	}
+
+	if req.Mode == "" {
+		req.Mode = ChargeModeSnap
+	}
+	if req.Mode != ChargeModeSnap && req.Mode != ChargeModeQris {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "mode must be snap or qris"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(req))
+		return result
+	}
+	charge := &providerCharge{
+		OrderID: req.OrderID,
+		UserID:  req.UserID,
+		Method:  paymentGateway.MethodQris,
+		Mode:    req.Mode,
+		Type:    entity.PaymentTypeTip,
+		Amount:  req.Amount,
+		scope:   "TipDriver",
+	}
+	return uc.idempotentCharge(ctx, req.IdempotencyKey, map[string]string{
+		"orderId": req.OrderID,
+		"userId":  req.UserID,
+		"mode":    req.Mode,
+		"type":    entity.PaymentTypeTip,
+		"amount":  fmt.Sprintf("%d", req.Amount),
+	}, charge)
+}
+
+// tripPaidAt is when the trip payment of order settled, or when the order
+// last changed if that is not recorded.
+func (uc *PaymentUseCase) tripPaidAt(ctx context.Context, order *entity.Order) time.Time {
+	payment, err := uc.PaymentRepository.FindLatestByRideOrderID(ctx, order.ID)
+	if err == nil && payment != nil && payment.PaidAt != nil {
+		return *payment.PaidAt
+	}
+	return order.UpdatedAt
+}
+
+// tipFromWallet moves the tip from the passenger's wallet to the driver's in
+// one transaction. The tip payment is born SUCCESS.
+func (uc *PaymentUseCase) tipFromWallet(ctx context.Context, order *entity.Order, amount money.Amount) utils.Result {
+	var result utils.Result
+
+	currency := paymentCurrency(uc.Config)
+	if _, err := uc.Methods.Check(paymentGateway.MethodEwallet, methodContext(ctx, uc.DriverRepository, *order.DriverID, amount)); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = err.Error()
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", order.OrderID)
+		return result
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := uc.OrderRepository.LockOrderTx(ctx, tx.Tx, order.ID); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to lock order"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+	if errObj := uc.checkTipConflict(ctx, tx, order, paymentGateway.MethodEwallet, amount, "TipDriver"); errObj != nil {
+		_ = tx.Rollback()
+		result.Error = errObj
+		return result
+	}
+
+	wallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, order.PassengerID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get wallet"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+	if wallet == nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "wallet not found"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", order.PassengerID)
+		return result
+	}
+	tip := money.New(amount, currency)
+	debit, rate, err := quoteWalletDebit(ctx, uc.Rates, wallet, tip)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = err.Error()
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", order.OrderID)
+		return result
+	}
+	if wallet.Balance < debit {
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "insufficient wallet balance"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", order.OrderID)
+		return result
+	}
+
+	now := time.Now()
+	payment := &entity.PaymentTransaction{
+		RideOrderID:   order.ID,
+		PassengerID:   order.PassengerID,
+		DriverID:      *order.DriverID,
+		Amount:        amount,
+		Currency:      currency,
+		PaymentMethod: paymentGateway.MethodEwallet,
+		PaymentStatus: entity.PaymentStatusSuccess,
+		PaymentType:   entity.PaymentTypeTip,
+		PaidAt:        &now,
+	}
+	paymentID, err := uc.PaymentRepository.InsertPaymentTransactionTx(ctx, tx, payment)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to save payment transaction"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+	payment.ID = paymentID
+	if err := recordFxConversion(ctx, tx, uc.PaymentRepository, paymentID, entity.FxPurposeHold, wallet, debit, tip, rate, uc.Rates); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to record fx conversion"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+
+	if err := uc.WalletRepository.UpdateWalletBalance(ctx, tx.Tx, wallet.ID, wallet.Balance-debit); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to update wallet balance"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+	trx := &entity.WalletTransaction{
+		WalletID:      wallet.ID,
+		TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+		Amount:        debit,
+		Type:          "debit",
+		Description:   fmt.Sprintf("Tip for order %s", order.OrderID),
+		Timestamp:     now,
+	}
+	if err := uc.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, trx); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to insert wallet transaction"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: paymentID,
+		EventType:            "SUCCESS",
+		EventDescription:     fmt.Sprintf("Wallet tip %d for order %s", amount, order.OrderID),
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to save payment event log"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+
+	if err := creditDriverTip(ctx, tx, uc.WalletRepository, uc.PaymentRepository, uc.Rates, payment, order.OrderID); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to credit driver tip"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("payment-usecase", errObj.Message, "TipDriver", utils.ConvertString(err))
+		return result
+	}
+
+	result.Data = model.TipResponse{
+		OrderID:       order.OrderID,
+		DriverID:      *order.DriverID,
+		Amount:        amount,
+		Currency:      currency,
+		PaymentMethod: paymentGateway.MethodEwallet,
+		Status:        entity.PaymentStatusSuccess,
+	}
+	return result
+}
+
+// checkTipConflict refuses a second tip on an order. A pending tip of the same
+// method and amount is let through so the charge can be handed back.
+func (uc *PaymentUseCase) checkTipConflict(ctx context.Context, tx *sqlx.Tx, order *entity.Order, method string, amount money.Amount, scope string) interface{} {
+	tips, err := uc.PaymentRepository.FindLiveTipsForUpdate(ctx, tx, order.ID)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get order tips"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return errObj
+	}
+	for _, tip := range tips {
+		if tip.PaymentStatus == entity.PaymentStatusPending && tip.PaymentMethod == method && tip.Amount == amount {
+			continue
+		}
+		errObj := httpError.NewConflict()
+		errObj.Message = "order was already tipped"
+		if tip.PaymentStatus == entity.PaymentStatusPending {
+			errObj.Message = fmt.Sprintf("order has a pending %s tip of %d", tip.PaymentMethod, tip.Amount)
+		}
+		uc.Log.Error("payment-usecase", errObj.Message, scope, order.OrderID)
+		return errObj
+	}
+	return nil
+}
+
+// settleTip pays out a provider tip once it has succeeded.
+func (uc *PaymentUseCase) settleTip(ctx context.Context, tx *sqlx.Tx, paymentTx *entity.PaymentTransaction, scope string) interface{} {
+	orderRef := fmt.Sprintf("%d", paymentTx.RideOrderID)
+	if order, err := uc.OrderRepository.FindOneOrder(ctx, entity.OrderFilter{ID: &paymentTx.RideOrderID}); err == nil && order != nil {
+		orderRef = order.OrderID
+	}
+	if err := creditDriverTip(ctx, tx, uc.WalletRepository, uc.PaymentRepository, uc.Rates, paymentTx, orderRef); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to credit driver tip"
+		uc.Log.Error("payment-usecase", errObj.Message, scope, utils.ConvertString(err))
+		return errObj
+	}
+	return nil
+}
+
+// creditDriverTip credits a settled tip to the driver's wallet in full and
+// records it as its own TIP settlement, so earnings reports can tell tips
+// from fares.
+func creditDriverTip(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	walletRepo *repository.WalletRepository,
+	paymentRepo *repository.PaymentRepository,
+	rates fx.RateSource,
+	paymentTx *entity.PaymentTransaction,
+	orderID string,
+) error {
+	wallet, err := lockDriverWallet(ctx, tx, walletRepo, paymentTx.DriverID, paymentTx.Currency)
+	if err != nil {
+		return err
+	}
+	tip := money.New(paymentTx.Amount, paymentTx.Currency)
+	credit, rate, err := quoteWalletCredit(ctx, rates, wallet, tip)
+	if err != nil {
+		return err
+	}
+	if err := recordFxConversion(ctx, tx, paymentRepo, paymentTx.ID, entity.FxPurposeSettlement, wallet, credit, tip, rate, rates); err != nil {
+		return err
+	}
+
+	if err := walletRepo.UpdateWalletBalance(ctx, tx.Tx, wallet.ID, wallet.Balance+credit); err != nil {
+		return fmt.Errorf("failed to update driver wallet balance: %v", err)
+	}
+	now := time.Now()
+	trx := &entity.WalletTransaction{
+		WalletID:      wallet.ID,
+		TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+		Amount:        credit,
+		Type:          "credit",
+		Description:   fmt.Sprintf("Tip for order %s", orderID),
+		Timestamp:     now,
+	}
+	if err := walletRepo.InsertWalletTransaction(ctx, tx.Tx, trx); err != nil {
+		return fmt.Errorf("failed to insert tip transaction: %v", err)
+	}
+
+	settlement := &entity.PaymentSettlement{
+		PaymentTransactionID: paymentTx.ID,
+		DriverID:             paymentTx.DriverID,
+		SettlementAmount:     paymentTx.Amount,
+		PlatformFee:          0,
+		TaxAmount:            0,
+		Status:               "PAID",
+		SettlementMethod:     SettlementMethodTip,
+		SettledAt:            &now,
+		CreatedAt:            now,
+	}
+	if err := paymentRepo.InsertPaymentSettlementTx(ctx, tx, settlement); err != nil {
+		return fmt.Errorf("failed to insert tip settlement: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/money"
+	"payment-service/src/pkg/utils"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// holdWallet reserves amount of the locked wallet for a payment. The amount
+// moves from the available to the held balance; nothing shows in the wallet
+// history until the hold is captured.
+func holdWallet(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	repo *repository.WalletRepository,
+	wallet *entity.Wallet,
+	paymentID uint64,
+	amount money.Amount,
+	expiresAt *time.Time,
+) (*entity.WalletHold, error) {
+	if amount > wallet.Balance {
+		return nil, fmt.Errorf("balance=%d need=%d", wallet.Balance, amount)
+	}
+	if err := repo.UpdateWalletBalances(ctx, tx.Tx, wallet.ID, wallet.Balance-amount, wallet.HeldBalance+amount); err != nil {
+		return nil, fmt.Errorf("failed to update wallet balance: %v", err)
+	}
+	wallet.Balance -= amount
+	wallet.HeldBalance += amount
+
+	hold := &entity.WalletHold{
+		HoldID:               utils.GenerateUniqueIDWithPrefix("hold"),
+		WalletID:             wallet.ID,
+		PaymentTransactionID: paymentID,
+		Amount:               amount,
+		Status:               entity.WalletHoldHeld,
+		ExpiresAt:            expiresAt,
+	}
+	if err := repo.InsertHold(ctx, tx.Tx, hold); err != nil {
+		return nil, fmt.Errorf("failed to insert wallet hold: %v", err)
+	}
+	return hold, nil
+}
+
+// captureHold charges amount of a payment's hold to the locked wallet and
+// gives the rest back to the available balance. The charge is the single
+// debit the wallet history shows for the payment.
+func captureHold(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	repo *repository.WalletRepository,
+	wallet *entity.Wallet,
+	paymentID uint64,
+	amount money.Amount,
+	description string,
+) (*entity.WalletHold, error) {
+	hold, err := openHold(ctx, tx, repo, wallet, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if amount < 0 || amount > hold.Amount {
+		return nil, fmt.Errorf("cannot capture %d of wallet hold %s for %d", amount, hold.HoldID, hold.Amount)
+	}
+
+	now := time.Now()
+	hold.Status = entity.WalletHoldCaptured
+	hold.CapturedAmount = amount
+	hold.CapturedAt = &now
+	if err := closeHold(ctx, tx, repo, wallet, hold); err != nil {
+		return nil, err
+	}
+	if amount > 0 {
+		trx := &entity.WalletTransaction{
+			WalletID:      wallet.ID,
+			TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+			Amount:        amount,
+			Type:          "debit",
+			Description:   description,
+			Timestamp:     now,
+		}
+		if err := repo.InsertWalletTransaction(ctx, tx.Tx, trx); err != nil {
+			return nil, fmt.Errorf("failed to insert wallet transaction: %v", err)
+		}
+	}
+	return hold, nil
+}
+
+// releaseHold gives a payment's whole hold back to the available balance of
+// the locked wallet. status is RELEASED, or EXPIRED when the hold ran out.
+func releaseHold(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	repo *repository.WalletRepository,
+	wallet *entity.Wallet,
+	paymentID uint64,
+	status string,
+) (*entity.WalletHold, error) {
+	hold, err := openHold(ctx, tx, repo, wallet, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	hold.Status = status
+	hold.ReleasedAt = &now
+	if err := closeHold(ctx, tx, repo, wallet, hold); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func openHold(ctx context.Context, tx *sqlx.Tx, repo *repository.WalletRepository, wallet *entity.Wallet, paymentID uint64) (*entity.WalletHold, error) {
+	hold, err := repo.GetHoldByPaymentIDForUpdate(ctx, tx.Tx, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet hold: %v", err)
+	}
+	if hold == nil {
+		return nil, fmt.Errorf("no wallet hold for payment %d", paymentID)
+	}
+	if hold.WalletID != wallet.ID {
+		return nil, fmt.Errorf("wallet hold %s belongs to wallet %s", hold.HoldID, hold.WalletID)
+	}
+	if hold.Status != entity.WalletHoldHeld {
+		return nil, repository.ErrHoldNotOpen
+	}
+	return hold, nil
+}
+
+// closeHold ends the hold and moves its uncaptured part back to the available
+// balance.
+func closeHold(ctx context.Context, tx *sqlx.Tx, repo *repository.WalletRepository, wallet *entity.Wallet, hold *entity.WalletHold) error {
+	if err := repo.CloseHold(ctx, tx.Tx, hold); err != nil {
+		return fmt.Errorf("failed to close wallet hold: %v", err)
+	}
+	returned := hold.Amount - hold.CapturedAmount
+	if err := repo.UpdateWalletBalances(ctx, tx.Tx, wallet.ID, wallet.Balance+returned, wallet.HeldBalance-hold.Amount); err != nil {
+		return fmt.Errorf("failed to update wallet balance: %v", err)
+	}
+	wallet.Balance += returned
+	wallet.HeldBalance -= hold.Amount
+	return nil
+}
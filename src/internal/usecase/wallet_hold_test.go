@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"context"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureAndReleaseHold(t *testing.T) {
+	holdColumns := []string{"id", "hold_id", "wallet_id", "payment_transaction_id", "amount", "captured_amount", "status",
+		"expires_at", "captured_at", "released_at", "created_at", "updated_at"}
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		holdStatus  string
+		release     string
+		capture     money.Amount
+		expect      func(mock sqlmock.Sqlmock)
+		wantErr     string
+		wantBalance money.Amount
+		wantHeld    money.Amount
+	}{
+		{
+			// the rest of the hold goes back; the charge is the one debit
+			// the wallet history shows
+			name:       "capture part of the hold",
+			holdStatus: entity.WalletHoldHeld,
+			capture:    30000,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE wallet_holds`).WithArgs(entity.WalletHoldCaptured, 30000, sqlmock.AnyArg(), nil, 21).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`SET balance = \?, held_balance = \?`).WithArgs(30000, 10000, "wlt-psg").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO wallet_transactions`).WithArgs("wlt-psg", sqlmock.AnyArg(), 30000, "debit", "Trip payment").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantBalance: 30000,
+			wantHeld:    10000,
+		},
+		{
+			name:       "capture nothing",
+			holdStatus: entity.WalletHoldHeld,
+			capture:    0,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE wallet_holds`).WithArgs(entity.WalletHoldCaptured, 0, sqlmock.AnyArg(), nil, 21).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`SET balance = \?, held_balance = \?`).WithArgs(60000, 10000, "wlt-psg").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantBalance: 60000,
+			wantHeld:    10000,
+		},
+		{
+			name:       "release",
+			holdStatus: entity.WalletHoldHeld,
+			release:    entity.WalletHoldReleased,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(`UPDATE wallet_holds`).WithArgs(entity.WalletHoldReleased, 0, nil, sqlmock.AnyArg(), 21).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`SET balance = \?, held_balance = \?`).WithArgs(60000, 10000, "wlt-psg").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantBalance: 60000,
+			wantHeld:    10000,
+		},
+		{
+			name:        "capture more than the hold",
+			holdStatus:  entity.WalletHoldHeld,
+			capture:     50001,
+			expect:      func(mock sqlmock.Sqlmock) {},
+			wantErr:     "cannot capture 50001",
+			wantBalance: 10000,
+			wantHeld:    60000,
+		},
+		{
+			// the expiry sweeper got there first
+			name:        "release a hold that has expired",
+			holdStatus:  entity.WalletHoldExpired,
+			release:     entity.WalletHoldReleased,
+			expect:      func(mock sqlmock.Sqlmock) {},
+			wantErr:     repository.ErrHoldNotOpen.Error(),
+			wantBalance: 10000,
+			wantHeld:    60000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, _, mock := newMockTx(t)
+			mock.ExpectQuery(`FROM wallet_holds\s+WHERE payment_transaction_id = \?`).WithArgs(2).
+				WillReturnRows(sqlmock.NewRows(holdColumns).AddRow(21, "hold-1", "wlt-psg", 2, 50000, 0, tt.holdStatus, nil, nil, nil, now, now))
+			tt.expect(mock)
+
+			// another payment's hold of 10000 stays on the wallet
+			wallet := &entity.Wallet{ID: "wlt-psg", UserID: "psg-1", Balance: 10000, HeldBalance: 60000, Currency: "IDR"}
+			repo := &repository.WalletRepository{}
+			var err error
+			if tt.release != "" {
+				_, err = releaseHold(context.Background(), tx, repo, wallet, 2, tt.release)
+			} else {
+				_, err = captureHold(context.Background(), tx, repo, wallet, 2, tt.capture, "Trip payment")
+			}
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantBalance, wallet.Balance)
+			assert.Equal(t, tt.wantHeld, wallet.HeldBalance)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
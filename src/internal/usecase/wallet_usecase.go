@@ -2,16 +2,22 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"payment-service/src/internal/entity"
+	"payment-service/src/internal/gateway/fx"
+	paymentGateway "payment-service/src/internal/gateway/payment"
 	"payment-service/src/internal/model"
 	"payment-service/src/internal/repository"
 	"payment-service/src/pkg/databases/mysql"
 	httpError "payment-service/src/pkg/http-error"
 	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/money"
 	"payment-service/src/pkg/utils"
+	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 )
@@ -23,8 +29,15 @@ type WalletUseCase struct {
 	WalletRepository  *repository.WalletRepository
 	PaymentRepository *repository.PaymentRepository
 	OrderRepository   *repository.OrderRepository
+	DriverRepository  *repository.DriverRepository
+	DebtRepository    *repository.DriverDebtRepository
+	CommissionRules   *repository.CommissionRuleRepository
+	TaxRepository     *repository.TaxRepository
 	DB                mysql.DBInterface
 	Redis             redis.UniversalClient
+	Rates             fx.RateSource
+	Methods           *paymentGateway.MethodCatalog
+	Providers         *paymentGateway.Registry
 }
 
 func NewWalletUseCase(
@@ -34,8 +47,15 @@ func NewWalletUseCase(
 	orderRepo *repository.OrderRepository,
 	walletRepo *repository.WalletRepository,
 	paymentRepo *repository.PaymentRepository,
+	driverRepo *repository.DriverRepository,
+	debtRepo *repository.DriverDebtRepository,
+	commissionRules *repository.CommissionRuleRepository,
+	taxRepo *repository.TaxRepository,
 	db mysql.DBInterface,
 	redisClient redis.UniversalClient,
+	rates fx.RateSource,
+	methods *paymentGateway.MethodCatalog,
+	providers *paymentGateway.Registry,
 ) *WalletUseCase {
 	return &WalletUseCase{
 		Log:               log,
@@ -44,8 +64,15 @@ func NewWalletUseCase(
 		WalletRepository:  walletRepo,
 		PaymentRepository: paymentRepo,
 		OrderRepository:   orderRepo,
+		DriverRepository:  driverRepo,
+		DebtRepository:    debtRepo,
+		CommissionRules:   commissionRules,
+		TaxRepository:     taxRepo,
 		DB:                db,
 		Redis:             redisClient,
+		Rates:             rates,
+		Methods:           methods,
+		Providers:         providers,
 	}
 }
 
@@ -59,6 +86,17 @@ func (uc *WalletUseCase) TopUpWallet(ctx context.Context, request *model.WalletR
 		uc.Log.Error("wallet-usecase", errObj.Message, "TopUpWallet", utils.ConvertString(request))
 		return result
 	}
+	currency := strings.ToUpper(request.Currency)
+	if currency == "" {
+		currency = paymentCurrency(uc.Config)
+	}
+	if !money.Known(currency) {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "unsupported currency " + currency
+		result.Error = errObj
+		uc.Log.Error("wallet-usecase", errObj.Message, "TopUpWallet", utils.ConvertString(request))
+		return result
+	}
 
 	db, err := uc.DB.GetDB()
 	if err != nil {
@@ -97,9 +135,10 @@ func (uc *WalletUseCase) TopUpWallet(ctx context.Context, request *model.WalletR
 	if wallet == nil {
 		walletID := utils.GenerateUniqueIDWithPrefix("wlt")
 		wallet = &entity.Wallet{
-			ID:      walletID,
-			UserID:  request.UserID,
-			Balance: 0,
+			ID:       walletID,
+			UserID:   request.UserID,
+			Balance:  0,
+			Currency: currency,
 		}
 		if err := uc.WalletRepository.InsertWallet(ctx, tx.Tx, wallet); err != nil {
 			_ = tx.Rollback()
@@ -110,9 +149,27 @@ func (uc *WalletUseCase) TopUpWallet(ctx context.Context, request *model.WalletR
 			return result
 		}
 	}
+	if walletCurrency(wallet) != currency {
+		// top ups are never converted
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("wallet is in %s, cannot top up %s", walletCurrency(wallet), currency)
+		result.Error = errObj
+		uc.Log.Error("wallet-usecase", errObj.Message, "TopUpWallet", utils.ConvertString(request))
+		return result
+	}
 
-	amount := float64(request.Amount)
-	newBalance := wallet.Balance + amount
+	amount := request.Amount
+	repaid, err := uc.debtLedger().repay(ctx, tx, wallet, amount, nil, entity.DriverDebtSourceTopUp, "Repaid from wallet top up")
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to repay driver debt"
+		result.Error = errObj
+		uc.Log.Error("wallet-usecase", errObj.Message, "TopUpWallet", utils.ConvertString(err))
+		return result
+	}
+	newBalance := wallet.Balance + amount - repaid
 
 	if err := uc.WalletRepository.UpdateWalletBalance(ctx, tx.Tx, wallet.ID, newBalance); err != nil {
 		_ = tx.Rollback()
@@ -141,6 +198,26 @@ func (uc *WalletUseCase) TopUpWallet(ctx context.Context, request *model.WalletR
 		uc.Log.Error("wallet-usecase", errObj.Message, "TopUpWallet", utils.ConvertString(err))
 		return result
 	}
+	trxs := []*entity.WalletTransaction{trx}
+	if repaid > 0 {
+		repayTrx := &entity.WalletTransaction{
+			WalletID:      wallet.ID,
+			TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+			Amount:        repaid,
+			Type:          "debit",
+			Description:   "Debt repayment from top up",
+			Timestamp:     trx.Timestamp,
+		}
+		if err := uc.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, repayTrx); err != nil {
+			_ = tx.Rollback()
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to insert debt repayment transaction"
+			result.Error = errObj
+			uc.Log.Error("wallet-usecase", errObj.Message, "TopUpWallet", utils.ConvertString(err))
+			return result
+		}
+		trxs = append(trxs, repayTrx)
+	}
 
 	if err := tx.Commit(); err != nil {
 		errObj := httpError.NewInternalServerError()
@@ -150,19 +227,25 @@ func (uc *WalletUseCase) TopUpWallet(ctx context.Context, request *model.WalletR
 		return result
 	}
 
+	histories := make([]model.WalletTransactionHistory, 0, len(trxs))
+	for _, t := range trxs {
+		histories = append(histories, model.WalletTransactionHistory{
+			TransactionID: t.TransactionID,
+			Amount:        t.Amount,
+			Type:          t.Type,
+			Description:   t.Description,
+			Timestamp:     t.Timestamp,
+		})
+	}
+
 	// 6. Response
 	result.Data = model.WalletResponse{
-		UserID:  request.UserID,
-		Balance: newBalance,
-		Transactions: []model.WalletTransactionHistory{
-			{
-				TransactionID: trx.TransactionID,
-				Amount:        trx.Amount,
-				Type:          trx.Type,
-				Description:   trx.Description,
-				Timestamp:     trx.Timestamp,
-			},
-		},
+		UserID:       request.UserID,
+		Balance:      newBalance,
+		HeldBalance:  wallet.HeldBalance,
+		Currency:     currency,
+		Debt:         uc.outstandingDebt(ctx, request.UserID),
+		Transactions: histories,
 	}
 
 	return result
@@ -186,6 +269,7 @@ func (uc *WalletUseCase) GetWallet(ctx context.Context, request *model.GetUserRe
 		result.Data = model.WalletResponse{
 			UserID:       userID,
 			Balance:      0,
+			Currency:     paymentCurrency(uc.Config),
 			Transactions: []model.WalletTransactionHistory{},
 		}
 		return result
@@ -214,6 +298,9 @@ func (uc *WalletUseCase) GetWallet(ctx context.Context, request *model.GetUserRe
 	result.Data = model.WalletResponse{
 		UserID:       userID,
 		Balance:      wallet.Balance,
+		HeldBalance:  wallet.HeldBalance,
+		Currency:     walletCurrency(wallet),
+		Debt:         uc.outstandingDebt(ctx, userID),
 		Transactions: histories,
 	}
 
@@ -226,7 +313,14 @@ func (uc *WalletUseCase) HoldWalletForOrder(ctx context.Context, request *model.
 		uc.Log.Error("wallet-usecase", "Order not found", "HoldWalletForOrder", utils.ConvertString(err))
 		return fmt.Errorf("order not found")
 	}
-	if order.PaymentMethod != "WALLET" && order.PaymentMethod != "EWALLET" {
+	if request.Message.ServiceTier != "" {
+		// kept for every order, whatever it is paid with, for its commission;
+		// without it the trip matches rules for any tier
+		if err := uc.CommissionRules.SaveOrderServiceTier(ctx, order.OrderID, request.Message.ServiceTier); err != nil {
+			uc.Log.Error("wallet-usecase", "failed to save order service tier", "HoldWalletForOrder", utils.ConvertString(err))
+		}
+	}
+	if method, ok := uc.Methods.Get(order.PaymentMethod); !ok || method.Code != paymentGateway.MethodEwallet {
 		uc.Log.Error("wallet-usecase", "Payment method is not wallet", "HoldWalletForOrder", order.PaymentMethod)
 		return fmt.Errorf("payment method is not wallet")
 	}
@@ -234,11 +328,15 @@ func (uc *WalletUseCase) HoldWalletForOrder(ctx context.Context, request *model.
 		uc.Log.Error("wallet-usecase", "Order is already paid", "HoldWalletForOrder", "")
 		return fmt.Errorf("order is already paid")
 	}
-	amount := order.MaxPrice
+	amount := orderAmount(uc.Config, order.MaxPrice)
 	if amount <= 0 {
 		uc.Log.Error("wallet-usecase", "Invalid order amount", "HoldWalletForOrder", utils.ConvertString(order))
 		return fmt.Errorf("invalid order amount")
 	}
+	if _, err := uc.Methods.Check(order.PaymentMethod, methodContext(ctx, uc.DriverRepository, request.Message.DriverID, amount)); err != nil {
+		uc.Log.Error("wallet-usecase", "Wallet payment not available for order", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
 	db, err := uc.DB.GetDB()
 	if err != nil {
 		uc.Log.Error("wallet-usecase", "failed to get db connection", "HoldWalletForOrder", utils.ConvertString(err))
@@ -266,38 +364,39 @@ func (uc *WalletUseCase) HoldWalletForOrder(ctx context.Context, request *model.
 		uc.Log.Error("wallet-usecase", "Wallet not found for passenger", "HoldWalletForOrder", request.Message.PassengerID)
 		return fmt.Errorf("wallet not found for passenger")
 	}
-	if wallet.Balance < amount {
-		_ = tx.Rollback()
-		uc.Log.Error("wallet-usecase", "Insufficient wallet balance", "HoldWalletForOrder",
-			fmt.Sprintf("balance=%.2f need=%.2f", wallet.Balance, amount))
-		return fmt.Errorf("balance=%.2f need=%.2f", wallet.Balance, amount)
-	}
-	newBalance := wallet.Balance - amount
-	if err := uc.WalletRepository.UpdateWalletBalance(ctx, tx.Tx, wallet.ID, newBalance); err != nil {
+	redemption, discount, err := redeemPromo(ctx, tx, uc.PaymentRepository, order, amount, paymentCurrency(uc.Config))
+	if err != nil {
 		_ = tx.Rollback()
-		uc.Log.Error("wallet-usecase", "failed to update wallet balance", "HoldWalletForOrder", utils.ConvertString(err))
-		return fmt.Errorf("failed to update wallet balance, err:%v", err)
-	}
-	walletTrx := &entity.WalletTransaction{
-		WalletID:      wallet.ID,
-		TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
-		Amount:        amount,
-		Type:          "debit",
-		Description:   fmt.Sprintf("Hold for order %s", request.Message.OrderID),
+		uc.Log.Error("wallet-usecase", "failed to apply promo", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
 	}
-	if err := uc.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, walletTrx); err != nil {
+	charge := money.New(amount-discount, paymentCurrency(uc.Config))
+	debit, rate, err := quoteWalletDebit(ctx, uc.Rates, wallet, charge)
+	if err != nil {
 		_ = tx.Rollback()
-		uc.Log.Error("wallet-usecase", "failed to insert wallet transaction", "HoldWalletForOrder", utils.ConvertString(err))
+		uc.Log.Error("wallet-usecase", "Wallet currency cannot pay this order", "HoldWalletForOrder", utils.ConvertString(err))
 		return err
 	}
+	if wallet.Balance < debit {
+		if !uc.Config.GetBool("payment.split.enabled") {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "Insufficient wallet balance", "HoldWalletForOrder",
+				fmt.Sprintf("balance=%d need=%d", wallet.Balance, debit))
+			return fmt.Errorf("balance=%d need=%d", wallet.Balance, debit)
+		}
+		return uc.holdSplitPayment(ctx, tx, order, wallet, charge, rate, redemption, request)
+	}
+	expiredAt := time.Now().Add(time.Duration(uc.Config.GetInt("wallet.hold.expiry_hours")) * time.Hour)
 	payment := &entity.PaymentTransaction{
-		RideOrderID:   order.ID,
-		PassengerID:   request.Message.PassengerID,
-		DriverID:      request.Message.DriverID,
-		Amount:        amount,
-		Currency:      "IDR",
-		PaymentMethod: "EWALLET",
-		PaymentStatus: "PENDING",
+		RideOrderID:    order.ID,
+		PassengerID:    request.Message.PassengerID,
+		DriverID:       request.Message.DriverID,
+		Amount:         charge.Amount,
+		DiscountAmount: discount,
+		Currency:       charge.Currency,
+		PaymentMethod:  paymentGateway.MethodEwallet,
+		PaymentStatus:  entity.PaymentStatusPending,
+		ExpiredAt:      &expiredAt,
 	}
 	paymentID, err := uc.PaymentRepository.InsertPaymentTransactionTx(ctx, tx, payment)
 	if err != nil {
@@ -305,6 +404,22 @@ func (uc *WalletUseCase) HoldWalletForOrder(ctx context.Context, request *model.
 		uc.Log.Error("wallet-usecase", "failed to create payment transaction", "HoldWalletForOrder", utils.ConvertString(err))
 		return err
 	}
+	if err := attachPromo(ctx, tx, uc.PaymentRepository, redemption, paymentID); err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to apply promo", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
+	hold, err := holdWallet(ctx, tx, uc.WalletRepository, wallet, paymentID, debit, &expiredAt)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to hold wallet balance", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
+	if err := recordFxConversion(ctx, tx, uc.PaymentRepository, paymentID, entity.FxPurposeHold, wallet, debit, charge, rate, uc.Rates); err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to record fx conversion", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
 
 	if err := tx.Commit(); err != nil {
 		uc.Log.Error("wallet-usecase", "failed to commit transaction", "HoldWalletForOrder", utils.ConvertString(err))
@@ -314,8 +429,10 @@ func (uc *WalletUseCase) HoldWalletForOrder(ctx context.Context, request *model.
 		"order_id":       request.ID,
 		"passenger_id":   request.Message.PassengerID,
 		"driver_id":      request.Message.DriverID,
-		"amount_hold":    amount,
-		"wallet_balance": newBalance,
+		"amount_hold":    debit,
+		"wallet_balance": wallet.Balance,
+		"held_balance":   wallet.HeldBalance,
+		"hold_id":        hold.HoldID,
 		"payment_tx_id":  paymentID,
 		"payment_status": "PENDING", // HOLD
 		"message":        "Wallet balance held for this order",
@@ -326,6 +443,193 @@ func (uc *WalletUseCase) HoldWalletForOrder(ctx context.Context, request *model.
 
 }
 
+// holdSplitPayment covers an order the wallet cannot pay in full. An order
+// level SPLIT payment is opened for the whole amount, whatever balance there
+// is is held as its wallet part and a QRIS charge is opened for the rest as
+// its QRIS part. The QRIS part is committed before the provider is called,
+// see openProviderCharge. The split completes once the trip has completed and
+// the QRIS part has paid; the QRIS part failing or expiring releases the
+// wallet part. rate is the wallet->payment rate when the wallet is in another
+// currency; redemption is the promo already taken off charge, recorded on the
+// split payment.
+func (uc *WalletUseCase) holdSplitPayment(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	order *entity.Order,
+	wallet *entity.Wallet,
+	charge money.Money,
+	rate *money.Rate,
+	redemption *entity.PromoRedemption,
+	request *model.OrderNotificationEvent,
+) error {
+	walletPart := wallet.Balance
+	if walletPart < 0 {
+		walletPart = 0
+	}
+	partAmount := walletPart
+	if rate != nil {
+		converted, err := rate.Convert(money.New(walletPart, walletCurrency(wallet)), money.RoundDown)
+		if err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to convert wallet balance", "HoldWalletForOrder", utils.ConvertString(err))
+			return err
+		}
+		partAmount = converted.Amount
+	}
+	qrisPart := charge.Amount - partAmount
+	if !methodSupportsCurrency(uc.Config, paymentGateway.MethodQris, charge.Currency) {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "Insufficient wallet balance and QRIS cannot pay the rest", "HoldWalletForOrder",
+			fmt.Sprintf("balance=%d need=%d currency=%s", wallet.Balance, charge.Amount, charge.Currency))
+		return fmt.Errorf("QRIS does not support %s payments", charge.Currency)
+	}
+	provider, err := uc.Providers.ForMethod("QRIS_CORE")
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "payment provider not configured", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
+	user, err := uc.UserRepository.FindByID(ctx, request.Message.PassengerID)
+	if err != nil || user == nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "User not found", "HoldWalletForOrder", utils.ConvertString(err))
+		return fmt.Errorf("user not found")
+	}
+
+	parent := &entity.PaymentTransaction{
+		RideOrderID:   order.ID,
+		PassengerID:   request.Message.PassengerID,
+		DriverID:      request.Message.DriverID,
+		Amount:        charge.Amount,
+		Currency:      charge.Currency,
+		PaymentMethod: PaymentMethodSplit,
+		PaymentStatus: entity.PaymentStatusPending,
+	}
+	if redemption != nil {
+		parent.DiscountAmount = redemption.DiscountApplied
+	}
+	parentID, err := uc.PaymentRepository.InsertPaymentTransactionTx(ctx, tx, parent)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to create split payment", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
+	if err := attachPromo(ctx, tx, uc.PaymentRepository, redemption, parentID); err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to apply promo", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
+	event := &entity.PaymentEventLog{
+		PaymentTransactionID: parentID,
+		EventType:            "CREATE",
+		EventDescription:     fmt.Sprintf("Split payment: %d from wallet, %d due by QRIS", partAmount, qrisPart),
+	}
+	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, event); err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to insert payment event log", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
+
+	var partID uint64
+	if partAmount > 0 {
+		expiredAt := time.Now().Add(time.Duration(uc.Config.GetInt("wallet.hold.expiry_hours")) * time.Hour)
+		part := &entity.PaymentTransaction{
+			RideOrderID:     order.ID,
+			PassengerID:     request.Message.PassengerID,
+			DriverID:        request.Message.DriverID,
+			Amount:          partAmount,
+			Currency:        charge.Currency,
+			PaymentMethod:   paymentGateway.MethodEwallet,
+			PaymentStatus:   entity.PaymentStatusPending,
+			ExpiredAt:       &expiredAt,
+			ParentPaymentID: &parentID,
+		}
+		partID, err = uc.PaymentRepository.InsertPaymentTransactionTx(ctx, tx, part)
+		if err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to create payment transaction", "HoldWalletForOrder", utils.ConvertString(err))
+			return err
+		}
+		if _, err := holdWallet(ctx, tx, uc.WalletRepository, wallet, partID, walletPart, &expiredAt); err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to hold wallet balance", "HoldWalletForOrder", utils.ConvertString(err))
+			return err
+		}
+		partCharge := money.New(partAmount, charge.Currency)
+		if err := recordFxConversion(ctx, tx, uc.PaymentRepository, partID, entity.FxPurposeHold, wallet, walletPart, partCharge, rate, uc.Rates); err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to record fx conversion", "HoldWalletForOrder", utils.ConvertString(err))
+			return err
+		}
+	}
+
+	chargeOrderID := utils.GenerateUniqueIDWithPrefix("payment")
+	providerName := provider.Name()
+	pendingMeta, _ := json.Marshal(&entity.PaymentChargeMetadata{Mode: ChargeModeQris})
+	pendingUntil := chargePendingUntil(uc.Config)
+	qris := &entity.PaymentTransaction{
+		RideOrderID:     order.ID,
+		PassengerID:     request.Message.PassengerID,
+		DriverID:        request.Message.DriverID,
+		Amount:          qrisPart,
+		Currency:        charge.Currency,
+		PaymentMethod:   paymentGateway.MethodQris,
+		PaymentStatus:   entity.PaymentStatusPending,
+		ProviderName:    &providerName,
+		ExpiredAt:       &pendingUntil,
+		Metadata:        pendingMeta,
+		ParentPaymentID: &parentID,
+		PaymentType:     entity.PaymentTypeTrip,
+		ChargeOrderID:   &chargeOrderID,
+	}
+	qrisID, err := uc.PaymentRepository.InsertPaymentTransactionTx(ctx, tx, qris)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to create payment transaction", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		uc.Log.Error("wallet-usecase", "failed to commit transaction", "HoldWalletForOrder", utils.ConvertString(err))
+		return err
+	}
+
+	// the split is committed either way: a QRIS part whose charge could not be
+	// opened is expired, which fails the split and releases the wallet part
+	db, err := uc.DB.GetDB()
+	if err == nil {
+		_, _, err = openProviderCharge(ctx, db, uc.PaymentRepository, provider, qrisID, &paymentGateway.ChargeRequest{
+			OrderID:       chargeOrderID,
+			Amount:        qrisPart,
+			Currency:      charge.Currency,
+			PaymentMethod: paymentGateway.MethodQris,
+			CustomerName:  user.FullName,
+			CustomerEmail: user.Email,
+		}, ChargeModeQris)
+	}
+	if err != nil {
+		uc.Log.Error("wallet-usecase", "failed to open the QRIS part of split payment", "HoldWalletForOrder", utils.ConvertString(err))
+	}
+
+	result := map[string]interface{}{
+		"order_id":        request.ID,
+		"passenger_id":    request.Message.PassengerID,
+		"driver_id":       request.Message.DriverID,
+		"amount_hold":     walletPart,
+		"amount_due_qris": qrisPart,
+		"wallet_balance":  wallet.Balance,
+		"held_balance":    wallet.HeldBalance,
+		"payment_tx_id":   parentID,
+		"wallet_part_id":  partID,
+		"qris_part_id":    qrisID,
+		"payment_status":  "PENDING",
+		"message":         "Wallet balance short, remainder due by QRIS",
+	}
+	uc.Log.Info("wallet-usecase", fmt.Sprintf("Split payment opened for order %s", request.Message.OrderID), "HoldWalletForOrder", utils.ConvertString(result))
+
+	return nil
+}
+
 func (uc *WalletUseCase) DebetWallet(ctx context.Context, req *model.NotificationUser) error {
 	uc.Log.Info(
 		"wallet-usecase",
@@ -347,43 +651,27 @@ func (uc *WalletUseCase) DebetWallet(ctx context.Context, req *model.Notificatio
 		return fmt.Errorf("order not found")
 	}
 
-	if order.PaymentMethod != "WALLET" && order.PaymentMethod != "EWALLET" {
-		uc.Log.Info("wallet-usecase", "Payment method is not wallet, skip debit", "DebetWallet", order.PaymentMethod)
-		return nil
-	}
 	if order.PaymentStatus == "PAID" {
 		uc.Log.Info("wallet-usecase", "Order already paid, skip debit", "DebetWallet", order.OrderID)
 		return nil
 	}
-
-	var actualPrice float64
-	if order.EstimatedFare != nil && *order.EstimatedFare > 0 {
-		actualPrice = *order.EstimatedFare
-	} else if order.BestRoutePrice > 0 {
-		actualPrice = order.BestRoutePrice
-	} else {
-		actualPrice = order.MaxPrice
+	if isCashMethod(order.PaymentMethod) {
+		return uc.settleCashTrip(ctx, req, order)
 	}
-
-	if actualPrice <= 0 {
-		uc.Log.Error("wallet-usecase", "Invalid actual price", "DebetWallet", utils.ConvertString(order))
-		return fmt.Errorf("invalid actual price")
-	}
-
-	maxPrice := order.MaxPrice
-	if maxPrice <= 0 {
-		uc.Log.Error("wallet-usecase", "Invalid max price", "DebetWallet", utils.ConvertString(order))
-		return fmt.Errorf("invalid max price")
+	if !isWalletMethod(order.PaymentMethod) {
+		uc.Log.Info("wallet-usecase", "Payment method is not wallet, skip debit", "DebetWallet", order.PaymentMethod)
+		return nil
 	}
 
-	actualPaid := actualPrice
-	if actualPaid > maxPrice {
-		actualPaid = maxPrice
+	actualPaid, _, err := tripFare(uc.Config, order)
+	if err != nil {
+		uc.Log.Error("wallet-usecase", err.Error(), "DebetWallet", utils.ConvertString(order))
+		return err
 	}
-
-	var refundAmount float64
-	if actualPrice < maxPrice {
-		refundAmount = maxPrice - actualPrice
+	commission, err := uc.resolveCommission(ctx, order, req.DriverID)
+	if err != nil {
+		uc.Log.Error("wallet-usecase", "failed to resolve commission rule", "DebetWallet", utils.ConvertString(err))
+		return err
 	}
 
 	db, err := uc.DB.GetDB()
@@ -404,6 +692,25 @@ func (uc *WalletUseCase) DebetWallet(ctx context.Context, req *model.Notificatio
 		}
 	}()
 
+	split, err := uc.PaymentRepository.FindOpenSplitParentTx(ctx, tx, order.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to get split payment", "DebetWallet", utils.ConvertString(err))
+		return fmt.Errorf("failed to get split payment: %v", err)
+	}
+	if split != nil {
+		if err := uc.settleSplitTrip(ctx, tx, req, split, actualPaid, commission); err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to settle split payment", "DebetWallet", utils.ConvertString(err))
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			uc.Log.Error("wallet-usecase", "failed to commit transaction", "DebetWallet", utils.ConvertString(err))
+			return fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		return nil
+	}
+
 	paymentTx, err := uc.PaymentRepository.FindPendingPaymentByOrder(ctx, tx.Tx, order.ID)
 	if err != nil {
 		_ = tx.Rollback()
@@ -415,132 +722,71 @@ func (uc *WalletUseCase) DebetWallet(ctx context.Context, req *model.Notificatio
 		uc.Log.Error("wallet-usecase", "No pending payment transaction for order", "DebetWallet", order.OrderID)
 		return fmt.Errorf("no pending payment transaction for order")
 	}
-
-	now := time.Now()
-
-	if refundAmount > 0 {
-		passengerWallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, req.PassengerID)
-		if err != nil {
-			_ = tx.Rollback()
-			uc.Log.Error("wallet-usecase", "failed to get passenger wallet", "DebetWallet", utils.ConvertString(err))
-			return fmt.Errorf("failed to get passenger wallet: %v", err)
-		}
-		if passengerWallet == nil {
-			_ = tx.Rollback()
-			uc.Log.Error("wallet-usecase", "Passenger wallet not found for refund", "DebetWallet", req.PassengerID)
-			return fmt.Errorf("passenger wallet not found")
-		}
-
-		newPassengerBalance := passengerWallet.Balance + refundAmount
-		if err := uc.WalletRepository.UpdateWalletBalance(ctx, tx.Tx, passengerWallet.ID, newPassengerBalance); err != nil {
-			_ = tx.Rollback()
-			uc.Log.Error("wallet-usecase", "failed to update passenger wallet balance", "DebetWallet", utils.ConvertString(err))
-			return fmt.Errorf("failed to update passenger wallet balance: %v", err)
-		}
-
-		refundTrx := &entity.WalletTransaction{
-			WalletID:      passengerWallet.ID,
-			TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
-			Amount:        refundAmount,
-			Type:          "credit",
-			Description:   fmt.Sprintf("Refund difference for order %s", req.OrderID),
-			Timestamp:     now,
-		}
-		if err := uc.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, refundTrx); err != nil {
-			_ = tx.Rollback()
-			uc.Log.Error("wallet-usecase", "failed to insert refund transaction", "DebetWallet", utils.ConvertString(err))
-			return fmt.Errorf("failed to insert refund transaction: %v", err)
-		}
-
-		// event log refund
-		refundEvent := &entity.PaymentEventLog{
-			PaymentTransactionID: paymentTx.ID,
-			EventType:            "REFUND",
-			EventDescription:     fmt.Sprintf("Refunded %.2f to passenger for order %s", refundAmount, req.OrderID),
-			RawPayload:           nil,
-		}
-		if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, refundEvent); err != nil {
-			_ = tx.Rollback()
-			uc.Log.Error("wallet-usecase", "failed to insert refund event log", "DebetWallet", utils.ConvertString(err))
-			return fmt.Errorf("failed to insert refund event log: %v", err)
-		}
+	if !entity.CanTransitionPayment(paymentTx.PaymentStatus, entity.PaymentStatusSuccess) {
+		// nothing has moved yet, so keep the rejection in the event log
+		err := transitionPayment(ctx, uc.PaymentRepository, tx, paymentTx, entity.PaymentStatusSuccess, "wallet capture", nil)
+		_ = tx.Commit()
+		uc.Log.Error("wallet-usecase", "illegal payment status transition", "DebetWallet", utils.ConvertString(err))
+		return err
 	}
 
-	driverWallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, req.DriverID)
+	passengerWallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, req.PassengerID)
 	if err != nil {
 		_ = tx.Rollback()
-		uc.Log.Error("wallet-usecase", "failed to get driver wallet", "DebetWallet", utils.ConvertString(err))
-		return fmt.Errorf("failed to get driver wallet: %v", err)
-	}
-	if driverWallet == nil {
-		walletID := utils.GenerateUniqueIDWithPrefix("wlt")
-		driverWallet = &entity.Wallet{
-			ID:      walletID,
-			UserID:  req.DriverID,
-			Balance: 0,
-		}
-		if err := uc.WalletRepository.InsertWallet(ctx, tx.Tx, driverWallet); err != nil {
-			_ = tx.Rollback()
-			uc.Log.Error("wallet-usecase", "failed to create driver wallet", "DebetWallet", utils.ConvertString(err))
-			return fmt.Errorf("failed to create driver wallet: %v", err)
-		}
+		uc.Log.Error("wallet-usecase", "failed to get passenger wallet", "DebetWallet", utils.ConvertString(err))
+		return fmt.Errorf("failed to get passenger wallet: %v", err)
 	}
-
-	platformFeeRate := uc.Config.GetFloat64("platform.fee")
-	taxRate := uc.Config.GetFloat64("platform.tax")
-	platformFee := actualPaid * platformFeeRate
-	taxAmount := (actualPaid - platformFee) * taxRate
-
-	driverSettlement := actualPaid - platformFee - taxAmount
-	if driverSettlement < 0 {
-		driverSettlement = 0
+	if passengerWallet == nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "Passenger wallet not found for capture", "DebetWallet", req.PassengerID)
+		return fmt.Errorf("passenger wallet not found")
 	}
-	uc.Log.Info("wallet-usecase",
-		fmt.Sprintf("PlatformFeeRate=%.2f, TaxRate=%.2f, PlatformFee=%.2f, TaxAmount=%.2f, DriverSettlement=%.2f",
-			platformFeeRate, taxRate, platformFee, taxAmount, driverSettlement),
-		"DebetWallet", "")
-	newDriverBalance := driverWallet.Balance + driverSettlement
-	if err := uc.WalletRepository.UpdateWalletBalance(ctx, tx.Tx, driverWallet.ID, newDriverBalance); err != nil {
+	// the promo is priced again on the fare; the platform funds it, so the
+	// driver is still settled on the full fare
+	discount, err := settlePromo(ctx, tx, uc.PaymentRepository, paymentTx.ID, actualPaid, paymentTx.Currency)
+	if err != nil {
 		_ = tx.Rollback()
-		uc.Log.Error("wallet-usecase", "failed to update driver wallet balance", "DebetWallet", utils.ConvertString(err))
-		return fmt.Errorf("failed to update driver wallet balance: %v", err)
+		uc.Log.Error("wallet-usecase", "failed to settle promo", "DebetWallet", utils.ConvertString(err))
+		return err
 	}
-
-	driverTrx := &entity.WalletTransaction{
-		WalletID:      driverWallet.ID,
-		TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
-		Amount:        driverSettlement,
-		Type:          "credit",
-		Description:   fmt.Sprintf("Trip earning for order %s", req.OrderID),
-		Timestamp:     now,
+	payable := actualPaid - discount
+	released := paymentTx.Amount - payable
+	walletCharge, err := walletShare(ctx, tx, uc.PaymentRepository, paymentTx.ID, entity.FxPurposeHold, passengerWallet,
+		money.New(payable, paymentTx.Currency))
+	if err != nil {
+		_ = tx.Rollback()
+		uc.Log.Error("wallet-usecase", "failed to convert fare to wallet currency", "DebetWallet", utils.ConvertString(err))
+		return err
 	}
-	if err := uc.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, driverTrx); err != nil {
+	hold, err := captureHold(ctx, tx, uc.WalletRepository, passengerWallet, paymentTx.ID, walletCharge, fmt.Sprintf("Payment for order %s", req.OrderID))
+	if err != nil {
 		_ = tx.Rollback()
-		uc.Log.Error("wallet-usecase", "failed to insert driver settlement transaction", "DebetWallet", utils.ConvertString(err))
-		return fmt.Errorf("failed to insert driver settlement transaction: %v", err)
+		uc.Log.Error("wallet-usecase", "failed to capture wallet hold", "DebetWallet", utils.ConvertString(err))
+		return err
 	}
-	settlement := &entity.PaymentSettlement{
-		PaymentTransactionID: paymentTx.ID,
-		DriverID:             req.DriverID,
-		SettlementAmount:     driverSettlement,
-		PlatformFee:          platformFee,
-		TaxAmount:            taxAmount,
-		Status:               "PAID",
-		SettlementMethod:     "WALLET",
-		CreatedAt:            time.Now(),
+	if released > 0 {
+		releaseEvent := &entity.PaymentEventLog{
+			PaymentTransactionID: paymentTx.ID,
+			EventType:            "RELEASE",
+			EventDescription:     fmt.Sprintf("Released %d of hold %s to passenger for order %s", released, hold.HoldID, req.OrderID),
+			RawPayload:           nil,
+		}
+		if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, releaseEvent); err != nil {
+			_ = tx.Rollback()
+			uc.Log.Error("wallet-usecase", "failed to insert release event log", "DebetWallet", utils.ConvertString(err))
+			return fmt.Errorf("failed to insert release event log: %v", err)
+		}
 	}
 
-	if err := uc.PaymentRepository.InsertPaymentSettlementTx(ctx, tx, settlement); err != nil {
+	if err := uc.settleTripDriver(ctx, tx, paymentTx, req.DriverID, req.OrderID, actualPaid, commission, "DebetWallet"); err != nil {
 		_ = tx.Rollback()
-		uc.Log.Error("wallet-usecase", "failed to insert payment settlement", "DebetWallet", err.Error())
+		uc.Log.Error("wallet-usecase", "failed to settle driver", "DebetWallet", utils.ConvertString(err))
 		return err
 	}
 
-	paymentTx.Amount = actualPaid
-	paymentTx.PaymentStatus = "SUCCESS"
-	paymentTx.PaidAt = &now
-
-	if err := uc.PaymentRepository.UpdatePaymentTransactionTx(ctx, tx, paymentTx); err != nil {
+	paymentTx.Amount = payable
+	paymentTx.DiscountAmount = discount
+	if err := transitionPayment(ctx, uc.PaymentRepository, tx, paymentTx, entity.PaymentStatusSuccess, "wallet capture", nil); err != nil {
 		_ = tx.Rollback()
 		uc.Log.Error("wallet-usecase", "failed to update payment transaction", "DebetWallet", utils.ConvertString(err))
 		return fmt.Errorf("failed to update payment transaction: %v", err)
@@ -549,7 +795,7 @@ func (uc *WalletUseCase) DebetWallet(ctx context.Context, req *model.Notificatio
 	successEvent := &entity.PaymentEventLog{
 		PaymentTransactionID: paymentTx.ID,
 		EventType:            "SUCCESS",
-		EventDescription:     fmt.Sprintf("Wallet payment captured %.2f for order %s", actualPaid, req.OrderID),
+		EventDescription:     fmt.Sprintf("Wallet payment captured %d for order %s", payable, req.OrderID),
 		RawPayload:           nil,
 	}
 	if err := uc.PaymentRepository.InsertPaymentEventLogTx(ctx, tx.Tx, successEvent); err != nil {
@@ -577,10 +823,266 @@ func (uc *WalletUseCase) DebetWallet(ctx context.Context, req *model.Notificatio
 
 	uc.Log.Info(
 		"wallet-usecase",
-		fmt.Sprintf("Debit wallet + settlement success. order=%s paid=%.2f refund=%.2f", req.OrderID, actualPaid, refundAmount),
+		fmt.Sprintf("Debit wallet + settlement success. order=%s paid=%d discount=%d released=%d", req.OrderID, payable, discount, released),
 		"DebetWallet",
 		"",
 	)
 
 	return nil
 }
+
+// settleTripDriver pays the driver of a completed trip their share of the
+// fare, less the platform fee and taxes, and records the settlement on the
+// trip's payment.
+func (uc *WalletUseCase) settleTripDriver(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	paymentTx *entity.PaymentTransaction,
+	driverID string,
+	orderID string,
+	actualPaid money.Amount,
+	commission *entity.CommissionRule,
+	scope string,
+) error {
+	settlement, err := uc.tripSettlement(ctx, paymentTx, driverID, actualPaid, commission, scope)
+	if err != nil {
+		return err
+	}
+	return payDriverSettlement(ctx, tx, uc.PaymentRepository, uc.WalletRepository, uc.debtLedger(), uc.Rates, settlement, paymentTx.Currency, orderID)
+}
+
+// tripSettlement prices the driver's share of a completed trip as a PENDING
+// settlement that is yet to be inserted. The platform funds any promo, so the
+// share is taken from the full fare.
+func (uc *WalletUseCase) tripSettlement(
+	ctx context.Context,
+	paymentTx *entity.PaymentTransaction,
+	driverID string,
+	actualPaid money.Amount,
+	commission *entity.CommissionRule,
+	scope string,
+) (*entity.PaymentSettlement, error) {
+	platformFee, taxes, driverSettlement, err := uc.platformCut(ctx, actualPaid, commission, scope)
+	if err != nil {
+		return nil, err
+	}
+	settlement := &entity.PaymentSettlement{
+		PaymentTransactionID: paymentTx.ID,
+		DriverID:             driverID,
+		SettlementAmount:     driverSettlement,
+		PlatformFee:          platformFee,
+		Status:               "PENDING",
+		SettlementMethod:     "WALLET",
+		CreatedAt:            time.Now(),
+	}
+	applyCommission(settlement, commission)
+	if err := taxes.apply(settlement); err != nil {
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// payDriverSettlement credits the driver the share a settlement records, in
+// currency, and stores the settlement as PAID: inserted when it is new,
+// updated when it was left PENDING for a split payment still being paid.
+func payDriverSettlement(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	paymentRepo *repository.PaymentRepository,
+	walletRepo *repository.WalletRepository,
+	ledger driverDebtLedger,
+	rates fx.RateSource,
+	settlement *entity.PaymentSettlement,
+	currency string,
+	orderID string,
+) error {
+	driverWallet, err := lockDriverWallet(ctx, tx, walletRepo, settlement.DriverID, currency)
+	if err != nil {
+		return err
+	}
+	settlementMoney := money.New(settlement.SettlementAmount, currency)
+	driverCredit, driverRate, err := quoteWalletCredit(ctx, rates, driverWallet, settlementMoney)
+	if err != nil {
+		return err
+	}
+	if err := recordFxConversion(ctx, tx, paymentRepo, settlement.PaymentTransactionID, entity.FxPurposeSettlement, driverWallet, driverCredit, settlementMoney, driverRate, rates); err != nil {
+		return err
+	}
+	if err := creditEarning(ctx, tx, walletRepo, ledger, driverWallet, driverCredit, settlement.PaymentTransactionID, entity.DriverDebtSourceTripEarning, orderID, fmt.Sprintf("Trip earning for order %s", orderID)); err != nil {
+		return err
+	}
+
+	settlement.Status = "PAID"
+	if settlement.ID != 0 {
+		now := time.Now()
+		settlement.SettledAt = &now
+		if err := paymentRepo.UpdateSettlementStatusTx(ctx, tx, settlement.ID, settlement.Status, settlement.SettledAt); err != nil {
+			return fmt.Errorf("failed to update payment settlement: %v", err)
+		}
+		return nil
+	}
+	if err := paymentRepo.InsertPaymentSettlementTx(ctx, tx, settlement); err != nil {
+		return fmt.Errorf("failed to insert payment settlement: %v", err)
+	}
+	return nil
+}
+
+// tripFare prices a completed trip from the order service fares: what the
+// passenger pays, capped at the max price, and the max price the wallet hold
+// was taken for.
+func tripFare(config *viper.Viper, order *entity.Order) (money.Amount, money.Amount, error) {
+	var actualPrice money.Amount
+	if order.EstimatedFare != nil && *order.EstimatedFare > 0 {
+		actualPrice = orderAmount(config, *order.EstimatedFare)
+	} else if order.BestRoutePrice > 0 {
+		actualPrice = orderAmount(config, order.BestRoutePrice)
+	} else {
+		actualPrice = orderAmount(config, order.MaxPrice)
+	}
+	if actualPrice <= 0 {
+		return 0, 0, fmt.Errorf("invalid actual price")
+	}
+
+	maxPrice := orderAmount(config, order.MaxPrice)
+	if maxPrice <= 0 {
+		return 0, 0, fmt.Errorf("invalid max price")
+	}
+
+	if actualPrice > maxPrice {
+		actualPrice = maxPrice
+	}
+	return actualPrice, maxPrice, nil
+}
+
+// platformCut splits what a trip paid into the platform fee, the taxes on
+// the trip and the driver's share, using the commission rule's rate and the
+// tax rates in effect now.
+func (uc *WalletUseCase) platformCut(ctx context.Context, paid money.Amount, commission *entity.CommissionRule, scope string) (money.Amount, taxBreakdown, money.Amount, error) {
+	platformFeeRate := commission.FeeRate
+	platformFee := paid.MulRate(platformFeeRate, roundingMode(uc.Config, "platform.fee_rounding"))
+	taxes, err := tripTaxes(ctx, uc.Config, uc.TaxRepository, paid, platformFee, time.Now())
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	taxAmount := taxes.Total()
+
+	driverShare := paid - platformFee - taxAmount
+	if driverShare < 0 {
+		driverShare = 0
+	}
+	uc.Log.Info("wallet-usecase",
+		fmt.Sprintf("CommissionRule=%s@v%d, PlatformFeeRate=%.4f, PlatformFee=%d, PPN=%d, PPh=%d, DriverSettlement=%d",
+			commission.RuleCode, commission.Version, platformFeeRate, platformFee,
+			taxes.Of(entity.TaxPPN).Amount, taxes.Of(entity.TaxPPh).Amount, driverShare),
+		scope, "")
+	return platformFee, taxes, driverShare, nil
+}
+
+// outstandingDebt is shown alongside the balance; a failed lookup only
+// leaves it out.
+func (uc *WalletUseCase) outstandingDebt(ctx context.Context, userID string) money.Amount {
+	debt, err := uc.DebtRepository.GetDebtByDriverID(ctx, userID)
+	if err != nil {
+		uc.Log.Error("wallet-usecase", "failed to get driver debt", "OutstandingDebt", utils.ConvertString(err))
+		return 0
+	}
+	if debt == nil {
+		return 0
+	}
+	return debt.Outstanding
+}
+
+// creditDriverEarning credits an earning to the driver's locked wallet. Any
+// driver debt is repaid from it first, and both movements are written as
+// wallet transactions.
+func (uc *WalletUseCase) creditDriverEarning(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	wallet *entity.Wallet,
+	credit money.Amount,
+	paymentID uint64,
+	source string,
+	orderID string,
+	description string,
+) error {
+	return creditEarning(ctx, tx, uc.WalletRepository, uc.debtLedger(), wallet, credit, paymentID, source, orderID, description)
+}
+
+func creditEarning(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	walletRepo *repository.WalletRepository,
+	ledger driverDebtLedger,
+	wallet *entity.Wallet,
+	credit money.Amount,
+	paymentID uint64,
+	source string,
+	orderID string,
+	description string,
+) error {
+	repaid, err := ledger.repay(ctx, tx, wallet, credit, &paymentID, source, fmt.Sprintf("Repaid from order %s", orderID))
+	if err != nil {
+		return err
+	}
+	if err := walletRepo.UpdateWalletBalance(ctx, tx.Tx, wallet.ID, wallet.Balance+credit-repaid); err != nil {
+		return fmt.Errorf("failed to update driver wallet balance: %v", err)
+	}
+	wallet.Balance += credit - repaid
+
+	now := time.Now()
+	trx := &entity.WalletTransaction{
+		WalletID:      wallet.ID,
+		TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+		Amount:        credit,
+		Type:          "credit",
+		Description:   description,
+		Timestamp:     now,
+	}
+	if err := walletRepo.InsertWalletTransaction(ctx, tx.Tx, trx); err != nil {
+		return fmt.Errorf("failed to insert driver earning transaction: %v", err)
+	}
+	if repaid > 0 {
+		repayTrx := &entity.WalletTransaction{
+			WalletID:      wallet.ID,
+			TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+			Amount:        repaid,
+			Type:          "debit",
+			Description:   fmt.Sprintf("Debt repayment from order %s", orderID),
+			Timestamp:     now,
+		}
+		if err := walletRepo.InsertWalletTransaction(ctx, tx.Tx, repayTrx); err != nil {
+			return fmt.Errorf("failed to insert debt repayment transaction: %v", err)
+		}
+	}
+	return nil
+}
+
+func (uc *WalletUseCase) debtLedger() driverDebtLedger {
+	return newDriverDebtLedger(uc.Config, uc.DebtRepository, uc.DriverRepository)
+}
+
+// driverWalletForUpdate locks the driver's wallet, opening one in currency
+// for a driver who has none yet.
+func (uc *WalletUseCase) driverWalletForUpdate(ctx context.Context, tx *sqlx.Tx, driverID, currency string) (*entity.Wallet, error) {
+	return lockDriverWallet(ctx, tx, uc.WalletRepository, driverID, currency)
+}
+
+func lockDriverWallet(ctx context.Context, tx *sqlx.Tx, repo *repository.WalletRepository, driverID, currency string) (*entity.Wallet, error) {
+	wallet, err := repo.GetWalletForUpdate(ctx, tx.Tx, driverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver wallet: %v", err)
+	}
+	if wallet != nil {
+		return wallet, nil
+	}
+	wallet = &entity.Wallet{
+		ID:       utils.GenerateUniqueIDWithPrefix("wlt"),
+		UserID:   driverID,
+		Balance:  0,
+		Currency: money.New(0, currency).Currency,
+	}
+	if err := repo.InsertWallet(ctx, tx.Tx, wallet); err != nil {
+		return nil, fmt.Errorf("failed to create driver wallet: %v", err)
+	}
+	return wallet, nil
+}
@@ -0,0 +1,309 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"payment-service/src/internal/entity"
+	paymentGateway "payment-service/src/internal/gateway/payment"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/model/converter"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/databases/mysql"
+	httpError "payment-service/src/pkg/http-error"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type WebhookInboxUseCase struct {
+	Log                    log.Log
+	Config                 *viper.Viper
+	WebhookInboxRepository *repository.WebhookInboxRepository
+	PaymentUseCase         *PaymentUseCase
+	DB                     mysql.DBInterface
+}
+
+func NewWebhookInboxUseCase(
+	log log.Log,
+	config *viper.Viper,
+	inboxRepo *repository.WebhookInboxRepository,
+	paymentUseCase *PaymentUseCase,
+	db mysql.DBInterface,
+) *WebhookInboxUseCase {
+	return &WebhookInboxUseCase{
+		Log:                    log,
+		Config:                 config,
+		WebhookInboxRepository: inboxRepo,
+		PaymentUseCase:         paymentUseCase,
+		DB:                     db,
+	}
+}
+
+// ReceiveNotification verifies a raw provider notification, stores it and
+// acknowledges it. Only the signature is checked before the payload claims its
+// dedupe key, so a forged payload cannot shadow the genuine one; applying it
+// is left to the inbox worker so a processing failure never costs us the
+// payload.
+func (uc *WebhookInboxUseCase) ReceiveNotification(ctx context.Context, payload []byte) utils.Result {
+	var result utils.Result
+
+	envelope, err := paymentGateway.PeekNotification(payload)
+	if err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = err.Error()
+		result.Error = errObj
+		uc.Log.Error("webhook-inbox-usecase", errObj.Message, "ReceiveNotification", string(payload))
+		return result
+	}
+
+	if verified := uc.PaymentUseCase.VerifyNotification(ctx, payload); verified.Error != nil {
+		result.Error = verified.Error
+		return result
+	}
+
+	inbox := &entity.WebhookInbox{
+		DedupeKey: envelope.DedupeKey(),
+		OrderID:   envelope.OrderID,
+		Payload:   string(payload),
+		Status:    entity.WebhookInboxReceived,
+	}
+	if envelope.TransactionID != "" {
+		inbox.TransactionID = &envelope.TransactionID
+	}
+	if envelope.TransactionStatus != "" {
+		inbox.TransactionStatus = &envelope.TransactionStatus
+	}
+
+	inserted, err := uc.WebhookInboxRepository.Insert(ctx, inbox)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to store notification"
+		result.Error = errObj
+		uc.Log.Error("webhook-inbox-usecase", errObj.Message, "ReceiveNotification", utils.ConvertString(err))
+		return result
+	}
+
+	if !inserted {
+		id, err := uc.WebhookInboxRepository.ReplaceRejected(ctx, inbox.DedupeKey, inbox.Payload)
+		if err != nil {
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to store notification"
+			result.Error = errObj
+			uc.Log.Error("webhook-inbox-usecase", errObj.Message, "ReceiveNotification", utils.ConvertString(err))
+			return result
+		}
+		if id == 0 {
+			result.Data = map[string]string{"message": "duplicate notification"}
+			return result
+		}
+		inbox.ID = id
+	}
+
+	go uc.processInBackground(inbox.ID)
+
+	result.Data = map[string]interface{}{
+		"message":  "notification received",
+		"inbox_id": inbox.ID,
+	}
+	return result
+}
+
+// processInBackground gives a fresh notification a first attempt straight
+// away; the worker picks it up if this one fails.
+func (uc *WebhookInboxUseCase) processInBackground(id uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := uc.processEntry(ctx, id, false); err != nil {
+		uc.Log.Error("webhook-inbox-usecase", fmt.Sprintf("failed to process inbox entry %d", id), "processInBackground", utils.ConvertString(err))
+	}
+}
+
+// ProcessDueNotifications works through one batch of RECEIVED and RETRY
+// entries whose next attempt is due.
+func (uc *WebhookInboxUseCase) ProcessDueNotifications(ctx context.Context) error {
+	batchSize := uc.Config.GetInt("webhook.inbox.batch_size")
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	ids, err := uc.WebhookInboxRepository.FindDueIDs(ctx, batchSize)
+	if err != nil {
+		uc.Log.Error("webhook-inbox-usecase", "failed to get due inbox entries", "ProcessDueNotifications", utils.ConvertString(err))
+		return fmt.Errorf("failed to get due inbox entries: %v", err)
+	}
+
+	for _, id := range ids {
+		if _, err := uc.processEntry(ctx, id, false); err != nil {
+			uc.Log.Error("webhook-inbox-usecase", fmt.Sprintf("failed to process inbox entry %d", id), "ProcessDueNotifications", utils.ConvertString(err))
+		}
+	}
+	return nil
+}
+
+func (uc *WebhookInboxUseCase) ListFailedNotifications(ctx context.Context, req *model.ListWebhookInboxRequest) utils.Result {
+	var result utils.Result
+
+	statuses := []string{entity.WebhookInboxFailed, entity.WebhookInboxRejected}
+	if req.Status != "" {
+		statuses = []string{strings.ToUpper(req.Status)}
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	entries, err := uc.WebhookInboxRepository.FindByStatuses(ctx, statuses, limit, offset)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get inbox entries"
+		result.Error = errObj
+		uc.Log.Error("webhook-inbox-usecase", errObj.Message, "ListFailedNotifications", utils.ConvertString(err))
+		return result
+	}
+
+	response := make([]*model.WebhookInboxResponse, 0, len(entries))
+	for i := range entries {
+		response = append(response, converter.WebhookInboxToResponse(&entries[i]))
+	}
+	result.Data = response
+	return result
+}
+
+// ReplayNotification runs an entry through processing again right away,
+// whatever its status, unless it has already been processed.
+func (uc *WebhookInboxUseCase) ReplayNotification(ctx context.Context, req *model.ReplayWebhookRequest) utils.Result {
+	var result utils.Result
+
+	inbox, err := uc.processEntry(ctx, req.ID, true)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to replay notification"
+		result.Error = errObj
+		uc.Log.Error("webhook-inbox-usecase", errObj.Message, "ReplayNotification", utils.ConvertString(err))
+		return result
+	}
+	if inbox == nil {
+		errObj := httpError.NewNotFound()
+		errObj.Message = "inbox entry not found"
+		result.Error = errObj
+		uc.Log.Error("webhook-inbox-usecase", errObj.Message, "ReplayNotification", fmt.Sprintf("%d", req.ID))
+		return result
+	}
+
+	result.Data = converter.WebhookInboxToResponse(inbox)
+	return result
+}
+
+// processEntry locks an inbox entry, hands its payload to CallbackPayment and
+// records the outcome. Holding the row lock while processing keeps the worker,
+// the receive path and a replay from applying the same entry twice.
+func (uc *WebhookInboxUseCase) processEntry(ctx context.Context, id uint64, replay bool) (*entity.WebhookInbox, error) {
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db connection: %v", err)
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	inbox, err := uc.WebhookInboxRepository.FindByIDForUpdate(ctx, tx, id)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to get inbox entry: %v", err)
+	}
+	if inbox == nil || inbox.Status == entity.WebhookInboxProcessed {
+		_ = tx.Rollback()
+		return inbox, nil
+	}
+	if !replay && inbox.Status != entity.WebhookInboxReceived && inbox.Status != entity.WebhookInboxRetry {
+		_ = tx.Rollback()
+		return inbox, nil
+	}
+
+	processed := uc.PaymentUseCase.CallbackPayment(ctx, []byte(inbox.Payload))
+
+	now := time.Now()
+	inbox.Attempts++
+	inbox.NextAttemptAt = now
+	if processed.Error == nil {
+		inbox.Status = entity.WebhookInboxProcessed
+		inbox.LastError = nil
+		inbox.ProcessedAt = &now
+	} else {
+		lastError := utils.ConvertString(processed.Error)
+		inbox.LastError = &lastError
+		inbox.Status = uc.failureStatus(processed.Error, inbox.Attempts)
+		if inbox.Status == entity.WebhookInboxRetry {
+			inbox.NextAttemptAt = now.Add(uc.retryDelay(inbox.Attempts))
+		}
+	}
+
+	if err := uc.WebhookInboxRepository.UpdateTx(ctx, tx, inbox); err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("failed to update inbox entry: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	if inbox.Status != entity.WebhookInboxProcessed {
+		uc.Log.Info("webhook-inbox-usecase",
+			fmt.Sprintf("Inbox entry %d for order %s is %s after attempt %d", inbox.ID, inbox.OrderID, inbox.Status, inbox.Attempts),
+			"processEntry", utils.ConvertString(inbox.LastError))
+	}
+	return inbox, nil
+}
+
+// failureStatus decides what a failed attempt leaves behind. Payloads that
+// fail verification or cannot be parsed will never succeed, so they are not
+// retried.
+func (uc *WebhookInboxUseCase) failureStatus(processErr interface{}, attempts int) string {
+	switch processErr.(type) {
+	case httpError.UnauthorizedData:
+		return entity.WebhookInboxRejected
+	case httpError.BadRequestData:
+		return entity.WebhookInboxFailed
+	}
+
+	maxAttempts := uc.Config.GetInt("webhook.inbox.max_attempts")
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	if attempts >= maxAttempts {
+		return entity.WebhookInboxFailed
+	}
+	return entity.WebhookInboxRetry
+}
+
+// retryDelay doubles from webhook.inbox.retry_base_seconds and is capped at an
+// hour.
+func (uc *WebhookInboxUseCase) retryDelay(attempts int) time.Duration {
+	base := time.Duration(uc.Config.GetInt("webhook.inbox.retry_base_seconds")) * time.Second
+	if base <= 0 {
+		base = 30 * time.Second
+	}
+	delay := base
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
@@ -0,0 +1,970 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payment-service/src/internal/entity"
+	"payment-service/src/internal/gateway/payout"
+	"payment-service/src/internal/model"
+	"payment-service/src/internal/model/converter"
+	"payment-service/src/internal/repository"
+	"payment-service/src/pkg/databases/mysql"
+	httpError "payment-service/src/pkg/http-error"
+	"payment-service/src/pkg/log"
+	"payment-service/src/pkg/money"
+	"payment-service/src/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+)
+
+type WithdrawalUseCase struct {
+	Log                  log.Log
+	Config               *viper.Viper
+	WalletRepository     *repository.WalletRepository
+	WithdrawalRepository *repository.WithdrawalRepository
+	DriverRepository     *repository.DriverRepository
+	PayoutAccounts       *repository.PayoutAccountRepository
+	DB                   mysql.DBInterface
+	Payouts              payout.PayoutProvider
+	Cipher               *utils.FieldCipher
+}
+
+func NewWithdrawalUseCase(
+	log log.Log,
+	config *viper.Viper,
+	walletRepo *repository.WalletRepository,
+	withdrawalRepo *repository.WithdrawalRepository,
+	driverRepo *repository.DriverRepository,
+	payoutAccountRepo *repository.PayoutAccountRepository,
+	db mysql.DBInterface,
+	payouts payout.PayoutProvider,
+	cipher *utils.FieldCipher,
+) *WithdrawalUseCase {
+	return &WithdrawalUseCase{
+		Log:                  log,
+		Config:               config,
+		WalletRepository:     walletRepo,
+		WithdrawalRepository: withdrawalRepo,
+		DriverRepository:     driverRepo,
+		PayoutAccounts:       payoutAccountRepo,
+		DB:                   db,
+		Payouts:              payouts,
+		Cipher:               cipher,
+	}
+}
+
+// RequestWithdrawal moves the requested amount of a driver's wallet into the
+// held balance and queues the withdrawal for approval. It is paid out to one
+// of the driver's verified payout accounts past its cooling-off period.
+// Amounts up to withdrawal.auto_approve_max are sent to the payout provider
+// straight away.
+func (uc *WithdrawalUseCase) RequestWithdrawal(ctx context.Context, req *model.WithdrawalRequest) utils.Result {
+	var result utils.Result
+
+	if req.PayoutAccountID == "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "payoutAccountId is required"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(req))
+		return result
+	}
+	minAmount := money.Amount(uc.Config.GetInt64("withdrawal.min_amount"))
+	fee := money.Amount(uc.Config.GetInt64("withdrawal.fee"))
+	if req.Amount < minAmount || req.Amount <= fee {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("minimum withdrawal is %d", max(minAmount, fee+1))
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(req))
+		return result
+	}
+	if _, err := uc.DriverRepository.GetDetailDriver(ctx, req.DriverID); err != nil {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "only drivers can withdraw"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	wallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, req.DriverID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get wallet"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	if wallet == nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewNotFound()
+		errObj.Message = "wallet not found"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", req.DriverID)
+		return result
+	}
+	// payouts go to local bank accounts only
+	if wallet.Currency != paymentCurrency(uc.Config) {
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "withdrawals are only available from " + paymentCurrency(uc.Config) + " wallets"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", wallet.ID)
+		return result
+	}
+
+	account, err := uc.PayoutAccounts.FindDriverAccountForUpdate(ctx, tx, req.DriverID, req.PayoutAccountID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payout account"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	if account == nil || account.Status == entity.PayoutAccountRemoved {
+		_ = tx.Rollback()
+		errObj := httpError.NewNotFound()
+		errObj.Message = "payout account not found"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", req.PayoutAccountID)
+		return result
+	}
+	if !account.Usable(time.Now()) {
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "payout account is " + strings.ToLower(account.Status)
+		if account.Status == entity.PayoutAccountVerified && account.AvailableAfter != nil {
+			errObj.Message = "payout account is in its cooling-off period until " + account.AvailableAfter.Format(time.RFC3339)
+		}
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", req.PayoutAccountID)
+		return result
+	}
+	accountName, err := uc.payoutAccountName(account)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to decrypt payout account"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+
+	count, total, err := uc.WithdrawalRepository.SumDriverWithdrawalsSinceTx(ctx, tx, req.DriverID, uc.startOfDay())
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get today's withdrawals"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	if limit := uc.Config.GetInt("withdrawal.daily_count"); limit > 0 && count >= limit {
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("at most %d withdrawals a day", limit)
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", req.DriverID)
+		return result
+	}
+	if limit := money.Amount(uc.Config.GetInt64("withdrawal.daily_limit")); limit > 0 && total+req.Amount > limit {
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = fmt.Sprintf("daily withdrawal limit is %d, %d left today", limit, max(limit-total, 0))
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", req.DriverID)
+		return result
+	}
+	if req.Amount > wallet.Balance {
+		_ = tx.Rollback()
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "insufficient balance"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", fmt.Sprintf("balance=%d need=%d", wallet.Balance, req.Amount))
+		return result
+	}
+
+	if err := uc.WalletRepository.UpdateWalletBalances(ctx, tx.Tx, wallet.ID, wallet.Balance-req.Amount, wallet.HeldBalance+req.Amount); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to reserve withdrawal amount"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+
+	withdrawal := &entity.DriverWithdrawal{
+		WithdrawalID:    utils.GenerateUniqueIDWithPrefix("withdrawal"),
+		DriverID:        req.DriverID,
+		WalletID:        wallet.ID,
+		PayoutAccountID: &account.ID,
+		Amount:          req.Amount,
+		Fee:             fee,
+		NetAmount:       req.Amount - fee,
+		Currency:        wallet.Currency,
+		BankCode:        account.BankCode,
+		AccountNumber:   converter.MaskAccountNumber(account.AccountNumberLast4),
+		AccountName:     accountName,
+		Status:          entity.WithdrawalRequested,
+		CreatedAt:       time.Now(),
+	}
+	id, err := uc.WithdrawalRepository.InsertWithdrawalTx(ctx, tx, withdrawal)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to create withdrawal"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	withdrawal.ID = id
+
+	var payoutReq *payout.PayoutRequest
+	if autoMax := money.Amount(uc.Config.GetInt64("withdrawal.auto_approve_max")); autoMax > 0 && req.Amount <= autoMax {
+		reviewer := "system"
+		now := time.Now()
+		withdrawal.ReviewedBy = &reviewer
+		withdrawal.ReviewedAt = &now
+		if payoutReq, err = uc.startPayout(ctx, tx, withdrawal); err != nil {
+			_ = tx.Rollback()
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to send withdrawal"
+			result.Error = errObj
+			uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+			return result
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "RequestWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	if payoutReq != nil {
+		// the withdrawal is recorded whatever the provider answers
+		if err := uc.sendPayout(ctx, withdrawal, payoutReq); err != nil {
+			uc.Log.Error("withdrawal-usecase", "failed to send withdrawal", "RequestWithdrawal", utils.ConvertString(err))
+		}
+	}
+
+	uc.Log.Info("withdrawal-usecase",
+		fmt.Sprintf("Withdrawal %s requested. driver=%s amount=%d fee=%d status=%s", withdrawal.WithdrawalID, req.DriverID, req.Amount, fee, withdrawal.Status),
+		"RequestWithdrawal", "")
+	result.Data = converter.WithdrawalToResponse(withdrawal)
+	return result
+}
+
+// ListWithdrawals lists a driver's own withdrawals, newest first, or for an
+// admin the withdrawals in a status, REQUESTED by default, oldest first.
+func (uc *WithdrawalUseCase) ListWithdrawals(ctx context.Context, req *model.ListWithdrawalRequest) utils.Result {
+	var result utils.Result
+
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	var withdrawals []entity.DriverWithdrawal
+	var err error
+	if req.DriverID != "" {
+		withdrawals, err = uc.WithdrawalRepository.FindByDriver(ctx, req.DriverID, limit, offset)
+	} else {
+		status := entity.WithdrawalRequested
+		if req.Status != "" {
+			status = strings.ToUpper(req.Status)
+		}
+		withdrawals, err = uc.WithdrawalRepository.FindByStatus(ctx, status, limit, offset)
+	}
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get withdrawals"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "ListWithdrawals", utils.ConvertString(err))
+		return result
+	}
+
+	response := make([]*model.WithdrawalResponse, 0, len(withdrawals))
+	for i := range withdrawals {
+		response = append(response, converter.WithdrawalToResponse(&withdrawals[i]))
+	}
+	result.Data = response
+	return result
+}
+
+// DecideWithdrawal approves a requested withdrawal and sends it to the payout
+// provider, or rejects it and gives the held amount back to the wallet. A
+// PROCESSING withdrawal whose payout call ended without a provider reference
+// can also be rejected, once the provider's dashboard shows no payout was
+// made for it.
+func (uc *WithdrawalUseCase) DecideWithdrawal(ctx context.Context, req *model.DecideWithdrawalRequest) utils.Result {
+	var result utils.Result
+
+	if req.ReviewedBy == "" {
+		errObj := httpError.NewBadRequest()
+		errObj.Message = "reviewedBy is required"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "DecideWithdrawal", utils.ConvertString(req))
+		return result
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "DecideWithdrawal", utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "DecideWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	withdrawal, err := uc.WithdrawalRepository.FindByIDForUpdate(ctx, tx, req.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get withdrawal"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "DecideWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	if withdrawal == nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewNotFound()
+		errObj.Message = "withdrawal not found"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "DecideWithdrawal", fmt.Sprintf("%d", req.ID))
+		return result
+	}
+	unsent := withdrawal.Status == entity.WithdrawalProcessing && withdrawal.ProviderReference == nil
+	if withdrawal.Status != entity.WithdrawalRequested && (req.Approve || !unsent) {
+		_ = tx.Rollback()
+		errObj := httpError.NewConflict()
+		errObj.Message = "withdrawal already " + strings.ToLower(withdrawal.Status)
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "DecideWithdrawal", fmt.Sprintf("%d", req.ID))
+		return result
+	}
+
+	now := time.Now()
+	withdrawal.ReviewedBy = &req.ReviewedBy
+	withdrawal.ReviewedAt = &now
+	if req.Note != "" {
+		withdrawal.ReviewNote = &req.Note
+	}
+	var payoutReq *payout.PayoutRequest
+	if req.Approve {
+		payoutReq, err = uc.startPayout(ctx, tx, withdrawal)
+	} else {
+		err = uc.releaseWithdrawal(ctx, tx, withdrawal, entity.WithdrawalRejected, "rejected by "+req.ReviewedBy)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to decide withdrawal"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "DecideWithdrawal", utils.ConvertString(err))
+		return result
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "DecideWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	if payoutReq != nil {
+		if err := uc.sendPayout(ctx, withdrawal, payoutReq); err != nil {
+			uc.Log.Error("withdrawal-usecase", "failed to send withdrawal", "DecideWithdrawal", utils.ConvertString(err))
+		}
+	}
+
+	uc.Log.Info("withdrawal-usecase",
+		fmt.Sprintf("Withdrawal %s decided by %s: %s", withdrawal.WithdrawalID, req.ReviewedBy, withdrawal.Status),
+		"DecideWithdrawal", "")
+	result.Data = converter.WithdrawalToResponse(withdrawal)
+	return result
+}
+
+// HandlePayoutNotification applies a payout provider's webhook: a completed
+// payout charges the held amount to the wallet, a failed one gives it back.
+// Notifications for a withdrawal that has already ended are acknowledged and
+// ignored.
+func (uc *WithdrawalUseCase) HandlePayoutNotification(ctx context.Context, payload []byte, signature string) utils.Result {
+	var result utils.Result
+
+	notif, err := uc.Payouts.ParseNotification(ctx, payload, signature)
+	if err != nil {
+		var sigErr *payout.SignatureError
+		if errors.As(err, &sigErr) {
+			errObj := httpError.NewUnauthorized()
+			errObj.Message = "invalid signature"
+			result.Error = errObj
+			uc.Log.Error("withdrawal-usecase", errObj.Message, "HandlePayoutNotification", string(payload))
+			return result
+		}
+		errObj := httpError.NewBadRequest()
+		errObj.Message = err.Error()
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "HandlePayoutNotification", string(payload))
+		return result
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "HandlePayoutNotification", utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "HandlePayoutNotification", utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	withdrawal, err := uc.WithdrawalRepository.FindByProviderReferenceForUpdate(ctx, tx, uc.Payouts.Name(), notif.ReferenceNo)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get withdrawal"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "HandlePayoutNotification", utils.ConvertString(err))
+		return result
+	}
+	if withdrawal == nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewNotFound()
+		errObj.Message = "withdrawal not found"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "HandlePayoutNotification", notif.ReferenceNo)
+		return result
+	}
+	if withdrawal.Status != entity.WithdrawalProcessing || notif.Status == payout.StatusProcessing {
+		_ = tx.Rollback()
+		result.Data = map[string]string{"message": "notification ignored", "status": withdrawal.Status}
+		return result
+	}
+
+	if err := uc.applyPayout(ctx, tx, withdrawal, notif.Status, notif.FailReason); err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to apply payout notification"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "HandlePayoutNotification", utils.ConvertString(err))
+		return result
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "HandlePayoutNotification", utils.ConvertString(err))
+		return result
+	}
+
+	uc.Log.Info("withdrawal-usecase",
+		fmt.Sprintf("Withdrawal %s payout %s: %s", withdrawal.WithdrawalID, notif.ReferenceNo, withdrawal.Status),
+		"HandlePayoutNotification", "")
+	result.Data = map[string]string{"message": "notification processed", "status": withdrawal.Status}
+	return result
+}
+
+// ReconcilePayouts settles one batch of withdrawals PROCESSING for longer
+// than withdrawal.reconcile_after_seconds, whose notification was lost or
+// whose payout call did not get a clear answer, with the provider's status of
+// the payout. A withdrawal without a provider reference may or may not have a
+// payout; its amount stays held and it is logged for an admin to reconcile
+// with the reference from the provider's dashboard, or to reject. A failure
+// on one withdrawal does not stop the rest of the batch.
+func (uc *WithdrawalUseCase) ReconcilePayouts(ctx context.Context) error {
+	batchSize := uc.Config.GetInt("scheduler.payout_reconcile.batch_size")
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	before := time.Now().Add(-time.Duration(uc.Config.GetInt("withdrawal.reconcile_after_seconds")) * time.Second)
+
+	withdrawals, err := uc.WithdrawalRepository.FindStaleProcessing(ctx, before, batchSize)
+	if err != nil {
+		uc.Log.Error("withdrawal-usecase", "failed to get processing withdrawals", "ReconcilePayouts", utils.ConvertString(err))
+		return fmt.Errorf("failed to get processing withdrawals: %v", err)
+	}
+
+	settled := 0
+	for i := range withdrawals {
+		withdrawal := &withdrawals[i]
+		if withdrawal.ProviderReference == nil {
+			uc.Log.Error("withdrawal-usecase",
+				fmt.Sprintf("withdrawal %s has no payout reference, reconcile it with the provider's dashboard", withdrawal.WithdrawalID),
+				"ReconcilePayouts", "")
+			continue
+		}
+		ok, err := uc.reconcile(ctx, withdrawal)
+		if err != nil {
+			uc.Log.Error("withdrawal-usecase", fmt.Sprintf("failed to reconcile withdrawal %s", withdrawal.WithdrawalID), "ReconcilePayouts", utils.ConvertString(err))
+			continue
+		}
+		if ok {
+			settled++
+		}
+	}
+
+	if len(withdrawals) > 0 {
+		uc.Log.Info("withdrawal-usecase",
+			fmt.Sprintf("Settled %d of %d processing withdrawals", settled, len(withdrawals)),
+			"ReconcilePayouts", "")
+	}
+	return nil
+}
+
+// ReconcileWithdrawal settles one PROCESSING withdrawal with the provider's
+// status of its payout, first attaching the payout reference an admin found
+// in the provider's dashboard when the withdrawal has none.
+func (uc *WithdrawalUseCase) ReconcileWithdrawal(ctx context.Context, req *model.ReconcileWithdrawalRequest) utils.Result {
+	var result utils.Result
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get db connection"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", utils.ConvertString(err))
+		return result
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to start transaction"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	withdrawal, err := uc.WithdrawalRepository.FindByIDForUpdate(ctx, tx, req.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get withdrawal"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", utils.ConvertString(err))
+		return result
+	}
+	if withdrawal == nil {
+		_ = tx.Rollback()
+		errObj := httpError.NewNotFound()
+		errObj.Message = "withdrawal not found"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", fmt.Sprintf("%d", req.ID))
+		return result
+	}
+	if withdrawal.Status != entity.WithdrawalProcessing {
+		_ = tx.Rollback()
+		errObj := httpError.NewConflict()
+		errObj.Message = "withdrawal already " + strings.ToLower(withdrawal.Status)
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", fmt.Sprintf("%d", req.ID))
+		return result
+	}
+	if withdrawal.ProviderReference != nil && req.ReferenceNo != "" && *withdrawal.ProviderReference != req.ReferenceNo {
+		_ = tx.Rollback()
+		errObj := httpError.NewConflict()
+		errObj.Message = "withdrawal already has payout " + *withdrawal.ProviderReference
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", fmt.Sprintf("%d", req.ID))
+		return result
+	}
+	if withdrawal.ProviderReference == nil {
+		if req.ReferenceNo == "" {
+			_ = tx.Rollback()
+			errObj := httpError.NewBadRequest()
+			errObj.Message = "referenceNo is required for a withdrawal without a payout reference"
+			result.Error = errObj
+			uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", fmt.Sprintf("%d", req.ID))
+			return result
+		}
+		withdrawal.ProviderReference = &req.ReferenceNo
+		if err := uc.WithdrawalRepository.UpdateWithdrawalTx(ctx, tx, withdrawal); err != nil {
+			_ = tx.Rollback()
+			errObj := httpError.NewInternalServerError()
+			errObj.Message = "failed to update withdrawal"
+			result.Error = errObj
+			uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", utils.ConvertString(err))
+			return result
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to commit transaction"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", utils.ConvertString(err))
+		return result
+	}
+
+	if _, err := uc.reconcile(ctx, withdrawal); err != nil {
+		errObj := httpError.NewInternalServerError()
+		errObj.Message = "failed to get payout status"
+		result.Error = errObj
+		uc.Log.Error("withdrawal-usecase", errObj.Message, "ReconcileWithdrawal", utils.ConvertString(err))
+		return result
+	}
+
+	uc.Log.Info("withdrawal-usecase",
+		fmt.Sprintf("Withdrawal %s payout %s reconciled: %s", withdrawal.WithdrawalID, *withdrawal.ProviderReference, withdrawal.Status),
+		"ReconcileWithdrawal", "")
+	result.Data = converter.WithdrawalToResponse(withdrawal)
+	return result
+}
+
+// reconcile asks the provider for the status of a PROCESSING withdrawal's
+// payout and applies it once the payout has ended. It reports whether the
+// withdrawal was settled.
+func (uc *WithdrawalUseCase) reconcile(ctx context.Context, withdrawal *entity.DriverWithdrawal) (bool, error) {
+	status, err := uc.Payouts.QueryStatus(ctx, *withdrawal.ProviderReference)
+	if err != nil {
+		return false, fmt.Errorf("failed to get payout status: %v", err)
+	}
+	if status.Status == payout.StatusProcessing {
+		return false, nil
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		return false, fmt.Errorf("failed to get db connection: %v", err)
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	current, err := uc.WithdrawalRepository.FindByIDForUpdate(ctx, tx, withdrawal.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, fmt.Errorf("failed to get withdrawal: %v", err)
+	}
+	if current == nil || current.Status != entity.WithdrawalProcessing ||
+		current.ProviderReference == nil || *current.ProviderReference != *withdrawal.ProviderReference {
+		// a notification got there first
+		_ = tx.Rollback()
+		return false, nil
+	}
+	if err := uc.applyPayout(ctx, tx, current, status.Status, status.FailReason); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	*withdrawal = *current
+	return true, nil
+}
+
+// startPayout moves an approved withdrawal to PROCESSING and builds its
+// payout, which is sent with sendPayout once the transaction is committed, so
+// every payout the provider makes has a withdrawal to land on. A withdrawal
+// whose payout account was removed since it was requested fails instead and
+// gives the held amount back; no payout is returned then.
+func (uc *WithdrawalUseCase) startPayout(ctx context.Context, tx *sqlx.Tx, withdrawal *entity.DriverWithdrawal) (*payout.PayoutRequest, error) {
+	req := &payout.PayoutRequest{
+		WithdrawalID:       withdrawal.WithdrawalID,
+		Amount:             withdrawal.NetAmount,
+		Currency:           withdrawal.Currency,
+		BeneficiaryName:    withdrawal.AccountName,
+		BeneficiaryAccount: withdrawal.AccountNumber,
+		BeneficiaryBank:    withdrawal.BankCode,
+		Notes:              "Withdrawal " + withdrawal.WithdrawalID,
+	}
+	// withdrawals requested before the account registry carry the number
+	if withdrawal.PayoutAccountID != nil {
+		account, err := uc.PayoutAccounts.FindByIDTx(ctx, tx, *withdrawal.PayoutAccountID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get payout account: %v", err)
+		}
+		if account == nil || account.Status != entity.PayoutAccountVerified {
+			return nil, uc.releaseWithdrawal(ctx, tx, withdrawal, entity.WithdrawalFailed, "payout account no longer available")
+		}
+		req.BeneficiaryAccount, err = uc.Cipher.Decrypt(account.AccountNumberEncrypted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt payout account: %v", err)
+		}
+		req.BeneficiaryBank = account.BankCode
+	}
+
+	provider := uc.Payouts.Name()
+	withdrawal.Provider = &provider
+	withdrawal.Status = entity.WithdrawalProcessing
+	if err := uc.WithdrawalRepository.UpdateWithdrawalTx(ctx, tx, withdrawal); err != nil {
+		return nil, fmt.Errorf("failed to update withdrawal: %v", err)
+	}
+	return req, nil
+}
+
+// sendPayout sends a committed PROCESSING withdrawal to the payout provider
+// and records the answer. Only a payout the provider refused outright fails
+// the withdrawal and gives the held amount back. After any other error the
+// payout may exist at the provider, so the amount stays held until
+// ReconcilePayouts or a notification settles it.
+func (uc *WithdrawalUseCase) sendPayout(ctx context.Context, withdrawal *entity.DriverWithdrawal, req *payout.PayoutRequest) error {
+	resp, payoutErr := uc.Payouts.CreatePayout(ctx, req)
+	var rejected *payout.RejectedError
+	if payoutErr != nil && !errors.As(payoutErr, &rejected) && (resp == nil || resp.ReferenceNo == "") {
+		return fmt.Errorf("payout of withdrawal %s left unconfirmed: %v", withdrawal.WithdrawalID, payoutErr)
+	}
+
+	db, err := uc.DB.GetDB()
+	if err != nil {
+		return fmt.Errorf("failed to get db connection: %v", err)
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	current, err := uc.WithdrawalRepository.FindByIDForUpdate(ctx, tx, withdrawal.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to get withdrawal: %v", err)
+	}
+	if current == nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("withdrawal %s not found", withdrawal.WithdrawalID)
+	}
+	if current.Status != entity.WithdrawalProcessing || current.ProviderReference != nil {
+		// settled by an admin in the meantime; nothing to do unless the
+		// provider has taken a payout for it all the same
+		if rejected != nil || (current.ProviderReference != nil && *current.ProviderReference == resp.ReferenceNo) {
+			_ = tx.Rollback()
+			return nil
+		}
+		return uc.recordStrayPayout(ctx, tx, current, resp.ReferenceNo)
+	}
+
+	if rejected != nil {
+		uc.Log.Error("withdrawal-usecase", "payout provider refused withdrawal", "SendPayout", rejected.Reason)
+		err = uc.releaseWithdrawal(ctx, tx, current, entity.WithdrawalFailed, rejected.Reason)
+	} else {
+		current.ProviderReference = &resp.ReferenceNo
+		if resp.Status != payout.StatusProcessing {
+			err = uc.applyPayout(ctx, tx, current, resp.Status, resp.FailReason)
+		} else {
+			err = uc.WithdrawalRepository.UpdateWithdrawalTx(ctx, tx, current)
+		}
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	*withdrawal = *current
+
+	if payoutErr != nil {
+		return fmt.Errorf("payout %s of withdrawal %s created: %v", resp.ReferenceNo, withdrawal.WithdrawalID, payoutErr)
+	}
+	return nil
+}
+
+// recordStrayPayout keeps the reference of a payout the provider created for
+// a withdrawal that had already ended, e.g. rejected by an admin while the
+// payout call was in flight. The wallet is left alone, since its amount was
+// given back when the withdrawal ended; the payout has to be recalled or
+// charged by an admin, so it is logged and returned as an error.
+func (uc *WithdrawalUseCase) recordStrayPayout(ctx context.Context, tx *sqlx.Tx, withdrawal *entity.DriverWithdrawal, reference string) error {
+	strayErr := fmt.Errorf("payout %s created for withdrawal %s after it ended as %s", reference, withdrawal.WithdrawalID, withdrawal.Status)
+	if withdrawal.ProviderReference != nil {
+		// a second payout; the first reference stays on the withdrawal
+		_ = tx.Rollback()
+		strayErr = fmt.Errorf("payout %s created for withdrawal %s, which already has payout %s", reference, withdrawal.WithdrawalID, *withdrawal.ProviderReference)
+		uc.Log.Error("withdrawal-usecase", "stray payout for withdrawal", "SendPayout", strayErr.Error())
+		return strayErr
+	}
+
+	withdrawal.ProviderReference = &reference
+	if err := uc.WithdrawalRepository.UpdateWithdrawalTx(ctx, tx, withdrawal); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("%v; failed to record it: %v", strayErr, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%v; failed to record it: %v", strayErr, err)
+	}
+	uc.Log.Error("withdrawal-usecase", "stray payout for withdrawal", "SendPayout", strayErr.Error())
+	return strayErr
+}
+
+// applyPayout ends a processing withdrawal with the payout's outcome.
+func (uc *WithdrawalUseCase) applyPayout(ctx context.Context, tx *sqlx.Tx, withdrawal *entity.DriverWithdrawal, status, failReason string) error {
+	if status == payout.StatusFailed {
+		if failReason == "" {
+			failReason = "payout failed"
+		}
+		return uc.releaseWithdrawal(ctx, tx, withdrawal, entity.WithdrawalFailed, failReason)
+	}
+	return uc.completeWithdrawal(ctx, tx, withdrawal)
+}
+
+// completeWithdrawal charges the held amount to the wallet; the debit is the
+// one entry the wallet history shows for the withdrawal.
+func (uc *WithdrawalUseCase) completeWithdrawal(ctx context.Context, tx *sqlx.Tx, withdrawal *entity.DriverWithdrawal) error {
+	wallet, err := uc.lockWallet(ctx, tx, withdrawal)
+	if err != nil {
+		return err
+	}
+	if err := uc.WalletRepository.UpdateWalletBalances(ctx, tx.Tx, wallet.ID, wallet.Balance, wallet.HeldBalance-withdrawal.Amount); err != nil {
+		return fmt.Errorf("failed to update wallet balance: %v", err)
+	}
+	now := time.Now()
+	trx := &entity.WalletTransaction{
+		WalletID:      wallet.ID,
+		TransactionID: utils.GenerateUniqueIDWithPrefix("wtrx"),
+		Amount:        withdrawal.Amount,
+		Type:          "debit",
+		Description:   fmt.Sprintf("Withdrawal %s to %s %s", withdrawal.WithdrawalID, strings.ToUpper(withdrawal.BankCode), converter.MaskWithdrawalAccount(withdrawal.AccountNumber)),
+		Timestamp:     now,
+	}
+	if err := uc.WalletRepository.InsertWalletTransaction(ctx, tx.Tx, trx); err != nil {
+		return fmt.Errorf("failed to insert withdrawal transaction: %v", err)
+	}
+
+	withdrawal.Status = entity.WithdrawalCompleted
+	withdrawal.CompletedAt = &now
+	if err := uc.WithdrawalRepository.UpdateWithdrawalTx(ctx, tx, withdrawal); err != nil {
+		return fmt.Errorf("failed to update withdrawal: %v", err)
+	}
+	return nil
+}
+
+// releaseWithdrawal ends a withdrawal that will not be paid out, REJECTED or
+// FAILED, and gives its held amount back to the available balance.
+func (uc *WithdrawalUseCase) releaseWithdrawal(ctx context.Context, tx *sqlx.Tx, withdrawal *entity.DriverWithdrawal, status, reason string) error {
+	wallet, err := uc.lockWallet(ctx, tx, withdrawal)
+	if err != nil {
+		return err
+	}
+	if err := uc.WalletRepository.UpdateWalletBalances(ctx, tx.Tx, wallet.ID, wallet.Balance+withdrawal.Amount, wallet.HeldBalance-withdrawal.Amount); err != nil {
+		return fmt.Errorf("failed to update wallet balance: %v", err)
+	}
+
+	now := time.Now()
+	withdrawal.Status = status
+	withdrawal.FailureReason = &reason
+	if status == entity.WithdrawalFailed {
+		withdrawal.FailedAt = &now
+	}
+	if err := uc.WithdrawalRepository.UpdateWithdrawalTx(ctx, tx, withdrawal); err != nil {
+		return fmt.Errorf("failed to update withdrawal: %v", err)
+	}
+	return nil
+}
+
+func (uc *WithdrawalUseCase) lockWallet(ctx context.Context, tx *sqlx.Tx, withdrawal *entity.DriverWithdrawal) (*entity.Wallet, error) {
+	wallet, err := uc.WalletRepository.GetWalletForUpdate(ctx, tx.Tx, withdrawal.DriverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet: %v", err)
+	}
+	if wallet == nil || wallet.ID != withdrawal.WalletID {
+		return nil, fmt.Errorf("wallet %s of withdrawal %s not found", withdrawal.WalletID, withdrawal.WithdrawalID)
+	}
+	if wallet.HeldBalance < withdrawal.Amount {
+		return nil, fmt.Errorf("wallet %s holds %d, less than withdrawal %s of %d", wallet.ID, wallet.HeldBalance, withdrawal.WithdrawalID, withdrawal.Amount)
+	}
+	return wallet, nil
+}
+
+// payoutAccountName is the holder name the bank returned for the account,
+// which is what a payout must be addressed to.
+func (uc *WithdrawalUseCase) payoutAccountName(account *entity.PayoutAccount) (string, error) {
+	encrypted := account.HolderNameEncrypted
+	if account.InquiryNameEncrypted != nil {
+		encrypted = *account.InquiryNameEncrypted
+	}
+	return uc.Cipher.Decrypt(encrypted)
+}
+
+// startOfDay is midnight today in withdrawal.timezone, where the daily
+// limits reset.
+func (uc *WithdrawalUseCase) startOfDay() time.Time {
+	loc, err := time.LoadLocation(uc.Config.GetString("withdrawal.timezone"))
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,306 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is assumed wherever a stored amount has no currency.
+const DefaultCurrency = "IDR"
+
+// minorUnits is the per-currency table of minor-unit digits. Rupiah is
+// settled in whole rupiah (Midtrans rejects sen), so IDR has none, as do the
+// other currencies whose smallest coin is the major unit in practice.
+var minorUnits = map[string]int{
+	"IDR": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"MYR": 2,
+	"PHP": 2,
+	"SGD": 2,
+	"THB": 2,
+	"USD": 2,
+	"AUD": 2,
+	"EUR": 2,
+}
+
+// Known reports whether currency is in the minor-unit table.
+func Known(currency string) bool {
+	_, ok := minorUnits[strings.ToUpper(currency)]
+	return ok
+}
+
+// Exponent returns the number of minor-unit digits of currency, 2 unless
+// listed otherwise.
+func Exponent(currency string) int {
+	if exp, ok := minorUnits[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+	return 2
+}
+
+// Amount is a count of minor units. It is what is stored in BIGINT amount
+// columns and sent on the wire; the currency always travels next to it.
+type Amount int64
+
+// Int64 returns a as a plain integer, e.g. for provider SDKs.
+func (a Amount) Int64() int64 {
+	return int64(a)
+}
+
+// Abs returns the absolute value of a.
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// MulRate multiplies a by rate and rounds the product back to whole minor
+// units with mode. The rate is taken at its shortest decimal form, so 0.1 is
+// exactly one tenth.
+func (a Amount) MulRate(rate float64, mode RoundingMode) Amount {
+	r := ratFromFloat(rate)
+	r.Mul(r, new(big.Rat).SetInt64(int64(a)))
+	return Amount(roundRat(r, mode))
+}
+
+// Prorate returns the share of a that part is of whole, a*part/whole, rounded
+// with mode. It is what a partial refund takes back from each settlement leg.
+func (a Amount) Prorate(part, whole Amount, mode RoundingMode) Amount {
+	if whole == 0 {
+		return 0
+	}
+	r := new(big.Rat).SetFrac(big.NewInt(int64(a)), big.NewInt(1))
+	r.Mul(r, new(big.Rat).SetFrac(big.NewInt(int64(part)), big.NewInt(int64(whole))))
+	return Amount(roundRat(r, mode))
+}
+
+// Scan reads an amount column. Integers are taken as is. DECIMAL and DOUBLE
+// values, which legacy columns hold until they are migrated to BIGINT, are
+// rounded half up to whole minor units; every such row is in rupiah, where
+// major and minor units coincide.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+	case int64:
+		*a = Amount(v)
+	case float64:
+		*a = Amount(math.Round(v))
+	case []byte:
+		return a.scanDecimal(string(v))
+	case string:
+		return a.scanDecimal(v)
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+	return nil
+}
+
+func (a *Amount) scanDecimal(s string) error {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return fmt.Errorf("money: invalid amount %q", s)
+	}
+	*a = Amount(roundRat(r, RoundHalfUp))
+	return nil
+}
+
+// Value writes an amount column as an integer.
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// UnmarshalJSON accepts a JSON number holding a whole number of minor units.
+// Fractions are rejected rather than rounded away.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("money: invalid amount %s", string(data))
+	}
+	if !r.IsInt() {
+		return fmt.Errorf("money: amount %s is not a whole number of minor units", string(data))
+	}
+	if !r.Num().IsInt64() {
+		return fmt.Errorf("money: amount %s out of range", string(data))
+	}
+	*a = Amount(r.Num().Int64())
+	return nil
+}
+
+// Money is an amount together with its currency.
+type Money struct {
+	Amount   Amount `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New returns amount minor units of currency. An empty currency means
+// DefaultCurrency.
+func New(amount Amount, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// FromMajor converts a major-unit value such as a fare from the order service
+// (12500.5 rupiah) to Money, rounding half up to whole minor units.
+func FromMajor(value float64, currency string) Money {
+	m := New(0, currency)
+	r := ratFromFloat(value)
+	r.Mul(r, new(big.Rat).SetInt(pow10(Exponent(m.Currency))))
+	m.Amount = Amount(roundRat(r, RoundHalfUp))
+	return m
+}
+
+// ParseMajor reads a major-unit decimal string such as a provider's
+// gross_amount ("12500.00") exactly, rounding half up to whole minor units.
+func ParseMajor(value string, currency string) (Money, error) {
+	m := New(0, currency)
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return m, fmt.Errorf("money: invalid amount %q", value)
+	}
+	r.Mul(r, new(big.Rat).SetInt(pow10(Exponent(m.Currency))))
+	m.Amount = Amount(roundRat(r, RoundHalfUp))
+	return m, nil
+}
+
+// Major returns m in major units. It is meant for display and logging, not
+// for further arithmetic.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / math.Pow10(Exponent(m.Currency))
+}
+
+// Add returns m+o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m-o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}, nil
+}
+
+// MulRate multiplies m by rate, see Amount.MulRate.
+func (m Money) MulRate(rate float64, mode RoundingMode) Money {
+	return Money{Amount: m.Amount.MulRate(rate, mode), Currency: m.Currency}
+}
+
+// Decimal formats m in major units with the currency's minor-unit digits,
+// e.g. "12500" for IDR or "12.50" for USD.
+func (m Money) Decimal() string {
+	exp := Exponent(m.Currency)
+	if exp == 0 {
+		return strconv.FormatInt(int64(m.Amount), 10)
+	}
+	r := new(big.Rat).SetFrac(big.NewInt(int64(m.Amount)), pow10(exp))
+	return r.FloatString(exp)
+}
+
+func (m Money) String() string {
+	return m.Currency + " " + m.Decimal()
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if !strings.EqualFold(m.Currency, o.Currency) {
+		return fmt.Errorf("money: currency mismatch %s and %s", m.Currency, o.Currency)
+	}
+	return nil
+}
+
+// RoundingMode says how a fractional number of minor units is settled.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds to the nearest unit, halves away from zero.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds to the nearest unit, halves to the even one.
+	RoundHalfEven
+	// RoundDown truncates toward zero.
+	RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp
+)
+
+// ParseRoundingMode reads a mode from config: HALF_UP, HALF_EVEN, DOWN or UP.
+// An empty string is HALF_UP.
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", "HALF_UP":
+		return RoundHalfUp, nil
+	case "HALF_EVEN":
+		return RoundHalfEven, nil
+	case "DOWN":
+		return RoundDown, nil
+	case "UP":
+		return RoundUp, nil
+	}
+	return RoundHalfUp, fmt.Errorf("money: unknown rounding mode %q", s)
+}
+
+func (m RoundingMode) String() string {
+	switch m {
+	case RoundHalfEven:
+		return "HALF_EVEN"
+	case RoundDown:
+		return "DOWN"
+	case RoundUp:
+		return "UP"
+	}
+	return "HALF_UP"
+}
+
+func ratFromFloat(v float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(v, 'f', -1, 64))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func roundRat(r *big.Rat, mode RoundingMode) int64 {
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		half := new(big.Int).Lsh(rem, 1).Cmp(den)
+		switch mode {
+		case RoundUp:
+			q.Add(q, big.NewInt(1))
+		case RoundHalfEven:
+			if half > 0 || (half == 0 && q.Bit(0) == 1) {
+				q.Add(q, big.NewInt(1))
+			}
+		case RoundHalfUp:
+			if half >= 0 {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
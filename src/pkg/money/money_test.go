@@ -0,0 +1,261 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponent(t *testing.T) {
+	tests := []struct {
+		currency string
+		exponent int
+		known    bool
+	}{
+		{"IDR", 0, true},
+		{"idr", 0, true},
+		{"JPY", 0, true},
+		{"KRW", 0, true},
+		{"VND", 0, true},
+		{"USD", 2, true},
+		{"sgd", 2, true},
+		{"EUR", 2, true},
+		{"XYZ", 2, false},
+		{"", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			assert.Equal(t, tt.exponent, Exponent(tt.currency))
+			assert.Equal(t, tt.known, Known(tt.currency))
+		})
+	}
+}
+
+func TestRoundingModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Amount
+		rate   float64
+		want   map[RoundingMode]Amount
+	}{
+		{"exact", 100, 0.1, map[RoundingMode]Amount{RoundHalfUp: 10, RoundHalfEven: 10, RoundDown: 10, RoundUp: 10}},
+		{"below half", 10, 0.33, map[RoundingMode]Amount{RoundHalfUp: 3, RoundHalfEven: 3, RoundDown: 3, RoundUp: 4}},
+		{"above half", 10, 0.37, map[RoundingMode]Amount{RoundHalfUp: 4, RoundHalfEven: 4, RoundDown: 3, RoundUp: 4}},
+		{"half to even below", 5, 0.5, map[RoundingMode]Amount{RoundHalfUp: 3, RoundHalfEven: 2, RoundDown: 2, RoundUp: 3}},
+		{"half to even above", 7, 0.5, map[RoundingMode]Amount{RoundHalfUp: 4, RoundHalfEven: 4, RoundDown: 3, RoundUp: 4}},
+		{"negative half", -5, 0.5, map[RoundingMode]Amount{RoundHalfUp: -3, RoundHalfEven: -2, RoundDown: -2, RoundUp: -3}},
+		{"negative odd half", -7, 0.5, map[RoundingMode]Amount{RoundHalfUp: -4, RoundHalfEven: -4, RoundDown: -3, RoundUp: -4}},
+		{"negative fraction", -10, 0.33, map[RoundingMode]Amount{RoundHalfUp: -3, RoundHalfEven: -3, RoundDown: -3, RoundUp: -4}},
+		// 30 * 0.1 is 3.0000000000000004 in float64; the rate is taken as
+		// the decimal 0.1, so rounding up does not add a unit
+		{"float artifact", 30, 0.1, map[RoundingMode]Amount{RoundHalfUp: 3, RoundHalfEven: 3, RoundDown: 3, RoundUp: 3}},
+		{"zero", 0, 0.25, map[RoundingMode]Amount{RoundHalfUp: 0, RoundHalfEven: 0, RoundDown: 0, RoundUp: 0}},
+	}
+	for _, tt := range tests {
+		for mode, want := range tt.want {
+			t.Run(tt.name+"/"+mode.String(), func(t *testing.T) {
+				assert.Equal(t, want, tt.amount.MulRate(tt.rate, mode))
+			})
+		}
+	}
+}
+
+func TestProrate(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      Amount
+		part, whole Amount
+		mode        RoundingMode
+		want        Amount
+	}{
+		{"full", 1000, 5000, 5000, RoundHalfUp, 1000},
+		{"third half up", 1000, 1, 3, RoundHalfUp, 333},
+		{"third up", 1000, 1, 3, RoundUp, 334},
+		{"two thirds down", 1000, 2, 3, RoundDown, 666},
+		{"two thirds half up", 1000, 2, 3, RoundHalfUp, 667},
+		{"half half up", 1001, 1, 2, RoundHalfUp, 501},
+		{"half half even", 1001, 1, 2, RoundHalfEven, 500},
+		{"negative half up", -1001, 1, 2, RoundHalfUp, -501},
+		{"negative down", -1000, 1, 3, RoundDown, -333},
+		{"zero whole", 1000, 1, 0, RoundHalfUp, 0},
+		{"zero part", 1000, 0, 3, RoundUp, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.amount.Prorate(tt.part, tt.whole, tt.mode))
+		})
+	}
+}
+
+func TestParseRoundingMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    RoundingMode
+		wantErr bool
+	}{
+		{"", RoundHalfUp, false},
+		{"HALF_UP", RoundHalfUp, false},
+		{"half_even", RoundHalfEven, false},
+		{" DOWN ", RoundDown, false},
+		{"up", RoundUp, false},
+		{"CEILING", RoundHalfUp, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRoundingMode(tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if err == nil {
+				roundTrip, _ := ParseRoundingMode(got.String())
+				assert.Equal(t, got, roundTrip)
+			}
+		})
+	}
+}
+
+func TestFromMajor(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		currency string
+		want     Money
+	}{
+		{"whole rupiah", 12500, "IDR", Money{12500, "IDR"}},
+		{"rupiah half up", 12500.5, "IDR", Money{12501, "IDR"}},
+		{"rupiah below half", 12500.49, "IDR", Money{12500, "IDR"}},
+		{"default currency", 19.99, "", Money{20, "IDR"}},
+		{"dollars", 12.34, "usd", Money{1234, "USD"}},
+		{"dollars half up", 12.345, "USD", Money{1235, "USD"}},
+		{"negative dollars", -12.345, "USD", Money{-1235, "USD"}},
+		{"float sum", 0.1 + 0.2, "USD", Money{30, "USD"}},
+		{"yen", 1500.5, "JPY", Money{1501, "JPY"}},
+		{"unknown currency", 1.5, "XYZ", Money{150, "XYZ"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FromMajor(tt.value, tt.currency))
+		})
+	}
+}
+
+func TestParseMajor(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		currency string
+		want     Money
+		wantErr  bool
+	}{
+		{"provider gross amount", "12500.00", "IDR", Money{12500, "IDR"}, false},
+		{"rupiah half up", "12500.50", "IDR", Money{12501, "IDR"}, false},
+		{"dollars", "12.345", "USD", Money{1235, "USD"}, false},
+		{"negative", "-0.005", "USD", Money{-1, "USD"}, false},
+		{"padded", " 7.5 ", "JPY", Money{8, "JPY"}, false},
+		{"invalid", "12,500", "IDR", Money{0, "IDR"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMajor(tt.value, tt.currency)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		money   Money
+		decimal string
+		major   float64
+	}{
+		{New(12500, "IDR"), "12500", 12500},
+		{New(-12500, "idr"), "-12500", -12500},
+		{New(1250, "USD"), "12.50", 12.5},
+		{New(5, "USD"), "0.05", 0.05},
+		{New(-5, "USD"), "-0.05", -0.05},
+		{New(0, "EUR"), "0.00", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.money.String(), func(t *testing.T) {
+			assert.Equal(t, tt.decimal, tt.money.Decimal())
+			assert.Equal(t, tt.money.Currency+" "+tt.decimal, tt.money.String())
+			assert.InDelta(t, tt.major, tt.money.Major(), 1e-9)
+		})
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		sum     Money
+		diff    Money
+		wantErr bool
+	}{
+		{"same currency", New(1500, "IDR"), New(500, "IDR"), Money{2000, "IDR"}, Money{1000, "IDR"}, false},
+		{"case insensitive", New(150, "usd"), New(250, "USD"), Money{400, "USD"}, Money{-100, "USD"}, false},
+		{"negative", New(-150, "USD"), New(-50, "USD"), Money{-200, "USD"}, Money{-100, "USD"}, false},
+		{"mismatch", New(1500, "IDR"), New(100, "USD"), Money{}, Money{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum, err := tt.a.Add(tt.b)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.sum, sum)
+
+			diff, err := tt.a.Sub(tt.b)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.diff, diff)
+		})
+	}
+}
+
+func TestAmountScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    Amount
+		wantErr bool
+	}{
+		{"null", nil, 0, false},
+		{"bigint", int64(12500), 12500, false},
+		{"double", 12500.5, 12501, false},
+		{"negative double", -12500.5, -12501, false},
+		{"decimal bytes", []byte("12500.50"), 12501, false},
+		{"decimal string", "-12500.49", -12500, false},
+		{"invalid decimal", "abc", 0, true},
+		{"unsupported type", true, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Amount
+			err := got.Scan(tt.src)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAmountUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{`12500`, 12500, false},
+		{`-250`, -250, false},
+		{`"300"`, 300, false},
+		{`null`, 0, false},
+		{`12.5`, 0, true},
+		{`"abc"`, 0, true},
+		{`99999999999999999999`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var got Amount
+			err := got.UnmarshalJSON([]byte(tt.in))
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Rate is an exchange rate: one unit of From buys Value units of To. Values
+// are kept as exact decimals so a conversion can be repeated to the unit.
+type Rate struct {
+	From  string
+	To    string
+	Value *big.Rat
+}
+
+// NewRate parses value, a positive decimal such as "11650.25".
+func NewRate(from, to, value string) (Rate, error) {
+	v, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || v.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("money: invalid rate %q for %s/%s", value, from, to)
+	}
+	return Rate{From: strings.ToUpper(from), To: strings.ToUpper(to), Value: v}, nil
+}
+
+// Pair names the rate as FROM/TO.
+func (r Rate) Pair() string {
+	return r.From + "/" + r.To
+}
+
+// String returns the rate value as a decimal.
+func (r Rate) String() string {
+	if r.Value == nil {
+		return "0"
+	}
+	s := r.Value.FloatString(12)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Convert turns m, in From, into To.
+func (r Rate) Convert(m Money, mode RoundingMode) (Money, error) {
+	if !strings.EqualFold(m.Currency, r.From) {
+		return Money{}, fmt.Errorf("money: cannot convert %s with a %s rate", m.Currency, r.Pair())
+	}
+	v := majorRat(m)
+	v.Mul(v, r.Value)
+	return fromMajorRat(v, r.To, mode), nil
+}
+
+// Reverse returns how much of From is worth m, which is in To.
+func (r Rate) Reverse(m Money, mode RoundingMode) (Money, error) {
+	if !strings.EqualFold(m.Currency, r.To) {
+		return Money{}, fmt.Errorf("money: cannot reverse %s with a %s rate", m.Currency, r.Pair())
+	}
+	v := majorRat(m)
+	v.Quo(v, r.Value)
+	return fromMajorRat(v, r.From, mode), nil
+}
+
+func majorRat(m Money) *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(int64(m.Amount)), pow10(Exponent(m.Currency)))
+}
+
+func fromMajorRat(v *big.Rat, currency string, mode RoundingMode) Money {
+	out := New(0, currency)
+	v = new(big.Rat).Mul(v, new(big.Rat).SetInt(pow10(Exponent(out.Currency))))
+	out.Amount = Amount(roundRat(v, mode))
+	return out
+}
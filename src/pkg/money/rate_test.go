@@ -0,0 +1,65 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateConversion(t *testing.T) {
+	usdIdr, err := NewRate("usd", "idr", "16000")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		reverse bool
+		in      Money
+		mode    RoundingMode
+		want    Money
+		wantErr bool
+	}{
+		{"convert", false, New(150, "USD"), RoundHalfUp, Money{24000, "IDR"}, false},
+		{"convert cents", false, New(1, "USD"), RoundHalfUp, Money{160, "IDR"}, false},
+		{"convert negative", false, New(-150, "USD"), RoundHalfUp, Money{-24000, "IDR"}, false},
+		{"reverse half up", true, New(24001, "IDR"), RoundHalfUp, Money{150, "USD"}, false},
+		{"reverse up", true, New(24001, "IDR"), RoundUp, Money{151, "USD"}, false},
+		{"convert wrong currency", false, New(150, "IDR"), RoundHalfUp, Money{}, true},
+		{"reverse wrong currency", true, New(150, "USD"), RoundHalfUp, Money{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			convert := usdIdr.Convert
+			if tt.reverse {
+				convert = usdIdr.Reverse
+			}
+			got, err := convert(tt.in, tt.mode)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewRate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"16000", "16000", false},
+		{" 11650.25 ", "11650.25", false},
+		{"0.000062", "0.000062", false},
+		{"0", "", true},
+		{"-1", "", true},
+		{"abc", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			rate, err := NewRate("USD", "IDR", tt.value)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if err == nil {
+				assert.Equal(t, tt.want, rate.String())
+				assert.Equal(t, "USD/IDR", rate.Pair())
+			}
+		})
+	}
+}
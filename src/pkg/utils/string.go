@@ -3,6 +3,7 @@ package utils
 import (
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
@@ -17,12 +18,16 @@ import (
 )
 
 var entityPrefixes = map[string]string{
-	"user":    "USR",
-	"driver":  "DRV",
-	"trip":    "TRP",
-	"order":   "ORD",
-	"wallet":  "WLT",
-	"payment": "PAY",
+	"user":           "USR",
+	"driver":         "DRV",
+	"trip":           "TRP",
+	"order":          "ORD",
+	"wallet":         "WLT",
+	"payment":        "PAY",
+	"tip":            "TIP",
+	"hold":           "HLD",
+	"withdrawal":     "WDR",
+	"payout_account": "PAC",
 }
 
 // ConvertString to convert any data type to String
@@ -202,6 +207,12 @@ func GenerateUniqueIDWithPrefix(entityType string) string {
 	return fmt.Sprintf("NBJ_%s_%s_%s", prefix, timestamp, randomHex)
 }
 
+// HashRequest returns a stable sha256 hex digest of the JSON form of v.
+func HashRequest(v interface{}) string {
+	hash := sha256.Sum256([]byte(ConvertString(v)))
+	return hex.EncodeToString(hash[:])
+}
+
 func GenerateMidtransSignature(orderID, statusCode, grossAmount, serverKey string) string {
 	raw := orderID + statusCode + grossAmount + serverKey
 	hash := sha512.Sum512([]byte(raw))